@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// The maximum time to resolve the hostname of a target
+const resolveTimeout = 5 * time.Second
+
+// NormalizeIPAddress parses the target address of a request and returns it in canonical form.
+// Brackets are removed, IPv4-mapped IPv6 addresses become IPv4, and the zone ID of an IPv6 address is kept only when it is link-local, as it is meaningless otherwise.
+// When the address is a hostname, it is resolved to its first IP address if resolve is true; otherwise, an error is returned.
+func NormalizeIPAddress(address string, resolve bool) (string, error) {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(address), "["), "]")
+	if host == "" {
+		return "", fmt.Errorf("target address required")
+	}
+	zone := ""
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host, zone = host[:i], host[i+1:]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		if zone != "" || strings.Contains(host, ":") {
+			return "", fmt.Errorf("invalid IP address %s", address)
+		}
+		if !resolve {
+			return "", fmt.Errorf("invalid IP address %s, hostnames are not allowed", address)
+		}
+		return resolveHostname(host)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String(), nil
+	}
+	if zone != "" && zone != "0" && ip.IsLinkLocalUnicast() {
+		return ip.String() + "%" + zone, nil
+	}
+	return ip.String(), nil
+}
+
+// Resolves a hostname to its first IP address, preferring IPv4
+func resolveHostname(hostname string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, hostname)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve hostname %s: %v", hostname, err)
+	}
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("cannot resolve hostname %s: no addresses found", hostname)
+	}
+	for _, addr := range addresses {
+		if ip4 := addr.IP.To4(); ip4 != nil {
+			return ip4.String(), nil
+		}
+	}
+	return addresses[0].IP.String(), nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNormalizeIPAddress(t *testing.T) {
+	cases := []struct {
+		address  string
+		expected string
+	}{
+		{"192.168.0.1", "192.168.0.1"},
+		{" 10.0.0.1 ", "10.0.0.1"},
+		{"::ffff:10.0.0.1", "10.0.0.1"},
+		{"2001:DB8:0:0::1", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"fe80::1%eth0", "fe80::1%eth0"},
+		{"fe80::1%0", "fe80::1"},
+		{"2001:db8::1%2", "2001:db8::1"},
+	}
+	for _, c := range cases {
+		address, err := NormalizeIPAddress(c.address, false)
+		assert.NilError(t, err, c.address)
+		assert.Equal(t, c.expected, address)
+	}
+
+	_, err := NormalizeIPAddress("", false)
+	assert.ErrorContains(t, err, "required")
+	_, err = NormalizeIPAddress("2001:db8::zz", true)
+	assert.ErrorContains(t, err, "invalid IP address")
+	_, err = NormalizeIPAddress("fe80::zz%eth0", true)
+	assert.ErrorContains(t, err, "invalid IP address")
+}
+
+func TestNormalizeIPAddressHostname(t *testing.T) {
+	_, err := NormalizeIPAddress("localhost", false)
+	assert.ErrorContains(t, err, "hostnames are not allowed")
+
+	address, err := NormalizeIPAddress("localhost", true)
+	assert.NilError(t, err)
+	assert.Equal(t, "127.0.0.1", address)
+
+	_, err = NormalizeIPAddress("unknown.host.invalid", true)
+	assert.ErrorContains(t, err, "cannot resolve hostname")
+}
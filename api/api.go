@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+
 	"github.com/agalue/gominion/protobuf/ipc"
 )
 
@@ -34,6 +36,14 @@ type SinkModule interface {
 	Stop()
 }
 
+// ListenerModule represents a Sink Module that receives data through the configured listeners
+// The Minion uses it to validate the parser of each listener at startup
+type ListenerModule interface {
+
+	// Returns the simple class names of the parsers supported by the module
+	GetParsers() []string
+}
+
 // RPCModule represents an implementation of an OpenNMS RPC Module
 type RPCModule interface {
 
@@ -45,6 +55,22 @@ type RPCModule interface {
 	Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto
 }
 
+// RPCErrorResponder represents an RPC Module able to build a response for a failed request
+// Brokers use it when the module cannot produce a response by itself, for instance when the request expires
+type RPCErrorResponder interface {
+
+	// Returns a response that tells the operation failed with the given error
+	ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto
+}
+
+// ContextRPCModule represents an RPC Module able to stop executing a request when its context is done (e.g. when the request expires)
+// The broker uses it instead of Execute when available.
+type ContextRPCModule interface {
+
+	// Executes an RPC request, honoring the cancellation of the context, and returns the response
+	ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto
+}
+
 // ServiceCollector represents an implementation of a service collector
 type ServiceCollector interface {
 
@@ -56,6 +82,21 @@ type ServiceCollector interface {
 	Collect(request *CollectorRequestDTO) *CollectorResponseDTO
 }
 
+// ContextServiceCollector represents a service collector able to stop collecting when its context is done, and to log through the logger of the context
+// The Collect RPC module uses it instead of Collect when available.
+type ContextServiceCollector interface {
+
+	// Executes the data collection operation from the request, honoring the cancellation of the context
+	CollectWithContext(ctx context.Context, request *CollectorRequestDTO) *CollectorResponseDTO
+}
+
+// StoppableModule represents a module that holds resources (e.g. connection pools) that must be released when the Minion stops
+type StoppableModule interface {
+
+	// Releases the resources held by the module
+	Stop()
+}
+
 // ServiceDetector represents an implementation of a service detector
 type ServiceDetector interface {
 
@@ -67,6 +108,14 @@ type ServiceDetector interface {
 	Detect(request *DetectorRequestDTO) *DetectorResponseDTO
 }
 
+// ContextServiceDetector represents a service detector able to stop detecting when its context is done, and to log through the logger of the context
+// The Detect RPC module uses it instead of Detect when available.
+type ContextServiceDetector interface {
+
+	// Executes the detection operation from the request, honoring the cancellation of the context
+	DetectWithContext(ctx context.Context, request *DetectorRequestDTO) *DetectorResponseDTO
+}
+
 // ServiceMonitor represents an implementation of a service monitor
 type ServiceMonitor interface {
 
@@ -77,3 +126,11 @@ type ServiceMonitor interface {
 	// The response tells if the operation was successful or not
 	Poll(request *PollerRequestDTO) *PollerResponseDTO
 }
+
+// ContextServiceMonitor represents a service monitor able to stop polling when its context is done (e.g. when the RPC request expires)
+// The Poller RPC module uses it instead of Poll when available.
+type ContextServiceMonitor interface {
+
+	// Executes the polling operation from the request, honoring the cancellation of the context
+	PollWithContext(ctx context.Context, request *PollerRequestDTO) *PollerResponseDTO
+}
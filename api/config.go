@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -32,6 +33,15 @@ func (listener *MinionListener) Is(parser string) bool {
 	return strings.EqualFold(listener.GetParser(), parser)
 }
 
+// GetProtocol returns the transport protocol of the listener, either tcp or udp
+// TCP is used by the IPFIX and NX-OS parsers, and by the listeners whose name ends with -TCP (e.g. Graphite-TCP)
+func (listener *MinionListener) GetProtocol() string {
+	if listener.Is("IpfixTcpParser") || listener.Is("NxosGrpcParser") || strings.HasSuffix(strings.ToUpper(listener.Name), "-TCP") {
+		return "tcp"
+	}
+	return "udp"
+}
+
 // CircuitBreakerConfig Circuit Breaker Configuration
 type CircuitBreakerConfig struct {
 	MaxRequests uint32 `yaml:"maxRequests,omitempty" json:"maxRequests,omitempty"`
@@ -49,37 +59,82 @@ type DNSConfig struct {
 
 // MinionConfig represents basic Minion Configuration
 type MinionConfig struct {
-	ID               string            `yaml:"id" json:"id"`
-	Location         string            `yaml:"location" json:"location"`
-	BrokerURL        string            `yaml:"brokerUrl" json:"brokerUrl"`
-	BrokerType       string            `yaml:"brokerType" json:"brokerType"`
-	BrokerProperties map[string]string `yaml:"brokerProperties,omitempty" json:"brokerProperties,omitempty"`
-	TrapPort         int               `yaml:"trapPort" json:"traPort"`
-	SyslogPort       int               `yaml:"syslogPort" json:"syslogPort"`
-	StatsPort        int               `yaml:"statsPort" json:"statsPort"`
-	LogLevel         string            `yaml:"logLevel" json:"logLevel"`
-	DNS              *DNSConfig        `yaml:"dns,omitempty" json:"dns,omitempty"`
-	Listeners        []MinionListener  `yaml:"listeners,omitempty" json:"listeners,omitempty"`
-}
-
-// ParseListeners parses an array of listeners in CSV format
+	ID                 string            `yaml:"id" json:"id"`
+	Location           string            `yaml:"location" json:"location"`
+	BrokerURL          string            `yaml:"brokerUrl" json:"brokerUrl"`
+	BrokerType         string            `yaml:"brokerType" json:"brokerType"`
+	BrokerProperties   map[string]string `yaml:"brokerProperties,omitempty" json:"brokerProperties,omitempty"` // env: GOMINION_BROKERPROPERTIES_<NAME>, where _ is - and __ is . in the name
+	TrapPort           int               `yaml:"trapPort" json:"traPort"`
+	SyslogPort         int               `yaml:"syslogPort" json:"syslogPort"`
+	SyslogBufferSize   int               `yaml:"syslogBufferSize,omitempty" json:"syslogBufferSize,omitempty"`
+	StatsPort          int               `yaml:"statsPort" json:"statsPort"`
+	BindAddress        string            `yaml:"bindAddress,omitempty" json:"bindAddress,omitempty"`
+	SourcePortRange    string            `yaml:"sourcePortRange,omitempty" json:"sourcePortRange,omitempty"` // Local ports for the connections of the monitors, detectors and collectors, as min-max
+	LogLevel           string            `yaml:"logLevel" json:"logLevel"`
+	LogFormat          string            `yaml:"logFormat,omitempty" json:"logFormat,omitempty"`
+	LogFile            string            `yaml:"logFile,omitempty" json:"logFile,omitempty"`             // When set, the logs are written to this file instead of stdout
+	LogMaxSize         int               `yaml:"logMaxSize,omitempty" json:"logMaxSize,omitempty"`       // Megabytes before rotating the log file
+	LogMaxBackups      int               `yaml:"logMaxBackups,omitempty" json:"logMaxBackups,omitempty"` // Rotated log files to keep (0 keeps all)
+	LogMaxAge          int               `yaml:"logMaxAge,omitempty" json:"logMaxAge,omitempty"`         // Days to keep the rotated log files (0 keeps them regardless of their age)
+	DNS                *DNSConfig        `yaml:"dns,omitempty" json:"dns,omitempty"`
+	SnmpV3Users        []SNMPv3User      `yaml:"snmpV3Users,omitempty" json:"snmpV3Users,omitempty"`
+	SnmpSessionIdleMs  int               `yaml:"snmpSessionIdleMs" json:"snmpSessionIdleMs"`                       // 0 disables the SNMP session cache
+	MaxListenerWorkers int               `yaml:"maxListenerWorkers,omitempty" json:"maxListenerWorkers,omitempty"` // Workers shared by all the listeners (0 means unlimited)
+	Listeners          []MinionListener  `yaml:"listeners,omitempty" json:"listeners,omitempty"`                   // env: GOMINION_LISTENERS, with ; between listeners in name,port,parser[,key=value...] format
+	EnabledRPCModules  []string          `yaml:"enabledRpcModules,omitempty" json:"enabledRpcModules,omitempty"`   // When set, only these RPC modules answer requests
+	DisabledRPCModules []string          `yaml:"disabledRpcModules,omitempty" json:"disabledRpcModules,omitempty"` // These RPC modules reject requests
+}
+
+// ParseListeners parses an array of listeners in CSV format: name,port,parser[,key=value...]
+// The optional key=value pairs are the listener properties.
 func (cfg *MinionConfig) ParseListeners(csvs []string) error {
 	for _, csv := range csvs {
 		parts := strings.Split(csv, ",")
-		if len(parts) == 3 {
-			if port, err := strconv.Atoi(parts[1]); err == nil {
-				listener := MinionListener{Name: parts[0], Parser: parts[2], Port: port}
-				cfg.Listeners = append(cfg.Listeners, listener)
-			} else {
-				return fmt.Errorf("invalid port on listener CSV %s: %s", csv, err)
-			}
-		} else {
+		if len(parts) < 3 {
 			return fmt.Errorf("invalid listener CSV %s", csv)
 		}
+		port, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid port on listener CSV %s: %s", csv, err)
+		}
+		listener := MinionListener{Name: parts[0], Parser: parts[2], Port: port}
+		for _, property := range parts[3:] {
+			kv := strings.SplitN(property, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return fmt.Errorf("invalid property %s on listener CSV %s, expected key=value", property, csv)
+			}
+			if listener.Properties == nil {
+				listener.Properties = make(map[string]string)
+			}
+			listener.Properties[kv[0]] = kv[1]
+		}
+		cfg.Listeners = append(cfg.Listeners, listener)
+	}
+	return cfg.validatePorts()
+}
+
+// ValidateListeners returns an error if a listener uses a parser that is not in the given list of supported parsers
+// Listeners without a parser (for instance, the ones used to configure Trap or Syslog) are ignored.
+func (cfg *MinionConfig) ValidateListeners(parsers []string) error {
+	for _, listener := range cfg.Listeners {
+		if listener.Parser == "" {
+			continue
+		}
+		valid := false
+		for _, parser := range parsers {
+			if listener.Is(parser) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown parser %s on listener %s, valid parsers are: %s", listener.Parser, listener.Name, strings.Join(parsers, ", "))
+		}
 	}
 	return nil
 }
 
+// GetBrokerProperty gets the value of a given broker property; returns an empty string when it doesn't exist
 func (cfg *MinionConfig) GetBrokerProperty(property string) string {
 	if cfg.BrokerProperties == nil {
 		return ""
@@ -90,6 +145,24 @@ func (cfg *MinionConfig) GetBrokerProperty(property string) string {
 	return ""
 }
 
+// GetInstanceID gets the OpenNMS Instance ID (org.opennms.instance.id) from the instance-id broker property; returns "OpenNMS" when it is not set
+func (cfg *MinionConfig) GetInstanceID() string {
+	if value := cfg.GetBrokerProperty("instance-id"); value != "" {
+		return value
+	}
+	return "OpenNMS"
+}
+
+// GetBrokerPropertyAsInt gets the value of a given broker property as an integer; returns the default value when it doesn't exist or is invalid
+func (cfg *MinionConfig) GetBrokerPropertyAsInt(property string, defaultValue int) int {
+	if value := cfg.GetBrokerProperty(property); value != "" {
+		if v, err := strconv.Atoi(value); err == nil {
+			return v
+		}
+	}
+	return defaultValue
+}
+
 // GetListener gets a given listener by name
 func (cfg *MinionConfig) GetListener(name string) *MinionListener {
 	for _, listener := range cfg.Listeners {
@@ -110,37 +183,188 @@ func (cfg *MinionConfig) GetListenerByParser(parser string) *MinionListener {
 	return nil
 }
 
+// GetListenersByParser gets all the listeners for a given parser name
+func (cfg *MinionConfig) GetListenersByParser(parser string) []MinionListener {
+	var listeners []MinionListener
+	for _, listener := range cfg.Listeners {
+		if strings.EqualFold(listener.GetParser(), parser) {
+			listeners = append(listeners, listener)
+		}
+	}
+	return listeners
+}
+
+// GetBindAddress gets the local address for the receiver of a given listener.
+// The bind-address property of the listener takes precedence over the global bind address; returns an empty string to bind to all the interfaces.
+func (cfg *MinionConfig) GetBindAddress(listener *MinionListener) string {
+	if listener != nil {
+		if value := listener.Properties["bind-address"]; value != "" {
+			return value
+		}
+	}
+	return cfg.BindAddress
+}
+
 func (cfg *MinionConfig) String() string {
 	bytes, _ := json.MarshalIndent(cfg, "", "  ")
 	return string(bytes)
 }
 
+// IsRPCModuleEnabled returns true if the RPC module is allowed to answer requests
+// All modules are enabled by default; when EnabledRPCModules is set, only those are, minus the ones in DisabledRPCModules.
+func (cfg *MinionConfig) IsRPCModuleEnabled(id string) bool {
+	for _, m := range cfg.DisabledRPCModules {
+		if strings.EqualFold(m, id) {
+			return false
+		}
+	}
+	if len(cfg.EnabledRPCModules) == 0 {
+		return true
+	}
+	for _, m := range cfg.EnabledRPCModules {
+		if strings.EqualFold(m, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// The maximum length of the Minion ID and location.
+// The location is part of the Kafka topic names (e.g. OpenNMS.<location>.rpc-request), limited to 249 characters.
+const maxIdentifierLength = 200
+
+// The characters accepted by Kafka on topic names, also safe for the OpenNMS registration and the NATS subjects
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// Returns an error if the value contains characters not accepted on Kafka topic names, or is too long.
+// Spaces are accepted on locations unless they are used for Kafka topics, as the NATS client replaces them.
+func validateIdentifier(name string, value string, allowSpaces bool) error {
+	if len(value) > maxIdentifierLength {
+		return fmt.Errorf("%s is %d characters long, the maximum is %d", name, len(value), maxIdentifierLength)
+	}
+	check := value
+	if allowSpaces {
+		if strings.TrimSpace(value) != value {
+			return fmt.Errorf("%s %q cannot start or end with spaces", name, value)
+		}
+		check = strings.ReplaceAll(value, " ", "_")
+	}
+	if !identifierRegexp.MatchString(check) {
+		allowed := "letters, digits, '.', '_' and '-'"
+		if allowSpaces {
+			allowed = "letters, digits, spaces, '.', '_' and '-'"
+		}
+		for _, c := range check {
+			if !identifierRegexp.MatchString(string(c)) {
+				return fmt.Errorf("invalid character %q on %s %q, only %s are allowed", c, name, value, allowed)
+			}
+		}
+	}
+	return nil
+}
+
 // IsValid returns an error if the configuration is not valid
 func (cfg *MinionConfig) IsValid() error {
 	if cfg.ID == "" {
 		return fmt.Errorf("minion ID required")
 	}
+	if err := validateIdentifier("minion ID", cfg.ID, false); err != nil {
+		return err
+	}
 	if cfg.Location == "" {
 		return fmt.Errorf("location required")
 	}
+	if err := validateIdentifier("location", cfg.Location, !strings.EqualFold(cfg.BrokerType, "kafka")); err != nil {
+		return err
+	}
 	if cfg.BrokerURL == "" {
 		return fmt.Errorf("broker URL required")
 	}
+	if instanceID := cfg.GetBrokerProperty("instance-id"); instanceID != "" {
+		if err := validateIdentifier("instance ID", instanceID, false); err != nil {
+			return err
+		}
+	} else if cfg.GetBrokerProperty("multi-instance") == "true" {
+		return fmt.Errorf("instance-id broker property required in multi-instance mode")
+	}
+	if format := strings.ToLower(cfg.LogFormat); format != "" && format != "console" && format != "json" {
+		return fmt.Errorf("invalid log format %s, expected console or json", cfg.LogFormat)
+	}
+	if cfg.LogFile != "" && cfg.LogMaxSize <= 0 {
+		return fmt.Errorf("invalid log max size %d, expected a positive number of megabytes", cfg.LogMaxSize)
+	}
+	if cfg.LogMaxBackups < 0 || cfg.LogMaxAge < 0 {
+		return fmt.Errorf("invalid log rotation settings, the maximum backups and age cannot be negative")
+	}
+	if cfg.BindAddress != "" && net.ParseIP(cfg.BindAddress) == nil {
+		return fmt.Errorf("invalid bind address %s", cfg.BindAddress)
+	}
 	if cfg.DNS != nil && cfg.DNS.NameServer != "" {
 		ip := net.ParseIP(cfg.DNS.NameServer)
 		if ip == nil {
 			return fmt.Errorf("invalid DNS name server")
 		}
 	}
+	if cfg.SnmpSessionIdleMs < 0 {
+		return fmt.Errorf("invalid SNMP session idle time %d, expected 0 or more milliseconds", cfg.SnmpSessionIdleMs)
+	}
+	if cfg.MaxListenerWorkers < 0 {
+		return fmt.Errorf("invalid max listener workers %d, expected 0 or more", cfg.MaxListenerWorkers)
+	}
+	users := make(map[string]bool)
+	for _, user := range cfg.SnmpV3Users {
+		if err := user.IsValid(); err != nil {
+			return fmt.Errorf("invalid SNMPv3 user: %v", err)
+		}
+		if users[user.SecurityName] {
+			return fmt.Errorf("duplicate SNMPv3 user %s", user.SecurityName)
+		}
+		users[user.SecurityName] = true
+	}
+	return cfg.validatePorts()
+}
+
+// Returns an error if two receivers (Trap, Syslog, or listeners) use the same port and protocol
+func (cfg *MinionConfig) validatePorts() error {
+	owners := make(map[string]string)
+	register := func(name string, protocol string, port int) error {
+		if port == 0 {
+			return nil
+		}
+		key := fmt.Sprintf("%s/%d", protocol, port)
+		if owner, ok := owners[key]; ok {
+			return fmt.Errorf("%s and %s cannot use the same port %s", owner, name, key)
+		}
+		owners[key] = name
+		return nil
+	}
+	if err := register("Trap", "udp", cfg.TrapPort); err != nil {
+		return err
+	}
+	for _, protocol := range []string{"udp", "tcp"} {
+		if err := register("Syslog", protocol, cfg.SyslogPort); err != nil {
+			return err
+		}
+	}
+	for _, listener := range cfg.Listeners {
+		if err := register("listener "+listener.Name, listener.GetProtocol(), listener.Port); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // GetHeaderResponse builds an RPC response with the headers context
+// The instance-id broker property, when set, is included in the tracing info, as the headers have no field for it.
 func (cfg *MinionConfig) GetHeaderResponse() *ipc.RpcResponseProto {
-	return &ipc.RpcResponseProto{
+	headers := &ipc.RpcResponseProto{
 		ModuleId: "MINION_HEADERS",
 		Location: cfg.Location,
 		SystemId: cfg.ID,
 		RpcId:    cfg.ID,
 	}
+	if instanceID := cfg.GetBrokerProperty("instance-id"); instanceID != "" {
+		headers.TracingInfo = map[string]string{"instance-id": instanceID}
+	}
+	return headers
 }
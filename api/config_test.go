@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v2"
@@ -117,6 +118,8 @@ listeners:
 
 	sflow = config.GetListenerByParser("SFlowUdpParser")
 	assert.Assert(t, sflow == nil)
+	assert.Equal(t, 0, len(config.GetListenersByParser("SFlowUdpParser")))
+	assert.Equal(t, 1, len(config.GetListenersByParser("Netflow5UdpParser")))
 
 	listeners := []string{
 		"Graphite,12003,ForwardParser",
@@ -127,4 +130,166 @@ listeners:
 	config.ParseListeners(listeners)
 	assert.Equal(t, 4, len(config.Listeners))
 	assert.Assert(t, config.GetListener("Graphite").Is("ForwardParser"))
+
+	assert.NilError(t, config.ParseListeners([]string{"NXOS-Fabric2,50001,NxosGrpcParser"}))
+	nxos := config.GetListenersByParser("NxosGrpcParser")
+	assert.Equal(t, 2, len(nxos))
+	assert.Equal(t, 50001, nxos[1].Port)
+}
+
+func TestBrokerProperties(t *testing.T) {
+	config := &MinionConfig{
+		BrokerProperties: map[string]string{
+			"reconnect-base-ms": "500",
+			"reconnect-max-ms":  "wrong",
+		},
+	}
+	assert.Equal(t, "500", config.GetBrokerProperty("reconnect-base-ms"))
+	assert.Equal(t, "", config.GetBrokerProperty("unknown"))
+	assert.Equal(t, 500, config.GetBrokerPropertyAsInt("reconnect-base-ms", 1000))
+	assert.Equal(t, 60000, config.GetBrokerPropertyAsInt("reconnect-max-ms", 60000))
+	assert.Equal(t, 10, config.GetBrokerPropertyAsInt("unknown", 10))
+}
+
+func TestInstanceID(t *testing.T) {
+	cfg := &MinionConfig{ID: "minion01", Location: "Apex", BrokerURL: "localhost:8990", BrokerProperties: map[string]string{}}
+	assert.Equal(t, "OpenNMS", cfg.GetInstanceID())
+	assert.Assert(t, cfg.GetHeaderResponse().TracingInfo == nil)
+
+	cfg.BrokerProperties["multi-instance"] = "true"
+	assert.ErrorContains(t, cfg.IsValid(), "instance-id broker property required in multi-instance mode")
+
+	cfg.BrokerProperties["instance-id"] = "Blue/Green"
+	assert.ErrorContains(t, cfg.IsValid(), `invalid character '/' on instance ID "Blue/Green"`)
+
+	cfg.BrokerProperties["instance-id"] = "Blue"
+	assert.NilError(t, cfg.IsValid())
+	assert.Equal(t, "Blue", cfg.GetInstanceID())
+	assert.DeepEqual(t, map[string]string{"instance-id": "Blue"}, cfg.GetHeaderResponse().TracingInfo)
+}
+
+func TestBindAddress(t *testing.T) {
+	cfg := &MinionConfig{
+		ID:          "minion1",
+		Location:    "Test",
+		BrokerURL:   "localhost:8990",
+		BindAddress: "10.0.0.1",
+		Listeners: []MinionListener{
+			{Name: "Netflow-5", Port: 8877, Parser: "Netflow5UdpParser", Properties: map[string]string{"bind-address": "192.168.0.1"}},
+			{Name: "Netflow-9", Port: 4729, Parser: "Netflow9UdpParser"},
+		},
+	}
+	assert.NilError(t, cfg.IsValid())
+	assert.Equal(t, "192.168.0.1", cfg.GetBindAddress(cfg.GetListener("Netflow-5")))
+	assert.Equal(t, "10.0.0.1", cfg.GetBindAddress(cfg.GetListener("Netflow-9")))
+	assert.Equal(t, "10.0.0.1", cfg.GetBindAddress(nil))
+
+	cfg.BindAddress = "eth0"
+	assert.ErrorContains(t, cfg.IsValid(), "invalid bind address")
+}
+
+func TestLogFormat(t *testing.T) {
+	cfg := &MinionConfig{ID: "minion1", Location: "Test", BrokerURL: "localhost:8990", LogFormat: "json"}
+	assert.NilError(t, cfg.IsValid())
+	cfg.LogFormat = "xml"
+	assert.ErrorContains(t, cfg.IsValid(), "invalid log format")
+}
+
+func TestLogFile(t *testing.T) {
+	cfg := &MinionConfig{ID: "minion1", Location: "Test", BrokerURL: "localhost:8990", LogFile: "/var/log/gominion.log", LogMaxSize: 100}
+	assert.NilError(t, cfg.IsValid())
+	cfg.LogMaxSize = 0
+	assert.ErrorContains(t, cfg.IsValid(), "invalid log max size")
+	cfg.LogMaxSize = 100
+	cfg.LogMaxBackups = -1
+	assert.ErrorContains(t, cfg.IsValid(), "invalid log rotation settings")
+}
+
+func TestValidateListeners(t *testing.T) {
+	parsers := []string{"Netflow5UdpParser", "Netflow9UdpParser"}
+	cfg := &MinionConfig{
+		Listeners: []MinionListener{
+			{Name: "Netflow-5", Port: 8877, Parser: "org.opennms.netmgt.telemetry.protocols.netflow.parser.Netflow5UdpParser"},
+			{Name: "Syslog", Properties: map[string]string{"bind-address": "10.0.0.1"}},
+		},
+	}
+	assert.NilError(t, cfg.ValidateListeners(parsers))
+
+	cfg.Listeners = append(cfg.Listeners, MinionListener{Name: "Netflow-9", Port: 4729, Parser: "Netflow9UdpParserr"})
+	assert.ErrorContains(t, cfg.ValidateListeners(parsers), "unknown parser Netflow9UdpParserr on listener Netflow-9, valid parsers are: Netflow5UdpParser, Netflow9UdpParser")
+}
+
+func TestDuplicatePorts(t *testing.T) {
+	cfg := &MinionConfig{
+		ID:         "minion1",
+		Location:   "Test",
+		BrokerURL:  "localhost:8990",
+		TrapPort:   1162,
+		SyslogPort: 1514,
+		Listeners: []MinionListener{
+			{Name: "Graphite", Port: 2003, Parser: "ForwardParser"},
+			{Name: "Graphite-TCP", Port: 2003, Parser: "ForwardParser"},
+			{Name: "Syslog", Properties: map[string]string{"bind-address": "10.0.0.1"}},
+		},
+	}
+	assert.NilError(t, cfg.IsValid())
+
+	assert.ErrorContains(t, cfg.ParseListeners([]string{"NXOS,1514,NxosGrpcParser"}), "Syslog and listener NXOS cannot use the same port tcp/1514")
+
+	cfg.Listeners = []MinionListener{
+		{Name: "Netflow-5", Port: 8877, Parser: "Netflow5UdpParser"},
+	}
+	assert.ErrorContains(t, cfg.ParseListeners([]string{"Netflow-9,8877,Netflow9UdpParser"}), "listener Netflow-5 and listener Netflow-9 cannot use the same port udp/8877")
+
+	cfg.Listeners = nil
+	cfg.TrapPort = 1514
+	assert.ErrorContains(t, cfg.IsValid(), "Trap and Syslog cannot use the same port udp/1514")
+}
+
+func TestParseListeners(t *testing.T) {
+	cfg := &MinionConfig{}
+	assert.NilError(t, cfg.ParseListeners([]string{"Netflow-9,4729,Netflow9UdpParser,workers=4,bind-address=10.0.0.1"}))
+	listener := cfg.GetListener("Netflow-9")
+	assert.Equal(t, 4729, listener.Port)
+	assert.Equal(t, "4", listener.Properties["workers"])
+	assert.Equal(t, "10.0.0.1", listener.Properties["bind-address"])
+
+	assert.ErrorContains(t, cfg.ParseListeners([]string{"NXOS,50001"}), "invalid listener CSV")
+	assert.ErrorContains(t, cfg.ParseListeners([]string{"NXOS,50001,NxosGrpcParser,workers"}), "expected key=value")
+}
+
+func TestIsRPCModuleEnabled(t *testing.T) {
+	cfg := &MinionConfig{}
+	assert.Assert(t, cfg.IsRPCModuleEnabled("Collect"))
+
+	cfg.DisabledRPCModules = []string{"collect"}
+	assert.Assert(t, !cfg.IsRPCModuleEnabled("Collect"))
+	assert.Assert(t, cfg.IsRPCModuleEnabled("Detect"))
+
+	cfg.EnabledRPCModules = []string{"Echo", "Poller", "Collect"}
+	assert.Assert(t, cfg.IsRPCModuleEnabled("Poller"))
+	assert.Assert(t, !cfg.IsRPCModuleEnabled("Detect"))
+	assert.Assert(t, !cfg.IsRPCModuleEnabled("Collect"))
+}
+
+func TestValidateIdentifiers(t *testing.T) {
+	cfg := &MinionConfig{ID: "minion-01.dc1", Location: "Apex Office", BrokerURL: "localhost:8990"}
+	assert.NilError(t, cfg.IsValid())
+
+	cfg.BrokerType = "kafka"
+	assert.ErrorContains(t, cfg.IsValid(), `invalid character ' ' on location "Apex Office", only letters, digits, '.', '_' and '-' are allowed`)
+
+	cfg.BrokerType = "grpc"
+	cfg.Location = "Apex/Office"
+	assert.ErrorContains(t, cfg.IsValid(), `invalid character '/' on location "Apex/Office", only letters, digits, spaces, '.', '_' and '-' are allowed`)
+
+	cfg.Location = "Apex "
+	assert.ErrorContains(t, cfg.IsValid(), `location "Apex " cannot start or end with spaces`)
+
+	cfg.Location = "Apex"
+	cfg.ID = "minion 01"
+	assert.ErrorContains(t, cfg.IsValid(), `invalid character ' ' on minion ID "minion 01"`)
+
+	cfg.ID = strings.Repeat("m", 201)
+	assert.ErrorContains(t, cfg.IsValid(), "minion ID is 201 characters long, the maximum is 200")
 }
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthStatusOK health status name when all the subsystems are working
+const HealthStatusOK = "ok"
+
+// HealthStatusFailed health status name when at least one subsystem has failed
+const HealthStatusFailed = "failed"
+
+// The states of the RPC channel verification, for the brokers that perform it
+const (
+	RPCChannelVerifying = "verifying"
+	RPCChannelVerified  = "verified"
+	RPCChannelFailed    = "failed"
+)
+
+var healthMutex = sync.RWMutex{}
+var healthBrokerState = "UNKNOWN"
+var healthRPCChannel string
+var healthSinkModules []string
+var healthLastSinkDelivery time.Time
+var healthFailedModules map[string]string = make(map[string]string)
+
+// MinionHealthDTO represents the health status of the Minion subsystems
+type MinionHealthDTO struct {
+	Status           string            `json:"status"`
+	BrokerState      string            `json:"brokerState"`
+	RPCChannel       string            `json:"rpcChannel,omitempty"`
+	RPCModules       int               `json:"rpcModules"`
+	SinkModules      int               `json:"sinkModules"`
+	LastSinkDelivery *time.Time        `json:"lastSinkDelivery,omitempty"`
+	FailedModules    map[string]string `json:"failedModules,omitempty"`
+	Error            string            `json:"error,omitempty"`
+}
+
+// IsHealthy returns true when none of the subsystems have failed
+func (health *MinionHealthDTO) IsHealthy() bool {
+	return health.Status == HealthStatusOK
+}
+
+// IsBrokerReady returns true when the broker is connected to OpenNMS
+func (health *MinionHealthDTO) IsBrokerReady() bool {
+	return health.BrokerState == "READY"
+}
+
+// IsRPCReady returns true when the RPC channel was verified, or when the broker doesn't verify it
+func (health *MinionHealthDTO) IsRPCReady() bool {
+	return health.RPCChannel == "" || health.RPCChannel == RPCChannelVerified
+}
+
+// SetRPCChannelState updates the state of the RPC channel verification
+func SetRPCChannelState(state string) {
+	healthMutex.Lock()
+	healthRPCChannel = state
+	healthMutex.Unlock()
+}
+
+// SetBrokerState updates the state of the broker connection
+func SetBrokerState(state string) {
+	healthMutex.Lock()
+	healthBrokerState = state
+	healthMutex.Unlock()
+}
+
+// MarkSinkDelivery records the time of the last successful Sink message delivery
+func MarkSinkDelivery() {
+	healthMutex.Lock()
+	healthLastSinkDelivery = time.Now()
+	healthMutex.Unlock()
+}
+
+// ReportModuleFailure records that a given module (or one of its listeners) is not running due to an error
+func ReportModuleFailure(id string, err error) {
+	healthMutex.Lock()
+	healthFailedModules[id] = err.Error()
+	healthMutex.Unlock()
+}
+
+// ClearModuleFailure removes a previously reported failure for a given module
+func ClearModuleFailure(id string) {
+	healthMutex.Lock()
+	delete(healthFailedModules, id)
+	healthMutex.Unlock()
+}
+
+// GetHealth returns the current health status of the Minion subsystems
+func GetHealth() *MinionHealthDTO {
+	healthMutex.RLock()
+	defer healthMutex.RUnlock()
+	health := &MinionHealthDTO{
+		Status:      HealthStatusOK,
+		BrokerState: healthBrokerState,
+		RPCChannel:  healthRPCChannel,
+		RPCModules:  len(GetAllRPCModules()),
+		SinkModules: len(healthSinkModules),
+	}
+	if !healthLastSinkDelivery.IsZero() {
+		ts := healthLastSinkDelivery
+		health.LastSinkDelivery = &ts
+	}
+	if len(healthFailedModules) > 0 {
+		health.Status = HealthStatusFailed
+		health.FailedModules = make(map[string]string, len(healthFailedModules))
+		for id, reason := range healthFailedModules {
+			health.FailedModules[id] = reason
+		}
+	}
+	return health
+}
+
+func setSinkModules(ids []string) {
+	healthMutex.Lock()
+	healthSinkModules = ids
+	healthMutex.Unlock()
+}
+
+// GetSinkModules returns the sorted IDs of the Sink modules started by the broker
+func GetSinkModules() []string {
+	healthMutex.RLock()
+	defer healthMutex.RUnlock()
+	ids := append([]string{}, healthSinkModules...)
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestHealth(t *testing.T) {
+	SetBrokerState("READY")
+	health := GetHealth()
+	assert.Assert(t, health.IsHealthy())
+	assert.Equal(t, "READY", health.BrokerState)
+	assert.Assert(t, health.LastSinkDelivery == nil)
+
+	MarkSinkDelivery()
+	ReportModuleFailure("Trap", fmt.Errorf("cannot listen"))
+	health = GetHealth()
+	assert.Assert(t, !health.IsHealthy())
+	assert.Assert(t, health.LastSinkDelivery != nil)
+	assert.Equal(t, "cannot listen", health.FailedModules["Trap"])
+
+	ClearModuleFailure("Trap")
+	assert.Assert(t, GetHealth().IsHealthy())
+}
+
+func TestSinkModules(t *testing.T) {
+	registry := &SinkRegistry{}
+	registry.Init()
+	registry.RegisterModule(&mockSinkModule{id: "Syslog"})
+	registry.RegisterModule(&mockSinkModule{id: "Heartbeat"})
+	assert.NilError(t, registry.StartModules(&MinionConfig{}, nil))
+	assert.DeepEqual(t, []string{"Heartbeat", "Syslog"}, GetSinkModules())
+	assert.Equal(t, 2, GetHealth().SinkModules)
+}
+
+type mockSinkModule struct {
+	id string
+}
+
+func (module *mockSinkModule) GetID() string {
+	return module.id
+}
+
+func (module *mockSinkModule) Start(config *MinionConfig, sink Sink) error {
+	return nil
+}
+
+func (module *mockSinkModule) Stop() {
+}
@@ -0,0 +1,30 @@
+package api
+
+import "sync"
+
+var inventoryMutex = sync.RWMutex{}
+var inventoryVersion = "unknown"
+
+// MinionInventoryDTO represents the modules supported by the Minion
+type MinionInventoryDTO struct {
+	Version     string   `json:"version"`
+	RPCModules  []string `json:"rpcModules"`
+	SinkModules []string `json:"sinkModules"`
+	Collectors  []string `json:"collectors"`
+	Detectors   []string `json:"detectors"`
+	Monitors    []string `json:"monitors"`
+}
+
+// SetVersion sets the version of the Minion reported by the inventory
+func SetVersion(version string) {
+	inventoryMutex.Lock()
+	inventoryVersion = version
+	inventoryMutex.Unlock()
+}
+
+// GetVersion returns the version of the Minion
+func GetVersion() string {
+	inventoryMutex.RLock()
+	defer inventoryMutex.RUnlock()
+	return inventoryVersion
+}
@@ -6,27 +6,61 @@ import (
 
 // Metrics represents the broker metric set per module
 type Metrics struct {
-	SinkMsgDeliverySucceeded *prometheus.CounterVec // Sink messages successfully delivered
-	SinkMsgDeliveryFailed    *prometheus.CounterVec // Failed attempts to send Sink messages
-	RPCReqReceivedSucceeded  *prometheus.CounterVec // RPC requests successfully received
-	RPCReqReceivedFailed     *prometheus.CounterVec // Failed attempts to receive RPC requests
-	RPCReqProcessedSucceeded *prometheus.CounterVec // RPC requests successfully processed
-	RPCReqProcessedFailed    *prometheus.CounterVec // Failed attempts to process RPC requests
-	RPCResSentSucceeded      *prometheus.CounterVec // RPC responses successfully sent
-	RPCResSentFailed         *prometheus.CounterVec // Failed attempts to send RPC responses
+	SinkMsgDeliverySucceeded *prometheus.CounterVec   // Sink messages successfully delivered
+	SinkMsgDeliveryFailed    *prometheus.CounterVec   // Failed attempts to send Sink messages
+	SinkMsgBufferDropped     *prometheus.CounterVec   // Buffered Sink messages dropped because the buffer was full
+	SinkMsgBuffered          prometheus.Gauge         // Sink messages waiting in the buffer to be resent
+	RPCReqReceivedSucceeded  *prometheus.CounterVec   // RPC requests successfully received
+	RPCReqReceivedFailed     *prometheus.CounterVec   // Failed attempts to receive RPC requests
+	RPCReqProcessedSucceeded *prometheus.CounterVec   // RPC requests successfully processed
+	RPCReqProcessedFailed    *prometheus.CounterVec   // Failed attempts to process RPC requests
+	RPCReqTimedOut           *prometheus.CounterVec   // RPC requests that expired before being processed
+	RPCReqUnsupported        *prometheus.CounterVec   // RPC requests for modules not implemented by the Minion
+	RPCReqPanicked           *prometheus.CounterVec   // RPC requests whose module panicked
+	RPCResSentSucceeded      *prometheus.CounterVec   // RPC responses successfully sent
+	RPCResSentFailed         *prometheus.CounterVec   // Failed attempts to send RPC responses
+	RPCReqInFlight           prometheus.Gauge         // RPC requests currently being executed
+	RPCReqQueued             prometheus.Gauge         // RPC requests waiting for an available worker
+	BrokerConnectionState    prometheus.Gauge         // The state of the gRPC connection
+	BrokerActiveEndpoint     *prometheus.GaugeVec     // The gRPC endpoint currently in use
+	RPCReqProcessedDuration  *prometheus.HistogramVec // Time to execute RPC requests
+	SinkMsgSize              *prometheus.HistogramVec // Size of the Sink messages
+	TelemetryBytesReceived   *prometheus.CounterVec   // Bytes received by the telemetry listeners
+	TelemetryPacketsReceived *prometheus.CounterVec   // Packets (or messages, for stream listeners) received by the telemetry listeners
+	TelemetryPacketsDropped  *prometheus.CounterVec   // Telemetry messages that couldn't be forwarded to OpenNMS
 }
 
+// RPCDurationBuckets are the buckets in seconds for the RPC execution time, from 5ms to 1 minute
+var RPCDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// SinkSizeBuckets are the buckets in bytes for the Sink message size, from 256B to 16MB
+var SinkSizeBuckets = prometheus.ExponentialBuckets(256, 4, 9)
+
 // Register register all prometheus metrics
 func (m *Metrics) Register() {
 	prometheus.MustRegister(
 		m.SinkMsgDeliverySucceeded,
 		m.SinkMsgDeliveryFailed,
+		m.SinkMsgBufferDropped,
+		m.SinkMsgBuffered,
 		m.RPCReqReceivedSucceeded,
 		m.RPCReqReceivedFailed,
 		m.RPCReqProcessedSucceeded,
 		m.RPCReqProcessedFailed,
+		m.RPCReqTimedOut,
+		m.RPCReqUnsupported,
+		m.RPCReqPanicked,
 		m.RPCResSentSucceeded,
 		m.RPCResSentFailed,
+		m.RPCReqInFlight,
+		m.RPCReqQueued,
+		m.BrokerConnectionState,
+		m.BrokerActiveEndpoint,
+		m.RPCReqProcessedDuration,
+		m.SinkMsgSize,
+		m.TelemetryBytesReceived,
+		m.TelemetryPacketsReceived,
+		m.TelemetryPacketsDropped,
 	)
 }
 
@@ -41,6 +75,14 @@ func NewMetrics() *Metrics {
 			Name: "onms_sink_messages_delivery_failed",
 			Help: "The total number of failed attempts to send Sink messages per module",
 		}, []string{"minion", "module"}),
+		SinkMsgBufferDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "onms_sink_messages_buffer_dropped",
+			Help: "The total number of buffered Sink messages dropped because the buffer was full per module",
+		}, []string{"minion", "module"}),
+		SinkMsgBuffered: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "onms_sink_messages_buffered",
+			Help: "The number of Sink messages waiting in the buffer to be resent",
+		}),
 		RPCReqReceivedSucceeded: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "onms_rpc_requests_received_succeeded",
 			Help: "The total number of RPC requests successfully received per module",
@@ -57,6 +99,18 @@ func NewMetrics() *Metrics {
 			Name: "onms_rpc_requests_processed_failed",
 			Help: "The total number of failed attempts to process RPC messages per module",
 		}, []string{"minion", "module"}),
+		RPCReqTimedOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "onms_rpc_requests_timed_out",
+			Help: "The total number of RPC requests that expired before being processed per module",
+		}, []string{"minion", "module"}),
+		RPCReqUnsupported: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "onms_rpc_requests_unsupported",
+			Help: "The total number of RPC requests rejected because the module is not implemented by the Minion",
+		}, []string{"minion", "module"}),
+		RPCReqPanicked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "onms_rpc_requests_panicked",
+			Help: "The total number of RPC requests whose module panicked during the execution",
+		}, []string{"minion", "module"}),
 		RPCResSentSucceeded: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "onms_rpc_responses_sent_succeeded",
 			Help: "The total number of RPC responses successfully sent per module",
@@ -65,5 +119,43 @@ func NewMetrics() *Metrics {
 			Name: "onms_rpc_responses_sent_failed",
 			Help: "The total number of failed attempts to send RPC responses per module",
 		}, []string{"minion", "module"}),
+		RPCReqInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "onms_rpc_requests_in_flight",
+			Help: "The number of RPC requests currently being executed",
+		}),
+		RPCReqQueued: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "onms_rpc_requests_queued",
+			Help: "The number of RPC requests waiting for an available worker",
+		}),
+		BrokerConnectionState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "onms_broker_connection_state",
+			Help: "The state of the gRPC broker connection: 0 idle, 1 connecting, 2 ready, 3 transient failure, 4 shutdown",
+		}),
+		BrokerActiveEndpoint: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "onms_broker_active_endpoint",
+			Help: "Whether the gRPC broker endpoint is the one currently in use (1) or not (0)",
+		}, []string{"endpoint"}),
+		RPCReqProcessedDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "onms_rpc_requests_processed_duration_seconds",
+			Help:    "The time to execute RPC requests per module",
+			Buckets: RPCDurationBuckets,
+		}, []string{"minion", "module"}),
+		SinkMsgSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "onms_sink_messages_size_bytes",
+			Help:    "The size of the Sink messages sent per module",
+			Buckets: SinkSizeBuckets,
+		}, []string{"minion", "module"}),
+		TelemetryBytesReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "onms_telemetry_bytes_received",
+			Help: "The total number of bytes received per telemetry listener and parser",
+		}, []string{"minion", "listener", "parser"}),
+		TelemetryPacketsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "onms_telemetry_packets_received",
+			Help: "The total number of packets received per telemetry listener and parser (messages for the TCP and gRPC listeners)",
+		}, []string{"minion", "listener", "parser"}),
+		TelemetryPacketsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "onms_telemetry_packets_dropped",
+			Help: "The total number of telemetry messages that couldn't be forwarded per listener and parser",
+		}, []string{"minion", "listener", "parser"}),
 	}
 }
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/agalue/gominion/protobuf/ipc"
+)
+
+// MockBroker represents an in-memory broker that records the Sink messages instead of sending them to OpenNMS
+// It is intended to be used by the unit tests of the Sink and RPC modules.
+type MockBroker struct {
+	// Error when not nil, is returned by Send, and the message is not recorded
+	Error error
+
+	messages []*ipc.SinkMessage
+	mutex    sync.Mutex
+}
+
+// Start starts the broker (does nothing)
+func (broker *MockBroker) Start() error {
+	return nil
+}
+
+// Stop stops the broker (does nothing)
+func (broker *MockBroker) Stop() {
+}
+
+// Send records a Sink message; returns the configured error if any
+func (broker *MockBroker) Send(msg *ipc.SinkMessage) error {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	if broker.Error != nil {
+		return broker.Error
+	}
+	broker.messages = append(broker.messages, msg)
+	return nil
+}
+
+// GetMessages gets a copy of all the recorded Sink messages
+func (broker *MockBroker) GetMessages() []*ipc.SinkMessage {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	return append([]*ipc.SinkMessage{}, broker.messages...)
+}
+
+// GetMessagesForModule gets the recorded Sink messages for a given module ID
+func (broker *MockBroker) GetMessagesForModule(moduleID string) []*ipc.SinkMessage {
+	messages := make([]*ipc.SinkMessage, 0)
+	for _, msg := range broker.GetMessages() {
+		if msg.ModuleId == moduleID {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// WaitForMessages waits until at least the given number of messages are recorded, or the timeout expires.
+// Returns the recorded Sink messages.
+func (broker *MockBroker) WaitForMessages(count int, timeout time.Duration) []*ipc.SinkMessage {
+	deadline := time.Now().Add(timeout)
+	for {
+		messages := broker.GetMessages()
+		if len(messages) >= count || time.Now().After(deadline) {
+			return messages
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Reset discards all the recorded Sink messages
+func (broker *MockBroker) Reset() {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	broker.messages = nil
+}
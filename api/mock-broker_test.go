@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/protobuf/ipc"
+	"gotest.tools/v3/assert"
+)
+
+func TestMockBroker(t *testing.T) {
+	broker := &MockBroker{}
+	var _ Broker = broker
+	var _ Sink = broker
+
+	go func() {
+		broker.Send(&ipc.SinkMessage{ModuleId: "Heartbeat"})
+		broker.Send(&ipc.SinkMessage{ModuleId: "Syslog"})
+	}()
+	assert.Equal(t, 2, len(broker.WaitForMessages(2, time.Second)))
+	assert.Equal(t, 1, len(broker.GetMessagesForModule("Syslog")))
+
+	broker.Reset()
+	broker.Error = fmt.Errorf("broker unavailable")
+	assert.ErrorContains(t, broker.Send(&ipc.SinkMessage{ModuleId: "Heartbeat"}), "broker unavailable")
+	assert.Equal(t, 0, len(broker.WaitForMessages(1, 50*time.Millisecond)))
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parameters represents the attributes of a request merged over the defaults declared by a module, as OpenNMS omits the parameters that keep their default values.
+// Keys are case-insensitive. The typed getters try the request value, then the module default, and then the given default, skipping the values that cannot be parsed.
+type Parameters struct {
+	values   map[string]string
+	defaults map[string]string
+}
+
+// NewParameters creates the parameters from the values of a request and the defaults of a module (either can be nil)
+func NewParameters(values map[string]string, defaults map[string]string) *Parameters {
+	return &Parameters{values: lowerKeys(values), defaults: lowerKeys(defaults)}
+}
+
+// Get gets the value of a parameter, or an empty string when it is neither on the request nor on the defaults
+func (p *Parameters) Get(key string) string {
+	return p.GetString(key, "")
+}
+
+// GetString gets the value of a parameter, or the given default when it is neither on the request nor on the defaults
+func (p *Parameters) GetString(key string, defaultValue string) string {
+	if values := p.lookup(key); len(values) > 0 {
+		return values[0]
+	}
+	return defaultValue
+}
+
+// GetInt gets the value of a parameter as an integer
+func (p *Parameters) GetInt(key string, defaultValue int) int {
+	for _, value := range p.lookup(key) {
+		if v, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return v
+		}
+	}
+	return defaultValue
+}
+
+// GetFloat gets the value of a parameter as a float
+func (p *Parameters) GetFloat(key string, defaultValue float64) float64 {
+	for _, value := range p.lookup(key) {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return v
+		}
+	}
+	return defaultValue
+}
+
+// GetBool gets the value of a parameter as a boolean (true, false, 1, 0, and the other forms accepted by strconv.ParseBool)
+func (p *Parameters) GetBool(key string, defaultValue bool) bool {
+	for _, value := range p.lookup(key) {
+		if v, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+			return v
+		}
+	}
+	return defaultValue
+}
+
+// GetDuration gets the value of a parameter as a duration, either in milliseconds as OpenNMS sends them, or with a unit (e.g. 5s)
+func (p *Parameters) GetDuration(key string, defaultValue time.Duration) time.Duration {
+	for _, value := range p.lookup(key) {
+		value = strings.TrimSpace(value)
+		if v, err := strconv.Atoi(value); err == nil {
+			return time.Duration(v) * time.Millisecond
+		}
+		if v, err := time.ParseDuration(value); err == nil {
+			return v
+		}
+	}
+	return defaultValue
+}
+
+// Gets the values of a parameter in order of precedence: the one from the request, and then the default
+func (p *Parameters) lookup(key string) []string {
+	key = strings.ToLower(key)
+	values := make([]string, 0, 2)
+	for _, source := range []map[string]string{p.values, p.defaults} {
+		if value, ok := source[key]; ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func lowerKeys(values map[string]string) map[string]string {
+	lowered := make(map[string]string, len(values))
+	for key, value := range values {
+		lowered[strings.ToLower(key)] = value
+	}
+	return lowered
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParameters(t *testing.T) {
+	defaults := map[string]string{"port": "443", "days": "7", "ssl": "true", "interval": "1000"}
+	params := NewParameters(map[string]string{"Days": "30", "ssl": "no", "interval": "2s", "retries": "x", "ratio": "0.5"}, defaults)
+
+	assert.Equal(t, "443", params.Get("port"))
+	assert.Equal(t, "", params.Get("missing"))
+	assert.Equal(t, "fallback", params.GetString("missing", "fallback"))
+	assert.Equal(t, 30, params.GetInt("days", 1))
+	assert.Equal(t, 30, params.GetInt("DAYS", 1))
+	assert.Equal(t, 443, params.GetInt("port", 80))
+	assert.Equal(t, 3, params.GetInt("retries", 3))     // Invalid, without a module default
+	assert.Equal(t, true, params.GetBool("ssl", false)) // Invalid, so the module default applies
+	assert.Equal(t, false, params.GetBool("missing", false))
+	assert.Equal(t, 2*time.Second, params.GetDuration("interval", time.Minute))
+	assert.Equal(t, time.Second, NewParameters(nil, defaults).GetDuration("interval", time.Minute))
+	assert.Equal(t, time.Minute, params.GetDuration("missing", time.Minute))
+	assert.Equal(t, 0.5, params.GetFloat("ratio", 1))
+}
+
+func TestRequestParameters(t *testing.T) {
+	poller := &PollerRequestDTO{}
+	err := xml.Unmarshal([]byte(`
+	<poller-request location="Test" system-id="minion1" class-name="org.opennms.netmgt.poller.monitors.SSLCertMonitor" address="10.0.0.1">
+		<attribute key="Port" value="8443"/>
+		<attribute key="port" value="9443"/>
+	</poller-request>`), poller)
+	assert.NilError(t, err)
+	params := poller.GetParameters(map[string]string{"port": "443", "days": "7"})
+	assert.Equal(t, 8443, params.GetInt("port", 0)) // The first attribute wins, as with GetAttributeValue
+	assert.Equal(t, 7, params.GetInt("days", 0))
+
+	detector := &DetectorRequestDTO{DetectorAttributes: []DetectorAttributeDTO{{Key: "checkRetCode", Value: "true"}}}
+	assert.Equal(t, true, detector.GetParameters(nil).GetBool("checkretcode", false))
+
+	collector := &CollectorRequestDTO{Attributes: []CollectionAttributeDTO{{Key: "url", Content: "<![CDATA[http://localhost/metrics]]>"}}}
+	assert.Equal(t, "http://localhost/metrics", collector.GetParameters(nil).Get("url"))
+}
@@ -9,6 +9,7 @@ type HTTPAttribute struct {
 	XMLName    xml.Name `xml:"attrib"`
 	Alias      string   `xml:"alias,attr"`
 	MatchGroup int      `xml:"match-group,attr"`
+	Locator    string   `xml:"locator,attr,omitempty"` // XPath or JSON path, used when the response type is xml or json
 	Type       string   `xml:"type,attr"`
 }
 
@@ -22,6 +23,7 @@ type HTTPAttributeList struct {
 type HTTPUrl struct {
 	XMLName       xml.Name `xml:"url"`
 	Path          string   `xml:"path,attr"`
+	Query         string   `xml:"query,attr,omitempty"`
 	UserAgent     string   `xml:"user-agent,attr"`
 	Matches       string   `xml:"matches,attr"`
 	ResponseRange string   `xml:"response-range,attr"`
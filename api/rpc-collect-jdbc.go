@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// JDBCColumn represents a result column mapped to a collection attribute
+type JDBCColumn struct {
+	XMLName        xml.Name `xml:"column"`
+	Name           string   `xml:"name,attr"`
+	DataSourceName string   `xml:"data-source-name,attr"`
+	Type           string   `xml:"type,attr"`
+	Alias          string   `xml:"alias,attr"`
+}
+
+// GetAlias returns the name of the collection attribute
+func (column *JDBCColumn) GetAlias() string {
+	if column.Alias != "" {
+		return column.Alias
+	}
+	if column.DataSourceName != "" {
+		return column.DataSourceName
+	}
+	return column.Name
+}
+
+// GetAttributeType returns the type of the collection attribute: string, counter, or gauge
+func (column *JDBCColumn) GetAttributeType() string {
+	t := strings.ToLower(column.Type)
+	switch {
+	case strings.Contains(t, "string"):
+		return "string"
+	case strings.Contains(t, "counter"):
+		return "counter"
+	default:
+		return "gauge"
+	}
+}
+
+// JDBCQuery represents an SQL statement to execute
+// When the instance column is set, every row is a table resource of the given type, indexed by that column; otherwise, the first row is collected at the node level
+type JDBCQuery struct {
+	XMLName        xml.Name     `xml:"query"`
+	Name           string       `xml:"name,attr"`
+	ResourceType   string       `xml:"resource-type,attr"`
+	InstanceColumn string       `xml:"instance-column,attr"`
+	Statement      string       `xml:"statement>queryString"`
+	Columns        []JDBCColumn `xml:"columns>column"`
+}
+
+// FindColumn finds a column by its name (case insensitive)
+func (query *JDBCQuery) FindColumn(name string) *JDBCColumn {
+	for i := range query.Columns {
+		if strings.EqualFold(query.Columns[i].Name, name) {
+			return &query.Columns[i]
+		}
+	}
+	return nil
+}
+
+// JDBCCollection represents a JDBC data collection definition
+type JDBCCollection struct {
+	XMLName xml.Name    `xml:"jdbc-collection"`
+	Name    string      `xml:"name,attr"`
+	Queries []JDBCQuery `xml:"queries>query"`
+}
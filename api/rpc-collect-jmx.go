@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// JMXAttrib represents an MBean attribute mapped to a collection attribute
+type JMXAttrib struct {
+	XMLName xml.Name `xml:"attrib"`
+	Name    string   `xml:"name,attr"`
+	Alias   string   `xml:"alias,attr"`
+	Type    string   `xml:"type,attr"`
+}
+
+// GetAlias returns the name of the collection attribute
+func (attrib *JMXAttrib) GetAlias() string {
+	if attrib.Alias != "" {
+		return attrib.Alias
+	}
+	return attrib.Name
+}
+
+// GetAttributeType returns the type of the collection attribute: string, counter, or gauge
+func (attrib *JMXAttrib) GetAttributeType() string {
+	return getJMXAttributeType(attrib.Type)
+}
+
+// JMXCompMember represents a member of a composite MBean attribute
+type JMXCompMember struct {
+	XMLName xml.Name `xml:"comp-member"`
+	Name    string   `xml:"name,attr"`
+	Alias   string   `xml:"alias,attr"`
+	Type    string   `xml:"type,attr"`
+}
+
+// GetAlias returns the name of the collection attribute
+func (member *JMXCompMember) GetAlias() string {
+	if member.Alias != "" {
+		return member.Alias
+	}
+	return member.Name
+}
+
+// GetAttributeType returns the type of the collection attribute: string, counter, or gauge
+func (member *JMXCompMember) GetAttributeType() string {
+	return getJMXAttributeType(member.Type)
+}
+
+// JMXCompAttrib represents a composite MBean attribute
+// When no members are defined, all the numeric members are collected as gauges.
+type JMXCompAttrib struct {
+	XMLName xml.Name        `xml:"comp-attrib"`
+	Name    string          `xml:"name,attr"`
+	Alias   string          `xml:"alias,attr"`
+	Type    string          `xml:"type,attr"`
+	Members []JMXCompMember `xml:"comp-member"`
+}
+
+// JMXMBean represents an MBean to collect
+// When the object name is a pattern, each matching MBean is collected as a resource of the given type.
+type JMXMBean struct {
+	XMLName      xml.Name        `xml:"mbean"`
+	Name         string          `xml:"name,attr"`
+	ObjectName   string          `xml:"objectname,attr"`
+	ResourceType string          `xml:"resource-type,attr"`
+	Attribs      []JMXAttrib     `xml:"attrib"`
+	CompAttribs  []JMXCompAttrib `xml:"comp-attrib"`
+}
+
+// IsPattern returns true when the object name matches multiple MBeans
+func (mbean *JMXMBean) IsPattern() bool {
+	return strings.ContainsAny(mbean.ObjectName, "*?")
+}
+
+// GetAttributeNames returns the names of all the simple and composite attributes
+func (mbean *JMXMBean) GetAttributeNames() []string {
+	names := make([]string, 0, len(mbean.Attribs)+len(mbean.CompAttribs))
+	for _, attrib := range mbean.Attribs {
+		names = append(names, attrib.Name)
+	}
+	for _, attrib := range mbean.CompAttribs {
+		names = append(names, attrib.Name)
+	}
+	return names
+}
+
+// JMXCollection represents a JMX data collection definition
+type JMXCollection struct {
+	XMLName xml.Name   `xml:"jmx-collection"`
+	Name    string     `xml:"name,attr"`
+	MBeans  []JMXMBean `xml:"mbeans>mbean"`
+}
+
+func getJMXAttributeType(attrType string) string {
+	t := strings.ToLower(attrType)
+	switch {
+	case strings.Contains(t, "string"):
+		return "string"
+	case strings.Contains(t, "counter"):
+		return "counter"
+	default:
+		return "gauge"
+	}
+}
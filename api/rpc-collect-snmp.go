@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// SNMPMibObject represents an SNMP MIB object to collect
+// The instance is either a fixed index (e.g. 0 for scalars), ifIndex for the interfaces table, or the name of a resource type for generic tables
+type SNMPMibObject struct {
+	XMLName  xml.Name `xml:"mibObj"`
+	OID      string   `xml:"oid,attr"`
+	Instance string   `xml:"instance,attr"`
+	Alias    string   `xml:"alias,attr"`
+	Type     string   `xml:"type,attr"`
+}
+
+// IsScalar returns true when the object has a fixed instance
+func (obj *SNMPMibObject) IsScalar() bool {
+	if obj.Instance == "" {
+		return false
+	}
+	for _, c := range obj.Instance {
+		if (c < '0' || c > '9') && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// GetAttributeType returns the type of the collection attribute: string, counter, or gauge
+func (obj *SNMPMibObject) GetAttributeType() string {
+	t := strings.ToLower(obj.Type)
+	switch {
+	case strings.Contains(t, "string"):
+		return "string"
+	case strings.Contains(t, "counter"):
+		return "counter"
+	default:
+		return "gauge"
+	}
+}
+
+// SNMPGroup represents a group of SNMP MIB objects
+type SNMPGroup struct {
+	XMLName    xml.Name        `xml:"group"`
+	Name       string          `xml:"name,attr"`
+	MibObjects []SNMPMibObject `xml:"mibObj"`
+}
+
+// SNMPCollection represents an SNMP data collection definition
+type SNMPCollection struct {
+	XMLName xml.Name    `xml:"snmp-collection"`
+	Name    string      `xml:"name,attr"`
+	Groups  []SNMPGroup `xml:"groups>group"`
+}
@@ -71,6 +71,47 @@ func (resource *CollectionResourceDTO) AddAttribute(attr ResourceAttributeDTO) {
 	}
 }
 
+// Verifies the resource type carries what OpenNMS needs to build the path of the resource
+func (resource *CollectionResourceDTO) validate() error {
+	switch t := resource.ResourceType.(type) {
+	case *NodeLevelResourceDTO:
+		return nil
+	case *InterfaceLevelResourceDTO:
+		if t.IntfName == "" {
+			return fmt.Errorf("cannot persist an interface resource without an interface name")
+		}
+		return nil
+	case *GenericTypeResourceDTO:
+		if t.Name == "" {
+			return fmt.Errorf("cannot persist a generic resource without a resource type")
+		}
+		if t.Instance == "" {
+			return fmt.Errorf("cannot persist a resource of type %s without an instance", t.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot persist a resource with an unknown resource type %T", resource.ResourceType)
+}
+
+// NewNodeResource returns a node level resource for the given agent
+func NewNodeResource(agent *CollectionAgentDTO) *CollectionResourceDTO {
+	return &CollectionResourceDTO{ResourceType: &NodeLevelResourceDTO{NodeID: agent.NodeID}}
+}
+
+// NewInterfaceResource returns an interface level resource for the given agent (for SNMP, the interface name is its ifIndex)
+func NewInterfaceResource(agent *CollectionAgentDTO, ifName string) *CollectionResourceDTO {
+	return &CollectionResourceDTO{
+		ResourceType: &InterfaceLevelResourceDTO{Node: &NodeLevelResourceDTO{NodeID: agent.NodeID}, IntfName: ifName},
+	}
+}
+
+// NewGenericResource returns a resource of a generic resource type (as defined in the OpenNMS datacollection configuration) for the given agent
+func NewGenericResource(agent *CollectionAgentDTO, resourceType string, instance string) *CollectionResourceDTO {
+	return &CollectionResourceDTO{
+		ResourceType: &GenericTypeResourceDTO{Node: &NodeLevelResourceDTO{NodeID: agent.NodeID}, Name: resourceType, Instance: instance},
+	}
+}
+
 // CollectionSetDTO represents a collection set
 type CollectionSetDTO struct {
 	XMLName                   xml.Name                `xml:"collection-set"`
@@ -149,10 +190,29 @@ func (req *CollectorRequestDTO) GetAttributeValue(key string, defaultValue strin
 	return defaultValue
 }
 
+// GetAttributeValueAsInt gets the value of a given attribute as an integer; returns the default value when it doesn't exist or is invalid
+func (req *CollectorRequestDTO) GetAttributeValueAsInt(key string, defaultValue int) int {
+	if v, err := strconv.Atoi(req.GetAttributeValue(key, "")); err == nil {
+		return v
+	}
+	return defaultValue
+}
+
+// GetParameters gets the attributes of the request merged over the given module defaults
+func (req *CollectorRequestDTO) GetParameters(defaults map[string]string) *Parameters {
+	values := make(map[string]string, len(req.Attributes))
+	for _, attr := range req.Attributes {
+		if _, ok := values[strings.ToLower(attr.Key)]; !ok {
+			values[strings.ToLower(attr.Key)] = req.GetAttributeValue(attr.Key, "")
+		}
+	}
+	return NewParameters(values, defaults)
+}
+
 // GetTimeout extracts the duration of the timeout attribute if available; otherwise returns default value
 func (req *CollectorRequestDTO) GetTimeout() time.Duration {
 	if value := req.GetAttributeValue("timeout", ""); value != "" {
-		if t, err := strconv.Atoi(value); err != nil {
+		if t, err := strconv.Atoi(value); err == nil && t > 0 {
 			return time.Duration(t) * time.Millisecond
 		}
 	}
@@ -173,6 +233,15 @@ func (set *CollectorResponseDTO) MarkAsFailed(agent *CollectionAgentDTO, err err
 	set.Error = err.Error()
 }
 
+// SetCollectionSet builds the collection set, marking the response as failed when OpenNMS cannot persist it (see CollectionSetBuilder.Validate)
+func (set *CollectorResponseDTO) SetCollectionSet(builder *CollectionSetBuilder) {
+	if err := builder.Validate(); err != nil {
+		set.MarkAsFailed(builder.Agent, err)
+		return
+	}
+	set.CollectionSet = builder.Build()
+}
+
 // GetStatus returns the collection status as a string
 func (set *CollectorResponseDTO) GetStatus() string {
 	if set.CollectionSet == nil {
@@ -240,6 +309,28 @@ func (builder *CollectionSetBuilder) WithMetric(resource *CollectionResourceDTO,
 	return builder
 }
 
+// Validate verifies that OpenNMS can persist the collected attributes.
+// Every attribute requires a group, a name and a type, and every resource requires a resource type; generic resources also require the name of their type and an instance, and interface resources the name of the interface.
+func (builder *CollectionSetBuilder) Validate() error {
+	for cres, attribs := range builder.attributesByResource {
+		if err := cres.validate(); err != nil {
+			return err
+		}
+		for _, attr := range attribs {
+			if attr.Name == "" {
+				return fmt.Errorf("cannot persist an attribute of group %s without a name", attr.Group)
+			}
+			if attr.Group == "" {
+				return fmt.Errorf("cannot persist attribute %s without a group", attr.Name)
+			}
+			if attr.Type == "" {
+				return fmt.Errorf("cannot persist attribute %s of group %s without a type", attr.Name, attr.Group)
+			}
+		}
+	}
+	return nil
+}
+
 // Build generates the collection set
 func (builder *CollectionSetBuilder) Build() *CollectionSetDTO {
 	cs := &CollectionSetDTO{
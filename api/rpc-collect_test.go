@@ -39,3 +39,40 @@ func TestCollectionSetBuilder(t *testing.T) {
 	fmt.Println(string(bytes))
 	assert.Equal(t, 3, len(cs.Resources))
 }
+
+func TestCollectionSetBuilderValidate(t *testing.T) {
+	agent := &CollectionAgentDTO{NodeID: 1, IPAddress: "10.0.0.1"}
+	builder := NewCollectionSetBuilder(agent)
+	builder.WithAttribute(NewNodeResource(agent), "mib2-tcp", "tcpActiveOpens", "100", "counter")
+	builder.WithAttribute(NewInterfaceResource(agent, "2"), "mib2-interfaces", "ifInOctets", "1000", "counter")
+	builder.WithAttribute(NewGenericResource(agent, "hrStorageIndex", "1"), "mib2-host-resources-storage", "hrStorageUsed", "50", "gauge")
+	assert.NilError(t, builder.Validate())
+	response := &CollectorResponseDTO{}
+	response.SetCollectionSet(builder)
+	assert.Equal(t, CollectionStatusSucceded, response.CollectionSet.Status)
+	assert.Equal(t, 3, len(response.CollectionSet.Resources))
+
+	builder = NewCollectionSetBuilder(agent)
+	builder.WithAttribute(NewGenericResource(agent, "hrStorageIndex", ""), "mib2-host-resources-storage", "hrStorageUsed", "50", "gauge")
+	assert.Error(t, builder.Validate(), "cannot persist a resource of type hrStorageIndex without an instance")
+
+	builder = NewCollectionSetBuilder(agent)
+	builder.WithAttribute(NewGenericResource(agent, "", "1"), "mib2-host-resources-storage", "hrStorageUsed", "50", "gauge")
+	assert.Error(t, builder.Validate(), "cannot persist a generic resource without a resource type")
+
+	builder = NewCollectionSetBuilder(agent)
+	builder.WithAttribute(NewNodeResource(agent), "", "tcpActiveOpens", "100", "counter")
+	assert.Error(t, builder.Validate(), "cannot persist attribute tcpActiveOpens without a group")
+	response = &CollectorResponseDTO{}
+	response.SetCollectionSet(builder)
+	assert.Equal(t, CollectionStatusFailed, response.CollectionSet.Status)
+	assert.Equal(t, "cannot persist attribute tcpActiveOpens without a group", response.Error)
+
+	builder = NewCollectionSetBuilder(agent)
+	builder.WithAttribute(NewNodeResource(agent), "mib2-tcp", "tcpActiveOpens", "100", "")
+	assert.Error(t, builder.Validate(), "cannot persist attribute tcpActiveOpens of group mib2-tcp without a type")
+
+	builder = NewCollectionSetBuilder(agent)
+	builder.WithAttribute(&CollectionResourceDTO{}, "mib2-tcp", "tcpActiveOpens", "100", "counter")
+	assert.ErrorContains(t, builder.Validate(), "unknown resource type")
+}
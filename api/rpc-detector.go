@@ -48,18 +48,26 @@ func (req *DetectorRequestDTO) GetTimeout() time.Duration {
 // GetRetries extracts the retries attribute if available; otherwise returns default value
 func (req *DetectorRequestDTO) GetRetries() int {
 	if value := req.GetAttributeValue("retries", ""); value != "" {
-		if t, err := strconv.Atoi(value); err != nil {
+		if t, err := strconv.Atoi(value); err == nil {
 			return t
 		}
 	}
 	return DefaultRetries
 }
 
+// GetRetryInterval extracts the duration of the retry-interval attribute if available; otherwise there is no wait between retries
+func (req *DetectorRequestDTO) GetRetryInterval() time.Duration {
+	if value := req.GetAttributeValueAsInt("retry-interval"); value > 0 {
+		return time.Duration(value) * time.Millisecond
+	}
+	return 0
+}
+
 // GetAttributeValue extract the value of a given detector attribute
 func (req *DetectorRequestDTO) GetAttributeValue(key string, defaultValue string) string {
 	if req.DetectorAttributes != nil && len(req.DetectorAttributes) > 0 {
 		for _, attr := range req.DetectorAttributes {
-			if strings.ToLower(attr.Key) == key {
+			if strings.EqualFold(attr.Key, key) {
 				return attr.Value
 			}
 		}
@@ -77,11 +85,22 @@ func (req *DetectorRequestDTO) GetAttributeValueAsInt(key string) int {
 	return 0
 }
 
+// GetParameters gets the detector attributes of the request merged over the given module defaults
+func (req *DetectorRequestDTO) GetParameters(defaults map[string]string) *Parameters {
+	values := make(map[string]string, len(req.DetectorAttributes))
+	for _, attr := range req.DetectorAttributes {
+		if _, ok := values[strings.ToLower(attr.Key)]; !ok {
+			values[strings.ToLower(attr.Key)] = attr.Value
+		}
+	}
+	return NewParameters(values, defaults)
+}
+
 // GetRuntimeAttributeValue extract the value of a given runtime attribute
 func (req *DetectorRequestDTO) GetRuntimeAttributeValue(key string) string {
 	if req.RuntimeAttributes != nil && len(req.RuntimeAttributes) > 0 {
 		for _, attr := range req.RuntimeAttributes {
-			if strings.ToLower(attr.Key) == key {
+			if strings.EqualFold(attr.Key, key) {
 				return attr.Value
 			}
 		}
@@ -86,17 +86,14 @@ func (status *PollStatus) SetProperty(key string, value float64) {
 	if status.Properties == nil {
 		status.Properties = &PollStatusPropertyList{}
 	}
-	found := false
-	for _, p := range status.Properties.PropertyList {
+	for i, p := range status.Properties.PropertyList {
 		if p.Key == key {
-			p.Value = value
-			found = true
+			status.Properties.PropertyList[i].Value = value
+			return
 		}
 	}
-	if !found {
-		p := PollStatusProperty{Key: key, Value: value}
-		status.Properties.PropertyList = append(status.Properties.PropertyList, p)
-	}
+	p := PollStatusProperty{Key: key, Value: value}
+	status.Properties.PropertyList = append(status.Properties.PropertyList, p)
 }
 
 // GetPropertyValue adds or updates an existing property
@@ -136,23 +133,33 @@ type PollerRequestDTO struct {
 // GetTimeout extracts the duration of the timeout attribute if available; otherwise returns default value
 func (req *PollerRequestDTO) GetTimeout() time.Duration {
 	if value := req.GetAttributeValue("timeout", ""); value != "" {
-		if t, err := strconv.Atoi(value); err != nil {
-			return time.Duration(t) * time.Microsecond
+		if t, err := strconv.Atoi(value); err == nil && t > 0 {
+			return time.Duration(t) * time.Millisecond
 		}
 	}
 	return DefaultTimeout
 }
 
-// GetRetries extracts the retries attribute if available; otherwise returns default value
+// GetRetries extracts the retry (or retries) attribute if available; otherwise returns default value
 func (req *PollerRequestDTO) GetRetries() int {
-	if value := req.GetAttributeValue("retries", ""); value != "" {
-		if t, err := strconv.Atoi(value); err != nil {
-			return t
+	for _, key := range []string{"retry", "retries"} {
+		if value := req.GetAttributeValue(key, ""); value != "" {
+			if t, err := strconv.Atoi(value); err == nil && t >= 0 {
+				return t
+			}
 		}
 	}
 	return DefaultRetries
 }
 
+// GetRetryInterval extracts the duration of the retry-interval attribute if available; otherwise there is no wait between retries
+func (req *PollerRequestDTO) GetRetryInterval() time.Duration {
+	if value := req.GetAttributeValueAsInt("retry-interval", 0); value > 0 {
+		return time.Duration(value) * time.Millisecond
+	}
+	return 0
+}
+
 // GetMonitor returns the simple class name for the monitor implementation
 func (req *PollerRequestDTO) GetMonitor() string {
 	if req.ClassName == "" {
@@ -166,7 +173,7 @@ func (req *PollerRequestDTO) GetMonitor() string {
 func (req *PollerRequestDTO) GetAttributeValue(key string, defaultValue string) string {
 	if req.Attributes != nil && len(req.Attributes) > 0 {
 		for _, attr := range req.Attributes {
-			if strings.ToLower(attr.Key) == key {
+			if strings.EqualFold(attr.Key, key) {
 				return attr.Value
 			}
 		}
@@ -177,17 +184,28 @@ func (req *PollerRequestDTO) GetAttributeValue(key string, defaultValue string)
 // GetAttributeValueAsInt gets the value of a given attribute as integer
 func (req *PollerRequestDTO) GetAttributeValueAsInt(key string, defaultValue int) int {
 	value := req.GetAttributeValue(key, strconv.Itoa(defaultValue))
-	if v, err := strconv.Atoi(value); err != nil {
+	if v, err := strconv.Atoi(value); err == nil {
 		return v
 	}
 	return defaultValue
 }
 
+// GetParameters gets the attributes of the request merged over the given module defaults
+func (req *PollerRequestDTO) GetParameters(defaults map[string]string) *Parameters {
+	values := make(map[string]string, len(req.Attributes))
+	for _, attr := range req.Attributes {
+		if _, ok := values[strings.ToLower(attr.Key)]; !ok {
+			values[strings.ToLower(attr.Key)] = attr.Value
+		}
+	}
+	return NewParameters(values, defaults)
+}
+
 // GetAttributeContent gets the value of a given attribute
 func (req *PollerRequestDTO) GetAttributeContent(key string) string {
 	if req.Attributes != nil && len(req.Attributes) > 0 {
 		for _, attr := range req.Attributes {
-			if strings.ToLower(attr.Key) == key {
+			if strings.EqualFold(attr.Key, key) {
 				return attr.Content
 			}
 		}
@@ -41,6 +41,10 @@ func TestPollerRequest(t *testing.T) {
 	assert.Equal(t, "SNMP", request.ServiceName)
 	assert.Equal(t, "SnmpMonitor", request.GetMonitor())
 	assert.Equal(t, ".1.3.6.1.2.1.1.2.0", request.GetAttributeValue("oid", ""))
+	assert.Equal(t, int64(5000), request.GetTimeout().Milliseconds())
+	assert.Equal(t, 3, request.GetRetries())
+	assert.Equal(t, 5000, request.GetAttributeValueAsInt("timeout", 0))
+	assert.Equal(t, 10, request.GetAttributeValueAsInt("unknown", 10))
 
 	// Parse and validate SNMP Agent
 	agent := &SNMPAgentDTO{}
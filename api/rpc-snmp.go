@@ -1,7 +1,9 @@
 package api
 
 import (
+	"encoding/hex"
 	"encoding/xml"
+	"strings"
 	"time"
 
 	"github.com/gosnmp/gosnmp"
@@ -92,9 +94,12 @@ func (agent *SNMPAgentDTO) GetSNMPClient() SNMPHandler {
 		MaxRepetitions:     uint32(agent.MaxRepetitions),
 	}
 	if agent.Version == 3 {
+		if user := GetSNMPv3User(agent.SecurityName); user != nil {
+			user.Apply(agent)
+		}
 		session.SecurityModel = gosnmp.UserSecurityModel
-		session.MsgFlags = agent.getV3Flags()
-		session.SecurityParameters = agent.getSecurityParameters()
+		session.MsgFlags = agent.GetV3Flags()
+		session.SecurityParameters = agent.GetSecurityParameters()
 	}
 	return &SNMPClient{snmp: session}
 }
@@ -112,7 +117,8 @@ func (agent *SNMPAgentDTO) getVersion() gosnmp.SnmpVersion {
 	}
 }
 
-func (agent *SNMPAgentDTO) getV3Flags() gosnmp.SnmpV3MsgFlags {
+// GetV3Flags gets the SNMPv3 message flags based on the security level
+func (agent *SNMPAgentDTO) GetV3Flags() gosnmp.SnmpV3MsgFlags {
 	switch agent.SecurityLevel {
 	case 3:
 		return gosnmp.AuthPriv
@@ -151,10 +157,16 @@ func (agent *SNMPAgentDTO) getPrivProtocol() gosnmp.SnmpV3PrivProtocol {
 	}
 }
 
-func (agent *SNMPAgentDTO) getSecurityParameters() *gosnmp.UsmSecurityParameters {
+// GetSecurityParameters gets the SNMPv3 USM parameters based on the security level
+func (agent *SNMPAgentDTO) GetSecurityParameters() *gosnmp.UsmSecurityParameters {
 	params := &gosnmp.UsmSecurityParameters{
 		UserName: agent.SecurityName,
 	}
+	if agent.EngineID != "" {
+		if engineID, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(agent.EngineID), "0x")); err == nil {
+			params.AuthoritativeEngineID = string(engineID)
+		}
+	}
 	if agent.SecurityLevel > 1 {
 		params.AuthenticationPassphrase = agent.AuthPassPhrase
 		params.AuthenticationProtocol = agent.getAuthProtocol()
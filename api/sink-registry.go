@@ -2,6 +2,9 @@ package api
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 )
 
 // SinkRegistry tracks all the enabled Sink module instances for a given broker.
@@ -33,8 +36,31 @@ func (r *SinkRegistry) GetAllModules() []SinkModule {
 	return modules
 }
 
+// GetSupportedParsers gets the sorted list of parsers supported by the registered Sink modules
+func (r *SinkRegistry) GetSupportedParsers() []string {
+	parsers := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range r.sinkRegistryMap {
+		if lm, ok := m.(ListenerModule); ok {
+			for _, parser := range lm.GetParsers() {
+				if !seen[parser] {
+					seen[parser] = true
+					parsers = append(parsers, parser)
+				}
+			}
+		}
+	}
+	sort.Strings(parsers)
+	return parsers
+}
+
 // StartModules starts all the registered Sink modules (non-blocking method)
 func (r *SinkRegistry) StartModules(config *MinionConfig, sink Sink) error {
+	ids := make([]string, 0, len(r.sinkRegistryMap))
+	for id := range r.sinkRegistryMap {
+		ids = append(ids, id)
+	}
+	setSinkModules(ids)
 	for _, m := range r.sinkRegistryMap {
 		if err := m.Start(config, sink); err != nil {
 			return fmt.Errorf("cannot start Sink API module %s: %v", m.GetID(), err)
@@ -49,3 +75,75 @@ func (r *SinkRegistry) StopModules() {
 		m.Stop()
 	}
 }
+
+// RestartModules stops the given Sink modules, and starts them again with the new configuration
+func (r *SinkRegistry) RestartModules(ids []string, config *MinionConfig, sink Sink) error {
+	for _, id := range ids {
+		m, ok := r.sinkRegistryMap[id]
+		if !ok {
+			continue
+		}
+		m.Stop()
+		ClearModuleFailure(id)
+		if err := m.Start(config, sink); err != nil {
+			return fmt.Errorf("cannot restart Sink API module %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// GetAffectedModules gets the sorted IDs of the Sink modules affected by the differences between two configurations.
+// A module is affected when a listener named after it, or using one of its parsers, was added, removed or changed.
+// Changes to the settings shared by the receivers (bindAddress, the Trap and Syslog settings, the DNS settings, and maxListenerWorkers) affect all the modules.
+// When maxListenerWorkers is set, any listener change affects all the modules too, as the workers are shared between the listeners.
+func (r *SinkRegistry) GetAffectedModules(oldConfig *MinionConfig, newConfig *MinionConfig) []string {
+	shared := func(cfg *MinionConfig) []interface{} {
+		return []interface{}{cfg.BindAddress, cfg.TrapPort, cfg.SyslogPort, cfg.SyslogBufferSize, cfg.DNS, cfg.MaxListenerWorkers}
+	}
+	sharedChanged := !reflect.DeepEqual(shared(oldConfig), shared(newConfig))
+	if newConfig.MaxListenerWorkers > 0 && !reflect.DeepEqual(oldConfig.Listeners, newConfig.Listeners) {
+		sharedChanged = true
+	}
+	ids := make([]string, 0)
+	for id, m := range r.sinkRegistryMap {
+		if sharedChanged || !reflect.DeepEqual(r.getModuleListeners(m, oldConfig), r.getModuleListeners(m, newConfig)) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Gets the listeners named after the module, or using one of its parsers (unless they are named after another module)
+func (r *SinkRegistry) getModuleListeners(module SinkModule, config *MinionConfig) []MinionListener {
+	var parsers []string
+	if lm, ok := module.(ListenerModule); ok {
+		parsers = lm.GetParsers()
+	}
+	listeners := make([]MinionListener, 0)
+	for _, listener := range config.Listeners {
+		if strings.EqualFold(listener.Name, module.GetID()) {
+			listeners = append(listeners, listener)
+			continue
+		}
+		if r.isModuleID(listener.Name) {
+			continue
+		}
+		for _, parser := range parsers {
+			if listener.Is(parser) {
+				listeners = append(listeners, listener)
+				break
+			}
+		}
+	}
+	return listeners
+}
+
+func (r *SinkRegistry) isModuleID(name string) bool {
+	for id := range r.sinkRegistryMap {
+		if strings.EqualFold(id, name) {
+			return true
+		}
+	}
+	return false
+}
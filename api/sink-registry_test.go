@@ -0,0 +1,69 @@
+package api
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type mockListenerModule struct {
+	mockSinkModule
+	parser string
+	starts int
+	stops  int
+}
+
+func (module *mockListenerModule) GetParsers() []string {
+	return []string{module.parser}
+}
+
+func (module *mockListenerModule) Start(config *MinionConfig, sink Sink) error {
+	module.starts++
+	return nil
+}
+
+func (module *mockListenerModule) Stop() {
+	module.stops++
+}
+
+func TestGetAffectedModules(t *testing.T) {
+	graphite := &mockListenerModule{mockSinkModule: mockSinkModule{id: "Graphite"}, parser: "ForwardParser"}
+	nxos := &mockListenerModule{mockSinkModule: mockSinkModule{id: "NXOS"}, parser: "NxosGrpcParser"}
+	registry := &SinkRegistry{}
+	registry.Init()
+	registry.RegisterModule(graphite)
+	registry.RegisterModule(nxos)
+	registry.RegisterModule(&mockSinkModule{id: "Heartbeat"})
+
+	oldConfig := &MinionConfig{
+		TrapPort: 1162,
+		Listeners: []MinionListener{
+			{Name: "Graphite", Port: 2003, Parser: "ForwardParser"},
+			{Name: "Cisco", Port: 50001, Parser: "NxosGrpcParser"},
+		},
+	}
+	newConfig := &MinionConfig{
+		TrapPort: 1162,
+		Listeners: []MinionListener{
+			{Name: "Graphite", Port: 2003, Parser: "ForwardParser"},
+			{Name: "Cisco", Port: 50002, Parser: "NxosGrpcParser"},
+			{Name: "Heartbeat", Parser: "Heartbeat", Properties: map[string]string{"interval-ms": "5000"}},
+		},
+	}
+	assert.DeepEqual(t, []string{}, registry.GetAffectedModules(oldConfig, oldConfig))
+	assert.DeepEqual(t, []string{"Heartbeat", "NXOS"}, registry.GetAffectedModules(oldConfig, newConfig))
+
+	newConfig.TrapPort = 0
+	assert.DeepEqual(t, []string{"Graphite", "Heartbeat", "NXOS"}, registry.GetAffectedModules(oldConfig, newConfig))
+
+	newConfig.TrapPort = 1162
+	newConfig.MaxListenerWorkers = 8
+	oldConfig.MaxListenerWorkers = 8
+	assert.DeepEqual(t, []string{"Graphite", "Heartbeat", "NXOS"}, registry.GetAffectedModules(oldConfig, newConfig))
+	assert.DeepEqual(t, []string{}, registry.GetAffectedModules(oldConfig, oldConfig))
+
+	assert.NilError(t, registry.RestartModules([]string{"NXOS", "Unknown"}, newConfig, nil))
+	assert.Equal(t, 1, nxos.stops)
+	assert.Equal(t, 1, nxos.starts)
+	assert.Equal(t, 0, graphite.starts)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var snmpUsersMutex = sync.RWMutex{}
+var snmpUsers = make(map[string]SNMPv3User)
+
+// SNMPv3User represents the SNMPv3 credentials of a user defined at the Minion level
+// The SNMP modules look them up by the security-name of the requests, so the secrets don't have to be sent by OpenNMS.
+type SNMPv3User struct {
+	SecurityName   string `yaml:"securityName" json:"securityName"`
+	SecurityLevel  int    `yaml:"securityLevel,omitempty" json:"securityLevel,omitempty"`
+	AuthProtocol   string `yaml:"authProtocol,omitempty" json:"authProtocol,omitempty"`
+	AuthPassPhrase string `yaml:"authPassPhrase,omitempty" json:"-"`
+	PrivProtocol   string `yaml:"privProtocol,omitempty" json:"privProtocol,omitempty"`
+	PrivPassPhrase string `yaml:"privPassPhrase,omitempty" json:"-"`
+	EngineID       string `yaml:"engineId,omitempty" json:"engineId,omitempty"`
+}
+
+// GetSecurityLevel gets the security level of the user, inferred from the passphrases when not specified
+func (user *SNMPv3User) GetSecurityLevel() int {
+	if user.SecurityLevel > 0 {
+		return user.SecurityLevel
+	}
+	if user.PrivPassPhrase != "" {
+		return 3
+	}
+	if user.AuthPassPhrase != "" {
+		return 2
+	}
+	return 1
+}
+
+// Apply overrides the SNMPv3 credentials of a given agent with the ones from the user
+func (user *SNMPv3User) Apply(agent *SNMPAgentDTO) {
+	agent.SecurityName = user.SecurityName
+	agent.SecurityLevel = user.GetSecurityLevel()
+	agent.AuthProtocol = strings.ToUpper(user.AuthProtocol)
+	agent.AuthPassPhrase = user.AuthPassPhrase
+	agent.PrivProtocol = strings.ToUpper(user.PrivProtocol)
+	agent.PrivPassPhrase = user.PrivPassPhrase
+	if user.EngineID != "" {
+		agent.EngineID = user.EngineID
+	}
+}
+
+// IsValid returns an error if the user credentials are incomplete or inconsistent
+func (user *SNMPv3User) IsValid() error {
+	if user.SecurityName == "" {
+		return fmt.Errorf("security name required")
+	}
+	switch strings.ToUpper(user.AuthProtocol) {
+	case "", "MD5", "SHA":
+	default:
+		return fmt.Errorf("invalid auth protocol %s for user %s, expected MD5 or SHA", user.AuthProtocol, user.SecurityName)
+	}
+	switch strings.ToUpper(user.PrivProtocol) {
+	case "", "DES", "AES", "AES192", "AES256":
+	default:
+		return fmt.Errorf("invalid priv protocol %s for user %s, expected DES, AES, AES192 or AES256", user.PrivProtocol, user.SecurityName)
+	}
+	if (user.AuthProtocol == "") != (user.AuthPassPhrase == "") {
+		return fmt.Errorf("auth protocol and passphrase must be specified together for user %s", user.SecurityName)
+	}
+	if (user.PrivProtocol == "") != (user.PrivPassPhrase == "") {
+		return fmt.Errorf("priv protocol and passphrase must be specified together for user %s", user.SecurityName)
+	}
+	if user.PrivProtocol != "" && user.AuthProtocol == "" {
+		return fmt.Errorf("priv protocol requires an auth protocol for user %s", user.SecurityName)
+	}
+	level := user.GetSecurityLevel()
+	if level < 1 || level > 3 {
+		return fmt.Errorf("invalid security level %d for user %s, expected 1, 2 or 3", level, user.SecurityName)
+	}
+	if level > 1 && user.AuthPassPhrase == "" {
+		return fmt.Errorf("security level %d requires auth credentials for user %s", level, user.SecurityName)
+	}
+	if level > 2 && user.PrivPassPhrase == "" {
+		return fmt.Errorf("security level %d requires priv credentials for user %s", level, user.SecurityName)
+	}
+	return nil
+}
+
+// SetSNMPv3Users replaces the SNMPv3 users available to the SNMP modules
+func SetSNMPv3Users(users []SNMPv3User) {
+	snmpUsersMutex.Lock()
+	defer snmpUsersMutex.Unlock()
+	snmpUsers = make(map[string]SNMPv3User, len(users))
+	for _, user := range users {
+		snmpUsers[user.SecurityName] = user
+	}
+}
+
+// GetSNMPv3User gets the SNMPv3 user for a given security name; returns nil when it doesn't exist
+func GetSNMPv3User(securityName string) *SNMPv3User {
+	snmpUsersMutex.RLock()
+	defer snmpUsersMutex.RUnlock()
+	if user, ok := snmpUsers[securityName]; ok {
+		return &user
+	}
+	return nil
+}
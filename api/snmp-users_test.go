@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestSNMPv3UserIsValid(t *testing.T) {
+	user := &SNMPv3User{SecurityName: "opennms", AuthProtocol: "SHA", AuthPassPhrase: "secret1"}
+	assert.NilError(t, user.IsValid())
+	assert.Equal(t, 2, user.GetSecurityLevel())
+
+	user.PrivProtocol = "AES"
+	assert.ErrorContains(t, user.IsValid(), "priv protocol and passphrase must be specified together")
+	user.PrivPassPhrase = "secret2"
+	assert.NilError(t, user.IsValid())
+	assert.Equal(t, 3, user.GetSecurityLevel())
+
+	user.AuthProtocol = "SHA1024"
+	assert.ErrorContains(t, user.IsValid(), "invalid auth protocol")
+
+	user = &SNMPv3User{SecurityName: "opennms", SecurityLevel: 3, AuthProtocol: "MD5", AuthPassPhrase: "secret1"}
+	assert.ErrorContains(t, user.IsValid(), "security level 3 requires priv credentials")
+
+	cfg := &MinionConfig{ID: "minion1", Location: "Test", BrokerURL: "localhost:8990", SnmpV3Users: []SNMPv3User{
+		{SecurityName: "opennms"},
+		{SecurityName: "opennms", AuthProtocol: "MD5", AuthPassPhrase: "secret1"},
+	}}
+	assert.ErrorContains(t, cfg.IsValid(), "duplicate SNMPv3 user opennms")
+}
+
+func TestSNMPv3UserLookup(t *testing.T) {
+	SetSNMPv3Users([]SNMPv3User{{SecurityName: "opennms", AuthProtocol: "sha", AuthPassPhrase: "secret1", EngineID: "0x8000000001020304"}})
+	defer SetSNMPv3Users(nil)
+	assert.Assert(t, GetSNMPv3User("unknown") == nil)
+
+	agent := &SNMPAgentDTO{Address: "127.0.0.1", Port: 161, Version: 3, SecurityName: "opennms"}
+	client := agent.GetSNMPClient().(*SNMPClient)
+	assert.Equal(t, gosnmp.AuthNoPriv, client.snmp.MsgFlags)
+	usm := client.snmp.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	assert.Equal(t, gosnmp.SHA, usm.AuthenticationProtocol)
+	assert.Equal(t, "secret1", usm.AuthenticationPassphrase)
+	assert.Equal(t, "\x80\x00\x00\x00\x01\x02\x03\x04", usm.AuthoritativeEngineID)
+
+	agent = &SNMPAgentDTO{Address: "127.0.0.1", Port: 161, Version: 2, SecurityName: "opennms", ReadCommunity: "public"}
+	client = agent.GetSNMPClient().(*SNMPClient)
+	assert.Assert(t, client.snmp.SecurityParameters == nil)
+}
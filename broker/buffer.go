@@ -0,0 +1,97 @@
+package broker
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+)
+
+// The modules whose messages are buffered when sink-buffer-modules is not specified
+const defaultSinkBufferModules = "Heartbeat,Syslog,Trap"
+
+// sinkBuffer retains the Sink messages that couldn't be delivered for a subset of modules, so they can be resent later.
+// It is bounded; when full, the oldest message is dropped.
+type sinkBuffer struct {
+	size     int
+	modules  map[string]bool
+	messages []*ipc.SinkMessage
+	mutex    *sync.Mutex
+	metrics  *api.Metrics
+}
+
+// Creates a new Sink buffer for the given comma-separated list of module IDs
+func newSinkBuffer(size int, modules string, metrics *api.Metrics) *sinkBuffer {
+	buffer := &sinkBuffer{
+		size:    size,
+		modules: make(map[string]bool),
+		mutex:   new(sync.Mutex),
+		metrics: metrics,
+	}
+	for _, id := range strings.Split(modules, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			buffer.modules[id] = true
+		}
+	}
+	return buffer
+}
+
+// Accepts returns true when the messages of a given module should be buffered
+func (buffer *sinkBuffer) Accepts(moduleID string) bool {
+	return buffer.modules[moduleID]
+}
+
+// Add appends a message to the buffer, dropping the oldest one when full
+func (buffer *sinkBuffer) Add(msg *ipc.SinkMessage) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	if len(buffer.messages) >= buffer.size {
+		dropped := buffer.messages[0]
+		buffer.messages = buffer.messages[1:]
+		buffer.metrics.SinkMsgBufferDropped.WithLabelValues(dropped.SystemId, dropped.ModuleId).Inc()
+	}
+	buffer.messages = append(buffer.messages, msg)
+	buffer.metrics.SinkMsgBuffered.Set(float64(len(buffer.messages)))
+}
+
+// Replay sends the buffered messages in order, until the buffer is empty or a message cannot be sent.
+// The message that failed is kept at the head of the buffer. Returns the number of messages sent.
+func (buffer *sinkBuffer) Replay(send func(msg *ipc.SinkMessage) error) int {
+	sent := 0
+	for {
+		buffer.mutex.Lock()
+		if len(buffer.messages) == 0 {
+			buffer.mutex.Unlock()
+			return sent
+		}
+		msg := buffer.messages[0]
+		buffer.messages = buffer.messages[1:]
+		buffer.metrics.SinkMsgBuffered.Set(float64(len(buffer.messages)))
+		buffer.mutex.Unlock()
+		if err := send(msg); err != nil {
+			buffer.pushFront(msg)
+			return sent
+		}
+		sent++
+	}
+}
+
+// Len returns the number of buffered messages
+func (buffer *sinkBuffer) Len() int {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return len(buffer.messages)
+}
+
+// Puts back a message at the head of the buffer, unless it was filled in the meantime
+func (buffer *sinkBuffer) pushFront(msg *ipc.SinkMessage) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	if len(buffer.messages) >= buffer.size {
+		buffer.metrics.SinkMsgBufferDropped.WithLabelValues(msg.SystemId, msg.ModuleId).Inc()
+		return
+	}
+	buffer.messages = append([]*ipc.SinkMessage{msg}, buffer.messages...)
+	buffer.metrics.SinkMsgBuffered.Set(float64(len(buffer.messages)))
+}
@@ -0,0 +1,46 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/v3/assert"
+)
+
+func TestSinkBuffer(t *testing.T) {
+	metrics := api.NewMetrics()
+	buffer := newSinkBuffer(2, "Heartbeat, Syslog", metrics)
+	assert.Assert(t, buffer.Accepts("Syslog"))
+	assert.Assert(t, !buffer.Accepts("NetFlow"))
+
+	for i := 1; i <= 3; i++ {
+		buffer.Add(&ipc.SinkMessage{MessageId: fmt.Sprintf("msg%d", i), SystemId: "minion1", ModuleId: "Syslog"})
+	}
+	assert.Equal(t, 2, buffer.Len())
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.SinkMsgBufferDropped.WithLabelValues("minion1", "Syslog")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.SinkMsgBuffered))
+
+	// The message that cannot be sent stays at the head of the buffer
+	sent := make([]string, 0)
+	failing := func(msg *ipc.SinkMessage) error {
+		if msg.MessageId == "msg3" {
+			return fmt.Errorf("server unreachable")
+		}
+		sent = append(sent, msg.MessageId)
+		return nil
+	}
+	assert.Equal(t, 1, buffer.Replay(failing))
+	assert.DeepEqual(t, []string{"msg2"}, sent)
+	assert.Equal(t, 1, buffer.Len())
+
+	assert.Equal(t, 1, buffer.Replay(func(msg *ipc.SinkMessage) error {
+		sent = append(sent, msg.MessageId)
+		return nil
+	}))
+	assert.DeepEqual(t, []string{"msg2", "msg3"}, sent)
+	assert.Equal(t, 0, buffer.Len())
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.SinkMsgBuffered))
+}
@@ -0,0 +1,113 @@
+package broker
+
+import (
+	"math"
+
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/protobuf/ipc"
+	"github.com/agalue/gominion/protobuf/rpc"
+	"github.com/agalue/gominion/protobuf/sink"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// chunkBuffer reassembles the RPC requests that OpenNMS splits in chunks when using Kafka or NATS.
+// It is not thread-safe, as the requests are consumed sequentially.
+type chunkBuffer struct {
+	msgBuffer    map[string][]byte
+	chunkTracker map[string]int32
+}
+
+// Creates a new chunk buffer
+func newChunkBuffer() *chunkBuffer {
+	return &chunkBuffer{
+		msgBuffer:    make(map[string][]byte),
+		chunkTracker: make(map[string]int32),
+	}
+}
+
+// Adds a chunk of an RPC request; returns the content of the request and true after receiving the last chunk
+func (buffer *chunkBuffer) add(request *rpc.RpcMessageProto) ([]byte, bool) {
+	chunk := request.CurrentChunkNumber + 1 // Chunks starts at 0
+	log.Debugf("%s RPC chunk %d of %d for %s received", request.ModuleId, chunk, request.TotalChunks, request.RpcId)
+	if chunk != request.TotalChunks {
+		if buffer.chunkTracker[request.RpcId] < chunk {
+			// Adds partial message to the buffer
+			buffer.msgBuffer[request.RpcId] = append(buffer.msgBuffer[request.RpcId], request.RpcContent...)
+			buffer.chunkTracker[request.RpcId] = chunk
+		} else {
+			log.Warnf("Chunk %d from %s was already processed, ignoring...", chunk, request.RpcId)
+		}
+		return nil, false
+	}
+	// Retrieve the complete message from the buffer
+	var data []byte
+	if request.TotalChunks == 1 { // Handle special case chunk == total == 1
+		data = request.RpcContent
+	} else {
+		data = append(buffer.msgBuffer[request.RpcId], request.RpcContent...)
+	}
+	delete(buffer.msgBuffer, request.RpcId)
+	delete(buffer.chunkTracker, request.RpcId)
+	return data, true
+}
+
+// Gets the number of chunks required to send the data; there are no chunks when the maximum buffer size is zero
+func getTotalChunks(data []byte, maxBufferSize int) int32 {
+	if maxBufferSize == 0 {
+		return int32(1)
+	}
+	chunks := int32(math.Ceil(float64(len(data) / maxBufferSize)))
+	if len(data)%maxBufferSize > 0 {
+		chunks++
+	}
+	return chunks
+}
+
+// Gets the size of a given chunk
+func getRemainingBufferSize(messageSize, chunk int32, maxBufferSize int) int32 {
+	if maxBufferSize > 0 && messageSize > int32(maxBufferSize) {
+		remaining := messageSize - chunk*int32(maxBufferSize)
+		if remaining > int32(maxBufferSize) {
+			return int32(maxBufferSize)
+		}
+		return remaining
+	}
+	return messageSize
+}
+
+// Wraps a chunk of a Sink message
+func wrapMessageToSink(request *ipc.SinkMessage, chunk, totalChunks int32, maxBufferSize int) []byte {
+	bufferSize := getRemainingBufferSize(int32(len(request.Content)), chunk, maxBufferSize)
+	offset := chunk * int32(maxBufferSize)
+	msg := request.Content[offset : offset+bufferSize]
+	sinkMsg := &sink.SinkMessage{
+		MessageId:          request.MessageId,
+		CurrentChunkNumber: chunk,
+		TotalChunks:        totalChunks,
+		Content:            msg,
+	}
+	bytes, err := proto.Marshal(sinkMsg)
+	if err != nil {
+		return []byte{}
+	}
+	return bytes
+}
+
+// Wraps a chunk of an RPC response
+func wrapMessageToRPC(response *ipc.RpcResponseProto, chunk, totalChunks int32, maxBufferSize int) []byte {
+	bufferSize := getRemainingBufferSize(int32(len(response.RpcContent)), chunk, maxBufferSize)
+	offset := chunk * int32(maxBufferSize)
+	msg := response.RpcContent[offset : offset+bufferSize]
+	rpcMsg := &rpc.RpcMessageProto{
+		RpcId:              response.RpcId,
+		RpcContent:         msg,
+		CurrentChunkNumber: chunk,
+		TotalChunks:        totalChunks,
+	}
+	bytes, err := proto.Marshal(rpcMsg)
+	if err != nil {
+		return []byte{}
+	}
+	return bytes
+}
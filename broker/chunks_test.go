@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/agalue/gominion/protobuf/ipc"
+	"github.com/agalue/gominion/protobuf/rpc"
+	"github.com/agalue/gominion/protobuf/sink"
+
+	"google.golang.org/protobuf/proto"
+	"gotest.tools/v3/assert"
+)
+
+func TestChunks(t *testing.T) {
+	assert.Equal(t, int32(1), getTotalChunks([]byte("0123456789"), 0))
+	assert.Equal(t, int32(1), getTotalChunks([]byte("0123456789"), 10))
+	assert.Equal(t, int32(3), getTotalChunks([]byte("0123456789"), 4))
+
+	msg := &ipc.SinkMessage{MessageId: "001", Content: []byte("0123456789")}
+	content := []byte{}
+	for chunk := int32(0); chunk < 3; chunk++ {
+		sinkMsg := &sink.SinkMessage{}
+		assert.NilError(t, proto.Unmarshal(wrapMessageToSink(msg, chunk, 3, 4), sinkMsg))
+		assert.Equal(t, chunk, sinkMsg.CurrentChunkNumber)
+		content = append(content, sinkMsg.Content...)
+	}
+	assert.Equal(t, "0123456789", string(content))
+}
+
+func TestChunkBuffer(t *testing.T) {
+	buffer := newChunkBuffer()
+	response := &ipc.RpcResponseProto{RpcId: "001", RpcContent: []byte("0123456789")}
+	var data []byte
+	var complete bool
+	for chunk := int32(0); chunk < 3; chunk++ {
+		request := &rpc.RpcMessageProto{}
+		assert.NilError(t, proto.Unmarshal(wrapMessageToRPC(response, chunk, 3, 4), request))
+		data, complete = buffer.add(request)
+		assert.Equal(t, chunk == 2, complete)
+		if chunk == 0 {
+			// Repeated chunks are ignored
+			_, complete = buffer.add(request)
+			assert.Assert(t, !complete)
+		}
+	}
+	assert.Equal(t, "0123456789", string(data))
+	assert.Equal(t, 0, len(buffer.msgBuffer))
+	assert.Equal(t, 0, len(buffer.chunkTracker))
+}
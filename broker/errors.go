@@ -0,0 +1,51 @@
+package broker
+
+import (
+	"errors"
+)
+
+// The kinds of broker failures, to be checked with errors.Is
+var (
+	// ErrInvalidConfig is returned when the broker properties are invalid; retrying won't help
+	ErrInvalidConfig = errors.New("invalid broker configuration")
+	// ErrTLSConfig is returned when the TLS certificates or keys cannot be loaded; retrying won't help
+	ErrTLSConfig = errors.New("invalid TLS configuration")
+	// ErrBrokerUnreachable is returned when the broker cannot be reached, or the connection is not ready
+	ErrBrokerUnreachable = errors.New("broker unreachable")
+	// ErrStreamClosed is returned when a stream cannot be opened, or it was closed by the server
+	ErrStreamClosed = errors.New("stream closed")
+)
+
+// Error represents a broker failure of a given kind, wrapping its cause.
+// The message is the one of the cause, so wrapping an error doesn't alter the logs.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the cause of the failure
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true when the target is the kind of the failure
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Wraps an error as a broker failure of the given kind
+func wrapError(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// IsFatal returns true when the error is caused by the configuration, so retrying the operation won't help
+func IsFatal(err error) bool {
+	return errors.Is(err, ErrInvalidConfig) || errors.Is(err, ErrTLSConfig)
+}
@@ -0,0 +1,64 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestWrapError(t *testing.T) {
+	assert.NilError(t, wrapError(ErrStreamClosed, nil))
+
+	err := fmt.Errorf("cannot start: %w", wrapError(ErrBrokerUnreachable, io.EOF))
+	assert.Assert(t, errors.Is(err, ErrBrokerUnreachable))
+	assert.Assert(t, errors.Is(err, io.EOF))
+	assert.Assert(t, !errors.Is(err, ErrTLSConfig))
+	assert.Equal(t, "cannot start: EOF", err.Error())
+	assert.Assert(t, !IsFatal(err))
+
+	var brokerErr *Error
+	assert.Assert(t, errors.As(err, &brokerErr))
+	assert.Equal(t, ErrBrokerUnreachable, brokerErr.Kind)
+}
+
+func TestGrpcClientErrors(t *testing.T) {
+	cli := &GrpcClient{
+		config:   &api.MinionConfig{BrokerProperties: map[string]string{"tls-enabled": "true", "ca-cert": "invalid"}},
+		registry: &api.SinkRegistry{},
+		metrics:  api.NewMetrics(),
+	}
+	err := cli.Start()
+	assert.Assert(t, errors.Is(err, ErrTLSConfig))
+	assert.Assert(t, IsFatal(err))
+
+	cli.config.BrokerProperties = map[string]string{"compression": "snappy"}
+	err = cli.Start()
+	assert.Assert(t, errors.Is(err, ErrInvalidConfig))
+	assert.ErrorContains(t, err, "invalid compression snappy")
+
+	cli.config.BrokerProperties = map[string]string{"rpc.message.max.bytes": "abc", "max-message-size": "16MB"}
+	err = cli.Start()
+	assert.Assert(t, errors.Is(err, ErrInvalidConfig))
+	assert.ErrorContains(t, err, "invalid max message size abc")
+
+	cli.config.BrokerProperties = map[string]string{"max-message-size": "xyz"}
+	err = cli.Start()
+	assert.ErrorContains(t, err, "invalid max message size xyz")
+}
+
+func TestNatsClientErrors(t *testing.T) {
+	cli := &NatsClient{
+		config:   &api.MinionConfig{BrokerProperties: map[string]string{"max-buffer-size": "abc"}},
+		registry: &api.SinkRegistry{},
+		metrics:  api.NewMetrics(),
+	}
+	err := cli.Start()
+	assert.Assert(t, errors.Is(err, ErrInvalidConfig))
+	var numErr *strconv.NumError
+	assert.Assert(t, errors.As(err, &numErr)) // The cause is kept
+}
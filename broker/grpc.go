@@ -4,10 +4,16 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/agalue/gominion/api"
@@ -21,7 +27,11 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 // GrpcClient represents the gRPC client implementation for the OpenNMS IPC API.
@@ -29,14 +39,25 @@ import (
 type GrpcClient struct {
 	config      *api.MinionConfig
 	registry    *api.SinkRegistry
-	conn        *grpc.ClientConn
+	conn        *grpc.ClientConn // Replaced on failover; use getConnection
 	onms        ipc.OpenNMSIpcClient
+	connMutex   sync.RWMutex
 	rpcStream   ipc.OpenNMSIpc_RpcStreamingClient
 	sinkStream  ipc.OpenNMSIpc_SinkStreamingClient
 	traceCloser io.Closer
 	metrics     *api.Metrics
 	sinkMutex   *sync.Mutex
 	rpcMutex    *sync.Mutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+	maxMsgSize  int
+	rpcPool     *rpcWorkerPool
+	stateDone   chan struct{}
+	sinkBuffer  *sinkBuffer
+	replaying   int32
+	endpoints   []string
+	endpoint    string
+	options     []grpc.DialOption
 }
 
 // Start initializes the gRPC client.
@@ -55,13 +76,17 @@ func (cli *GrpcClient) Start() error {
 
 	cli.sinkMutex = new(sync.Mutex)
 	cli.rpcMutex = new(sync.Mutex)
+	cli.ctx, cli.cancel = context.WithCancel(context.Background())
+	if instanceID := cli.config.GetBrokerProperty("instance-id"); instanceID != "" {
+		// OpenNMS ignores it, but proxies in front of the servers can route the streams by instance
+		cli.ctx = metadata.AppendToOutgoingContext(cli.ctx, "instance-id", instanceID)
+	}
 
 	if cli.traceCloser, err = initTracing(cli.config); err != nil {
 		return err
 	}
 
 	options := []grpc.DialOption{
-		grpc.WithBlock(),
 		grpc.WithStreamInterceptor(grpc_zap.StreamClientInterceptor(log.GetLogger())),
 	}
 
@@ -70,7 +95,7 @@ func (cli *GrpcClient) Start() error {
 		if cred, err := cli.getTransportCredentials(); err == nil {
 			options = append(options, grpc.WithTransportCredentials(cred))
 		} else {
-			return err
+			return wrapError(ErrTLSConfig, err)
 		}
 	} else {
 		log.Infof("Using Insecure Connection")
@@ -81,11 +106,65 @@ func (cli *GrpcClient) Start() error {
 		options = append(options, grpc.WithStreamInterceptor(grpc_prometheus.StreamClientInterceptor))
 	}
 
-	cli.conn, err = grpc.Dial(cli.config.BrokerURL, options...)
+	callOptions := []grpc.CallOption{}
+	if value := cli.getMaxMessageSize(); value != "" {
+		if cli.maxMsgSize, err = parseByteSize(value); err != nil {
+			return wrapError(ErrInvalidConfig, fmt.Errorf("invalid max message size %s: %w", value, err))
+		}
+		log.Infof("Using max message size of %d bytes", cli.maxMsgSize)
+		callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(cli.maxMsgSize), grpc.MaxCallSendMsgSize(cli.maxMsgSize))
+	}
+
+	if compressor, err := cli.getCompressor(); err == nil && compressor != "" {
+		log.Infof("Using %s compression for the Sink and RPC streams", compressor)
+		callOptions = append(callOptions, grpc.UseCompressor(compressor))
+	} else if err != nil {
+		return wrapError(ErrInvalidConfig, err)
+	}
+
+	if len(callOptions) > 0 {
+		options = append(options, grpc.WithDefaultCallOptions(callOptions...))
+	}
+
+	if params, err := cli.getKeepaliveParams(); err == nil {
+		log.Infof("Using keepalive time %s, timeout %s, permit without stream %t", params.Time, params.Timeout, params.PermitWithoutStream)
+		options = append(options, grpc.WithKeepaliveParams(params))
+	} else {
+		return wrapError(ErrInvalidConfig, err)
+	}
+
+	concurrency := cli.config.GetBrokerPropertyAsInt("rpc-concurrency", runtime.NumCPU()*16)
+	queueSize := cli.config.GetBrokerPropertyAsInt("rpc-queue-size", 1000)
+	if concurrency <= 0 || queueSize < 0 {
+		return wrapError(ErrInvalidConfig, fmt.Errorf("invalid RPC settings: concurrency %d, queue size %d", concurrency, queueSize))
+	}
+	log.Infof("Processing up to %d RPC requests concurrently, queueing up to %d", concurrency, queueSize)
+	cli.rpcPool = newRPCWorkerPool(concurrency, queueSize, cli.metrics)
+
+	if size := cli.config.GetBrokerPropertyAsInt("sink-buffer-size", 0); size > 0 {
+		modules := cli.config.GetBrokerProperty("sink-buffer-modules")
+		if modules == "" {
+			modules = defaultSinkBufferModules
+		}
+		log.Infof("Buffering up to %d undelivered Sink messages for %s", size, modules)
+		cli.sinkBuffer = newSinkBuffer(size, modules, cli.metrics)
+	}
+
+	cli.endpoints = getEndpoints(cli.config.BrokerURL)
+	if len(cli.endpoints) == 0 {
+		return wrapError(ErrInvalidConfig, fmt.Errorf("gRPC server endpoint required"))
+	}
+	if len(cli.endpoints) > 1 {
+		log.Infof("Using gRPC server endpoints %s, in order of preference", strings.Join(cli.endpoints, ", "))
+	}
+	cli.options = options
+	conn, err := cli.dial()
 	if err != nil {
-		return fmt.Errorf("cannot dial gRPC server: %v", err)
+		return err
 	}
-	cli.onms = ipc.NewOpenNMSIpcClient(cli.conn)
+	cli.setConnection(conn)
+	cli.stateDone = make(chan struct{})
+	go cli.watchConnectionState()
 
 	log.Infof("Starting Sink API Stream")
 	if err = cli.initSinkStream(); err != nil {
@@ -101,21 +180,38 @@ func (cli *GrpcClient) Start() error {
 		return err
 	}
 
-	return nil
+	return cli.verifyRPCStream()
 }
 
 // Stop finalizes the gRPC client and all its dependencies.
+// New RPC requests are rejected, while the in-flight ones have up to shutdown-grace-ms to finish and send their responses before closing the streams.
+// Cancels any in-progress connection attempt.
 func (cli *GrpcClient) Stop() {
 	cli.registry.StopModules()
 	log.Warnf("Stopping gRPC client")
-	if cli.rpcStream != nil {
-		cli.rpcStream.CloseSend()
+	if cli.rpcPool != nil {
+		grace := time.Duration(cli.config.GetBrokerPropertyAsInt("shutdown-grace-ms", 10000)) * time.Millisecond
+		log.Infof("Waiting up to %s for in-flight RPC requests", grace)
+		if !cli.rpcPool.Stop(grace) {
+			log.Warnf("In-flight RPC requests didn't finish in %s, their responses will be lost", grace)
+		}
+	}
+	if cli.cancel != nil {
+		cli.cancel()
+	}
+	if cli.stateDone != nil {
+		<-cli.stateDone
+	}
+	if stream := cli.getRPCStream(); stream != nil {
+		stream.CloseSend()
 	}
+	cli.sinkMutex.Lock()
 	if cli.sinkStream != nil {
 		cli.sinkStream.CloseSend()
 	}
-	if cli.conn != nil {
-		cli.conn.Close()
+	cli.sinkMutex.Unlock()
+	if conn, _ := cli.getConnection(); conn != nil {
+		conn.Close()
 	}
 	if cli.traceCloser != nil {
 		cli.traceCloser.Close()
@@ -125,9 +221,38 @@ func (cli *GrpcClient) Stop() {
 
 // Send forwards a Sink API message to the OpenNMS gRPC server.
 // Attempts to restart the client when the stream is unavailable or the connection is not ready.
-// Messages are discarded when the server is unavailable.
+// Messages are discarded when the server is unavailable, unless the Sink buffer is enabled for the module;
+// in that case, they are resent when the server is available again.
 func (cli *GrpcClient) Send(msg *ipc.SinkMessage) error {
-	if cli.sinkStream == nil || cli.conn.GetState() != connectivity.Ready {
+	size := proto.Size(msg)
+	cli.metrics.SinkMsgSize.WithLabelValues(msg.SystemId, msg.ModuleId).Observe(float64(size))
+	if cli.maxMsgSize > 0 && size > cli.maxMsgSize {
+		err := fmt.Errorf("message of %d bytes exceeds the max message size of %d bytes", size, cli.maxMsgSize)
+		cli.metrics.SinkMsgDeliveryFailed.WithLabelValues(msg.SystemId, msg.ModuleId).Inc()
+		trace := startSpanForSinkMessage(msg)
+		trace.SetTag("failed", "true")
+		trace.LogKV("event", err.Error())
+		trace.Finish()
+		return err
+	}
+	if err := cli.send(msg); err != nil {
+		if cli.sinkBuffer != nil && cli.sinkBuffer.Accepts(msg.ModuleId) {
+			cli.sinkBuffer.Add(msg)
+			log.Warnf("Cannot send %s message, buffered for later delivery: %v", msg.ModuleId, err)
+		}
+		return err
+	}
+	cli.replaySinkBuffer()
+	return nil
+}
+
+// Sends a Sink API message through the stream, restarting it when needed
+func (cli *GrpcClient) send(msg *ipc.SinkMessage) error {
+	conn, _ := cli.getConnection()
+	cli.sinkMutex.Lock()
+	stream := cli.sinkStream
+	cli.sinkMutex.Unlock()
+	if stream == nil || conn.GetState() != connectivity.Ready {
 		// Try to restart the Sink stream
 		if err := cli.initSinkStream(); err != nil {
 			return err
@@ -139,12 +264,16 @@ func (cli *GrpcClient) Send(msg *ipc.SinkMessage) error {
 	cli.sinkMutex.Lock()
 	err := cli.sinkStream.Send(msg)
 	cli.sinkMutex.Unlock()
+	api.SetBrokerState(conn.GetState().String())
 	if err == nil {
 		cli.metrics.SinkMsgDeliverySucceeded.WithLabelValues(msg.SystemId, msg.ModuleId).Inc()
+		api.MarkSinkDelivery()
 		return nil
 	}
 	if err == io.EOF {
-		err = fmt.Errorf("server unreachable")
+		err = wrapError(ErrBrokerUnreachable, fmt.Errorf("server unreachable"))
+	} else {
+		err = wrapError(ErrStreamClosed, err)
 	}
 	cli.metrics.SinkMsgDeliveryFailed.WithLabelValues(msg.SystemId, msg.ModuleId).Inc()
 	trace.SetTag("failed", "true")
@@ -152,6 +281,22 @@ func (cli *GrpcClient) Send(msg *ipc.SinkMessage) error {
 	return err
 }
 
+// Resends the buffered Sink messages in the background, unless there is nothing to resend or a replay is in progress
+func (cli *GrpcClient) replaySinkBuffer() {
+	if cli.sinkBuffer == nil || cli.sinkBuffer.Len() == 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&cli.replaying, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&cli.replaying, 0)
+		if sent := cli.sinkBuffer.Replay(cli.send); sent > 0 {
+			log.Infof("Resent %d buffered Sink messages", sent)
+		}
+	}()
+}
+
 // Initializes the Sink API stream
 func (cli *GrpcClient) initSinkStream() error {
 	var err error
@@ -163,18 +308,18 @@ func (cli *GrpcClient) initSinkStream() error {
 		cli.sinkStream.CloseSend()
 	}
 
-	cli.sinkStream, err = cli.onms.SinkStreaming(context.Background())
+	_, onms := cli.getConnection()
+	cli.sinkStream, err = onms.SinkStreaming(cli.ctx)
 	if err != nil {
-		return fmt.Errorf("cannot initialize Sink API Stream: %v", err)
+		return wrapError(ErrStreamClosed, fmt.Errorf("cannot initialize Sink API Stream: %w", err))
 	}
 
 	return nil
 }
 
-// Initializes the RPC API stream
+// Initializes the RPC API stream, replacing the current one.
+// The new stream is served by its own goroutines: one receives the requests, and the other restarts the stream when it terminates, unless it was replaced.
 func (cli *GrpcClient) initRPCStream() error {
-	var err error
-
 	cli.rpcMutex.Lock()
 	defer cli.rpcMutex.Unlock()
 
@@ -182,71 +327,337 @@ func (cli *GrpcClient) initRPCStream() error {
 		cli.rpcStream.CloseSend()
 	}
 
-	cli.rpcStream, err = cli.onms.RpcStreaming(context.Background())
+	_, onms := cli.getConnection()
+	stream, err := onms.RpcStreaming(cli.ctx)
 	if err != nil {
-		return fmt.Errorf("cannot initialize RPC API Stream: %v", err)
+		return wrapError(ErrStreamClosed, fmt.Errorf("cannot initialize RPC API Stream: %w", err))
 	}
+	cli.rpcStream = stream
+	go cli.receiveRPCRequests(stream)
+	go cli.watchRPCStream(stream)
+	return nil
+}
 
-	// Goroutine to handle RPC API requests from the gRPC server.
-	go func() {
-		cli.sendMinionHeaders()
-		for {
-			if cli.rpcStream == nil || cli.conn.GetState() != connectivity.Ready {
+// Handles the RPC API requests received from the gRPC server through a given stream, until it fails
+func (cli *GrpcClient) receiveRPCRequests(stream ipc.OpenNMSIpc_RpcStreamingClient) {
+	cli.sendMinionHeaders(stream)
+	for {
+		if conn, _ := cli.getConnection(); conn.GetState() != connectivity.Ready {
+			break
+		}
+		if request, err := stream.Recv(); err == nil {
+			cli.processRequest(request)
+			cli.metrics.RPCReqReceivedSucceeded.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+		} else {
+			if err == io.EOF || cli.getRPCStream() != stream {
 				break
 			}
-			if request, err := cli.rpcStream.Recv(); err == nil {
-				cli.processRequest(request)
-				cli.metrics.RPCReqReceivedSucceeded.WithLabelValues(request.SystemId, request.ModuleId).Inc()
-			} else {
-				if err == io.EOF {
-					break
-				}
-				if errStatus, _ := status.FromError(err); errStatus.Code() != codes.Unavailable {
-					log.Errorf("Cannot receive RPC Request: %v", err)
-				}
-				cli.metrics.RPCReqReceivedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+			if errStatus, _ := status.FromError(err); errStatus.Code() != codes.Unavailable {
+				log.Errorf("Cannot receive RPC Request: %v", err)
 			}
+			cli.metrics.RPCReqReceivedFailed.WithLabelValues(cli.config.ID, request.GetModuleId()).Inc() // The request is nil on errors
+			// The stream cannot be used after an error, and it is restarted by its watcher
+			break
 		}
-		log.Warnf("Terminating RPC API handler")
-	}()
+	}
+	log.Warnf("Terminating RPC API handler")
+}
 
-	// Detects the termination of the stream and try to restart it until success
-	go func() {
-		<-cli.rpcStream.Context().Done()
-		for {
-			if err := cli.initRPCStream(); err == nil {
-				log.Warnf("RPC API stream restarted")
-				return
+// Detects the termination of a stream and tries to restart it until success.
+// Does nothing when the stream was replaced in the meantime (e.g. on failover).
+func (cli *GrpcClient) watchRPCStream(stream ipc.OpenNMSIpc_RpcStreamingClient) {
+	<-stream.Context().Done()
+	if cli.getRPCStream() != stream {
+		return
+	}
+	if cli.ctx.Err() == nil && cli.getRPCVerifyGrace() > 0 {
+		api.SetRPCChannelState(api.RPCChannelFailed)
+	}
+	for {
+		if cli.ctx.Err() != nil {
+			return
+		}
+		if err := cli.initRPCStream(); err == nil {
+			log.Warnf("RPC API stream restarted")
+			if err := cli.verifyRPCStream(); err != nil {
+				log.Errorf("%v", err)
 			}
-			time.Sleep(1 * time.Second)
+			return
 		}
-	}()
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// Gets the current RPC API stream
+func (cli *GrpcClient) getRPCStream() ipc.OpenNMSIpc_RpcStreamingClient {
+	cli.rpcMutex.Lock()
+	defer cli.rpcMutex.Unlock()
+	return cli.rpcStream
+}
+
+// Gets the time the RPC stream must stay open after sending the headers to consider it usable; 0 disables the verification
+func (cli *GrpcClient) getRPCVerifyGrace() time.Duration {
+	return time.Duration(cli.config.GetBrokerPropertyAsInt("rpc-verify-grace-ms", 3000)) * time.Millisecond
+}
 
+// Verifies that the RPC stream is usable, as OpenNMS doesn't acknowledge the Minion headers:
+// the stream must stay open, with the connection ready, for the grace period after sending them.
+// The result is reflected on the health status, so the Minion is not reported as ready while half-registered.
+func (cli *GrpcClient) verifyRPCStream() error {
+	grace := cli.getRPCVerifyGrace()
+	if grace <= 0 {
+		return nil
+	}
+	stream := cli.getRPCStream()
+	api.SetRPCChannelState(api.RPCChannelVerifying)
+	log.Infof("Verifying RPC channel for %s", grace)
+	select {
+	case <-stream.Context().Done():
+		api.SetRPCChannelState(api.RPCChannelFailed)
+		return wrapError(ErrStreamClosed, fmt.Errorf("RPC channel verification failed: the stream was closed by the server"))
+	case <-time.After(grace):
+	}
+	conn, _ := cli.getConnection()
+	if state := conn.GetState(); state != connectivity.Ready {
+		api.SetRPCChannelState(api.RPCChannelFailed)
+		return wrapError(ErrBrokerUnreachable, fmt.Errorf("RPC channel verification failed: the connection is %s", state))
+	}
+	api.SetRPCChannelState(api.RPCChannelVerified)
+	log.Infof("RPC channel verified")
 	return nil
 }
 
+// Keeps the connection state gauge and the health state current, until the client is stopped.
+// With multiple endpoints, fails over when the connection is lost, re-evaluating the list in order.
+func (cli *GrpcClient) watchConnectionState() {
+	defer close(cli.stateDone)
+	for {
+		conn, _ := cli.getConnection()
+		state := conn.GetState()
+		cli.metrics.BrokerConnectionState.Set(float64(state))
+		api.SetBrokerState(state.String())
+		log.Debugf("gRPC connection state is %s", state)
+		if state == connectivity.Ready {
+			cli.replaySinkBuffer()
+		}
+		if state == connectivity.TransientFailure && len(cli.endpoints) > 1 {
+			cli.failover()
+			continue
+		}
+		if !conn.WaitForStateChange(cli.ctx, state) {
+			return
+		}
+	}
+}
+
+// Replaces the connection with a new one to the first reachable endpoint, and restarts the streams on it.
+// The old connection is closed only after the new streams are up, so the senders never pick a closed one.
+func (cli *GrpcClient) failover() {
+	log.Warnf("Connection to gRPC server %s lost, looking for an available endpoint", cli.endpoint)
+	conn, err := cli.dial()
+	if err != nil {
+		log.Errorf("Cannot fail over: %v", err)
+		current, _ := cli.getConnection()
+		current.WaitForStateChange(cli.ctx, connectivity.TransientFailure)
+		return
+	}
+	old := cli.setConnection(conn)
+	if err := cli.initSinkStream(); err != nil {
+		log.Errorf("Cannot restart Sink API stream: %v", err)
+	}
+	rpcErr := cli.initRPCStream() // On failure, the watcher of the old stream restarts it once the old connection is closed
+	if rpcErr != nil {
+		log.Errorf("Cannot restart RPC API stream: %v", rpcErr)
+	}
+	old.Close()
+	if rpcErr == nil {
+		go func() {
+			if err := cli.verifyRPCStream(); err != nil {
+				log.Errorf("%v", err)
+			}
+		}()
+	}
+}
+
+// Gets the current connection and its IPC client
+func (cli *GrpcClient) getConnection() (*grpc.ClientConn, ipc.OpenNMSIpcClient) {
+	cli.connMutex.RLock()
+	defer cli.connMutex.RUnlock()
+	return cli.conn, cli.onms
+}
+
+// Replaces the connection and its IPC client, and returns the previous connection
+func (cli *GrpcClient) setConnection(conn *grpc.ClientConn) *grpc.ClientConn {
+	cli.connMutex.Lock()
+	defer cli.connMutex.Unlock()
+	old := cli.conn
+	cli.conn = conn
+	cli.onms = ipc.NewOpenNMSIpcClient(conn)
+	return old
+}
+
+// Dials the gRPC server endpoints in order, retrying with exponential backoff until a connection is established.
+// Gives up after the maximum number of attempts (unlimited by default), or when the client is stopped.
+func (cli *GrpcClient) dial() (*grpc.ClientConn, error) {
+	baseDelay := time.Duration(cli.config.GetBrokerPropertyAsInt("reconnect-base-ms", 1000)) * time.Millisecond
+	maxDelay := time.Duration(cli.config.GetBrokerPropertyAsInt("reconnect-max-ms", 60000)) * time.Millisecond
+	maxAttempts := cli.config.GetBrokerPropertyAsInt("reconnect-max-attempts", 0)
+	timeout := time.Duration(cli.config.GetBrokerPropertyAsInt("connect-timeout-ms", 10000)) * time.Millisecond
+	multiplier := 1.6
+	if value := cli.config.GetBrokerProperty("reconnect-multiplier"); value != "" {
+		if m, err := strconv.ParseFloat(value, 64); err == nil && m >= 1 {
+			multiplier = m
+		} else {
+			return nil, wrapError(ErrInvalidConfig, fmt.Errorf("invalid reconnect multiplier %s", value))
+		}
+	}
+	if baseDelay <= 0 || maxDelay < baseDelay || timeout <= 0 {
+		return nil, wrapError(ErrInvalidConfig, fmt.Errorf("invalid reconnect settings: base delay %s, max delay %s, connect timeout %s", baseDelay, maxDelay, timeout))
+	}
+
+	options := append(append([]grpc.DialOption{}, cli.options...), grpc.WithBlock())
+	targets := strings.Join(cli.endpoints, ", ")
+	delay := baseDelay
+	for attempt := 1; ; attempt++ {
+		var err error
+		for _, endpoint := range cli.endpoints {
+			ctx, cancel := context.WithTimeout(cli.ctx, timeout)
+			var conn *grpc.ClientConn
+			conn, err = grpc.DialContext(ctx, endpoint, options...)
+			cancel()
+			if err == nil {
+				cli.setActiveEndpoint(endpoint)
+				api.SetBrokerState(conn.GetState().String())
+				return conn, nil
+			}
+			if cli.ctx.Err() != nil {
+				return nil, fmt.Errorf("connection attempt to gRPC server %s cancelled", targets)
+			}
+			if len(cli.endpoints) > 1 {
+				log.Warnf("Cannot dial gRPC server %s: %v", endpoint, err)
+			}
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return nil, wrapError(ErrBrokerUnreachable, fmt.Errorf("cannot dial gRPC server %s after %d attempts: %w", targets, attempt, err))
+		}
+		log.Warnf("Cannot dial gRPC server %s (attempt %d): %v; retrying in %s", targets, attempt, err, delay)
+		select {
+		case <-cli.ctx.Done():
+			return nil, fmt.Errorf("connection attempt to gRPC server %s cancelled", targets)
+		case <-time.After(delay):
+		}
+		delay = time.Duration(float64(delay) * multiplier)
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// Updates the active endpoint and its gauge
+func (cli *GrpcClient) setActiveEndpoint(endpoint string) {
+	if endpoint != cli.endpoint {
+		log.Infof("Connected to gRPC server %s", endpoint)
+	}
+	cli.endpoint = endpoint
+	for _, e := range cli.endpoints {
+		value := 0.0
+		if e == endpoint {
+			value = 1
+		}
+		cli.metrics.BrokerActiveEndpoint.WithLabelValues(e).Set(value)
+	}
+}
+
+// Gets the gRPC server endpoints from a comma-separated list, ignoring empty entries
+func getEndpoints(brokerURL string) []string {
+	endpoints := make([]string, 0)
+	for _, e := range strings.Split(brokerURL, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints
+}
+
+// Gets the keepalive parameters from the broker properties.
+func (cli *GrpcClient) getKeepaliveParams() (keepalive.ClientParameters, error) {
+	params := keepalive.ClientParameters{
+		Time:                10 * time.Second,
+		Timeout:             5 * time.Second,
+		PermitWithoutStream: true,
+	}
+	if value := cli.config.GetBrokerProperty("keepalive-time-ms"); value != "" {
+		if t, err := strconv.Atoi(value); err == nil && t > 0 {
+			params.Time = time.Duration(t) * time.Millisecond
+		} else {
+			return params, fmt.Errorf("invalid keepalive time %s", value)
+		}
+	}
+	if value := cli.config.GetBrokerProperty("keepalive-timeout-ms"); value != "" {
+		if t, err := strconv.Atoi(value); err == nil && t > 0 {
+			params.Timeout = time.Duration(t) * time.Millisecond
+		} else {
+			return params, fmt.Errorf("invalid keepalive timeout %s", value)
+		}
+	}
+	if value := cli.config.GetBrokerProperty("keepalive-permit-without-stream"); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			params.PermitWithoutStream = b
+		} else {
+			return params, fmt.Errorf("invalid keepalive permit without stream flag %s", value)
+		}
+	}
+	return params, nil
+}
+
+// Gets the max message size from rpc.message.max.bytes, as the property is named on OpenNMS, or from its max-message-size alias
+func (cli *GrpcClient) getMaxMessageSize() string {
+	if value := cli.config.GetBrokerProperty("rpc.message.max.bytes"); value != "" {
+		return value
+	}
+	return cli.config.GetBrokerProperty("max-message-size")
+}
+
+// Gets the name of the compressor for the streams from the broker properties; returns an empty string when compression is disabled.
+func (cli *GrpcClient) getCompressor() (string, error) {
+	switch value := strings.ToLower(cli.config.GetBrokerProperty("compression")); value {
+	case "", "none":
+		return "", nil
+	case gzip.Name:
+		return gzip.Name, nil
+	default:
+		return "", fmt.Errorf("invalid compression %s, expected gzip or none", value)
+	}
+}
+
 // Gets the TLS transport credentials from a file or a string.
 func (cli *GrpcClient) getTransportCredentials() (credentials.TransportCredentials, error) {
 	cfg := &tls.Config{}
 
-	if srvCertPath := cli.config.GetBrokerProperty("ca-cert-path"); srvCertPath != "" {
+	caCert, err := cli.getPEMBrokerProperty("ca-cert", "ca-cert-path")
+	if err != nil {
+		return nil, err
+	}
+	if caCert != nil {
 		log.Infof("Loading CA certificate")
 		certPool := x509.NewCertPool()
-		if certificate, err := ioutil.ReadFile(srvCertPath); err == nil {
-			if ok := certPool.AppendCertsFromPEM(certificate); !ok {
-				return nil, fmt.Errorf("failed to append certs")
-			}
-		} else {
-			return nil, err
+		if ok := certPool.AppendCertsFromPEM(caCert); !ok {
+			return nil, fmt.Errorf("failed to append certs")
 		}
 		cfg.RootCAs = certPool
 	}
 
-	cliCertPath := cli.config.GetBrokerProperty("client-cert-path")
-	cliKeyPath := cli.config.GetBrokerProperty("client-key-path")
-	if cliCertPath != "" && cliKeyPath != "" {
+	clientCert, err := cli.getPEMBrokerProperty("client-cert", "client-cert-path")
+	if err != nil {
+		return nil, err
+	}
+	clientKey, err := cli.getPEMBrokerProperty("client-key", "client-key-path")
+	if err != nil {
+		return nil, err
+	}
+	if clientCert != nil && clientKey != nil {
 		log.Infof("Loading Client certificate for mTLS")
-		certificate, err := tls.LoadX509KeyPair(cliCertPath, cliKeyPath)
+		certificate, err := tls.X509KeyPair(clientCert, clientKey)
 		if err != nil {
 			return nil, err
 		}
@@ -256,27 +667,74 @@ func (cli *GrpcClient) getTransportCredentials() (credentials.TransportCredentia
 	return credentials.NewTLS(cfg), nil
 }
 
+// Gets PEM content from a broker property, either inline (plain or base64-encoded) or from the file referenced by the path property.
+// The inline property takes precedence. Returns nil when neither property is set.
+func (cli *GrpcClient) getPEMBrokerProperty(inlineProperty string, pathProperty string) ([]byte, error) {
+	if value := strings.TrimSpace(cli.config.GetBrokerProperty(inlineProperty)); value != "" {
+		if strings.Contains(value, "-----BEGIN") {
+			return []byte(value), nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || !strings.Contains(string(decoded), "-----BEGIN") {
+			return nil, fmt.Errorf("invalid %s: expected PEM content, either plain or base64-encoded", inlineProperty)
+		}
+		return decoded, nil
+	}
+	if path := cli.config.GetBrokerProperty(pathProperty); path != "" {
+		return ioutil.ReadFile(path)
+	}
+	return nil, nil
+}
+
 // Sends the Minion headers as an RPC API response, to register the Minion as a client.
 // Executes this every time the RPC API Stream is created.
-func (cli *GrpcClient) sendMinionHeaders() {
+func (cli *GrpcClient) sendMinionHeaders(stream ipc.OpenNMSIpc_RpcStreamingClient) {
 	headers := cli.config.GetHeaderResponse()
 	log.Infof("Sending Minion Headers from SystemId %s to gRPC server", cli.config.ID)
 	cli.rpcMutex.Lock()
-	if err := cli.rpcStream.Send(headers); err != nil {
+	if err := stream.Send(headers); err != nil {
 		log.Errorf("Cannot send RPC headers: %v", err)
 	}
 	cli.rpcMutex.Unlock()
 }
 
-// Processes an RPC API request sent by OpenNMS asynchronously within a goroutine and sends back the response from the module.
+// Processes an RPC API request sent by OpenNMS asynchronously through the worker pool and sends back the response from the module.
 func (cli *GrpcClient) processRequest(request *ipc.RpcRequestProto) {
-	log.Debugf("Received RPC request with ID %s for module %s at location %s", request.RpcId, request.ModuleId, request.Location)
-	if module, ok := api.GetRPCModule(request.ModuleId); ok {
-		go func() {
+	logger := getRPCLogger(request)
+	logger.Debugf("Received RPC request with ID %s for module %s at location %s", request.RpcId, request.ModuleId, request.Location)
+	if module, ok := api.GetRPCModule(request.ModuleId); ok && !cli.config.IsRPCModuleEnabled(request.ModuleId) {
+		logger.Warnf("Module %s is disabled, rejecting request with ID %s", request.ModuleId, request.RpcId)
+		cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+		if err := cli.sendResponse(disabledModuleResponse(module, request)); err != nil {
+			logger.Warnf("Cannot reject RPC request with ID %s: %v", request.RpcId, err)
+		}
+	} else if ok {
+		err := cli.rpcPool.Submit(func() {
 			trace := startSpanFromRPCMessage(request)
-			var err error
-			if response := module.Execute(request); response != nil {
-				cli.metrics.RPCReqProcessedSucceeded.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+			start := time.Now()
+			response, finished, err := runRPCModule(module, request)
+			cli.metrics.RPCReqProcessedDuration.WithLabelValues(request.SystemId, request.ModuleId).Observe(time.Since(start).Seconds())
+			if errors.Is(err, errModulePanicked) {
+				cli.metrics.RPCReqPanicked.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				if sendErr := cli.sendResponse(response); sendErr != nil {
+					err = sendErr
+				}
+			} else if err != nil {
+				logger.Warnf("Cannot process RPC request in time: %v", err)
+				cli.metrics.RPCReqTimedOut.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				if sendErr := cli.sendResponse(response); sendErr != nil {
+					err = sendErr
+				}
+			} else if response != nil {
+				if size := proto.Size(response); cli.maxMsgSize > 0 && size > cli.maxMsgSize {
+					logger.Warnf("RPC response with ID %s for module %s has %d bytes, exceeding the max message size of %d bytes", request.RpcId, request.ModuleId, size, cli.maxMsgSize)
+					cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+					response = oversizedResponse(module, request, size, cli.maxMsgSize)
+				} else {
+					cli.metrics.RPCReqProcessedSucceeded.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				}
 				err = cli.sendResponse(response)
 			} else {
 				cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
@@ -287,25 +745,34 @@ func (cli *GrpcClient) processRequest(request *ipc.RpcRequestProto) {
 				trace.LogKV("event", err.Error())
 			}
 			trace.Finish()
-		}()
+			<-finished // An expired module keeps the worker busy until it returns, so it counts against the concurrency limit
+		})
+		if err != nil {
+			logger.Warnf("Cannot process RPC request with ID %s for module %s: %v", request.RpcId, request.ModuleId, err)
+		}
 	} else {
-		log.Errorf("Cannot find implementation for module %s, ignoring request with ID %s", request.ModuleId, request.RpcId)
+		logger.Errorf("Cannot find implementation for module %s, rejecting request with ID %s", request.ModuleId, request.RpcId)
+		cli.metrics.RPCReqUnsupported.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+		if err := cli.sendResponse(unsupportedModuleResponse(request)); err != nil {
+			logger.Warnf("Cannot reject RPC request with ID %s: %v", request.RpcId, err)
+		}
 	}
 }
 
 // Sends an RPC API response to OpenNMS
 func (cli *GrpcClient) sendResponse(response *ipc.RpcResponseProto) error {
-	if cli.rpcStream != nil && cli.conn.GetState() == connectivity.Ready {
+	conn, _ := cli.getConnection()
+	if stream := cli.getRPCStream(); stream != nil && conn.GetState() == connectivity.Ready {
 		cli.rpcMutex.Lock()
-		err := cli.rpcStream.Send(response)
+		err := stream.Send(response)
 		cli.rpcMutex.Unlock()
 		if err == nil {
 			cli.metrics.RPCResSentSucceeded.WithLabelValues(response.SystemId, response.ModuleId).Inc()
 			return nil
 		}
 		cli.metrics.RPCResSentFailed.WithLabelValues(response.SystemId, response.ModuleId).Inc()
-		return fmt.Errorf("cannot send RPC response for module %s with ID %s: %v", response.ModuleId, response.RpcId, err)
+		return wrapError(ErrStreamClosed, fmt.Errorf("cannot send RPC response for module %s with ID %s: %w", response.ModuleId, response.RpcId, err))
 	}
 	cli.metrics.RPCResSentFailed.WithLabelValues(response.SystemId, response.ModuleId).Inc()
-	return fmt.Errorf("cannot connect to the server, ignoring RPC request for module %s with ID %s", response.ModuleId, response.RpcId)
+	return wrapError(ErrBrokerUnreachable, fmt.Errorf("cannot connect to the server, ignoring RPC request for module %s with ID %s", response.ModuleId, response.RpcId))
 }
@@ -0,0 +1,319 @@
+package broker
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/protobuf/ipc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"gotest.tools/v3/assert"
+)
+
+func TestGetPEMBrokerProperty(t *testing.T) {
+	pem := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	file, err := ioutil.TempFile("", "ca-*.crt")
+	assert.NilError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(pem)
+	assert.NilError(t, err)
+	file.Close()
+
+	cli := &GrpcClient{config: &api.MinionConfig{
+		BrokerProperties: map[string]string{
+			"ca-cert-path":     file.Name(),
+			"client-cert":      pem,
+			"client-key":       base64.StdEncoding.EncodeToString([]byte(pem)),
+			"client-key-path":  "/unknown/path",
+			"wrong-cert":       "not a certificate",
+			"wrong-cert-path":  file.Name(),
+			"wrong-base64-pem": base64.StdEncoding.EncodeToString([]byte("not a certificate")),
+		},
+	}}
+
+	data, err := cli.getPEMBrokerProperty("ca-cert", "ca-cert-path")
+	assert.NilError(t, err)
+	assert.Equal(t, pem, string(data))
+
+	data, err = cli.getPEMBrokerProperty("client-cert", "client-cert-path")
+	assert.NilError(t, err)
+	assert.Equal(t, strings.TrimSpace(pem), string(data))
+
+	data, err = cli.getPEMBrokerProperty("client-key", "client-key-path")
+	assert.NilError(t, err)
+	assert.Equal(t, pem, string(data))
+
+	_, err = cli.getPEMBrokerProperty("wrong-cert", "wrong-cert-path")
+	assert.ErrorContains(t, err, "expected PEM content")
+
+	_, err = cli.getPEMBrokerProperty("wrong-base64-pem", "")
+	assert.ErrorContains(t, err, "expected PEM content")
+
+	data, err = cli.getPEMBrokerProperty("unknown", "unknown-path")
+	assert.NilError(t, err)
+	assert.Assert(t, data == nil)
+}
+
+func TestWatchConnectionState(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	server := grpc.NewServer()
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	assert.NilError(t, err)
+	defer conn.Close()
+	cli := &GrpcClient{conn: conn, metrics: api.NewMetrics(), stateDone: make(chan struct{})}
+	cli.ctx, cli.cancel = context.WithCancel(context.Background())
+	go cli.watchConnectionState()
+
+	waitForState := func(ready bool) {
+		for i := 0; i < 100; i++ {
+			if (testutil.ToFloat64(cli.metrics.BrokerConnectionState) == float64(connectivity.Ready)) == ready {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("the connection state gauge never changed (ready: %t)", ready)
+	}
+	waitForState(true)
+	server.Stop()
+	waitForState(false)
+
+	cli.cancel()
+	select {
+	case <-cli.stateDone:
+	case <-time.After(time.Second):
+		t.Fatal("the connection state watcher didn't stop")
+	}
+}
+
+func TestDialFailover(t *testing.T) {
+	unused, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	down := unused.Addr().String()
+	unused.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	server := grpc.NewServer()
+	go server.Serve(listener)
+	defer server.Stop()
+	up := listener.Addr().String()
+
+	cli := &GrpcClient{
+		config: &api.MinionConfig{
+			BrokerURL:        down + ", " + up,
+			BrokerProperties: map[string]string{"connect-timeout-ms": "500", "reconnect-max-attempts": "1"},
+		},
+		metrics: api.NewMetrics(),
+		options: []grpc.DialOption{grpc.WithInsecure()},
+	}
+	cli.ctx, cli.cancel = context.WithCancel(context.Background())
+	defer cli.cancel()
+	cli.endpoints = getEndpoints(cli.config.BrokerURL)
+	assert.DeepEqual(t, []string{down, up}, cli.endpoints)
+
+	conn, err := cli.dial()
+	assert.NilError(t, err)
+	defer conn.Close()
+	assert.Equal(t, up, cli.endpoint)
+	assert.Equal(t, 1.0, testutil.ToFloat64(cli.metrics.BrokerActiveEndpoint.WithLabelValues(up)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(cli.metrics.BrokerActiveEndpoint.WithLabelValues(down)))
+
+	cli.endpoints = []string{down}
+	_, err = cli.dial()
+	assert.ErrorContains(t, err, "after 1 attempts")
+}
+
+func TestSendObservesMessageSize(t *testing.T) {
+	cli := &GrpcClient{metrics: api.NewMetrics(), maxMsgSize: 10}
+	msg := &ipc.SinkMessage{SystemId: "minion01", ModuleId: "Syslog", Content: []byte("a message larger than 10 bytes")}
+	assert.ErrorContains(t, cli.Send(msg), "exceeds the max message size")
+	assert.Equal(t, 1, testutil.CollectAndCount(cli.metrics.SinkMsgSize))
+	assert.Equal(t, 1.0, testutil.ToFloat64(cli.metrics.SinkMsgDeliveryFailed.WithLabelValues("minion01", "Syslog")))
+}
+
+func TestGetCompressor(t *testing.T) {
+	cli := &GrpcClient{config: &api.MinionConfig{BrokerProperties: map[string]string{}}}
+	compressor, err := cli.getCompressor()
+	assert.NilError(t, err)
+	assert.Equal(t, "", compressor)
+
+	cli.config.BrokerProperties["compression"] = "none"
+	compressor, err = cli.getCompressor()
+	assert.NilError(t, err)
+	assert.Equal(t, "", compressor)
+
+	cli.config.BrokerProperties["compression"] = "GZIP"
+	compressor, err = cli.getCompressor()
+	assert.NilError(t, err)
+	assert.Equal(t, "gzip", compressor)
+
+	cli.config.BrokerProperties["compression"] = "snappy"
+	_, err = cli.getCompressor()
+	assert.ErrorContains(t, err, "invalid compression snappy")
+}
+
+type rpcTestServer struct {
+	ipc.UnimplementedOpenNMSIpcServer
+	headers chan *ipc.RpcResponseProto
+}
+
+// Keeps the stream open until the client closes it, registering the headers
+func (server *rpcTestServer) RpcStreaming(stream ipc.OpenNMSIpc_RpcStreamingServer) error {
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		server.headers <- response
+	}
+}
+
+func TestVerifyRPCStream(t *testing.T) {
+	verify := func(server ipc.OpenNMSIpcServer) (string, error) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NilError(t, err)
+		grpcServer := grpc.NewServer()
+		ipc.RegisterOpenNMSIpcServer(grpcServer, server)
+		go grpcServer.Serve(listener)
+		defer grpcServer.Stop()
+
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+		assert.NilError(t, err)
+		defer conn.Close()
+		cli := &GrpcClient{
+			config:   &api.MinionConfig{ID: "minion1", Location: "Test", BrokerProperties: map[string]string{"rpc-verify-grace-ms": "200"}},
+			conn:     conn,
+			onms:     ipc.NewOpenNMSIpcClient(conn),
+			metrics:  api.NewMetrics(),
+			rpcMutex: new(sync.Mutex),
+		}
+		cli.ctx, cli.cancel = context.WithCancel(context.Background())
+		defer cli.cancel()
+		assert.NilError(t, cli.initRPCStream())
+		err = cli.verifyRPCStream()
+		return api.GetHealth().RPCChannel, err
+	}
+	defer api.SetRPCChannelState("")
+
+	server := &rpcTestServer{headers: make(chan *ipc.RpcResponseProto, 1)}
+	state, err := verify(server)
+	assert.NilError(t, err)
+	assert.Equal(t, api.RPCChannelVerified, state)
+	headers := <-server.headers
+	assert.Equal(t, "minion1", headers.SystemId)
+
+	// The RPC stream is closed right away by a server without the RPC API
+	state, err = verify(&ipc.UnimplementedOpenNMSIpcServer{})
+	assert.ErrorContains(t, err, "the stream was closed by the server")
+	assert.Equal(t, api.RPCChannelFailed, state)
+	assert.Assert(t, !(&api.MinionHealthDTO{RPCChannel: state}).IsRPCReady())
+}
+
+// The gRPC logging interceptor requires the logger; it must be initialized before any test starts its goroutines
+func init() {
+	log.InitLogger("error", "console", nil)
+}
+
+// failoverTestServer counts the Sink messages and the RPC headers it receives
+type failoverTestServer struct {
+	ipc.UnimplementedOpenNMSIpcServer
+	messages int32
+	headers  int32
+}
+
+func (server *failoverTestServer) SinkStreaming(stream ipc.OpenNMSIpc_SinkStreamingServer) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return nil
+		}
+		atomic.AddInt32(&server.messages, 1)
+	}
+}
+
+func (server *failoverTestServer) RpcStreaming(stream ipc.OpenNMSIpc_RpcStreamingServer) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return nil
+		}
+		atomic.AddInt32(&server.headers, 1)
+	}
+}
+
+// Fails over while messages are being sent; run with -race to verify the connection is swapped safely
+func TestGrpcClientFailover(t *testing.T) {
+	start := func() (*grpc.Server, *failoverTestServer, string) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NilError(t, err)
+		impl := &failoverTestServer{}
+		server := grpc.NewServer()
+		ipc.RegisterOpenNMSIpcServer(server, impl)
+		go server.Serve(listener)
+		return server, impl, listener.Addr().String()
+	}
+	primary, _, primaryAddr := start()
+	backup, backupImpl, backupAddr := start()
+	defer backup.Stop()
+
+	registry := &api.SinkRegistry{}
+	registry.Init()
+	cli := &GrpcClient{
+		config: &api.MinionConfig{
+			ID:        "minion1",
+			Location:  "Test",
+			BrokerURL: primaryAddr + "," + backupAddr,
+			BrokerProperties: map[string]string{
+				"trace-exporter":      "none",
+				"rpc-verify-grace-ms": "100",
+				"connect-timeout-ms":  "500",
+				"reconnect-base-ms":   "100",
+			},
+		},
+		registry: registry,
+		metrics:  api.NewMetrics(),
+	}
+	assert.NilError(t, cli.Start())
+	defer cli.Stop()
+
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg := &ipc.SinkMessage{SystemId: "minion1", ModuleId: "Heartbeat", Content: []byte("test")}
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					cli.Send(msg)
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+		}()
+	}
+
+	primary.Stop()
+	for i := 0; i < 200 && (atomic.LoadInt32(&backupImpl.messages) == 0 || atomic.LoadInt32(&backupImpl.headers) == 0); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	close(done)
+	wg.Wait()
+	assert.Assert(t, atomic.LoadInt32(&backupImpl.messages) > 0, "no Sink messages received after the failover")
+	assert.Assert(t, atomic.LoadInt32(&backupImpl.headers) > 0, "the RPC stream was not restarted after the failover")
+	assert.Equal(t, 1.0, testutil.ToFloat64(cli.metrics.BrokerActiveEndpoint.WithLabelValues(backupAddr)))
+}
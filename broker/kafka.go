@@ -1,16 +1,17 @@
 package broker
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/protobuf/ipc"
 	"github.com/agalue/gominion/protobuf/rpc"
-	"github.com/agalue/gominion/protobuf/sink"
 	"github.com/google/uuid"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
@@ -18,6 +19,9 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// The prefix for the broker properties that are passed directly to librdkafka
+const kafkaPropertyPrefix = "kafka."
+
 // KafkaClient represents the Kafka client implementation for the OpenNMS IPC API.
 type KafkaClient struct {
 	config        *api.MinionConfig
@@ -28,8 +32,7 @@ type KafkaClient struct {
 	metrics       *api.Metrics
 	maxBufferSize int
 	instanceID    string
-	msgBuffer     map[string][]byte
-	chunkTracker  map[string]int32
+	chunks        *chunkBuffer
 }
 
 // Start initializes the Kafka client.
@@ -46,8 +49,7 @@ func (cli *KafkaClient) Start() error {
 		return fmt.Errorf("prometheus Metrics required")
 	}
 
-	cli.msgBuffer = make(map[string][]byte)
-	cli.chunkTracker = make(map[string]int32)
+	cli.chunks = newChunkBuffer()
 
 	// Maximum size of the buffer to split messages in chunks
 	cli.maxBufferSize, err = strconv.Atoi(cli.config.GetBrokerProperty("max-buffer-size"))
@@ -56,36 +58,33 @@ func (cli *KafkaClient) Start() error {
 	}
 
 	// The OpenNMS Instance ID (org.opennms.instance.id), for Kafka topics
-	cli.instanceID = cli.config.GetBrokerProperty("instance-id")
-	if cli.instanceID == "" {
-		cli.instanceID = "OpenNMS"
-	}
+	cli.instanceID = cli.config.GetInstanceID()
 
 	if cli.traceCloser, err = initTracing(cli.config); err != nil {
 		return err
 	}
 
 	// Creating Kafka Producer
-	// TODO Parse external settings
-	producerCfg := &kafka.ConfigMap{
+	producerCfg := cli.getKafkaConfig(kafka.ConfigMap{
 		"bootstrap.servers": cli.config.BrokerURL,
-	}
+	})
 	if cli.producer, err = kafka.NewProducer(producerCfg); err != nil {
-		return fmt.Errorf("could not create producer: %v", err)
+		return wrapError(ErrInvalidConfig, fmt.Errorf("could not create producer: %w", err))
 	}
 
 	// Creating Kafka Consumer
-	// TODO Parse external settings
-	consumerCfg := &kafka.ConfigMap{
+	consumerCfg := cli.getKafkaConfig(kafka.ConfigMap{
 		"bootstrap.servers":       cli.config.BrokerURL,
 		"group.id":                cli.config.Location,
 		"enable.auto.commit":      true,
 		"auto.commit.interval.ms": 1000,
-	}
+	})
 	if cli.consumer, err = kafka.NewConsumer(consumerCfg); err != nil {
-		return fmt.Errorf("could not create consumer: %v", err)
+		return wrapError(ErrInvalidConfig, fmt.Errorf("could not create consumer: %w", err))
 	}
 
+	api.SetBrokerState("READY")
+
 	// Starting Sink Modules
 	if err := cli.registry.StartModules(cli.config, cli); err != nil {
 		return err
@@ -94,7 +93,7 @@ func (cli *KafkaClient) Start() error {
 	// Subscribe to RPC Requests
 	topic := fmt.Sprintf("%s.%s.rpc-request", cli.instanceID, cli.config.Location)
 	if err := cli.consumer.Subscribe(topic, nil); err != nil {
-		return fmt.Errorf("cannot subscribe to topic %s: %v", topic, err)
+		return wrapError(ErrStreamClosed, fmt.Errorf("cannot subscribe to topic %s: %w", topic, err))
 	}
 
 	go func() {
@@ -127,6 +126,9 @@ func (cli *KafkaClient) Start() error {
 				}
 			case kafka.Error:
 				log.Errorf("kafka consumer error %v", e)
+				if e.Code() == kafka.ErrAllBrokersDown {
+					api.SetBrokerState("TRANSIENT_FAILURE")
+				}
 			}
 		}
 	}()
@@ -150,14 +152,15 @@ func (cli *KafkaClient) Stop() {
 // Send forwards a Sink API message to Kafka.
 // Messages are discarded when the brokers are unavailable.
 func (cli *KafkaClient) Send(msg *ipc.SinkMessage) error {
+	cli.metrics.SinkMsgSize.WithLabelValues(msg.SystemId, msg.ModuleId).Observe(float64(proto.Size(msg)))
 	trace := startSpanForSinkMessage(msg)
 	defer trace.Finish()
-	totalChunks := cli.getTotalChunks(msg.Content)
+	totalChunks := getTotalChunks(msg.Content, cli.maxBufferSize)
 	var chunk int32
 	var err error
 	topic := fmt.Sprintf("%s.Sink.%s", cli.instanceID, msg.ModuleId)
 	for chunk = 0; chunk < totalChunks; chunk++ {
-		bytes := cli.wrapMessageToSink(msg, chunk, totalChunks)
+		bytes := wrapMessageToSink(msg, chunk, totalChunks, cli.maxBufferSize)
 		msg := &kafka.Message{
 			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 			Key:            []byte(uuid.New().String()),
@@ -171,91 +174,73 @@ func (cli *KafkaClient) Send(msg *ipc.SinkMessage) error {
 		cli.metrics.SinkMsgDeliveryFailed.WithLabelValues(msg.SystemId, msg.ModuleId).Inc()
 		trace.SetTag("failed", "true")
 		trace.LogKV("event", err.Error())
-		return fmt.Errorf("cannot send message to %s: %v", topic, err)
+		return wrapError(ErrBrokerUnreachable, fmt.Errorf("cannot send message to %s: %w", topic, err))
 	}
 	cli.metrics.SinkMsgDeliverySucceeded.WithLabelValues(msg.SystemId, msg.ModuleId).Inc()
+	api.MarkSinkDelivery()
 	return nil
 }
 
-func (cli *KafkaClient) getTotalChunks(data []byte) int32 {
-	if cli.maxBufferSize == 0 {
-		return int32(1)
+// Builds a Kafka configuration map from the defaults and the broker properties.
+// Properties prefixed with "kafka." are passed to librdkafka without the prefix (e.g. "kafka.security.protocol"),
+// overriding the defaults when they are present.
+func (cli *KafkaClient) getKafkaConfig(defaults kafka.ConfigMap) *kafka.ConfigMap {
+	cfg := kafka.ConfigMap{}
+	for key, value := range defaults {
+		cfg[key] = value
 	}
-	chunks := int32(math.Ceil(float64(len(data) / cli.maxBufferSize)))
-	if len(data)%cli.maxBufferSize > 0 {
-		chunks++
-	}
-	return chunks
-}
-
-func (cli *KafkaClient) wrapMessageToSink(request *ipc.SinkMessage, chunk, totalChunks int32) []byte {
-	bufferSize := cli.getRemainingBufferSize(int32(len(request.Content)), chunk)
-	offset := chunk * int32(cli.maxBufferSize)
-	msg := request.Content[offset : offset+bufferSize]
-	sinkMsg := &sink.SinkMessage{
-		MessageId:          request.MessageId,
-		CurrentChunkNumber: chunk,
-		TotalChunks:        totalChunks,
-		Content:            msg,
-	}
-	bytes, err := proto.Marshal(sinkMsg)
-	if err != nil {
-		return []byte{}
-	}
-	return bytes
-}
-
-func (cli *KafkaClient) getRemainingBufferSize(messageSize, chunk int32) int32 {
-	if cli.maxBufferSize > 0 && messageSize > int32(cli.maxBufferSize) {
-		remaining := messageSize - chunk*int32(cli.maxBufferSize)
-		if remaining > int32(cli.maxBufferSize) {
-			return int32(cli.maxBufferSize)
+	for key, value := range cli.config.BrokerProperties {
+		if strings.HasPrefix(key, kafkaPropertyPrefix) {
+			cfg[strings.TrimPrefix(key, kafkaPropertyPrefix)] = value
 		}
-		return remaining
 	}
-	return messageSize
+	return &cfg
 }
 
 // Processes an RPC API request sent by OpenNMS asynchronously within a goroutine and sends back the response from the module.
 func (cli *KafkaClient) processRequest(request *rpc.RpcMessageProto) {
-	// Process chunks
-	chunk := request.CurrentChunkNumber + 1 // Chunks starts at 0
-	log.Debugf("%s RPC chunk %d of %d for %s received", request.ModuleId, chunk, request.TotalChunks, request.RpcId)
-	if chunk != request.TotalChunks {
-		if cli.chunkTracker[request.RpcId] < chunk {
-			// Adds partial message to the buffer
-			cli.msgBuffer[request.RpcId] = append(cli.msgBuffer[request.RpcId], request.RpcContent...)
-			cli.chunkTracker[request.RpcId] = chunk
-		} else {
-			log.Warnf("Chunk %d from %s was already processed, ignoring...", chunk, request.RpcId)
-		}
+	data, complete := cli.chunks.add(request)
+	if !complete {
 		return
 	}
-	// Retrieve the complete message from the buffer
-	var data []byte
-	if request.TotalChunks == 1 { // Handle special case chunk == total == 1
-		data = request.RpcContent
-	} else {
-		data = append(cli.msgBuffer[request.RpcId], request.RpcContent...)
-	}
-	delete(cli.msgBuffer, request.RpcId)
-	delete(cli.chunkTracker, request.RpcId)
 	// Process RPC request
-	log.Debugf("Received RPC request with ID %s for module %s", request.RpcId, request.ModuleId)
-	if module, ok := api.GetRPCModule(request.ModuleId); ok {
+	req := &ipc.RpcRequestProto{
+		RpcId:          request.RpcId,
+		SystemId:       request.SystemId,
+		ModuleId:       request.ModuleId,
+		ExpirationTime: request.ExpirationTime,
+		RpcContent:     data,
+		Location:       cli.config.Location,
+		TracingInfo:    request.TracingInfo,
+	}
+	logger := getRPCLogger(req)
+	logger.Debugf("Received RPC request with ID %s for module %s", request.RpcId, request.ModuleId)
+	if module, ok := api.GetRPCModule(request.ModuleId); ok && !cli.config.IsRPCModuleEnabled(request.ModuleId) {
+		logger.Warnf("Module %s is disabled, rejecting request with ID %s", request.ModuleId, request.RpcId)
+		cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+		if err := cli.sendResponse(disabledModuleResponse(module, req)); err != nil {
+			logger.Warnf("Cannot reject RPC request with ID %s: %v", request.RpcId, err)
+		}
+	} else if ok {
 		go func() {
-			var err error
-			req := &ipc.RpcRequestProto{
-				RpcId:          request.RpcId,
-				SystemId:       request.SystemId,
-				ModuleId:       request.ModuleId,
-				ExpirationTime: request.ExpirationTime,
-				RpcContent:     data,
-				Location:       cli.config.Location,
-				TracingInfo:    request.TracingInfo,
-			}
 			trace := startSpanFromRPCMessage(req)
-			if response := module.Execute(req); response != nil {
+			start := time.Now()
+			response, err := executeRPCModule(module, req)
+			cli.metrics.RPCReqProcessedDuration.WithLabelValues(request.SystemId, request.ModuleId).Observe(time.Since(start).Seconds())
+			if errors.Is(err, errModulePanicked) {
+				cli.metrics.RPCReqPanicked.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				if sendErr := cli.sendResponse(response); sendErr != nil {
+					err = sendErr
+				}
+			} else if err != nil {
+				logger.Warnf("Cannot process RPC request in time: %v", err)
+				cli.metrics.RPCReqTimedOut.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				if sendErr := cli.sendResponse(response); sendErr != nil {
+					err = sendErr
+				}
+			} else if response != nil {
 				cli.metrics.RPCReqProcessedSucceeded.WithLabelValues(request.SystemId, request.ModuleId).Inc()
 				err = cli.sendResponse(response)
 			} else {
@@ -269,16 +254,20 @@ func (cli *KafkaClient) processRequest(request *rpc.RpcMessageProto) {
 			trace.Finish()
 		}()
 	} else {
-		log.Errorf("Cannot find implementation for module %s, ignoring request with ID %s", request.ModuleId, request.RpcId)
+		logger.Errorf("Cannot find implementation for module %s, rejecting request with ID %s", request.ModuleId, request.RpcId)
+		cli.metrics.RPCReqUnsupported.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+		if err := cli.sendResponse(unsupportedModuleResponse(req)); err != nil {
+			logger.Warnf("Cannot reject RPC request with ID %s: %v", request.RpcId, err)
+		}
 	}
 }
 
 func (cli *KafkaClient) sendResponse(response *ipc.RpcResponseProto) error {
-	totalChunks := cli.getTotalChunks(response.RpcContent)
+	totalChunks := getTotalChunks(response.RpcContent, cli.maxBufferSize)
 	var chunk int32
 	topic := fmt.Sprintf("%s.rpc-response", cli.instanceID)
 	for chunk = 0; chunk < totalChunks; chunk++ {
-		bytes := cli.wrapMessageToRPC(response, chunk, totalChunks)
+		bytes := wrapMessageToRPC(response, chunk, totalChunks, cli.maxBufferSize)
 		msg := &kafka.Message{
 			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 			Key:            []byte(response.RpcId),
@@ -286,26 +275,9 @@ func (cli *KafkaClient) sendResponse(response *ipc.RpcResponseProto) error {
 		}
 		if err := cli.producer.Produce(msg, nil); err != nil {
 			cli.metrics.RPCResSentFailed.WithLabelValues(response.SystemId, response.ModuleId).Inc()
-			return fmt.Errorf("cannot send message to %s: %v", topic, err)
+			return wrapError(ErrBrokerUnreachable, fmt.Errorf("cannot send message to %s: %w", topic, err))
 		}
 	}
 	cli.metrics.RPCResSentSucceeded.WithLabelValues(response.SystemId, response.ModuleId).Inc()
 	return nil
 }
-
-func (cli *KafkaClient) wrapMessageToRPC(response *ipc.RpcResponseProto, chunk, totalChunks int32) []byte {
-	bufferSize := cli.getRemainingBufferSize(int32(len(response.RpcContent)), chunk)
-	offset := chunk * int32(cli.maxBufferSize)
-	msg := response.RpcContent[offset : offset+bufferSize]
-	rpcMsg := &rpc.RpcMessageProto{
-		RpcId:              response.RpcId,
-		RpcContent:         msg,
-		CurrentChunkNumber: chunk,
-		TotalChunks:        totalChunks,
-	}
-	bytes, err := proto.Marshal(rpcMsg)
-	if err != nil {
-		return []byte{}
-	}
-	return bytes
-}
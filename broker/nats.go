@@ -0,0 +1,281 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/protobuf/ipc"
+	"github.com/agalue/gominion/protobuf/rpc"
+
+	"github.com/nats-io/nats.go"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// The default maximum size of each chunk, below the default maximum payload of the NATS server (1MB)
+const defaultNatsMaxBufferSize = "512KB"
+
+// NatsClient represents the NATS client implementation for the OpenNMS IPC API.
+// It uses the same subjects and message format as the Kafka client uses for the topics.
+type NatsClient struct {
+	config        *api.MinionConfig
+	registry      *api.SinkRegistry
+	conn          *nats.Conn
+	subscription  *nats.Subscription
+	traceCloser   io.Closer
+	metrics       *api.Metrics
+	maxBufferSize int
+	instanceID    string
+	chunks        *chunkBuffer
+}
+
+// Start initializes the NATS client.
+// Returns an error when the configuration is incorrect or cannot connect to the server.
+func (cli *NatsClient) Start() error {
+	var err error
+	if cli.config == nil {
+		return fmt.Errorf("minion configuration required")
+	}
+	if cli.registry == nil {
+		return fmt.Errorf("sink registry required")
+	}
+	if cli.metrics == nil {
+		return fmt.Errorf("prometheus Metrics required")
+	}
+
+	cli.chunks = newChunkBuffer()
+
+	// Maximum size of the buffer to split messages in chunks
+	maxBufferSize := cli.config.GetBrokerProperty("max-buffer-size")
+	if maxBufferSize == "" {
+		maxBufferSize = defaultNatsMaxBufferSize
+	}
+	if cli.maxBufferSize, err = parseByteSize(maxBufferSize); err != nil {
+		return wrapError(ErrInvalidConfig, fmt.Errorf("invalid max buffer size %s: %w", maxBufferSize, err))
+	}
+
+	// The OpenNMS Instance ID (org.opennms.instance.id), for NATS subjects
+	cli.instanceID = cli.config.GetInstanceID()
+
+	if cli.traceCloser, err = initTracing(cli.config); err != nil {
+		return err
+	}
+
+	// Connecting to NATS
+	if cli.conn, err = nats.Connect(cli.getServers(), cli.getOptions()...); err != nil {
+		return wrapError(ErrBrokerUnreachable, fmt.Errorf("cannot connect to NATS: %w", err))
+	}
+
+	api.SetBrokerState("READY")
+
+	// Starting Sink Modules
+	if err := cli.registry.StartModules(cli.config, cli); err != nil {
+		return err
+	}
+
+	// Subscribe to RPC Requests; the location is the queue group, so each request is processed by a single Minion
+	subject := cli.getRequestSubject()
+	log.Infof("starting RPC consumer for location %s", cli.config.Location)
+	if cli.subscription, err = cli.conn.QueueSubscribe(subject, cli.config.Location, cli.onRequest); err != nil {
+		return wrapError(ErrStreamClosed, fmt.Errorf("cannot subscribe to subject %s: %w", subject, err))
+	}
+
+	return nil
+}
+
+// Stop finalizes the NATS client and all its dependencies.
+func (cli *NatsClient) Stop() {
+	cli.registry.StopModules()
+	log.Warnf("Stopping NATS client")
+	if cli.subscription != nil {
+		cli.subscription.Unsubscribe()
+	}
+	if cli.conn != nil {
+		cli.conn.Flush()
+		cli.conn.Close()
+	}
+	if cli.traceCloser != nil {
+		cli.traceCloser.Close()
+	}
+	log.Infof("Good bye")
+}
+
+// Send forwards a Sink API message to NATS.
+// Messages are buffered by the NATS client while reconnecting, and discarded when its buffer is full.
+func (cli *NatsClient) Send(msg *ipc.SinkMessage) error {
+	cli.metrics.SinkMsgSize.WithLabelValues(msg.SystemId, msg.ModuleId).Observe(float64(proto.Size(msg)))
+	trace := startSpanForSinkMessage(msg)
+	defer trace.Finish()
+	totalChunks := getTotalChunks(msg.Content, cli.maxBufferSize)
+	var chunk int32
+	var err error
+	subject := cli.getSinkSubject(msg.ModuleId)
+	for chunk = 0; chunk < totalChunks; chunk++ {
+		bytes := wrapMessageToSink(msg, chunk, totalChunks, cli.maxBufferSize)
+		if err = cli.conn.Publish(subject, bytes); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		cli.metrics.SinkMsgDeliveryFailed.WithLabelValues(msg.SystemId, msg.ModuleId).Inc()
+		trace.SetTag("failed", "true")
+		trace.LogKV("event", err.Error())
+		return wrapError(ErrBrokerUnreachable, fmt.Errorf("cannot send message to %s: %w", subject, err))
+	}
+	cli.metrics.SinkMsgDeliverySucceeded.WithLabelValues(msg.SystemId, msg.ModuleId).Inc()
+	api.MarkSinkDelivery()
+	return nil
+}
+
+// Gets the NATS servers from the servers broker property (defaults to the broker URL)
+func (cli *NatsClient) getServers() string {
+	if servers := cli.config.GetBrokerProperty("servers"); servers != "" {
+		return servers
+	}
+	return cli.config.BrokerURL
+}
+
+// Builds the NATS connection options from the broker properties
+func (cli *NatsClient) getOptions() []nats.Option {
+	options := []nats.Option{
+		nats.Name(cli.config.ID),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			log.Warnf("Disconnected from NATS: %v", err)
+			api.SetBrokerState("TRANSIENT_FAILURE")
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.Infof("Reconnected to NATS server %s", conn.ConnectedUrl())
+			api.SetBrokerState("READY")
+		}),
+		nats.ErrorHandler(func(conn *nats.Conn, sub *nats.Subscription, err error) {
+			log.Errorf("NATS error: %v", err)
+		}),
+	}
+	if path := cli.config.GetBrokerProperty("credentials-file"); path != "" {
+		options = append(options, nats.UserCredentials(path))
+	}
+	if cli.config.GetBrokerProperty("tls-enabled") == "true" {
+		options = append(options, nats.Secure())
+		if path := cli.config.GetBrokerProperty("ca-cert-path"); path != "" {
+			options = append(options, nats.RootCAs(path))
+		}
+		cert := cli.config.GetBrokerProperty("client-cert-path")
+		key := cli.config.GetBrokerProperty("client-key-path")
+		if cert != "" && key != "" {
+			options = append(options, nats.ClientCert(cert, key))
+		}
+	}
+	return options
+}
+
+// Gets the subject for the Sink messages of a given module
+func (cli *NatsClient) getSinkSubject(moduleID string) string {
+	return fmt.Sprintf("%s.Sink.%s", cli.instanceID, moduleID)
+}
+
+// Gets the subject for the RPC requests of the Minion's location
+func (cli *NatsClient) getRequestSubject() string {
+	return fmt.Sprintf("%s.%s.rpc-request", cli.instanceID, strings.ReplaceAll(cli.config.Location, " ", "_"))
+}
+
+// Gets the subject for the RPC responses
+func (cli *NatsClient) getResponseSubject() string {
+	return fmt.Sprintf("%s.rpc-response", cli.instanceID)
+}
+
+// Handles a message received on the RPC request subject
+func (cli *NatsClient) onRequest(msg *nats.Msg) {
+	rpc := new(rpc.RpcMessageProto)
+	if err := proto.Unmarshal(msg.Data, rpc); err == nil {
+		cli.metrics.RPCReqReceivedSucceeded.WithLabelValues(rpc.SystemId, rpc.ModuleId).Inc()
+		cli.processRequest(rpc)
+	} else {
+		cli.metrics.RPCReqReceivedFailed.WithLabelValues(rpc.SystemId, rpc.ModuleId).Inc()
+		log.Errorf("Cannot process RPC Request: %v", err)
+	}
+}
+
+// Processes an RPC API request sent by OpenNMS asynchronously within a goroutine and sends back the response from the module.
+func (cli *NatsClient) processRequest(request *rpc.RpcMessageProto) {
+	data, complete := cli.chunks.add(request)
+	if !complete {
+		return
+	}
+	req := &ipc.RpcRequestProto{
+		RpcId:          request.RpcId,
+		SystemId:       request.SystemId,
+		ModuleId:       request.ModuleId,
+		ExpirationTime: request.ExpirationTime,
+		RpcContent:     data,
+		Location:       cli.config.Location,
+		TracingInfo:    request.TracingInfo,
+	}
+	logger := getRPCLogger(req)
+	logger.Debugf("Received RPC request with ID %s for module %s", request.RpcId, request.ModuleId)
+	if module, ok := api.GetRPCModule(request.ModuleId); ok && !cli.config.IsRPCModuleEnabled(request.ModuleId) {
+		logger.Warnf("Module %s is disabled, rejecting request with ID %s", request.ModuleId, request.RpcId)
+		cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+		if err := cli.sendResponse(disabledModuleResponse(module, req)); err != nil {
+			logger.Warnf("Cannot reject RPC request with ID %s: %v", request.RpcId, err)
+		}
+	} else if ok {
+		go func() {
+			trace := startSpanFromRPCMessage(req)
+			start := time.Now()
+			response, err := executeRPCModule(module, req)
+			cli.metrics.RPCReqProcessedDuration.WithLabelValues(request.SystemId, request.ModuleId).Observe(time.Since(start).Seconds())
+			if errors.Is(err, errModulePanicked) {
+				cli.metrics.RPCReqPanicked.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				if sendErr := cli.sendResponse(response); sendErr != nil {
+					err = sendErr
+				}
+			} else if err != nil {
+				logger.Warnf("Cannot process RPC request in time: %v", err)
+				cli.metrics.RPCReqTimedOut.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				if sendErr := cli.sendResponse(response); sendErr != nil {
+					err = sendErr
+				}
+			} else if response != nil {
+				cli.metrics.RPCReqProcessedSucceeded.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				err = cli.sendResponse(response)
+			} else {
+				cli.metrics.RPCReqProcessedFailed.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+				err = fmt.Errorf("module %s returned an empty response for request %s, ignoring", request.ModuleId, request.RpcId)
+			}
+			if err != nil {
+				trace.SetTag("failed", "true")
+				trace.LogKV("event", err.Error())
+			}
+			trace.Finish()
+		}()
+	} else {
+		logger.Errorf("Cannot find implementation for module %s, rejecting request with ID %s", request.ModuleId, request.RpcId)
+		cli.metrics.RPCReqUnsupported.WithLabelValues(request.SystemId, request.ModuleId).Inc()
+		if err := cli.sendResponse(unsupportedModuleResponse(req)); err != nil {
+			logger.Warnf("Cannot reject RPC request with ID %s: %v", request.RpcId, err)
+		}
+	}
+}
+
+func (cli *NatsClient) sendResponse(response *ipc.RpcResponseProto) error {
+	totalChunks := getTotalChunks(response.RpcContent, cli.maxBufferSize)
+	var chunk int32
+	subject := cli.getResponseSubject()
+	for chunk = 0; chunk < totalChunks; chunk++ {
+		bytes := wrapMessageToRPC(response, chunk, totalChunks, cli.maxBufferSize)
+		if err := cli.conn.Publish(subject, bytes); err != nil {
+			cli.metrics.RPCResSentFailed.WithLabelValues(response.SystemId, response.ModuleId).Inc()
+			return wrapError(ErrBrokerUnreachable, fmt.Errorf("cannot send message to %s: %w", subject, err))
+		}
+	}
+	cli.metrics.RPCResSentSucceeded.WithLabelValues(response.SystemId, response.ModuleId).Inc()
+	return nil
+}
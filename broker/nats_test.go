@@ -0,0 +1,44 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/agalue/gominion/api"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNatsClientSubjects(t *testing.T) {
+	cli := &NatsClient{
+		config:     &api.MinionConfig{ID: "minion01", Location: "Apex Office", BrokerURL: "nats://localhost:4222"},
+		instanceID: "OpenNMS",
+	}
+	assert.Equal(t, "OpenNMS.Sink.Trap", cli.getSinkSubject("Trap"))
+	assert.Equal(t, "OpenNMS.Apex_Office.rpc-request", cli.getRequestSubject())
+	assert.Equal(t, "OpenNMS.rpc-response", cli.getResponseSubject())
+	assert.Equal(t, "nats://localhost:4222", cli.getServers())
+	assert.Equal(t, 5, len(cli.getOptions()))
+
+	cli.config.BrokerProperties = map[string]string{
+		"servers":          "nats://nats1:4222,nats://nats2:4222",
+		"credentials-file": "/etc/gominion/nats.creds",
+		"tls-enabled":      "true",
+		"ca-cert-path":     "/etc/gominion/ca.crt",
+	}
+	assert.Equal(t, "nats://nats1:4222,nats://nats2:4222", cli.getServers())
+	assert.Equal(t, 8, len(cli.getOptions()))
+}
+
+func TestNatsClientStartWithoutServer(t *testing.T) {
+	cli := &NatsClient{
+		config: &api.MinionConfig{
+			ID:               "minion01",
+			Location:         "Apex",
+			BrokerURL:        "nats://127.0.0.1:1",
+			BrokerProperties: map[string]string{"trace-exporter": "none"},
+		},
+		registry: &api.SinkRegistry{},
+		metrics:  api.NewMetrics(),
+	}
+	assert.ErrorContains(t, cli.Start(), "cannot connect to NATS")
+}
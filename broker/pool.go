@@ -0,0 +1,84 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/agalue/gominion/api"
+)
+
+// rpcWorkerPool bounds the number of RPC requests executed concurrently.
+// Requests are queued when all the workers are busy, and Submit blocks when the queue is full.
+type rpcWorkerPool struct {
+	queue   chan func()
+	wg      *sync.WaitGroup
+	mutex   *sync.RWMutex
+	closed  bool
+	metrics *api.Metrics
+}
+
+// Creates and starts a new worker pool
+func newRPCWorkerPool(workers int, queueSize int, metrics *api.Metrics) *rpcWorkerPool {
+	pool := &rpcWorkerPool{
+		queue:   make(chan func(), queueSize),
+		wg:      new(sync.WaitGroup),
+		mutex:   new(sync.RWMutex),
+		metrics: metrics,
+	}
+	pool.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go pool.worker()
+	}
+	return pool
+}
+
+// Submit enqueues a task to be executed by the next available worker
+func (pool *rpcWorkerPool) Submit(task func()) error {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+	if pool.closed {
+		return fmt.Errorf("the RPC worker pool is stopped")
+	}
+	pool.metrics.RPCReqQueued.Inc()
+	pool.queue <- task
+	return nil
+}
+
+// Stop rejects new tasks and waits for the queued and in-flight tasks to finish.
+// Waits up to the given timeout (forever when it is not positive), and returns false when the tasks didn't finish in time.
+func (pool *rpcWorkerPool) Stop(timeout time.Duration) bool {
+	pool.mutex.Lock()
+	if !pool.closed {
+		pool.closed = true
+		close(pool.queue)
+	}
+	pool.mutex.Unlock()
+	if timeout <= 0 {
+		pool.wg.Wait()
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		pool.wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (pool *rpcWorkerPool) worker() {
+	defer pool.wg.Done()
+	for task := range pool.queue {
+		pool.metrics.RPCReqQueued.Dec()
+		pool.metrics.RPCReqInFlight.Inc()
+		task()
+		pool.metrics.RPCReqInFlight.Dec()
+	}
+}
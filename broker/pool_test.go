@@ -0,0 +1,98 @@
+package broker
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/v3/assert"
+)
+
+func TestRPCWorkerPool(t *testing.T) {
+	pool := newRPCWorkerPool(2, 1, api.NewMetrics())
+	var running, maxRunning, completed int32
+	for i := 0; i < 6; i++ {
+		err := pool.Submit(func() {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&completed, 1)
+		})
+		assert.NilError(t, err)
+	}
+	assert.Assert(t, pool.Stop(0))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&completed))
+	assert.Assert(t, atomic.LoadInt32(&maxRunning) <= 2)
+	assert.ErrorContains(t, pool.Submit(func() {}), "stopped")
+}
+
+func TestRPCWorkerPoolStopTimeout(t *testing.T) {
+	pool := newRPCWorkerPool(1, 1, api.NewMetrics())
+	release := make(chan struct{})
+	assert.NilError(t, pool.Submit(func() { <-release }))
+	assert.Assert(t, !pool.Stop(50*time.Millisecond))
+	close(release)
+	assert.Assert(t, pool.Stop(time.Second))
+}
+
+// countingRPCModule tracks the number of executions running concurrently
+type countingRPCModule struct {
+	delay      time.Duration
+	running    int32
+	maxRunning int32
+}
+
+func (module *countingRPCModule) GetID() string {
+	return "Counting"
+}
+
+func (module *countingRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	current := atomic.AddInt32(&module.running, 1)
+	for {
+		max := atomic.LoadInt32(&module.maxRunning)
+		if current <= max || atomic.CompareAndSwapInt32(&module.maxRunning, max, current) {
+			break
+		}
+	}
+	time.Sleep(module.delay)
+	atomic.AddInt32(&module.running, -1)
+	return &ipc.RpcResponseProto{RpcId: request.RpcId}
+}
+
+// Requests that expire before a module that cannot be cancelled returns keep their worker busy until it does
+func TestRPCWorkerPoolWithExpiredRequests(t *testing.T) {
+	metrics := api.NewMetrics()
+	pool := newRPCWorkerPool(2, 10, metrics)
+	module := &countingRPCModule{delay: 50 * time.Millisecond}
+	var expired, overflows int32
+	for i := 0; i < 6; i++ {
+		request := &ipc.RpcRequestProto{RpcId: strconv.Itoa(i), ExpirationTime: uint64(time.Now().Add(5*time.Millisecond).UnixNano() / int64(time.Millisecond))}
+		err := pool.Submit(func() {
+			_, finished, err := runRPCModule(module, request)
+			if err != nil {
+				atomic.AddInt32(&expired, 1)
+			}
+			if testutil.ToFloat64(metrics.RPCReqInFlight) > 2 {
+				atomic.AddInt32(&overflows, 1)
+			}
+			<-finished
+		})
+		assert.NilError(t, err)
+	}
+	assert.Assert(t, pool.Stop(0))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&expired))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overflows))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&module.maxRunning))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&module.running))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.RPCReqInFlight))
+}
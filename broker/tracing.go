@@ -1,7 +1,14 @@
 package broker
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/uber/jaeger-client-go"
@@ -9,15 +16,59 @@ import (
 	jaegerlog "github.com/uber/jaeger-client-go/log"
 	"github.com/uber/jaeger-lib/metrics"
 
+	"go.opentelemetry.io/otel"
+	otelbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/protobuf/ipc"
 )
 
-// Initializes the OpenTracing integration using Jaeger.
-// Overrides the global tracer when Jaeger is available.
+// A closer that does nothing, used when tracing is disabled
+type noopCloser struct{}
+
+func (c noopCloser) Close() error {
+	return nil
+}
+
+// A closer that flushes the pending spans and shuts down an OpenTelemetry tracer provider
+type tracerProviderCloser struct {
+	provider *sdktrace.TracerProvider
+}
+
+func (c tracerProviderCloser) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.provider.Shutdown(ctx)
+}
+
+// Initializes the OpenTracing integration using the exporter from the trace-exporter broker property: jaeger (the default), otlp, or none.
+// Overrides the global tracer, and returns a closer that flushes the pending spans.
 func initTracing(cfg *api.MinionConfig) (io.Closer, error) {
+	switch exporter := strings.ToLower(cfg.GetBrokerProperty("trace-exporter")); exporter {
+	case "", "jaeger":
+		return initJaegerTracing(cfg)
+	case "otlp":
+		return initOTLPTracing(cfg)
+	case "none":
+		log.Infof("Tracing disabled")
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+		return noopCloser{}, nil
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %s, expected jaeger, otlp or none", exporter)
+	}
+}
+
+// Initializes the tracer using Jaeger.
+func initJaegerTracing(cfg *api.MinionConfig) (io.Closer, error) {
 	jcfg := jaegercfg.Configuration{
-		ServiceName: cfg.Location + "@" + cfg.ID,
+		ServiceName: getTracingServiceName(cfg),
 		Sampler: &jaegercfg.SamplerConfig{
 			Type:  jaeger.SamplerTypeConst,
 			Param: 1, // JAEGER_SAMPLER_PARAM
@@ -37,13 +88,131 @@ func initTracing(cfg *api.MinionConfig) (io.Closer, error) {
 	return closer, nil
 }
 
+// Initializes the tracer using OpenTelemetry, exporting the spans via OTLP/gRPC.
+// The OpenTracing API used by the span helpers is bridged to OpenTelemetry.
+func initOTLPTracing(cfg *api.MinionConfig) (io.Closer, error) {
+	endpoint := cfg.GetBrokerProperty("trace-otlp-endpoint")
+	if endpoint == "" {
+		endpoint = "localhost:4317"
+	}
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if cfg.GetBrokerProperty("trace-otlp-insecure") == "true" {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	if value := cfg.GetBrokerProperty("trace-otlp-headers"); value != "" {
+		headers, err := parseTracingHeaders(value)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, otlptracegrpc.WithHeaders(headers))
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create OTLP exporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(getTracingServiceName(cfg)))),
+	)
+	// OpenNMS sends the parent span as a W3C traceparent or a Jaeger uber-trace-id, depending on its tracer
+	propagator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}, jaegerPropagator{})
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	bridge, _ := otelbridge.NewTracerPair(provider.Tracer("gominion"))
+	bridge.SetTextMapPropagator(propagator)
+	opentracing.SetGlobalTracer(bridge)
+	log.Infof("Exporting traces via OTLP to %s", endpoint)
+	return tracerProviderCloser{provider}, nil
+}
+
+// The header of the Jaeger propagation format
+const jaegerTraceHeader = "uber-trace-id"
+
+// jaegerPropagator propagates the span context with the uber-trace-id header of Jaeger ({trace-id}:{span-id}:{parent-span-id}:{flags}), for the OpenTelemetry tracer
+type jaegerPropagator struct{}
+
+// Inject sets the uber-trace-id header from the span context of ctx
+func (p jaegerPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	flags := 0
+	if sc.IsSampled() {
+		flags = 1
+	}
+	carrier.Set(jaegerTraceHeader, fmt.Sprintf("%s:%s:0:%d", sc.TraceID(), sc.SpanID(), flags))
+}
+
+// Extract returns a copy of ctx with the remote span context from the uber-trace-id header, when it is valid
+func (p jaegerPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	value, err := url.QueryUnescape(carrier.Get(jaegerTraceHeader))
+	if err != nil || value == "" {
+		return ctx
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 || len(parts[0]) > 32 || len(parts[1]) > 16 {
+		return ctx
+	}
+	traceID, err := trace.TraceIDFromHex(strings.Repeat("0", 32-len(parts[0])) + parts[0])
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(strings.Repeat("0", 16-len(parts[1])) + parts[1])
+	if err != nil {
+		return ctx
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return ctx
+	}
+	config := trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, Remote: true}
+	if flags&1 == 1 {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(config))
+}
+
+// Fields returns the header used by the propagator
+func (p jaegerPropagator) Fields() []string {
+	return []string{jaegerTraceHeader}
+}
+
+// Parses the OTLP headers from a comma-separated list of key=value pairs
+func parseTracingHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid OTLP header %s, expected key=value", pair)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers, nil
+}
+
+func getTracingServiceName(cfg *api.MinionConfig) string {
+	return cfg.Location + "@" + cfg.ID
+}
+
+// Extracts the parent span context from the tracing info of a message.
+// The info is passed as HTTP headers, the only format supported by the OpenTelemetry bridge, which Jaeger also accepts.
+// The spans are started as children of that context, as the bridge turns the other references into links to a new trace.
+func extractSpanContext(tracer opentracing.Tracer, info map[string]string) (opentracing.SpanContext, error) {
+	carrier := opentracing.HTTPHeadersCarrier(http.Header{})
+	for key, value := range info {
+		carrier.Set(key, value)
+	}
+	return tracer.Extract(opentracing.HTTPHeaders, carrier)
+}
+
 // Starts a tracing span for an RPC API request
 func startSpanFromRPCMessage(request *ipc.RpcRequestProto) opentracing.Span {
 	tracer := opentracing.GlobalTracer()
 	tags := getTagsForRPC(request)
-	ctx, err := tracer.Extract(opentracing.TextMap, request.TracingInfo)
+	ctx, err := extractSpanContext(tracer, request.TracingInfo)
 	if err == nil {
-		return tracer.StartSpan(request.ModuleId, opentracing.FollowsFrom(ctx), tags)
+		return tracer.StartSpan(request.ModuleId, opentracing.ChildOf(ctx), tags)
 	}
 	return tracer.StartSpan(request.ModuleId, tags)
 }
@@ -64,9 +233,9 @@ func getTagsForRPC(request *ipc.RpcRequestProto) opentracing.Tags {
 func startSpanForSinkMessage(msg *ipc.SinkMessage) opentracing.Span {
 	tracer := opentracing.GlobalTracer()
 	tags := getTagsForSink(msg)
-	ctx, err := tracer.Extract(opentracing.TextMap, msg.TracingInfo)
+	ctx, err := extractSpanContext(tracer, msg.TracingInfo)
 	if err == nil {
-		return tracer.StartSpan(msg.ModuleId, opentracing.FollowsFrom(ctx), tags)
+		return tracer.StartSpan(msg.ModuleId, opentracing.ChildOf(ctx), tags)
 	}
 	return tracer.StartSpan(msg.ModuleId, tags)
 }
@@ -0,0 +1,124 @@
+package broker
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseTracingHeaders(t *testing.T) {
+	headers, err := parseTracingHeaders("api-key=123, tenant = acme")
+	assert.NilError(t, err)
+	assert.Equal(t, "123", headers["api-key"])
+	assert.Equal(t, "acme", headers["tenant"])
+
+	_, err = parseTracingHeaders("api-key")
+	assert.ErrorContains(t, err, "invalid OTLP header")
+}
+
+func TestInitTracing(t *testing.T) {
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	cfg := &api.MinionConfig{ID: "minion1", Location: "Test", BrokerProperties: map[string]string{}}
+	msg := &ipc.SinkMessage{ModuleId: "Heartbeat", Location: "Test", SystemId: "minion1"}
+
+	cfg.BrokerProperties["trace-exporter"] = "none"
+	closer, err := initTracing(cfg)
+	assert.NilError(t, err)
+	startSpanForSinkMessage(msg).Finish()
+	assert.NilError(t, closer.Close())
+
+	cfg.BrokerProperties["trace-exporter"] = "zipkin"
+	_, err = initTracing(cfg)
+	assert.ErrorContains(t, err, "invalid trace exporter")
+}
+
+// An OTLP collector that keeps the exported spans by name
+type fakeTraceCollector struct {
+	coltracepb.UnimplementedTraceServiceServer
+	mutex sync.Mutex
+	spans map[string]*tracepb.Span
+}
+
+func (c *fakeTraceCollector) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				c.spans[span.Name] = span
+			}
+		}
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func TestOTLPTracingPropagation(t *testing.T) {
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	collector := &fakeTraceCollector{spans: make(map[string]*tracepb.Span)}
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, collector)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	cfg := &api.MinionConfig{ID: "minion1", Location: "Test", BrokerProperties: map[string]string{
+		"trace-exporter":      "otlp",
+		"trace-otlp-endpoint": lis.Addr().String(),
+		"trace-otlp-insecure": "true",
+	}}
+	closer, err := initTracing(cfg)
+	assert.NilError(t, err)
+
+	// A span injected by the Minion itself is kept as the parent
+	parent := opentracing.GlobalTracer().StartSpan("Parent")
+	headers := http.Header{}
+	assert.NilError(t, opentracing.GlobalTracer().Inject(parent.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers)))
+	assert.Assert(t, headers.Get("traceparent") != "")
+	assert.Assert(t, headers.Get(jaegerTraceHeader) != "")
+	parent.Finish()
+	startSpanFromRPCMessage(&ipc.RpcRequestProto{ModuleId: "Echo", Location: "Test", TracingInfo: map[string]string{"traceparent": headers.Get("traceparent")}}).Finish()
+
+	// The parents sent by OpenNMS with the Jaeger format, where the trace ID can have 64 bits
+	jaegerInfo := map[string]string{jaegerTraceHeader: "a1b2c3d4e5f60718:1122334455667788:0:1"}
+	startSpanForSinkMessage(&ipc.SinkMessage{ModuleId: "Heartbeat", Location: "Test", TracingInfo: jaegerInfo}).Finish()
+
+	assert.NilError(t, closer.Close()) // Flushes the spans to the collector
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	assert.Equal(t, 3, len(collector.spans))
+	echo := collector.spans["Echo"]
+	assert.DeepEqual(t, collector.spans["Parent"].TraceId, echo.TraceId)
+	assert.DeepEqual(t, collector.spans["Parent"].SpanId, echo.ParentSpanId)
+	heartbeat := collector.spans["Heartbeat"]
+	assert.Equal(t, "0000000000000000a1b2c3d4e5f60718", hex.EncodeToString(heartbeat.TraceId))
+	assert.Equal(t, "1122334455667788", hex.EncodeToString(heartbeat.ParentSpanId))
+}
+
+func TestJaegerPropagator(t *testing.T) {
+	propagator := jaegerPropagator{}
+	for _, value := range []string{"", "abc", "zz:11:0:1", "a1:b2:0", "a1:b2:0:xx", "00:00:0:1"} {
+		ctx := propagator.Extract(context.Background(), propagation.MapCarrier{jaegerTraceHeader: value})
+		assert.Assert(t, !trace.SpanContextFromContext(ctx).IsValid(), value)
+	}
+	ctx := propagator.Extract(context.Background(), propagation.MapCarrier{jaegerTraceHeader: "a1b2c3d4e5f60718a1b2c3d4e5f60718%3A1122334455667788%3A0%3A1"})
+	sc := trace.SpanContextFromContext(ctx)
+	assert.Assert(t, sc.IsValid() && sc.IsRemote() && sc.IsSampled())
+
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	assert.Equal(t, "a1b2c3d4e5f60718a1b2c3d4e5f60718:1122334455667788:0:1", carrier.Get(jaegerTraceHeader))
+}
@@ -1,13 +1,20 @@
 package broker
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/collectors"
 	"github.com/agalue/gominion/detectors"
 	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/monitors"
+	"github.com/agalue/gominion/protobuf/ipc"
 
 	_ "github.com/agalue/gominion/rpc" // Load all RPC modules
 )
@@ -28,24 +35,172 @@ func GetBroker(config *api.MinionConfig, registry *api.SinkRegistry, metrics *ap
 			metrics:  metrics,
 		}
 	}
+	if strings.ToLower(config.BrokerType) == "nats" {
+		return &NatsClient{
+			config:   config,
+			registry: registry,
+			metrics:  metrics,
+		}
+	}
 	return nil
 }
 
-// DisplayRegisteredModules displays all registered modules
-func DisplayRegisteredModules(sinkRegistry *api.SinkRegistry) {
+// DisplayRegisteredModules displays all registered modules using the given log function (e.g. log.Debugf)
+func DisplayRegisteredModules(sinkRegistry *api.SinkRegistry, logf func(format string, params ...interface{})) {
 	for _, m := range api.GetAllRPCModules() {
-		log.Debugf("Registered RPC module %s", m.GetID())
+		logf("Registered RPC module %s", m.GetID())
 	}
 	for _, m := range sinkRegistry.GetAllModules() {
-		log.Debugf("Registered Sink module %s", m.GetID())
+		logf("Registered Sink module %s", m.GetID())
 	}
 	for _, m := range collectors.GetAllCollectors() {
-		log.Debugf("Registered collector module %s", m.GetID())
+		logf("Registered collector module %s", m.GetID())
 	}
 	for _, m := range detectors.GetAllDetectors() {
-		log.Debugf("Registered detector module %s", m.GetID())
+		logf("Registered detector module %s", m.GetID())
 	}
 	for _, m := range monitors.GetAllMonitors() {
-		log.Debugf("Registered poller module %s", m.GetID())
+		logf("Registered poller module %s", m.GetID())
+	}
+}
+
+// DisplayModuleSummary displays the number of registered modules of each kind using the given log function (e.g. log.Infof)
+func DisplayModuleSummary(sinkRegistry *api.SinkRegistry, logf func(format string, params ...interface{})) {
+	logf("Registered %d RPC modules, %d Sink modules, %d collectors, %d detectors and %d monitors",
+		len(api.GetAllRPCModules()), len(sinkRegistry.GetAllModules()), len(collectors.GetAllCollectors()), len(detectors.GetAllDetectors()), len(monitors.GetAllMonitors()))
+}
+
+// Parses a human-friendly size (e.g. 512KB, 16MB, 1GB) and returns the number of bytes.
+// Units are powers of 1024; when the unit is omitted, the value is assumed to be in bytes.
+func parseByteSize(value string) (int, error) {
+	units := []struct {
+		suffix string
+		factor int
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+	value = strings.ToUpper(strings.TrimSpace(value))
+	factor := 1
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("size must be greater than zero")
+	}
+	return size * factor, nil
+}
+
+// errModulePanicked is wrapped by the error returned by executeRPCModule when the module panics
+var errModulePanicked = errors.New("module panicked")
+
+// Executes an RPC request honoring its expiration time (in milliseconds since epoch, or zero when it never expires).
+// When the request expires before the module finishes, it returns an error response built by the module (if supported) and a non-nil error.
+// Modules that implement api.ContextRPCModule are cancelled when the request expires; the rest keep running in the background until they return.
+// When the module panics, it returns an error response and an error wrapping errModulePanicked, as the panic is recovered.
+// The context also carries a logger tagged with the RPC and module IDs (see log.FromContext).
+func executeRPCModule(module api.RPCModule, request *ipc.RpcRequestProto) (*ipc.RpcResponseProto, error) {
+	response, _, err := runRPCModule(module, request)
+	return response, err
+}
+
+// Executes an RPC request like executeRPCModule, and also returns a channel that is closed when the module returns.
+// That happens after this function returns when the request expires, so callers with bounded concurrency can wait for the module in the background.
+func runRPCModule(module api.RPCModule, request *ipc.RpcRequestProto) (*ipc.RpcResponseProto, <-chan struct{}, error) {
+	finished := make(chan struct{})
+	parent := log.NewContext(context.Background(), getRPCLogger(request))
+	if request.ExpirationTime == 0 {
+		defer close(finished)
+		response, err := executeSafely(parent, module, request)
+		return response, finished, err
+	}
+	remaining := time.Until(time.Unix(0, int64(request.ExpirationTime)*int64(time.Millisecond)))
+	if remaining > 0 {
+		ctx, cancel := context.WithTimeout(parent, remaining)
+		type result struct {
+			response *ipc.RpcResponseProto
+			err      error
+		}
+		results := make(chan result, 1)
+		go func() {
+			defer close(finished)
+			defer cancel()
+			response, err := executeSafely(ctx, module, request)
+			results <- result{response, err}
+		}()
+		select {
+		case r := <-results:
+			return r.response, finished, r.err
+		case <-ctx.Done():
+		}
+	} else {
+		close(finished)
+	}
+	err := fmt.Errorf("request %s for module %s expired", request.RpcId, request.ModuleId)
+	return errorResponse(module, request, err), finished, err
+}
+
+// Gets a logger tagged with the RPC and module IDs of a request
+func getRPCLogger(request *ipc.RpcRequestProto) *log.Logger {
+	return log.WithFields("rpcId", request.RpcId, "module", request.ModuleId)
+}
+
+// Builds the response for a request to a module disabled by the Minion configuration
+func disabledModuleResponse(module api.RPCModule, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return errorResponse(module, request, fmt.Errorf("module %s is disabled on this Minion", request.ModuleId))
+}
+
+// Builds the response for a request to a module not implemented by the Minion, so OpenNMS fails the request without waiting for it to expire
+func unsupportedModuleResponse(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return errorResponse(nil, request, fmt.Errorf("module %s is not supported by this Minion", request.ModuleId))
+}
+
+// Builds the response for a request whose response exceeds the max message size of the gRPC stream.
+// OpenNMS doesn't reassemble chunked RPC responses over gRPC, and sending an oversized message would close the stream.
+func oversizedResponse(module api.RPCModule, request *ipc.RpcRequestProto, size, maxSize int) *ipc.RpcResponseProto {
+	return errorResponse(module, request, fmt.Errorf("response of %d bytes exceeds the max message size of %d bytes", size, maxSize))
+}
+
+// Builds the response for a failed request, in the format of the module when supported
+func errorResponse(module api.RPCModule, request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	if responder, ok := module.(api.RPCErrorResponder); ok {
+		return responder.ErrorResponse(request, err)
+	}
+	return &ipc.RpcResponseProto{
+		ModuleId:   request.ModuleId,
+		Location:   request.Location,
+		SystemId:   request.SystemId,
+		RpcId:      request.RpcId,
+		RpcContent: []byte(err.Error()),
+	}
+}
+
+// Executes an RPC request recovering from a panic of the module, which is logged with its stack trace
+func executeSafely(ctx context.Context, module api.RPCModule, request *ipc.RpcRequestProto) (response *ipc.RpcResponseProto, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.FromContext(ctx).Errorf("Module %s panicked while executing request %s: %v\n%s", request.ModuleId, request.RpcId, r, debug.Stack())
+			err = fmt.Errorf("%w while executing request %s for module %s: %v", errModulePanicked, request.RpcId, request.ModuleId, r)
+			response = errorResponse(module, request, err)
+		}
+	}()
+	return executeWithContext(ctx, module, request), nil
+}
+
+// Executes an RPC request with the given context when the module supports it
+func executeWithContext(ctx context.Context, module api.RPCModule, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	if m, ok := module.(api.ContextRPCModule); ok {
+		return m.ExecuteWithContext(ctx, request)
 	}
+	return module.Execute(request)
 }
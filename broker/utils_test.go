@@ -0,0 +1,146 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/protobuf/ipc"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseByteSize(t *testing.T) {
+	size, err := parseByteSize("1024")
+	assert.NilError(t, err)
+	assert.Equal(t, 1024, size)
+
+	size, err = parseByteSize("512KB")
+	assert.NilError(t, err)
+	assert.Equal(t, 512*1024, size)
+
+	size, err = parseByteSize("16MB")
+	assert.NilError(t, err)
+	assert.Equal(t, 16*1024*1024, size)
+
+	size, err = parseByteSize("1 gb")
+	assert.NilError(t, err)
+	assert.Equal(t, 1024*1024*1024, size)
+
+	_, err = parseByteSize("16XB")
+	assert.Assert(t, err != nil)
+
+	_, err = parseByteSize("0MB")
+	assert.Assert(t, err != nil)
+}
+
+type slowRPCModule struct {
+	delay time.Duration
+}
+
+func (module *slowRPCModule) GetID() string {
+	return "Slow"
+}
+
+func (module *slowRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	time.Sleep(module.delay)
+	return &ipc.RpcResponseProto{RpcId: request.RpcId, RpcContent: []byte("done")}
+}
+
+func TestExecuteRPCModule(t *testing.T) {
+	module := &slowRPCModule{delay: 50 * time.Millisecond}
+	expiration := func(d time.Duration) uint64 {
+		return uint64(time.Now().Add(d).UnixNano() / int64(time.Millisecond))
+	}
+
+	response, err := executeRPCModule(module, &ipc.RpcRequestProto{RpcId: "001"})
+	assert.NilError(t, err)
+	assert.Equal(t, "done", string(response.RpcContent))
+
+	response, err = executeRPCModule(module, &ipc.RpcRequestProto{RpcId: "002", ExpirationTime: expiration(time.Second)})
+	assert.NilError(t, err)
+	assert.Equal(t, "done", string(response.RpcContent))
+
+	response, err = executeRPCModule(module, &ipc.RpcRequestProto{RpcId: "003", ExpirationTime: expiration(10 * time.Millisecond)})
+	assert.ErrorContains(t, err, "expired")
+	assert.Equal(t, "003", response.RpcId)
+	assert.Assert(t, string(response.RpcContent) != "done")
+}
+
+type contextRPCModule struct {
+	cancelled chan bool
+}
+
+func (module *contextRPCModule) GetID() string {
+	return "Context"
+}
+
+func (module *contextRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return module.ExecuteWithContext(context.Background(), request)
+}
+
+func (module *contextRPCModule) ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	<-ctx.Done()
+	module.cancelled <- true
+	return &ipc.RpcResponseProto{RpcId: request.RpcId, RpcContent: []byte("cancelled")}
+}
+
+func TestExecuteRPCModuleWithContext(t *testing.T) {
+	module := &contextRPCModule{cancelled: make(chan bool, 1)}
+	expiration := uint64(time.Now().Add(10*time.Millisecond).UnixNano() / int64(time.Millisecond))
+	response, err := executeRPCModule(module, &ipc.RpcRequestProto{RpcId: "001", ExpirationTime: expiration})
+	assert.ErrorContains(t, err, "expired")
+	assert.Equal(t, "001", response.RpcId)
+	select {
+	case <-module.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the module was not cancelled")
+	}
+}
+
+type panicRPCModule struct{}
+
+func (module *panicRPCModule) GetID() string {
+	return "Panic"
+}
+
+func (module *panicRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	var response *ipc.RpcResponseProto
+	response.RpcContent = []byte("unreachable")
+	return response
+}
+
+func TestExecuteRPCModuleWithPanic(t *testing.T) {
+	module := &panicRPCModule{}
+	response, err := executeRPCModule(module, &ipc.RpcRequestProto{RpcId: "001", ModuleId: "Panic"})
+	assert.Assert(t, errors.Is(err, errModulePanicked))
+	assert.Equal(t, "001", response.RpcId)
+	assert.Assert(t, strings.Contains(string(response.RpcContent), "nil pointer dereference"))
+
+	expiration := uint64(time.Now().Add(time.Second).UnixNano() / int64(time.Millisecond))
+	response, err = executeRPCModule(module, &ipc.RpcRequestProto{RpcId: "002", ModuleId: "Panic", ExpirationTime: expiration})
+	assert.Assert(t, errors.Is(err, errModulePanicked))
+	assert.Equal(t, "002", response.RpcId)
+}
+
+func TestDisabledModuleResponse(t *testing.T) {
+	response := disabledModuleResponse(&slowRPCModule{}, &ipc.RpcRequestProto{RpcId: "001", ModuleId: "Slow"})
+	assert.Equal(t, "001", response.RpcId)
+	assert.Equal(t, "module Slow is disabled on this Minion", string(response.RpcContent))
+}
+
+func TestUnsupportedModuleResponse(t *testing.T) {
+	response := unsupportedModuleResponse(&ipc.RpcRequestProto{RpcId: "002", ModuleId: "Future", SystemId: "minion1", Location: "Test"})
+	assert.Equal(t, "002", response.RpcId)
+	assert.Equal(t, "Future", response.ModuleId)
+	assert.Equal(t, "minion1", response.SystemId)
+	assert.Equal(t, "module Future is not supported by this Minion", string(response.RpcContent))
+}
+
+func TestOversizedResponse(t *testing.T) {
+	response := oversizedResponse(&slowRPCModule{}, &ipc.RpcRequestProto{RpcId: "003", ModuleId: "Slow"}, 2048, 1024)
+	assert.Equal(t, "003", response.RpcId)
+	assert.Equal(t, "response of 2048 bytes exceeds the max message size of 1024 bytes", string(response.RpcContent))
+}
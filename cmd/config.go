@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultConfigTemplate is the commented default Minion configuration; the only argument is the Minion ID
+const defaultConfigTemplate = `---
+# The ID of the Minion (defaults to the hostname)
+id: %s
+
+# The location of the Minion, as defined in OpenNMS
+location: Local
+
+# The broker used to communicate with OpenNMS, either grpc, kafka or nats
+brokerType: grpc
+
+# The address of the OpenNMS gRPC server, Kafka bootstrap server, or NATS server
+brokerUrl: localhost:8990
+
+# The broker properties (the values must be strings)
+brokerProperties:
+  # Enable TLS for gRPC; mutual TLS is enabled when the client certificate and key are provided
+  tls-enabled: "false"
+  # ca-cert-path: /etc/gominion/ca.crt
+  # client-cert-path: /etc/gominion/client.crt
+  # client-key-path: /etc/gominion/client.key
+  # Maximum size of the gRPC messages
+  max-message-size: 4MB
+  # Compression of the gRPC messages, either gzip or none
+  compression: none
+  # Tracing exporter, either jaeger, otlp or none
+  trace-exporter: jaeger
+
+# The UDP port of the SNMP Trap receiver (0 to disable it)
+trapPort: 1162
+
+# The UDP/TCP port of the Syslog receiver (0 to disable it)
+syslogPort: 1514
+
+# The HTTP port of the Prometheus metrics (0 to disable it)
+statsPort: 0
+
+# The local IP address for the UDP receivers (empty to use all interfaces)
+bindAddress: ""
+
+# The local port range for the connections of the monitors, detectors and collectors, as min-max (empty to use any port)
+sourcePortRange: ""
+
+# The logging level (debug, info, warn or error) and format (console or json)
+logLevel: info
+logFormat: console
+
+# The time in milliseconds before closing the idle SNMP sessions reused across requests (0 to disable the cache)
+snmpSessionIdleMs: 60000
+
+# The maximum number of workers shared by all the listeners, proportionally to the workers each one requests (0 means unlimited)
+# maxListenerWorkers: 16
+
+# The RPC modules allowed to answer requests (all by default), and the ones that reject them
+# enabledRpcModules: [Echo, Health, Poller, Detect, DNS, PING]
+# disabledRpcModules: [Collect]
+
+# The SNMPv3 users referenced by security name from the SNMP requests and the Trap listener
+# snmpV3Users:
+# - securityName: opennms
+#   authProtocol: SHA
+#   authPassPhrase: changeme
+#   privProtocol: AES
+#   privPassPhrase: changeme
+
+# The Flow and Telemetry listeners; the parser must be one of the supported by the Sink modules
+listeners:
+- name: Netflow-5
+  port: 8877
+  parser: Netflow5UdpParser
+- name: Netflow-9
+  port: 4729
+  parser: Netflow9UdpParser
+  properties:
+    # The number of workers to receive and decode the packets
+    workers: "4"
+- name: IPFIX
+  port: 4730
+  parser: IpfixUdpParser
+- name: SFlow
+  port: 6343
+  parser: SFlowUdpParser
+- name: Graphite
+  port: 2003
+  parser: ForwardParser
+- name: NXOS
+  port: 50001
+  parser: NxosGrpcParser
+  # properties:
+  #   tls-cert-path: /etc/gominion/nxos.crt
+  #   tls-key-path: /etc/gominion/nxos.key
+  #   tls-client-ca-path: /etc/gominion/nxos-ca.crt
+`
+
+var (
+	// force overwrites an existing configuration file
+	force bool
+
+	// configCmd groups the commands to manage the configuration file
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manage the Minion configuration file",
+	}
+
+	// configInitCmd writes a default configuration file
+	configInitCmd = &cobra.Command{
+		Use:           "init [path]",
+		Short:         "Write a default configuration file to the given path (or stdout)",
+		Args:          cobra.MaximumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return writeDefaultConfig(cmd.OutOrStdout(), path, force)
+		},
+	}
+)
+
+func init() {
+	configInitCmd.Flags().BoolVar(&force, "force", false, "Overwrite the configuration file if it exists")
+	configCmd.AddCommand(configInitCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// Writes the default configuration to the given path, or to the writer when the path is empty
+func writeDefaultConfig(out io.Writer, path string, force bool) error {
+	hostname, _ := os.Hostname()
+	content := fmt.Sprintf(defaultConfigTemplate, hostname)
+	if path == "" {
+		_, err := io.WriteString(out, content)
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("cannot write configuration file: %v", err)
+	}
+	fmt.Fprintf(out, "Configuration written to %s\n", path)
+	return nil
+}
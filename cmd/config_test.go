@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/sink"
+	"github.com/spf13/viper"
+	"gotest.tools/v3/assert"
+)
+
+func TestWriteDefaultConfig(t *testing.T) {
+	out := new(bytes.Buffer)
+	assert.NilError(t, writeDefaultConfig(out, "", false))
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.NilError(t, v.ReadConfig(bytes.NewBuffer(out.Bytes())))
+	config := &api.MinionConfig{}
+	assert.NilError(t, v.Unmarshal(config))
+	assert.NilError(t, config.IsValid())
+	assert.NilError(t, config.ValidateListeners(sink.CreateSinkRegistry().GetSupportedParsers()))
+	assert.Equal(t, "Local", config.Location)
+	assert.Equal(t, 1162, config.TrapPort)
+	assert.Equal(t, "false", config.BrokerProperties["tls-enabled"])
+	assert.Equal(t, "4", config.GetListener("Netflow-9").Properties["workers"])
+
+	expected := out.String()
+	path := filepath.Join(t.TempDir(), "gominion.yaml")
+	assert.NilError(t, writeDefaultConfig(out, path, false))
+	assert.ErrorContains(t, writeDefaultConfig(out, path, false), "already exists")
+	assert.NilError(t, os.WriteFile(path, []byte("id: test"), 0644))
+	assert.NilError(t, writeDefaultConfig(out, path, true))
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, expected, string(data))
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/agalue/gominion/api"
+)
+
+// envPrefix is the prefix of the environment variables that override the configuration
+const envPrefix = "GOMINION"
+
+// The environment variable with the listeners, separated by listenersEnvDelimiter
+const listenersEnv = envPrefix + "_LISTENERS"
+
+// The delimiter between listeners on the listeners environment variable
+const listenersEnvDelimiter = ";"
+
+// The prefix of the environment variables with broker properties
+const brokerPropertiesEnvPrefix = envPrefix + "_BROKERPROPERTIES_"
+
+// Applies the nested settings that viper cannot bind from the environment (in KEY=value format).
+// GOMINION_BROKERPROPERTIES_<NAME> sets a broker property; the name is lowercased, with __ as . and _ as - (e.g. TLS_ENABLED is tls-enabled).
+// GOMINION_LISTENERS replaces the listeners, with the same format as the listener flag, separated by semicolons.
+func applyEnvironment(cfg *api.MinionConfig, environ []string) error {
+	for _, env := range environ {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], brokerPropertiesEnvPrefix) {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(kv[0], brokerPropertiesEnvPrefix))
+		name = strings.ReplaceAll(strings.ReplaceAll(name, "__", "."), "_", "-")
+		if name == "" {
+			continue
+		}
+		if cfg.BrokerProperties == nil {
+			cfg.BrokerProperties = make(map[string]string)
+		}
+		cfg.BrokerProperties[name] = kv[1]
+	}
+	for _, env := range environ {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || kv[0] != listenersEnv {
+			continue
+		}
+		cfg.Listeners = nil
+		listeners := make([]string, 0)
+		for _, listener := range strings.Split(kv[1], listenersEnvDelimiter) {
+			if listener = strings.TrimSpace(listener); listener != "" {
+				listeners = append(listeners, listener)
+			}
+		}
+		if err := cfg.ParseListeners(listeners); err != nil {
+			return err
+		}
+	}
+	return nil
+}
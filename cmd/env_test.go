@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestApplyEnvironment(t *testing.T) {
+	cfg := &api.MinionConfig{
+		Listeners: []api.MinionListener{{Name: "Netflow-5", Port: 8877, Parser: "Netflow5UdpParser"}},
+	}
+	assert.NilError(t, applyEnvironment(cfg, []string{
+		"HOME=/root",
+		"GOMINION_BROKERPROPERTIES_TLS_ENABLED=true",
+		"GOMINION_BROKERPROPERTIES_KAFKA__BOOTSTRAP__SERVERS=kafka:9092",
+		"GOMINION_LISTENERS=NXOS,50001,NxosGrpcParser; Netflow-9,4729,Netflow9UdpParser,workers=4",
+	}))
+	assert.Equal(t, "true", cfg.GetBrokerProperty("tls-enabled"))
+	assert.Equal(t, "kafka:9092", cfg.GetBrokerProperty("kafka.bootstrap.servers"))
+	assert.Equal(t, 2, len(cfg.Listeners))
+	assert.Assert(t, cfg.GetListener("Netflow-5") == nil)
+	assert.Equal(t, "4", cfg.GetListener("Netflow-9").Properties["workers"])
+
+	assert.ErrorContains(t, applyEnvironment(cfg, []string{"GOMINION_LISTENERS=NXOS,port,NxosGrpcParser"}), "invalid port")
+}
@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/agalue/gominion/log"
+)
+
+// The timeout of the requests to fetch the configuration from a remote source
+const configFetchTimeout = 30 * time.Second
+
+// The location of the credentials of the service account within a Kubernetes Pod
+const serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// configProvider fetches the content of the configuration from its source, keeping the last one
+type configProvider interface {
+	// Fetch returns the latest content of the configuration, and whether it changed since the previous fetch
+	Fetch() ([]byte, bool, error)
+	// String returns the location of the configuration
+	String() string
+}
+
+// Creates the provider for the location of the configuration: an http(s):// URL, a k8s://namespace/configmap[/key] ConfigMap, or a file path
+func newConfigProvider(location string) (configProvider, error) {
+	switch {
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		return &httpConfigProvider{url: location, client: &http.Client{Timeout: configFetchTimeout}}, nil
+	case strings.HasPrefix(location, "k8s://"):
+		return newK8sConfigProvider(location)
+	}
+	return &fileConfigProvider{path: location}, nil
+}
+
+// Returns true when the location of the configuration is not a local file
+func isRemoteConfig(location string) bool {
+	for _, prefix := range []string{"http://", "https://", "k8s://"} {
+		if strings.HasPrefix(location, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetches the configuration on every interval until the done channel is closed, notifying the reload channel when it changes.
+func watchConfig(provider configProvider, interval time.Duration, reload chan<- os.Signal, done <-chan struct{}) {
+	log.Infof("Checking %s for configuration changes every %s", provider, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, changed, err := provider.Fetch()
+			if err != nil {
+				log.Warnf("Cannot fetch configuration: %v", err)
+				continue
+			}
+			if changed {
+				log.Infof("Configuration changed on %s", provider)
+				select {
+				case reload <- syscall.SIGHUP:
+				default: // A reload is already pending
+				}
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// fileConfigProvider reads the configuration from a local file, only when its modification time or size changes
+type fileConfigProvider struct {
+	path    string
+	modTime time.Time
+	size    int64
+	content []byte
+	mutex   sync.Mutex
+}
+
+func (provider *fileConfigProvider) Fetch() ([]byte, bool, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	info, err := os.Stat(provider.path)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot read configuration file: %v", err)
+	}
+	if provider.content != nil && info.ModTime().Equal(provider.modTime) && info.Size() == provider.size {
+		return provider.content, false, nil
+	}
+	content, err := os.ReadFile(provider.path)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot read configuration file: %v", err)
+	}
+	changed := !bytes.Equal(content, provider.content)
+	provider.modTime, provider.size, provider.content = info.ModTime(), info.Size(), content
+	return content, changed, nil
+}
+
+func (provider *fileConfigProvider) String() string {
+	return provider.path
+}
+
+// httpConfigProvider downloads the configuration from a URL, using conditional requests when the server returns an ETag or a Last-Modified header
+type httpConfigProvider struct {
+	url          string
+	client       *http.Client
+	etag         string
+	lastModified string
+	content      []byte
+	mutex        sync.Mutex
+}
+
+func (provider *httpConfigProvider) Fetch() ([]byte, bool, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	req, err := http.NewRequest(http.MethodGet, provider.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if provider.content != nil {
+		if provider.etag != "" {
+			req.Header.Set("If-None-Match", provider.etag)
+		}
+		if provider.lastModified != "" {
+			req.Header.Set("If-Modified-Since", provider.lastModified)
+		}
+	}
+	resp, err := provider.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot fetch configuration from %s: %v", provider.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && provider.content != nil {
+		return provider.content, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("cannot fetch configuration from %s: %s", provider.url, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot fetch configuration from %s: %v", provider.url, err)
+	}
+	// Servers without validators return the content every time, so it is compared with the previous one
+	changed := !bytes.Equal(content, provider.content)
+	provider.etag, provider.lastModified, provider.content = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), content
+	return content, changed, nil
+}
+
+func (provider *httpConfigProvider) String() string {
+	return provider.url
+}
+
+// k8sConfigProvider reads the configuration from a ConfigMap through the Kubernetes API, using the service account of the Pod.
+// The key of the ConfigMap is optional when it has a single one.
+type k8sConfigProvider struct {
+	location        string
+	apiURL          string
+	namespace       string
+	name            string
+	key             string
+	token           string
+	client          *http.Client
+	resourceVersion string
+	content         []byte
+	mutex           sync.Mutex
+}
+
+// Parses a k8s://namespace/configmap[/key] location, for a Minion running within a Kubernetes Pod
+func newK8sConfigProvider(location string) (*k8sConfigProvider, error) {
+	parts := strings.Split(strings.TrimPrefix(location, "k8s://"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid ConfigMap location %s, expected k8s://namespace/configmap[/key]", location)
+	}
+	provider := &k8sConfigProvider{location: location, namespace: parts[0], name: parts[1]}
+	if len(parts) == 3 {
+		provider.key = parts[2]
+	}
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("cannot read ConfigMap %s: the Minion is not running on Kubernetes", location)
+	}
+	provider.apiURL = "https://" + net.JoinHostPort(host, port)
+	token, err := os.ReadFile(filepath.Join(serviceAccountPath, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the service account token: %v", err)
+	}
+	provider.token = strings.TrimSpace(string(token))
+	ca, err := os.ReadFile(filepath.Join(serviceAccountPath, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the service account CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	provider.client = &http.Client{
+		Timeout:   configFetchTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+	return provider, nil
+}
+
+func (provider *k8sConfigProvider) Fetch() ([]byte, bool, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	url := fmt.Sprintf("%s/api/v1/namespaces/%s/configmaps/%s", provider.apiURL, provider.namespace, provider.name)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+provider.token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := provider.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot read ConfigMap %s: %v", provider.location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("cannot read ConfigMap %s: %s", provider.location, resp.Status)
+	}
+	configMap := struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Data map[string]string `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&configMap); err != nil {
+		return nil, false, fmt.Errorf("cannot parse ConfigMap %s: %v", provider.location, err)
+	}
+	if provider.content != nil && configMap.Metadata.ResourceVersion == provider.resourceVersion {
+		return provider.content, false, nil
+	}
+	key := provider.key
+	if key == "" {
+		if len(configMap.Data) != 1 {
+			keys := make([]string, 0, len(configMap.Data))
+			for k := range configMap.Data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return nil, false, fmt.Errorf("ConfigMap %s has keys %v, expected k8s://namespace/configmap/key", provider.location, keys)
+		}
+		for k := range configMap.Data {
+			key = k
+		}
+	}
+	value, ok := configMap.Data[key]
+	if !ok {
+		return nil, false, fmt.Errorf("ConfigMap %s doesn't have key %s", provider.location, key)
+	}
+	content := []byte(value)
+	changed := !bytes.Equal(content, provider.content)
+	provider.resourceVersion, provider.content = configMap.Metadata.ResourceVersion, content
+	return content, changed, nil
+}
+
+func (provider *k8sConfigProvider) String() string {
+	return provider.location
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFileConfigProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gominion.yaml")
+	assert.NilError(t, os.WriteFile(path, []byte("id: minion1\n"), 0644))
+	provider, err := newConfigProvider(path)
+	assert.NilError(t, err)
+
+	content, changed, err := provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	assert.Equal(t, "id: minion1\n", string(content))
+
+	_, changed, err = provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, !changed)
+
+	assert.NilError(t, os.WriteFile(path, []byte("id: minion02\n"), 0644))
+	content, changed, err = provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	assert.Equal(t, "id: minion02\n", string(content))
+}
+
+func TestHTTPConfigProvider(t *testing.T) {
+	var version, downloads int32 = 1, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		etag := fmt.Sprintf(`"v%d"`, atomic.LoadInt32(&version))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, "id: minion%d\n", atomic.LoadInt32(&version))
+	}))
+	defer server.Close()
+	assert.Assert(t, isRemoteConfig(server.URL))
+	provider, err := newConfigProvider(server.URL)
+	assert.NilError(t, err)
+
+	content, changed, err := provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	assert.Equal(t, "id: minion1\n", string(content))
+
+	content, changed, err = provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, !changed)
+	assert.Equal(t, "id: minion1\n", string(content))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+	atomic.StoreInt32(&version, 2)
+	content, changed, err = provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	assert.Equal(t, "id: minion2\n", string(content))
+
+	provider, _ = newConfigProvider(server.URL + "/missing")
+	_, _, err = provider.Fetch()
+	assert.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestK8sConfigProvider(t *testing.T) {
+	var resourceVersion int32 = 1
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/opennms/configmaps/minion" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.NotFound(w, r)
+			return
+		}
+		v := atomic.LoadInt32(&resourceVersion)
+		fmt.Fprintf(w, `{"metadata":{"resourceVersion":"%d"},"data":{"gominion.yaml":"id: minion%d\n"}}`, v, v)
+	}))
+	defer server.Close()
+	provider := &k8sConfigProvider{location: "k8s://opennms/minion", apiURL: server.URL, namespace: "opennms", name: "minion", token: "secret", client: server.Client()}
+
+	content, changed, err := provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	assert.Equal(t, "id: minion1\n", string(content))
+
+	_, changed, err = provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, !changed)
+
+	atomic.StoreInt32(&resourceVersion, 2)
+	content, changed, err = provider.Fetch()
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	assert.Equal(t, "id: minion2\n", string(content))
+
+	provider.key = "other.yaml"
+	atomic.StoreInt32(&resourceVersion, 3)
+	_, _, err = provider.Fetch()
+	assert.ErrorContains(t, err, "doesn't have key other.yaml")
+
+	_, err = newConfigProvider("k8s://opennms")
+	assert.ErrorContains(t, err, "invalid ConfigMap location")
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gominion.yaml")
+	assert.NilError(t, os.WriteFile(path, []byte("id: minion1\n"), 0644))
+	provider := &fileConfigProvider{path: path}
+	provider.Fetch()
+
+	reload := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go watchConfig(provider, 10*time.Millisecond, reload, done)
+	assert.NilError(t, os.WriteFile(path, []byte("id: minion02\n"), 0644))
+	select {
+	case <-reload:
+	case <-time.After(time.Second):
+		t.Fatal("configuration change not detected")
+	}
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+	"github.com/spf13/viper"
+)
+
+// restartSettings are the settings that cannot be changed without restarting the Minion
+var restartSettings = []struct {
+	name  string
+	value func(cfg *api.MinionConfig) interface{}
+}{
+	{"id", func(cfg *api.MinionConfig) interface{} { return cfg.ID }},
+	{"location", func(cfg *api.MinionConfig) interface{} { return cfg.Location }},
+	{"brokerType", func(cfg *api.MinionConfig) interface{} { return cfg.BrokerType }},
+	{"brokerUrl", func(cfg *api.MinionConfig) interface{} { return cfg.BrokerURL }},
+	{"brokerProperties", func(cfg *api.MinionConfig) interface{} { return cfg.BrokerProperties }},
+	{"statsPort", func(cfg *api.MinionConfig) interface{} { return cfg.StatsPort }},
+	{"logLevel", func(cfg *api.MinionConfig) interface{} { return cfg.LogLevel }},
+	{"logFormat", func(cfg *api.MinionConfig) interface{} { return cfg.LogFormat }},
+	{"logFile", func(cfg *api.MinionConfig) interface{} { return cfg.LogFile }},
+	{"logMaxSize", func(cfg *api.MinionConfig) interface{} { return cfg.LogMaxSize }},
+	{"logMaxBackups", func(cfg *api.MinionConfig) interface{} { return cfg.LogMaxBackups }},
+	{"logMaxAge", func(cfg *api.MinionConfig) interface{} { return cfg.LogMaxAge }},
+	{"snmpSessionIdleMs", func(cfg *api.MinionConfig) interface{} { return cfg.SnmpSessionIdleMs }},
+	{"enabledRpcModules", func(cfg *api.MinionConfig) interface{} { return cfg.EnabledRPCModules }},
+	{"disabledRpcModules", func(cfg *api.MinionConfig) interface{} { return cfg.DisabledRPCModules }},
+}
+
+// Reads the configuration again, and applies the changes that don't require a restart.
+// The Sink modules affected by the changes are restarted with the new configuration, without touching the broker connection.
+// Returns the new configuration, or the current one when the new configuration is invalid.
+func reloadConfig(current *api.MinionConfig, registry *api.SinkRegistry, sink api.Sink) (*api.MinionConfig, error) {
+	config, err := readConfig(registry)
+	if err != nil {
+		return current, err
+	}
+	for _, setting := range getRestartSettings(current, config) {
+		log.Warnf("The %s setting changed; a full restart is required to apply it", setting)
+	}
+	sourcePorts, _ := tools.ParsePortRange(config.SourcePortRange) // Validated by readConfig
+	tools.SetSourcePortRange(sourcePorts)
+	api.SetSNMPv3Users(config.SnmpV3Users)
+	ids := registry.GetAffectedModules(current, config)
+	if len(ids) == 0 {
+		log.Infof("Configuration reloaded, no Sink modules affected")
+		return config, nil
+	}
+	log.Infof("Configuration reloaded, restarting Sink modules %v", ids)
+	return config, registry.RestartModules(ids, config, sink)
+}
+
+// Reads and validates the configuration from the file, the environment and the flags
+func readConfig(registry *api.SinkRegistry) (*api.MinionConfig, error) {
+	if configSource != nil {
+		if _, err := readConfigSource(); err != nil {
+			return nil, err
+		}
+	} else if err := viper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("cannot read configuration file: %v", err)
+	}
+	if strictConfig {
+		if err := checkConfigSource(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := os.LookupEnv(listenersEnv); ok {
+		viper.Set("listeners", nil) // Parsed by applyEnvironment, as viper cannot decode it
+	}
+	config := &api.MinionConfig{}
+	if err := viper.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("cannot parse configuration file: %v", err)
+	}
+	if err := applyEnvironment(config, os.Environ()); err != nil {
+		return nil, err
+	}
+	if err := config.IsValid(); err != nil {
+		return nil, err
+	}
+	if _, err := tools.ParsePortRange(config.SourcePortRange); err != nil {
+		return nil, err
+	}
+	if err := config.ParseListeners(listeners); err != nil {
+		return nil, err
+	}
+	if err := config.ValidateListeners(registry.GetSupportedParsers()); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Gets the names of the settings that changed, but cannot be applied without a restart
+func getRestartSettings(current *api.MinionConfig, config *api.MinionConfig) []string {
+	names := make([]string, 0)
+	for _, setting := range restartSettings {
+		if !reflect.DeepEqual(setting.value(current), setting.value(config)) {
+			names = append(names, setting.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/spf13/viper"
+	"gotest.tools/v3/assert"
+)
+
+type reloadTestModule struct {
+	starts int
+	config *api.MinionConfig
+}
+
+func (module *reloadTestModule) GetID() string {
+	return "Graphite"
+}
+
+func (module *reloadTestModule) GetParsers() []string {
+	return []string{"ForwardParser"}
+}
+
+func (module *reloadTestModule) Start(config *api.MinionConfig, sink api.Sink) error {
+	module.starts++
+	module.config = config
+	return nil
+}
+
+func (module *reloadTestModule) Stop() {
+}
+
+func TestReloadConfig(t *testing.T) {
+	module := &reloadTestModule{}
+	registry := &api.SinkRegistry{}
+	registry.Init()
+	registry.RegisterModule(module)
+
+	path := filepath.Join(t.TempDir(), "gominion.yaml")
+	write := func(content string) {
+		assert.NilError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	viper.SetConfigType("yaml")
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	write("id: minion1\nlocation: Test\nbrokerUrl: localhost:8990\nlisteners:\n- name: Graphite\n  port: 2003\n  parser: ForwardParser\n")
+	current, err := readConfig(registry)
+	assert.NilError(t, err)
+
+	// Only the affected modules are restarted
+	write("id: minion1\nlocation: Test\nbrokerUrl: localhost:8990\nlisteners:\n- name: Graphite\n  port: 2004\n  parser: ForwardParser\n")
+	config, err := reloadConfig(current, registry, &api.MockBroker{})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, module.starts)
+	assert.Equal(t, config, module.config)
+	assert.Equal(t, 2004, config.GetListener("Graphite").Port)
+
+	config, err = reloadConfig(config, registry, &api.MockBroker{})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, module.starts)
+
+	// Invalid configurations are rejected, keeping the current one
+	write("id: minion1\nlocation: Test\nbrokerUrl: localhost:8990\nlisteners:\n- name: Graphite\n  port: 2005\n  parser: UnknownParser\n")
+	previous := config
+	config, err = reloadConfig(config, registry, &api.MockBroker{})
+	assert.ErrorContains(t, err, "UnknownParser")
+	assert.Equal(t, previous, config)
+	assert.Equal(t, 1, module.starts)
+}
+
+func TestGetRestartSettings(t *testing.T) {
+	current := &api.MinionConfig{ID: "minion1", BrokerURL: "localhost:8990", TrapPort: 1162}
+	config := &api.MinionConfig{ID: "minion1", BrokerURL: "onms:8990", TrapPort: 11162, BrokerProperties: map[string]string{"tls-enabled": "true"}}
+	assert.DeepEqual(t, []string{"brokerUrl", "brokerProperties"}, getRestartSettings(current, config))
+	assert.DeepEqual(t, []string{}, getRestartSettings(current, current))
+}
@@ -1,19 +1,26 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/broker"
+	"github.com/agalue/gominion/collectors"
 	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/sink"
+	"github.com/agalue/gominion/snmp"
+	"github.com/agalue/gominion/tools"
 
 	homedir "github.com/mitchellh/go-homedir"
-
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -25,14 +32,30 @@ var (
 	// listeners a list of Sink API listeners
 	listeners = []string{}
 
+	// strictConfig rejects configuration files with unknown keys
+	strictConfig bool
+
+	// dryRun validates the configuration and lists the modules without connecting to OpenNMS
+	dryRun bool
+
+	// configPollInterval is how often the configuration source is checked for changes (0 disables it)
+	configPollInterval time.Duration
+
+	// configSource is the provider of the configuration, when there is one
+	configSource configProvider
+
 	// minionConfig is the Minion configuration with defaults
 	minionConfig = &api.MinionConfig{
-		BrokerType: "grpc",
-		Location:   "Local",
-		BrokerURL:  "localhost:8990",
-		TrapPort:   1162,
-		SyslogPort: 1514,
-		LogLevel:   "debug",
+		BrokerType:        "grpc",
+		Location:          "Local",
+		BrokerURL:         "localhost:8990",
+		TrapPort:          1162,
+		SyslogPort:        1514,
+		LogLevel:          "debug",
+		LogFormat:         "console",
+		LogMaxSize:        100,
+		LogMaxBackups:     5,
+		SnmpSessionIdleMs: 60000,
 	}
 
 	// rootCmd represents the base command that starts the Minion's gRPC client
@@ -49,7 +72,9 @@ var (
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		if err != errRunFailed {
+			fmt.Println(err)
+		}
 		os.Exit(1)
 	}
 }
@@ -61,16 +86,31 @@ func init() {
 	// Initialize Flags
 	hostname, _ := os.Hostname()
 	rootCmd.Flags().SortFlags = false
-	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is ~/.gominion.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file, http(s):// URL or k8s://namespace/configmap[/key] (default is ~/.gominion.yaml)")
 	rootCmd.Flags().StringVarP(&minionConfig.ID, "id", "i", hostname, "Minion ID")
 	rootCmd.Flags().StringVarP(&minionConfig.Location, "location", "l", minionConfig.Location, "Minion Location")
-	rootCmd.Flags().StringVarP(&minionConfig.BrokerType, "brokerType", "b", minionConfig.BrokerType, "Broker Type, either grpc or kafka")
-	rootCmd.Flags().StringVarP(&minionConfig.BrokerURL, "brokerUrl", "u", minionConfig.BrokerURL, "Broker URL")
+	rootCmd.Flags().StringVarP(&minionConfig.BrokerType, "brokerType", "b", minionConfig.BrokerType, "Broker Type, either grpc, kafka or nats")
+	rootCmd.Flags().StringVarP(&minionConfig.BrokerURL, "brokerUrl", "u", minionConfig.BrokerURL, "Broker URL (for gRPC, a comma-separated list of servers to fail over)")
 	rootCmd.Flags().IntVarP(&minionConfig.TrapPort, "trapPort", "t", minionConfig.TrapPort, "SNMP Trap port")
 	rootCmd.Flags().IntVarP(&minionConfig.SyslogPort, "syslogPort", "s", minionConfig.SyslogPort, "Syslog port")
+	rootCmd.Flags().IntVar(&minionConfig.SyslogBufferSize, "syslogBufferSize", minionConfig.SyslogBufferSize, "Syslog UDP receive buffer size in bytes (defaults to the OS setting)")
+	rootCmd.Flags().StringVar(&minionConfig.BindAddress, "bindAddress", minionConfig.BindAddress, "Local IP address for the UDP receivers (defaults to all interfaces)")
+	rootCmd.Flags().StringVar(&minionConfig.SourcePortRange, "sourcePortRange", minionConfig.SourcePortRange, "Local port range for the outbound connections of the monitors, detectors and collectors as min-max (defaults to any port)")
 	rootCmd.Flags().IntVarP(&minionConfig.StatsPort, "statsPort", "S", minionConfig.StatsPort, "HTTP Prometheus exporter statistics port")
-	rootCmd.Flags().StringArrayVarP(&listeners, "listener", "L", nil, "Flow/Telemetry listeners\ne.x. -L Graphite,2003,ForwardParser -L NXOS,5000,NxosGrpcParser")
+	rootCmd.Flags().IntVar(&minionConfig.MaxListenerWorkers, "maxListenerWorkers", minionConfig.MaxListenerWorkers, "Maximum number of workers shared by all the Sink listeners (0 means unlimited)")
+	rootCmd.Flags().IntVar(&minionConfig.SnmpSessionIdleMs, "snmpSessionIdleMs", minionConfig.SnmpSessionIdleMs, "Time in milliseconds before closing idle SNMP sessions (0 disables the SNMP session cache)")
+	rootCmd.Flags().StringSliceVar(&minionConfig.EnabledRPCModules, "enabledRpcModules", minionConfig.EnabledRPCModules, "RPC modules allowed to answer requests (defaults to all)")
+	rootCmd.Flags().StringSliceVar(&minionConfig.DisabledRPCModules, "disabledRpcModules", minionConfig.DisabledRPCModules, "RPC modules that reject requests")
+	rootCmd.Flags().StringArrayVarP(&listeners, "listener", "L", nil, "Flow/Telemetry listeners as name,port,parser[,key=value...]\ne.x. -L Graphite,2003,ForwardParser -L NXOS,5000,NxosGrpcParser,workers=2")
 	rootCmd.Flags().StringVarP(&minionConfig.LogLevel, "logLevel", "x", minionConfig.LogLevel, "Logging level")
+	rootCmd.Flags().StringVar(&minionConfig.LogFormat, "logFormat", minionConfig.LogFormat, "Logging format, either console or json")
+	rootCmd.Flags().StringVar(&minionConfig.LogFile, "logFile", minionConfig.LogFile, "Log file, rotated by size (defaults to stdout)")
+	rootCmd.Flags().IntVar(&minionConfig.LogMaxSize, "logMaxSize", minionConfig.LogMaxSize, "Size in megabytes before rotating the log file")
+	rootCmd.Flags().IntVar(&minionConfig.LogMaxBackups, "logMaxBackups", minionConfig.LogMaxBackups, "Number of rotated log files to keep (0 keeps all)")
+	rootCmd.Flags().IntVar(&minionConfig.LogMaxAge, "logMaxAge", minionConfig.LogMaxAge, "Days to keep the rotated log files (0 keeps them regardless of their age)")
+	rootCmd.Flags().BoolVar(&strictConfig, "strict-config", false, "Fail to start when the configuration file has unknown keys")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Validate the configuration and list the modules without connecting to OpenNMS")
+	rootCmd.Flags().DurationVar(&configPollInterval, "configPollInterval", 0, "Interval to check the configuration for changes, reloading it as SIGHUP does (0 disables it)")
 
 	// Initialize Flag Binding
 	viper.BindPFlags(rootCmd.Flags())
@@ -79,7 +119,12 @@ func init() {
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	viper.SetConfigType("yaml")
-	if cfgFile != "" {
+	if isRemoteConfig(cfgFile) {
+		var err error
+		if configSource, err = newConfigProvider(cfgFile); err != nil {
+			log.Fatalf("Invalid configuration location: %v", err)
+		}
+	} else if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
 	} else {
@@ -91,62 +136,186 @@ func initConfig() {
 		viper.SetConfigName(".gominion")
 	}
 
-	viper.SetEnvPrefix("GOMINION")
+	viper.SetEnvPrefix(envPrefix)
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
+	if configSource != nil {
+		if _, err := readConfigSource(); err == nil {
+			log.Infof("Using configuration from %s", configSource)
+		} else {
+			log.Warnf("Cannot read configuration: %v", err)
+		}
+	} else if err := viper.ReadInConfig(); err == nil {
 		log.Infof("Using config file:", viper.ConfigFileUsed())
+		configSource = &fileConfigProvider{path: viper.ConfigFileUsed()}
+		configSource.Fetch() // Keeps the state of the file to detect changes
 	} else {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			log.Warnf("Cannot read configuration file: %v", err)
 		}
 	}
+	if _, ok := os.LookupEnv(listenersEnv); ok {
+		viper.Set("listeners", nil) // Parsed by applyEnvironment, as viper cannot decode it
+	}
 	if err := viper.Unmarshal(minionConfig); err != nil {
 		log.Warnf("Cannot parse configuration file: %v", err)
 	}
+	if err := applyEnvironment(minionConfig, os.Environ()); err != nil {
+		log.Fatalf("Invalid environment configuration: %v", err)
+	}
 }
 
 func rootHandler(cmd *cobra.Command, args []string) {
-	log.InitLogger(minionConfig.LogLevel)
+	log.InitLogger(minionConfig.LogLevel, minionConfig.LogFormat, getLogFile(minionConfig))
+	api.SetVersion(cmd.Root().Version)
 	// Validate configuration
+	if strictConfig {
+		if err := checkConfigSource(); err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+	}
 	if err := minionConfig.IsValid(); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
+	sourcePorts, err := tools.ParsePortRange(minionConfig.SourcePortRange)
+	if err != nil {
+		log.Fatalf("Invalid source port range: %v", err)
+	}
 	if err := minionConfig.ParseListeners(listeners); err != nil {
 		log.Fatalf("Invalid listener configuration: %v", err)
 	}
+	sinkRegistry := sink.CreateSinkRegistry()
+	if err := minionConfig.ValidateListeners(sinkRegistry.GetSupportedParsers()); err != nil {
+		log.Fatalf("Invalid listener configuration: %v", err)
+	}
+	if dryRun {
+		log.Infof("Configuration is valid\n%s", minionConfig.String())
+		broker.DisplayRegisteredModules(sinkRegistry, log.Infof)
+		return
+	}
+	api.SetSNMPv3Users(minionConfig.SnmpV3Users)
+	tools.SetSourcePortRange(sourcePorts)
+	snmp.Configure(time.Duration(minionConfig.SnmpSessionIdleMs) * time.Millisecond)
 	// Initialize metrics object
 	metrics := api.NewMetrics()
 	if minionConfig.StatsPort > 0 {
 		metrics.Register()
 	}
+	sink.SetMetrics(metrics)
 	// Initialize client broker
-	sinkRegistry := sink.CreateSinkRegistry()
-	broker.DisplayRegisteredModules(sinkRegistry)
+	broker.DisplayRegisteredModules(sinkRegistry, log.Debugf)
+	broker.DisplayModuleSummary(sinkRegistry, log.Infof)
+	sink.DisplayListenerWorkers(minionConfig, log.Infof)
 	client := broker.GetBroker(minionConfig, sinkRegistry, metrics)
 	if client == nil {
 		log.Fatalf("Cannot find broker implementation for %s", minionConfig.BrokerType)
 	}
 	// Start statistics server
+	var statsServer *http.Server
 	if minionConfig.StatsPort > 0 {
-		go func() {
-			log.Infof("Starting Prometheus Metrics server on port %d", minionConfig.StatsPort)
-			http.Handle("/", promhttp.Handler())
-			err := http.ListenAndServe(fmt.Sprintf(":%d", minionConfig.StatsPort), nil)
-			if err != nil {
-				log.Fatalf("Cannot start prometheus HTTP server: %v", err)
-			}
-		}()
+		statsServer = startStatsServer(minionConfig.StatsPort)
 	}
 	// Start client broker
 	log.Infof("Starting OpenNMS Minion...\n%s", minionConfig.String())
 	if err := client.Start(); err != nil {
-		log.Fatalf("Cannot connect via %s: %v", minionConfig.BrokerType, err)
+		log.Fatalf("Cannot start the %s broker: %v", minionConfig.BrokerType, err)
 	}
-	// Wait for termination signal
+	// Wait for termination signal, reloading the configuration on SIGHUP
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
-	<-stop
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	done := make(chan struct{})
+	if configPollInterval > 0 && configSource != nil {
+		go watchConfig(configSource, configPollInterval, reload, done)
+	}
+	current := minionConfig
+	for waiting := true; waiting; {
+		select {
+		case <-reload:
+			log.Infof("Reloading configuration")
+			var err error
+			if current, err = reloadConfig(current, sinkRegistry, client.(api.Sink)); err != nil {
+				log.Errorf("Cannot reload configuration: %v", err)
+			}
+		case <-stop:
+			waiting = false
+		}
+	}
+	close(done)
 	client.Stop()
+	collectors.StopAllCollectors()
+	snmp.Close()
+	if statsServer != nil {
+		stopStatsServer(statsServer)
+	}
+}
+
+// Gets the log file from the configuration, or nil to log to stdout
+func getLogFile(cfg *api.MinionConfig) *log.FileOutput {
+	if cfg.LogFile == "" {
+		return nil
+	}
+	return &log.FileOutput{
+		Path:       cfg.LogFile,
+		MaxSize:    cfg.LogMaxSize,
+		MaxBackups: cfg.LogMaxBackups,
+		MaxAge:     cfg.LogMaxAge,
+	}
+}
+
+// Reads the configuration from its provider into viper, returning the content
+func readConfigSource() ([]byte, error) {
+	content, _, err := configSource.Fetch()
+	if err != nil {
+		return nil, err
+	}
+	if err := viper.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, fmt.Errorf("cannot parse configuration from %s: %v", configSource, err)
+	}
+	return content, nil
+}
+
+// checkConfigSource verifies the keys of the configuration from its provider, or from the file used by viper when there is none
+func checkConfigSource() error {
+	if configSource == nil {
+		return checkConfigFile(viper.ConfigFileUsed())
+	}
+	content, _, err := configSource.Fetch()
+	if err != nil {
+		return err
+	}
+	return checkConfigContent(configSource.String(), content)
+}
+
+// checkConfigFile verifies that all the keys of the configuration file are known (if any file is used)
+// Viper ignores the unknown keys, so a typo would silently leave the setting with its default value.
+func checkConfigFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read configuration file %s: %v", path, err)
+	}
+	return checkConfigContent(path, content)
+}
+
+// checkConfigContent verifies that all the keys of the configuration are known
+func checkConfigContent(path string, content []byte) error {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("cannot read configuration file %s: %v", path, err)
+	}
+	metadata := &mapstructure.Metadata{}
+	if err := v.Unmarshal(&api.MinionConfig{}, func(c *mapstructure.DecoderConfig) { c.Metadata = metadata }); err != nil {
+		return fmt.Errorf("cannot parse configuration file %s: %v", path, err)
+	}
+	if len(metadata.Unused) > 0 {
+		sort.Strings(metadata.Unused)
+		return fmt.Errorf("unknown keys on configuration file %s: %s", path, strings.Join(metadata.Unused, ", "))
+	}
+	return nil
 }
@@ -3,6 +3,8 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/agalue/gominion/api"
@@ -77,3 +79,32 @@ listeners:
 		assert.Equal(t, "4", netflow.Properties["workers"])
 	}
 }
+
+func TestCheckConfigFile(t *testing.T) {
+	assert.NilError(t, checkConfigFile(""))
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "default.yaml")
+	assert.NilError(t, writeDefaultConfig(new(bytes.Buffer), path, false))
+	assert.NilError(t, checkConfigFile(path))
+
+	path = filepath.Join(dir, "typo.yaml")
+	configYAML := []byte(`---
+id: go-minion1
+brokerProperites:
+  tls-enabled: "true"
+dns:
+  nameServer: 8.8.8.8
+  timeoutMs: 1000
+listeners:
+- name: Netflow-5
+  port: 18877
+  parser: Netflow5UdpParser
+  propertes:
+    workers: "4"
+`)
+	assert.NilError(t, os.WriteFile(path, configYAML, 0644))
+	err := checkConfigFile(path)
+	assert.ErrorContains(t, err, "unknown keys")
+	assert.ErrorContains(t, err, "DNS.timeoutms, Listeners[0].propertes, brokerproperites")
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/collectors"
+	"github.com/agalue/gominion/detectors"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/monitors"
+	"github.com/agalue/gominion/tools"
+	"github.com/spf13/cobra"
+)
+
+// errRunFailed is returned when the module ran, but the service is not available, not detected, or the collection failed.
+// The result was already printed, so it only sets the exit code.
+var errRunFailed = errors.New("run failed")
+
+// runResult is the JSON output of the run commands
+type runResult struct {
+	Module     string            `json:"module"`
+	Target     string            `json:"target"`
+	Status     string            `json:"status"`
+	Reason     string            `json:"reason,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Attributes []runAttribute    `json:"attributes,omitempty"`
+}
+
+// runAttribute is a collected attribute in the JSON output of the run collector command
+type runAttribute struct {
+	Resource string `json:"resource"`
+	Group    string `json:"group"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+}
+
+var (
+	// runTarget is the IP address or hostname of the target
+	runTarget string
+
+	// runParams are the module parameters as key=value
+	runParams []string
+
+	// runVerbose enables the debug logs of the module
+	runVerbose bool
+
+	// runCmd groups the commands to execute a module locally
+	runCmd = &cobra.Command{
+		Use:   "run",
+		Short: "Execute a monitor, detector or collector against a target without OpenNMS",
+	}
+
+	// runMonitorCmd executes a service monitor
+	runMonitorCmd = &cobra.Command{
+		Use:           "monitor <id>",
+		Short:         "Poll a service with the given monitor (e.g. TcpMonitor); exits with 1 when the service is not up",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runModule(cmd.OutOrStdout(), func(target string, params map[string]string) (*runResult, error) {
+				return runMonitor(args[0], target, params)
+			})
+		},
+	}
+
+	// runDetectorCmd executes a service detector
+	runDetectorCmd = &cobra.Command{
+		Use:           "detector <id>",
+		Short:         "Detect a service with the given detector (e.g. TcpDetector); exits with 1 when the service is not detected",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runModule(cmd.OutOrStdout(), func(target string, params map[string]string) (*runResult, error) {
+				return runDetector(args[0], target, params)
+			})
+		},
+	}
+
+	// runCollectorCmd executes a service collector
+	runCollectorCmd = &cobra.Command{
+		Use:           "collector <id>",
+		Short:         "Collect data with the given collector (e.g. HttpCollector); exits with 1 when the collection fails",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runModule(cmd.OutOrStdout(), func(target string, params map[string]string) (*runResult, error) {
+				return runCollector(args[0], target, params)
+			})
+		},
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{runMonitorCmd, runDetectorCmd, runCollectorCmd} {
+		cmd.Flags().StringVar(&runTarget, "target", "", "IP address or hostname of the target")
+		cmd.Flags().StringArrayVar(&runParams, "param", nil, "Module parameter as key=value (can be repeated)\ne.x. --param port=443 --param timeout=3000")
+		cmd.Flags().BoolVar(&runVerbose, "verbose", false, "Log the module activity")
+		cmd.MarkFlagRequired("target")
+		runCmd.AddCommand(cmd)
+	}
+	rootCmd.AddCommand(runCmd)
+}
+
+// Parses the parameters, executes the module, and prints the result as JSON
+func runModule(out io.Writer, run func(target string, params map[string]string) (*runResult, error)) error {
+	if runVerbose {
+		log.InitLogger("debug", "console", nil)
+	}
+	api.SetSNMPv3Users(minionConfig.SnmpV3Users)
+	sourcePorts, err := tools.ParsePortRange(minionConfig.SourcePortRange)
+	if err != nil {
+		return err
+	}
+	tools.SetSourcePortRange(sourcePorts)
+	params, err := parseRunParams(runParams)
+	if err != nil {
+		return err
+	}
+	target, err := api.NormalizeIPAddress(runTarget, true)
+	if err != nil {
+		return err
+	}
+	result, err := run(target, params)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	if !isRunSuccessful(result.Status) {
+		return errRunFailed
+	}
+	return nil
+}
+
+// Parses the module parameters in key=value format
+func parseRunParams(params []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, p := range params {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid parameter %s, expected key=value", p)
+		}
+		result[strings.TrimSpace(parts[0])] = parts[1]
+	}
+	return result, nil
+}
+
+// Returns true when the status represents an available service, a detected service, or a successful collection
+func isRunSuccessful(status string) bool {
+	return status == api.ServiceAvailable || status == "detected" || status == api.CollectionStatusSucceded
+}
+
+// Polls a service with the given monitor
+func runMonitor(id string, target string, params map[string]string) (*runResult, error) {
+	monitor, ok := monitors.GetMonitor(id)
+	if !ok {
+		ids := make([]string, 0)
+		for _, m := range monitors.GetAllMonitors() {
+			ids = append(ids, m.GetID())
+		}
+		return nil, unknownModuleError("monitor", id, ids)
+	}
+	request := &api.PollerRequestDTO{
+		ClassName:   id,
+		ServiceName: params["service-name"],
+		IPAddress:   target,
+		NodeID:      params["node-id"],
+		NodeLabel:   params["node-label"],
+	}
+	for key, value := range params {
+		request.Attributes = append(request.Attributes, api.PollerAttributeDTO{Key: key, Value: value})
+	}
+	response := monitor.Poll(request)
+	result := &runResult{Module: id, Target: target, Reason: response.Error}
+	if status := response.Status; status != nil {
+		result.Status = status.StatusName
+		if result.Reason == "" {
+			result.Reason = status.Reason
+		}
+		if status.Properties != nil {
+			result.Properties = make(map[string]string)
+			for _, p := range status.Properties.PropertyList {
+				result.Properties[p.Key] = fmt.Sprintf("%v", p.Value)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Detects a service with the given detector
+func runDetector(id string, target string, params map[string]string) (*runResult, error) {
+	detector, ok := detectors.GetDetector(id)
+	if !ok {
+		ids := make([]string, 0)
+		for _, d := range detectors.GetAllDetectors() {
+			ids = append(ids, d.GetID())
+		}
+		return nil, unknownModuleError("detector", id, ids)
+	}
+	request := &api.DetectorRequestDTO{ClassName: id, IPAddress: target}
+	for key, value := range params {
+		request.DetectorAttributes = append(request.DetectorAttributes, api.DetectorAttributeDTO{Key: key, Value: value})
+	}
+	response := detector.Detect(request)
+	result := &runResult{Module: id, Target: target, Status: response.GetStatus(), Reason: response.Error}
+	if len(response.Attributes) > 0 {
+		result.Properties = make(map[string]string)
+		for _, attr := range response.Attributes {
+			result.Properties[attr.Key] = attr.Value
+		}
+	}
+	return result, nil
+}
+
+// Collects data with the given collector
+func runCollector(id string, target string, params map[string]string) (*runResult, error) {
+	collector, ok := collectors.GetCollector(id)
+	if !ok {
+		ids := make([]string, 0)
+		for _, c := range collectors.GetAllCollectors() {
+			ids = append(ids, c.GetID())
+		}
+		return nil, unknownModuleError("collector", id, ids)
+	}
+	request := &api.CollectorRequestDTO{
+		ClassName: id,
+		CollectionAgent: &api.CollectionAgentDTO{
+			IPAddress: target,
+			NodeLabel: params["node-label"],
+		},
+	}
+	for key, value := range params {
+		request.Attributes = append(request.Attributes, api.CollectionAttributeDTO{Key: key, Content: value})
+	}
+	response := collector.Collect(request)
+	defer collectors.StopAllCollectors()
+	result := &runResult{Module: id, Target: target, Status: api.CollectionStatusUnknown, Reason: response.Error}
+	if set := response.CollectionSet; set != nil {
+		result.Status = set.Status
+		for _, resource := range set.Resources {
+			result.Attributes = append(result.Attributes, getRunAttributes(resource)...)
+		}
+	}
+	return result, nil
+}
+
+// Flattens the attributes of a collection resource, including its children
+func getRunAttributes(resource api.CollectionResourceDTO) []runAttribute {
+	name := "node"
+	switch r := resource.ResourceType.(type) {
+	case *api.InterfaceLevelResourceDTO:
+		name = "interface[" + r.IntfName + "]"
+	case *api.GenericTypeResourceDTO:
+		name = r.Name + "[" + r.Instance + "]"
+	}
+	attributes := make([]runAttribute, 0)
+	for _, list := range [][]api.ResourceAttributeDTO{resource.NumericAttributes, resource.StringAttributes} {
+		for _, attr := range list {
+			attributes = append(attributes, runAttribute{Resource: name, Group: attr.Group, Name: attr.Name, Type: attr.Type, Value: attr.Value})
+		}
+	}
+	for _, child := range resource.Resources {
+		attributes = append(attributes, getRunAttributes(child)...)
+	}
+	return attributes
+}
+
+// Builds the error for an unknown module, listing the available ones
+func unknownModuleError(kind string, id string, ids []string) error {
+	sort.Strings(ids)
+	return fmt.Errorf("cannot find %s %s, available: %s", kind, id, strings.Join(ids, ", "))
+}
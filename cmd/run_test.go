@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestRunMonitor(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	runTarget = "127.0.0.1"
+	runParams = []string{"port=" + port, "timeout=1000"}
+	out := &bytes.Buffer{}
+	err = runModule(out, func(target string, params map[string]string) (*runResult, error) {
+		return runMonitor("TcpMonitor", target, params)
+	})
+	assert.NilError(t, err)
+	result := &runResult{}
+	assert.NilError(t, json.Unmarshal(out.Bytes(), result))
+	assert.Equal(t, api.ServiceAvailable, result.Status)
+	assert.Assert(t, result.Properties["response-time"] != "")
+
+	listener.Close()
+	out.Reset()
+	err = runModule(out, func(target string, params map[string]string) (*runResult, error) {
+		return runDetector("TcpDetector", target, params)
+	})
+	assert.Equal(t, errRunFailed, err)
+	assert.NilError(t, json.Unmarshal(out.Bytes(), result))
+	assert.Equal(t, "not detected", result.Status)
+}
+
+func TestRunErrors(t *testing.T) {
+	_, err := parseRunParams([]string{"port"})
+	assert.ErrorContains(t, err, "expected key=value")
+
+	_, err = runCollector("UnknownCollector", "127.0.0.1", nil)
+	assert.ErrorContains(t, err, "cannot find collector UnknownCollector")
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Starts the HTTP server for the Prometheus metrics and the health check (non-blocking method)
+func startStatsServer(port int) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/", promhttp.Handler()) // For backward compatibility
+	mux.HandleFunc("/healthz", healthHandler)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	go func() {
+		log.Infof("Starting Prometheus Metrics server on port %d", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Cannot start prometheus HTTP server: %v", err)
+		}
+	}()
+	return server
+}
+
+// Stops the HTTP server, waiting for the active requests to finish
+func stopStatsServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warnf("Cannot stop prometheus HTTP server: %v", err)
+	}
+}
+
+// Returns the health status of the Minion as JSON; the status code is 503 when the broker is not ready, the RPC channel is not verified, or a module has failed
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	health := api.GetHealth()
+	w.Header().Set("Content-Type", "application/json")
+	if !health.IsHealthy() || !health.IsBrokerReady() || !health.IsRPCReady() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
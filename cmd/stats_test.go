@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestHealthHandler(t *testing.T) {
+	api.SetBrokerState("CONNECTING")
+	recorder := httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	api.SetBrokerState("READY")
+	recorder = httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	health := &api.MinionHealthDTO{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), health))
+	assert.Equal(t, "READY", health.BrokerState)
+	assert.Assert(t, health.IsHealthy())
+
+	api.SetRPCChannelState(api.RPCChannelVerifying)
+	recorder = httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	api.SetRPCChannelState(api.RPCChannelVerified)
+	recorder = httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	api.SetRPCChannelState("")
+
+	api.ReportModuleFailure("NXOS", fmt.Errorf("cannot bind"))
+	defer api.ClearModuleFailure("NXOS")
+	recorder = httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+}
+
+func TestStatsServer(t *testing.T) {
+	server := startStatsServer(18181)
+	defer stopStatsServer(server)
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ { // Wait for the server to start
+		if resp, err = http.Get("http://127.0.0.1:18181/metrics"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
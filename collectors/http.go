@@ -1,12 +1,17 @@
 package collectors
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/log"
@@ -25,7 +30,13 @@ func (collector *HTTPCollector) GetID() string {
 }
 
 // Collect execute the collector request and return the collection response
+// The response-type attribute selects how the attributes are extracted: text (regex match groups, the default), xml (XPath locators), or json (JSON path locators).
 func (collector *HTTPCollector) Collect(request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	return collector.CollectWithContext(context.Background(), request)
+}
+
+// CollectWithContext executes the collection like Collect, until the context is done
+func (collector *HTTPCollector) CollectWithContext(ctx context.Context, request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
 	response := &api.CollectorResponseDTO{}
 	httpCollection := &api.HTTPCollection{}
 	err := xml.Unmarshal([]byte(request.GetAttributeValue(httpCollectionAttr, "")), httpCollection)
@@ -33,50 +44,92 @@ func (collector *HTTPCollector) Collect(request *api.CollectorRequestDTO) *api.C
 		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("cannot parse %s: %v", httpCollectionAttr, err))
 		return response
 	}
+	var querier XPathQuerier
+	switch responseType := strings.ToLower(request.GetAttributeValue("response-type", "text")); responseType {
+	case "text":
+	case "xml", "json":
+		querier = &XPathQuery{kind: responseType}
+	default:
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("invalid response-type %s", responseType))
+		return response
+	}
 	builder := api.NewCollectionSetBuilder(request.CollectionAgent)
-	nodeResource := &api.CollectionResourceDTO{
-		ResourceType: &api.NodeLevelResourceDTO{
-			NodeID: request.CollectionAgent.NodeID,
-		},
+	nodeResource := api.NewNodeResource(request.CollectionAgent)
+	if httpCollection.URIs == nil {
+		httpCollection.URIs = &api.HTTPUriList{}
 	}
 	for _, uri := range httpCollection.URIs.URIList {
-		u := url.URL{
-			Scheme: "http",
-			Host:   request.CollectionAgent.IPAddress + ":" + request.GetAttributeValue("port", "80"),
-			Path:   uri.URL.Path,
-		}
-		log.Debugf("Executing an HTTP GET against %s", u.String())
-		httpreq, err := http.NewRequest("GET", u.String(), nil)
+		data, err := collector.fetch(ctx, request, uri)
 		if err != nil {
 			response.MarkAsFailed(request.CollectionAgent, err)
 			return response
 		}
-		if uri.URL.UserAgent != "" {
-			httpreq.Header.Set("User-Agent", uri.URL.UserAgent)
+		if querier == nil {
+			if err := collector.AddResourceAttributes(builder, nodeResource, uri, string(data)); err != nil {
+				log.FromContext(ctx).Warnf("Cannot extract attributes for %s: %v", uri.Name, err)
+			}
+			continue
 		}
-		client := tools.GetHTTPClient(false, request.GetTimeout())
-		httpres, err := client.Do(httpreq)
+		failures, err := collector.AddLocatedAttributes(builder, nodeResource, uri, querier, data)
 		if err != nil {
-			response.MarkAsFailed(request.CollectionAgent, err)
-			return response
-		}
-		min, max := tools.ParseHTTPResponseRange(uri.URL.ResponseRange)
-		if httpres.StatusCode < min || httpres.StatusCode > max {
-			exerr := fmt.Errorf("response code %d out of expected range: %d-%d", httpres.StatusCode, min, max)
-			response.MarkAsFailed(request.CollectionAgent, exerr)
+			response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("cannot parse response for %s: %v", uri.Name, err))
 			return response
 		}
-		data, err := ioutil.ReadAll(httpres.Body)
-		if err != nil {
-			response.MarkAsFailed(request.CollectionAgent, err)
-			return response
+		for alias, err := range failures {
+			log.FromContext(ctx).Warnf("Cannot collect attribute %s for %s from %s: %v", alias, uri.Name, request.CollectionAgent.IPAddress, err)
 		}
-		collector.AddResourceAttributes(builder, nodeResource, uri, string(data))
 	}
-	response.CollectionSet = builder.Build()
+	response.SetCollectionSet(builder)
 	return response
 }
 
+// Executes an HTTP GET for a given URI and returns the response body
+func (collector *HTTPCollector) fetch(ctx context.Context, request *api.CollectorRequestDTO, uri api.HTTPUri) ([]byte, error) {
+	if uri.URL == nil {
+		return nil, fmt.Errorf("missing url for %s", uri.Name)
+	}
+	u := url.URL{
+		Scheme:   request.GetAttributeValue("scheme", "http"),
+		Host:     net.JoinHostPort(request.CollectionAgent.IPAddress, request.GetAttributeValue("port", "80")),
+		Path:     uri.URL.Path,
+		RawQuery: uri.URL.Query,
+	}
+	log.FromContext(ctx).Debugf("Executing an HTTP GET against %s", u.String())
+	httpreq, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if uri.URL.UserAgent != "" {
+		httpreq.Header.Set("User-Agent", uri.URL.UserAgent)
+	}
+	// Custom headers are defined as header0, header1, etc. using the "Name: value" format
+	for i := 0; ; i++ {
+		header := request.GetAttributeValue(fmt.Sprintf("header%d", i), "")
+		if header == "" {
+			break
+		}
+		if parts := strings.SplitN(header, ":", 2); len(parts) == 2 {
+			httpreq.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		} else {
+			log.FromContext(ctx).Warnf("Ignoring invalid header %s", header)
+		}
+	}
+	if user := request.GetAttributeValue("user", ""); user != "" {
+		httpreq.SetBasicAuth(user, request.GetAttributeValue("password", ""))
+	}
+	client := tools.GetHTTPClient(false, request.GetTimeout(), nil)
+	httpres, err := client.Do(httpreq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpres.Body.Close()
+	min, max := tools.ParseHTTPResponseRange(uri.URL.ResponseRange)
+	if httpres.StatusCode < min || httpres.StatusCode > max {
+		return nil, fmt.Errorf("response code %d out of expected range: %d-%d", httpres.StatusCode, min, max)
+	}
+	return ioutil.ReadAll(httpres.Body)
+}
+
 // AddResourceAttributes adds attributes to resource based on HTML and URI configuration
 func (collector *HTTPCollector) AddResourceAttributes(builder *api.CollectionSetBuilder, cres *api.CollectionResourceDTO, uri api.HTTPUri, html string) error {
 	rp, err := regexp.Compile(uri.URL.Matches)
@@ -94,6 +147,68 @@ func (collector *HTTPCollector) AddResourceAttributes(builder *api.CollectionSet
 	return nil
 }
 
+// AddLocatedAttributes adds attributes to resource using the locator of each attribute against the parsed document.
+// It returns the attributes that cannot be collected indexed by alias, or an error when the document cannot be parsed.
+func (collector *HTTPCollector) AddLocatedAttributes(builder *api.CollectionSetBuilder, cres *api.CollectionResourceDTO, uri api.HTTPUri, querier XPathQuerier, data []byte) (map[string]error, error) {
+	doc, err := querier.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	failures := make(map[string]error)
+	if uri.Attributes == nil {
+		return failures, nil
+	}
+	for _, attr := range uri.Attributes.AttributeList {
+		if attr.Locator == "" {
+			failures[attr.Alias] = fmt.Errorf("missing locator")
+			continue
+		}
+		node, err := querier.Query(doc, jsonPathToXPath(attr.Locator))
+		if err != nil {
+			failures[attr.Alias] = fmt.Errorf("locator %s: %v", attr.Locator, err)
+			continue
+		}
+		value := strings.TrimSpace(node.GetContent())
+		if attr.Type != "string" {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				failures[attr.Alias] = fmt.Errorf("locator %s: invalid numeric value %q", attr.Locator, value)
+				continue
+			}
+		}
+		builder.WithAttribute(cres, uri.Name, attr.Alias, value, attr.Type)
+	}
+	return failures, nil
+}
+
+// Converts a JSON path expression like $.stats.cpu[0].load into the XPath expression understood by the JSON querier.
+// Expressions that don't start with $ are assumed to be XPath already.
+func jsonPathToXPath(path string) string {
+	if !strings.HasPrefix(path, "$") {
+		return path
+	}
+	xpath := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, "$"), "."), ".") {
+		name := segment
+		indexes := ""
+		if i := strings.Index(segment, "["); i >= 0 {
+			name = segment[:i]
+			for _, idx := range strings.Split(strings.TrimSuffix(segment[i+1:], "]"), "][") {
+				if n, err := strconv.Atoi(idx); err == nil {
+					indexes += fmt.Sprintf("/*[%d]", n+1)
+				}
+			}
+		}
+		if name != "" {
+			xpath += "/" + name
+		}
+		xpath += indexes
+	}
+	if xpath == "" {
+		return "/"
+	}
+	return xpath
+}
+
 func init() {
 	RegisterCollector(&HTTPCollector{})
 }
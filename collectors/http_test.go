@@ -87,3 +87,88 @@ func TestHttpCollector(t *testing.T) {
 	assert.Equal(t, 1, len(response.CollectionSet.Resources))
 	assert.Equal(t, 2, len(response.CollectionSet.Resources[0].NumericAttributes))
 }
+
+var mockHTTPJSONCollection = `
+<http-collection name="json">
+	<uris>
+		<uri name="stats">
+			<url path="/stats" query="format=json" response-range="200"/>
+			<attributes>
+				<attrib alias="cpuLoad" locator="$.stats.cpu[1].load" type="gauge"/>
+				<attrib alias="requests" locator="/stats/requests" type="counter"/>
+				<attrib alias="missing" locator="$.stats.missing" type="gauge"/>
+			</attributes>
+		</uri>
+	</uris>
+</http-collection>
+`
+
+var mockJSON = `{"stats":{"cpu":[{"load":1.5},{"load":2.5}],"requests":1024}}`
+
+func TestJSONPathToXPath(t *testing.T) {
+	assert.Equal(t, "/stats/cpu/*[2]/load", jsonPathToXPath("$.stats.cpu[1].load"))
+	assert.Equal(t, "/*[1]/name", jsonPathToXPath("$[0].name"))
+	assert.Equal(t, "/", jsonPathToXPath("$"))
+	assert.Equal(t, "//load", jsonPathToXPath("//load"))
+}
+
+func TestHttpCollectorWithJSON(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != "admin" || pass != "secret" || req.Header.Get("X-Api-Key") != "12345" || req.URL.Query().Get("format") != "json" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		res.Write([]byte(mockJSON))
+	}))
+	defer testServer.Close()
+	u, err := url.Parse(testServer.URL)
+	assert.NilError(t, err)
+	request := &api.CollectorRequestDTO{
+		CollectionAgent: &api.CollectionAgentDTO{
+			IPAddress:           u.Hostname(),
+			NodeID:              1,
+			NodeLabel:           "srv01",
+			StorageResourcePath: "snmp/1/node",
+		},
+		Attributes: []api.CollectionAttributeDTO{
+			{Key: httpCollectionAttr, Content: mockHTTPJSONCollection},
+			{Key: "port", Content: u.Port()},
+			{Key: "response-type", Content: "json"},
+			{Key: "user", Content: "admin"},
+			{Key: "password", Content: "secret"},
+			{Key: "header0", Content: "X-Api-Key: 12345"},
+		},
+	}
+	module := new(HTTPCollector)
+	response := module.Collect(request)
+	assert.Equal(t, api.CollectionStatusSucceded, response.CollectionSet.Status)
+	assert.Equal(t, 1, len(response.CollectionSet.Resources))
+	attributes := response.CollectionSet.Resources[0].NumericAttributes
+	assert.Equal(t, 2, len(attributes))
+	assert.Equal(t, "2.5", attributes[0].Value)
+	assert.Equal(t, "1024", attributes[1].Value)
+}
+
+func TestAddLocatedAttributesWithXML(t *testing.T) {
+	uri := api.HTTPUri{Name: "sensors"}
+	uri.AddAttribute(api.HTTPAttribute{Alias: "temperature", Locator: "/sensors/temperature", Type: "gauge"})
+	uri.AddAttribute(api.HTTPAttribute{Alias: "location", Locator: "/sensors/@location", Type: "string"})
+	uri.AddAttribute(api.HTTPAttribute{Alias: "humidity", Locator: "/sensors/humidity", Type: "gauge"})
+	uri.AddAttribute(api.HTTPAttribute{Alias: "pressure", Locator: "/sensors/pressure", Type: "gauge"})
+	data := []byte(`<sensors location="lab"><temperature>29</temperature><humidity>n/a</humidity></sensors>`)
+	resource := &api.CollectionResourceDTO{Name: "node"}
+	builder := api.NewCollectionSetBuilder(nil)
+	module := new(HTTPCollector)
+	failures, err := module.AddLocatedAttributes(builder, resource, uri, &XPathQuery{kind: "xml"}, data)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(failures))
+	assert.Assert(t, failures["humidity"] != nil)
+	assert.Assert(t, failures["pressure"] != nil)
+	cs := builder.Build()
+	assert.Equal(t, 1, len(cs.Resources))
+	assert.Equal(t, 1, len(cs.Resources[0].NumericAttributes))
+	assert.Equal(t, "29", cs.Resources[0].NumericAttributes[0].Value)
+	assert.Equal(t, 1, len(cs.Resources[0].StringAttributes))
+	assert.Equal(t, "lab", cs.Resources[0].StringAttributes[0].Value)
+}
@@ -0,0 +1,147 @@
+package collectors
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+)
+
+const jdbcCollectionAttr = "jdbcCollection"
+
+// JDBCCollector represents a collector implementation
+// Database connections are pooled per agent and data source, and closed when the collector is stopped.
+type JDBCCollector struct {
+	pools map[string]*sql.DB
+	mutex sync.Mutex
+}
+
+// GetID gets the collector ID (simple class name from its Java counterpart)
+func (collector *JDBCCollector) GetID() string {
+	return "JdbcCollector"
+}
+
+// Collect execute the collector request and return the collection response
+// The data source is defined by the driver, url, user and password attributes, and the queries by the jdbcCollection attribute.
+func (collector *JDBCCollector) Collect(request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	response := &api.CollectorResponseDTO{}
+	collection := &api.JDBCCollection{}
+	if err := xml.Unmarshal([]byte(request.GetAttributeValue(jdbcCollectionAttr, "")), collection); err != nil {
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("cannot parse %s: %v", jdbcCollectionAttr, err))
+		return response
+	}
+	driver, dsn, err := getDataSource(request)
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	db, err := collector.getPool(driver, dsn)
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	builder := api.NewCollectionSetBuilder(request.CollectionAgent)
+	for _, query := range collection.Queries {
+		if err := collector.collect(db, builder, request.CollectionAgent, query); err != nil {
+			response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("cannot execute query %s: %v", query.Name, err))
+			return response
+		}
+	}
+	response.SetCollectionSet(builder)
+	return response
+}
+
+// Stop closes all the database connection pools
+func (collector *JDBCCollector) Stop() {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	for key, db := range collector.pools {
+		if err := db.Close(); err != nil {
+			log.Warnf("Cannot close database connection pool: %v", err)
+		}
+		delete(collector.pools, key)
+	}
+}
+
+// Gets the connection pool for a given data source, creating it when it doesn't exist
+func (collector *JDBCCollector) getPool(driver string, dsn string) (*sql.DB, error) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	if collector.pools == nil {
+		collector.pools = make(map[string]*sql.DB)
+	}
+	key := driver + "|" + dsn
+	if db, ok := collector.pools[key]; ok {
+		return db, nil
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s connection: %v", driver, err)
+	}
+	db.SetMaxOpenConns(2)
+	collector.pools[key] = db
+	return db, nil
+}
+
+func (collector *JDBCCollector) collect(db *sql.DB, builder *api.CollectionSetBuilder, agent *api.CollectionAgentDTO, query api.JDBCQuery) error {
+	rows, err := db.Query(query.Statement)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		resource := api.NewNodeResource(agent)
+		if query.InstanceColumn != "" {
+			instance := ""
+			for i, name := range columns {
+				if strings.EqualFold(name, query.InstanceColumn) {
+					instance = values[i].String
+				}
+			}
+			if instance == "" {
+				return fmt.Errorf("cannot find a value for instance column %s", query.InstanceColumn)
+			}
+			resource = api.NewGenericResource(agent, query.ResourceType, instance)
+		}
+		for i, name := range columns {
+			if column := query.FindColumn(name); column != nil && values[i].Valid {
+				builder.WithAttribute(resource, query.Name, column.GetAlias(), values[i].String, column.GetAttributeType())
+			}
+		}
+		if query.InstanceColumn == "" {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+// Gets the Go driver name and DSN from the request attributes (see tools.GetDataSource)
+func getDataSource(request *api.CollectorRequestDTO) (string, string, error) {
+	ipaddr := ""
+	if request.CollectionAgent != nil {
+		ipaddr = request.CollectionAgent.IPAddress
+	}
+	return tools.GetDataSource(request.GetAttributeValue("driver", ""), request.GetAttributeValue("url", ""), ipaddr,
+		request.GetAttributeValue("user", ""), request.GetAttributeValue("password", ""))
+}
+
+func init() {
+	RegisterCollector(&JDBCCollector{})
+}
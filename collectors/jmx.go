@@ -8,6 +8,7 @@ import (
 )
 
 // JMXCollector represents a collector implementation
+// Go cannot speak RMI or JMXMP, so only the JMX-Minion service is supported; the JVMs must be collected through the JolokiaCollector.
 type JMXCollector struct {
 }
 
@@ -17,24 +18,20 @@ func (collector *JMXCollector) GetID() string {
 }
 
 // Collect execute the JMX collector request and return the collection response.
-// Currently returns mock data for the JMX-Minion service.
+// Returns mock data for the JMX-Minion service.
 func (collector *JMXCollector) Collect(request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
 	response := new(api.CollectorResponseDTO)
 	agent := request.CollectionAgent
 	if agent.IPAddress == "127.0.0.1" && agent.ForeignID == request.SystemID {
 		// Mock content for JMX-Minion
 		builder := api.NewCollectionSetBuilder(request.CollectionAgent)
-		node := &api.CollectionResourceDTO{
-			ResourceType: &api.NodeLevelResourceDTO{
-				NodeID: request.CollectionAgent.NodeID,
-			},
-		}
+		node := api.NewNodeResource(request.CollectionAgent)
 		for _, attr := range collector.getAttributes(request) {
 			builder.WithMetric(node, attr)
 		}
-		response.CollectionSet = builder.Build()
+		response.SetCollectionSet(builder)
 	} else {
-		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("not implemented"))
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("JSR-160 is not supported, use the JolokiaCollector instead"))
 	}
 	return response
 }
@@ -0,0 +1,24 @@
+package collectors
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestJMXCollectorWithoutJolokia(t *testing.T) {
+	request := &api.CollectorRequestDTO{
+		SystemID:        "minion1",
+		CollectionAgent: &api.CollectionAgentDTO{IPAddress: "127.0.0.1", NodeID: 1, ForeignID: "minion1"},
+	}
+	collector := &JMXCollector{}
+	response := collector.Collect(request)
+	assert.Equal(t, "", response.Error)
+	assert.Equal(t, 2, len(response.CollectionSet.Resources[0].NumericAttributes))
+
+	request.CollectionAgent.IPAddress = "10.0.0.1"
+	response = collector.Collect(request)
+	assert.ErrorContains(t, fmt.Errorf(response.Error), "use the JolokiaCollector")
+}
@@ -0,0 +1,311 @@
+package collectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+)
+
+const jmxCollectionAttr = "jmxCollection"
+
+// JolokiaCollector represents a collector implementation that reads the MBeans of a JVM through its Jolokia agent (the JMX-HTTP bridge), as Go cannot speak RMI or JMXMP.
+// HTTP clients are reused per agent, and their connections are closed when the collector is stopped.
+type JolokiaCollector struct {
+	clients map[string]*http.Client
+	mutex   sync.Mutex
+}
+
+// jolokiaRequest represents a Jolokia read request
+type jolokiaRequest struct {
+	Type      string            `json:"type"`
+	MBean     string            `json:"mbean"`
+	Attribute []string          `json:"attribute,omitempty"`
+	Config    map[string]string `json:"config,omitempty"`
+}
+
+// jolokiaResponse represents a Jolokia response
+type jolokiaResponse struct {
+	Status int                    `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	Value  map[string]interface{} `json:"value,omitempty"`
+}
+
+// GetID gets the collector ID (there is no Java counterpart)
+func (collector *JolokiaCollector) GetID() string {
+	return "JolokiaCollector"
+}
+
+// Collect execute the Jolokia collector request and return the collection response.
+// The MBeans are defined by the jmxCollection attribute, using the jmx-datacollection-config.xml format, and read from the Jolokia agent at jolokia-url.
+// Attributes that cannot be read are skipped and logged; the collection fails when nothing could be collected.
+func (collector *JolokiaCollector) Collect(request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	return collector.CollectWithContext(context.Background(), request)
+}
+
+// CollectWithContext executes the collection like Collect, until the context is done
+func (collector *JolokiaCollector) CollectWithContext(ctx context.Context, request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	response := new(api.CollectorResponseDTO)
+	agent := request.CollectionAgent
+	collection := &api.JMXCollection{}
+	if err := xml.Unmarshal([]byte(request.GetAttributeValue(jmxCollectionAttr, "")), collection); err != nil {
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("cannot parse %s: %v", jmxCollectionAttr, err))
+		return response
+	}
+	responses, err := collector.read(ctx, request, collection)
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	builder := api.NewCollectionSetBuilder(request.CollectionAgent)
+	node := api.NewNodeResource(agent)
+	failures := make(map[string]error)
+	collected := 0
+	for i, mbean := range collection.MBeans {
+		if responses[i].Status != http.StatusOK {
+			failures[mbean.ObjectName] = fmt.Errorf("%s", responses[i].Error)
+			continue
+		}
+		collected += collector.addMBean(builder, node, mbean, responses[i].Value, failures)
+	}
+	for name, err := range failures {
+		log.FromContext(ctx).Warnf("Cannot collect JMX attribute %s from %s: %v", name, agent.IPAddress, err)
+	}
+	if collected == 0 {
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("no JMX attribute collected from %s (%d failed)", collector.getURL(request), len(failures)))
+		return response
+	}
+	response.SetCollectionSet(builder)
+	return response
+}
+
+// Stop closes the connections of all the HTTP clients
+func (collector *JolokiaCollector) Stop() {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	for key, client := range collector.clients {
+		client.CloseIdleConnections()
+		delete(collector.clients, key)
+	}
+}
+
+// Reads all the MBeans of the collection with a single Jolokia bulk request; the responses follow the order of the MBeans
+func (collector *JolokiaCollector) read(ctx context.Context, request *api.CollectorRequestDTO, collection *api.JMXCollection) ([]jolokiaResponse, error) {
+	reads := make([]jolokiaRequest, len(collection.MBeans))
+	for i, mbean := range collection.MBeans {
+		reads[i] = jolokiaRequest{
+			Type:      "read",
+			MBean:     mbean.ObjectName,
+			Attribute: mbean.GetAttributeNames(),
+			Config:    map[string]string{"ignoreErrors": "true"},
+		}
+	}
+	data, err := json.Marshal(reads)
+	if err != nil {
+		return nil, err
+	}
+	url := collector.getURL(request)
+	log.FromContext(ctx).Debugf("Reading %d MBeans from %s", len(reads), url)
+	httpreq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpreq.Header.Set("Content-Type", "application/json")
+	if user := request.GetAttributeValue("username", ""); user != "" {
+		httpreq.SetBasicAuth(user, request.GetAttributeValue("password", ""))
+	}
+	httpres, err := collector.getClient(request).Do(httpreq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpres.Body.Close()
+	if httpres.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jolokia request failed with status %d", httpres.StatusCode)
+	}
+	responses := make([]jolokiaResponse, 0, len(reads))
+	if err := json.NewDecoder(httpres.Body).Decode(&responses); err != nil {
+		return nil, fmt.Errorf("cannot parse jolokia response: %v", err)
+	}
+	if len(responses) != len(reads) {
+		return nil, fmt.Errorf("expected %d jolokia responses, got %d", len(reads), len(responses))
+	}
+	return responses, nil
+}
+
+// Adds the attributes of an MBean (or all the MBeans matching a pattern) to the collection set; returns the number of attributes added
+func (collector *JolokiaCollector) addMBean(builder *api.CollectionSetBuilder, nodeResource *api.CollectionResourceDTO, mbean api.JMXMBean, value map[string]interface{}, failures map[string]error) int {
+	node := nodeResource.ResourceType.(*api.NodeLevelResourceDTO)
+	if !mbean.IsPattern() {
+		return collector.addAttributes(builder, nodeResource, node, mbean, mbean.ObjectName, value, failures)
+	}
+	resourceType := mbean.ResourceType
+	if resourceType == "" {
+		resourceType = mbean.Name
+	}
+	collected := 0
+	for objectName, attributes := range value {
+		values, ok := attributes.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resource := &api.CollectionResourceDTO{
+			ResourceType: &api.GenericTypeResourceDTO{Node: node, Name: resourceType, Instance: getMBeanInstance(objectName)},
+		}
+		collected += collector.addAttributes(builder, resource, node, mbean, objectName, values, failures)
+	}
+	return collected
+}
+
+// Adds the simple and composite attributes of a single MBean to a given resource
+func (collector *JolokiaCollector) addAttributes(builder *api.CollectionSetBuilder, resource *api.CollectionResourceDTO, node *api.NodeLevelResourceDTO, mbean api.JMXMBean, objectName string, values map[string]interface{}, failures map[string]error) int {
+	collected := 0
+	add := func(name string, alias string, attrType string, value interface{}) {
+		if v, err := getJMXValue(value, attrType); err == nil {
+			builder.WithMetric(resource, api.ResourceAttributeDTO{
+				Name:       alias,
+				Group:      mbean.Name,
+				Identifier: "JMX_" + objectName + "." + name,
+				Type:       attrType,
+				Value:      v,
+			})
+			collected++
+		} else {
+			failures[objectName+"."+name] = err
+		}
+	}
+	for _, attrib := range mbean.Attribs {
+		value, ok := values[attrib.Name]
+		if !ok {
+			failures[objectName+"."+attrib.Name] = fmt.Errorf("attribute not found")
+			continue
+		}
+		if members, ok := value.(map[string]interface{}); ok {
+			collected += collector.addMembers(builder, resource, node, mbean.Name, attrib.GetAlias(), members)
+			continue
+		}
+		add(attrib.Name, attrib.GetAlias(), attrib.GetAttributeType(), value)
+	}
+	for _, attrib := range mbean.CompAttribs {
+		members, ok := values[attrib.Name].(map[string]interface{})
+		if !ok {
+			failures[objectName+"."+attrib.Name] = fmt.Errorf("composite attribute not found")
+			continue
+		}
+		if len(attrib.Members) == 0 {
+			collected += collector.addMembers(builder, resource, node, mbean.Name, attrib.Alias, members)
+			continue
+		}
+		for _, member := range attrib.Members {
+			value, ok := members[member.Name]
+			if !ok {
+				failures[objectName+"."+attrib.Name+"."+member.Name] = fmt.Errorf("composite member not found")
+				continue
+			}
+			add(attrib.Name+"."+member.Name, member.GetAlias(), member.GetAttributeType(), value)
+		}
+	}
+	return collected
+}
+
+// Flattens the numeric members of a composite value as gauges named prefix.member (or member when the prefix is empty).
+// Tabular values (whose members are composite values) are added as resources of type prefix, indexed by the key of each row.
+func (collector *JolokiaCollector) addMembers(builder *api.CollectionSetBuilder, resource *api.CollectionResourceDTO, node *api.NodeLevelResourceDTO, group string, prefix string, members map[string]interface{}) int {
+	collected := 0
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if row, ok := members[key].(map[string]interface{}); ok {
+			instance := &api.CollectionResourceDTO{
+				ResourceType: &api.GenericTypeResourceDTO{Node: node, Name: prefix, Instance: key},
+			}
+			collected += collector.addMembers(builder, instance, node, group, "", row)
+			continue
+		}
+		if v, err := getJMXValue(members[key], "gauge"); err == nil {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			builder.WithAttribute(resource, group, name, v, "gauge")
+			collected++
+		}
+	}
+	return collected
+}
+
+// Gets the Jolokia URL from the request attributes (defaults to http://<ip>:<jolokia-port>/jolokia); the port attribute is the RMI port of JSR-160, so it is ignored
+func (collector *JolokiaCollector) getURL(request *api.CollectorRequestDTO) string {
+	url := request.GetAttributeValue("jolokia-url", "")
+	if url == "" {
+		url = fmt.Sprintf("http://${ipaddr}:%s/jolokia", request.GetAttributeValue("jolokia-port", "8778"))
+	}
+	return tools.ExpandURL(url, request.CollectionAgent.IPAddress)
+}
+
+// Gets the HTTP client for the agent, creating it when it doesn't exist
+func (collector *JolokiaCollector) getClient(request *api.CollectorRequestDTO) *http.Client {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	if collector.clients == nil {
+		collector.clients = make(map[string]*http.Client)
+	}
+	key := collector.getURL(request)
+	client, ok := collector.clients[key]
+	if !ok {
+		client = tools.GetPersistentHTTPClient(request.GetAttributeValue("ssl-verify", "true") == "false", request.GetTimeout(), nil)
+		collector.clients[key] = client
+	}
+	return client
+}
+
+// Gets a JSON value as a string; returns an error when a numeric attribute is not a number
+func getJMXValue(value interface{}, attrType string) (string, error) {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case string:
+		if attrType == "string" {
+			return v, nil
+		}
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return v, nil
+		}
+		return "", fmt.Errorf("%s is not a number", v)
+	case nil:
+		return "", fmt.Errorf("no value")
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+// Gets the instance of an MBean from its object name: the value of the name key property if present, or the whole key property list
+func getMBeanInstance(objectName string) string {
+	properties := objectName[strings.Index(objectName, ":")+1:]
+	for _, property := range strings.Split(properties, ",") {
+		if kv := strings.SplitN(property, "=", 2); len(kv) == 2 && kv[0] == "name" {
+			return kv[1]
+		}
+	}
+	return properties
+}
+
+func init() {
+	RegisterCollector(&JolokiaCollector{})
+}
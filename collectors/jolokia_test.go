@@ -0,0 +1,124 @@
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+const jmxCollection = `<jmx-collection name="jsr160">
+<mbeans>
+  <mbean name="JVM Threading" objectname="java.lang:type=Threading">
+    <attrib name="ThreadCount" alias="ThreadCount" type="gauge"/>
+    <attrib name="MissingAttr" alias="Missing" type="gauge"/>
+  </mbean>
+  <mbean name="JVM Memory" objectname="java.lang:type=Memory">
+    <comp-attrib name="HeapMemoryUsage" type="Composite" alias="HeapMemUsage">
+      <comp-member name="used" type="gauge" alias="HeapUsageUsed"/>
+      <comp-member name="max" type="gauge" alias="HeapUsageMax"/>
+    </comp-attrib>
+  </mbean>
+  <mbean name="JVM GC" objectname="java.lang:type=GarbageCollector,name=*" resource-type="gc">
+    <attrib name="CollectionCount" alias="GcCount" type="counter"/>
+  </mbean>
+  <mbean name="Unknown" objectname="com.example:type=Unknown">
+    <attrib name="Value" alias="Value" type="gauge"/>
+  </mbean>
+</mbeans>
+</jmx-collection>`
+
+func TestJolokiaCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reads := make([]jolokiaRequest, 0)
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&reads))
+		assert.Equal(t, 4, len(reads))
+		assert.DeepEqual(t, []string{"ThreadCount", "MissingAttr"}, reads[0].Attribute)
+		fmt.Fprint(w, `[
+{"status":200,"value":{"ThreadCount":42}},
+{"status":200,"value":{"HeapMemoryUsage":{"init":1024,"used":2048,"committed":4096,"max":8192}}},
+{"status":200,"value":{
+  "java.lang:name=G1 Young Generation,type=GarbageCollector":{"CollectionCount":10},
+  "java.lang:name=G1 Old Generation,type=GarbageCollector":{"CollectionCount":1}}},
+{"status":404,"error":"javax.management.InstanceNotFoundException : com.example:type=Unknown"}
+]`)
+	}))
+	defer server.Close()
+
+	request := &api.CollectorRequestDTO{
+		CollectionAgent: &api.CollectionAgentDTO{IPAddress: "127.0.0.1", NodeID: 1, NodeLabel: "jvm01"},
+		Attributes: []api.CollectionAttributeDTO{
+			{Key: "jolokia-url", Content: server.URL + "/jolokia"},
+			{Key: "username", Content: "admin"},
+			{Key: "password", Content: "secret"},
+			{Key: "jmxCollection", Content: jmxCollection},
+		},
+	}
+	collector := &JolokiaCollector{}
+	defer collector.Stop()
+	response := collector.Collect(request)
+	assert.Equal(t, "", response.Error)
+	assert.Equal(t, 3, len(response.CollectionSet.Resources))
+	instances := make(map[string]bool)
+	for _, resource := range response.CollectionSet.Resources {
+		switch r := resource.ResourceType.(type) {
+		case *api.NodeLevelResourceDTO:
+			assert.Equal(t, 3, len(resource.NumericAttributes)) // The missing attribute and MBean are skipped
+		case *api.GenericTypeResourceDTO:
+			assert.Equal(t, "gc", r.Name)
+			assert.Equal(t, 1, len(resource.NumericAttributes))
+			instances[r.Instance] = true
+		}
+	}
+	assert.Assert(t, instances["G1 Young Generation"])
+	assert.Assert(t, instances["G1 Old Generation"])
+	assert.Equal(t, 1, len(collector.clients))
+
+	request.Attributes[1].Content = "guest"
+	response = collector.Collect(request)
+	assert.ErrorContains(t, fmt.Errorf(response.Error), "status 401")
+}
+
+func TestJolokiaCollectorTabular(t *testing.T) {
+	collector := &JolokiaCollector{}
+	builder := api.NewCollectionSetBuilder(&api.CollectionAgentDTO{NodeID: 1})
+	node := &api.NodeLevelResourceDTO{NodeID: 1}
+	resource := &api.CollectionResourceDTO{ResourceType: node}
+	members := map[string]interface{}{
+		"queue1": map[string]interface{}{"size": 10.0, "name": "queue1"},
+		"queue2": map[string]interface{}{"size": 5.0, "name": "queue2"},
+	}
+	assert.Equal(t, 2, collector.addMembers(builder, resource, node, "Queues", "queue", members))
+	assert.Equal(t, 2, len(builder.Build().Resources))
+}
+
+func TestJolokiaCollectorWithoutAttributes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"status":404,"error":"not found"},{"status":404,"error":"not found"},{"status":200,"value":{}},{"status":200,"value":{}}]`)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NilError(t, err)
+
+	request := &api.CollectorRequestDTO{
+		CollectionAgent: &api.CollectionAgentDTO{IPAddress: "127.0.0.1", NodeID: 1, NodeLabel: "jvm01"},
+		Attributes: []api.CollectionAttributeDTO{
+			{Key: "port", Content: "1099"}, // The RMI port of JSR-160 is ignored
+			{Key: "jolokia-port", Content: u.Port()},
+			{Key: "jmxCollection", Content: jmxCollection},
+		},
+	}
+	collector := &JolokiaCollector{}
+	defer collector.Stop()
+	response := collector.Collect(request)
+	assert.ErrorContains(t, fmt.Errorf(response.Error), "no JMX attribute collected from "+server.URL+"/jolokia")
+}
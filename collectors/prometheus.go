@@ -0,0 +1,309 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+var prometheusMatcherRegexp = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*"((?:[^"\\]|\\.)*)"\s*$`)
+
+// PrometheusCollector represents a collector implementation for applications that expose their metrics in the Prometheus text format
+type PrometheusCollector struct {
+}
+
+// prometheusSelector represents a metric name with optional label matchers, like http_requests_total{method="GET",code=~"5.."}
+type prometheusSelector struct {
+	name     string
+	matchers []prometheusMatcher
+}
+
+// prometheusMatcher represents a label matcher of a selector
+type prometheusMatcher struct {
+	label  string
+	op     string
+	value  string
+	regexp *regexp.Regexp
+}
+
+// prometheusSample represents a sample of a metric family, flattened as OpenNMS expects it
+type prometheusSample struct {
+	name     string
+	labels   map[string]string
+	value    float64
+	attrType string
+}
+
+// GetID gets the collector ID (there is no Java counterpart, as the PrometheusCollector from OpenNMS relies on SpEL expressions)
+func (collector *PrometheusCollector) GetID() string {
+	return "PrometheusScrapeCollector"
+}
+
+// Collect execute the Prometheus collector request and return the collection response.
+// The endpoint defined by url is scraped, and the samples of the metrics selected by the metrics attribute are collected.
+// Samples without labels are collected at the node level; the rest as resources of the given resource-type, indexed by their labels.
+func (collector *PrometheusCollector) Collect(request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	return collector.CollectWithContext(context.Background(), request)
+}
+
+// CollectWithContext executes the collection like Collect, until the context is done
+func (collector *PrometheusCollector) CollectWithContext(ctx context.Context, request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	response := new(api.CollectorResponseDTO)
+	selectors, err := parsePrometheusSelectors(request.GetAttributeValue("metrics", ""))
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	if len(selectors) == 0 {
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("metrics attribute required"))
+		return response
+	}
+	families, err := collector.scrape(ctx, request)
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	group := request.GetAttributeValue("group", "prometheus")
+	resourceType := request.GetAttributeValue("resource-type", "prometheus")
+	builder := api.NewCollectionSetBuilder(request.CollectionAgent)
+	node := api.NewNodeResource(request.CollectionAgent)
+	resources := make(map[string]*api.CollectionResourceDTO)
+	collected := 0
+	for _, selector := range selectors {
+		family, ok := families[selector.name]
+		if !ok {
+			log.FromContext(ctx).Debugf("Prometheus metric %s not found on %s", selector.name, request.CollectionAgent.IPAddress)
+			continue
+		}
+		for _, sample := range getPrometheusSamples(family) {
+			if !selector.matches(sample.labels) || math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+				continue
+			}
+			resource := node
+			if instance := getPrometheusInstance(sample.labels); instance != "" {
+				if resource, ok = resources[instance]; !ok {
+					resource = api.NewGenericResource(request.CollectionAgent, resourceType, instance)
+					resources[instance] = resource
+				}
+			}
+			builder.WithAttribute(resource, group, sample.name, strconv.FormatFloat(sample.value, 'f', -1, 64), sample.attrType)
+			collected++
+		}
+	}
+	if collected == 0 {
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("none of the %d selected metrics found", len(selectors)))
+		return response
+	}
+	response.SetCollectionSet(builder)
+	return response
+}
+
+// Scrapes the Prometheus endpoint, and parses the metric families it exposes
+func (collector *PrometheusCollector) scrape(ctx context.Context, request *api.CollectorRequestDTO) (map[string]*dto.MetricFamily, error) {
+	params := request.GetParameters(nil)
+	ports, err := tools.ParsePortRange(params.Get(tools.SourcePortRangeAttribute))
+	if err != nil {
+		return nil, err
+	}
+	url := collector.getURL(request)
+	log.FromContext(ctx).Debugf("Scraping Prometheus metrics from %s", url)
+	httpreq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpreq.Header.Set("Accept", string(expfmt.FmtText))
+	if user := request.GetAttributeValue("username", ""); user != "" {
+		httpreq.SetBasicAuth(user, request.GetAttributeValue("password", ""))
+	}
+	client := tools.GetHTTPClient(!params.GetBool("ssl-verify", true), request.GetTimeout(), ports)
+	httpres, err := client.Do(httpreq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpres.Body.Close()
+	if httpres.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus scrape failed with status %d", httpres.StatusCode)
+	}
+	parser := &expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(httpres.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse prometheus metrics: %v", err)
+	}
+	return families, nil
+}
+
+// Gets the URL of the metrics from the request attributes (defaults to http://<ip>:9100/metrics)
+func (collector *PrometheusCollector) getURL(request *api.CollectorRequestDTO) string {
+	url := request.GetAttributeValue("url", "")
+	if url == "" {
+		url = fmt.Sprintf("http://${ipaddr}:%s/metrics", request.GetAttributeValue("port", "9100"))
+	}
+	return tools.ExpandURL(url, request.CollectionAgent.IPAddress)
+}
+
+// Flattens the metrics of a family into samples.
+// Histograms are flattened into name_bucket (with the le label), name_sum and name_count, and summaries into name (with the quantile label), name_sum and name_count.
+func getPrometheusSamples(family *dto.MetricFamily) []prometheusSample {
+	name := family.GetName()
+	samples := make([]prometheusSample, 0, len(family.Metric))
+	for _, metric := range family.Metric {
+		labels := make(map[string]string, len(metric.Label))
+		for _, label := range metric.Label {
+			labels[label.GetName()] = label.GetValue()
+		}
+		with := func(label string, value string) map[string]string {
+			extended := map[string]string{label: value}
+			for k, v := range labels {
+				extended[k] = v
+			}
+			return extended
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			samples = append(samples, prometheusSample{name, labels, metric.GetCounter().GetValue(), "counter"})
+		case dto.MetricType_GAUGE:
+			samples = append(samples, prometheusSample{name, labels, metric.GetGauge().GetValue(), "gauge"})
+		case dto.MetricType_HISTOGRAM:
+			histogram := metric.GetHistogram()
+			for _, bucket := range histogram.Bucket {
+				le := strconv.FormatFloat(bucket.GetUpperBound(), 'f', -1, 64)
+				samples = append(samples, prometheusSample{name + "_bucket", with("le", le), float64(bucket.GetCumulativeCount()), "counter"})
+			}
+			samples = append(samples, prometheusSample{name + "_sum", labels, histogram.GetSampleSum(), "gauge"})
+			samples = append(samples, prometheusSample{name + "_count", labels, float64(histogram.GetSampleCount()), "counter"})
+		case dto.MetricType_SUMMARY:
+			summary := metric.GetSummary()
+			for _, quantile := range summary.Quantile {
+				q := strconv.FormatFloat(quantile.GetQuantile(), 'f', -1, 64)
+				samples = append(samples, prometheusSample{name, with("quantile", q), quantile.GetValue(), "gauge"})
+			}
+			samples = append(samples, prometheusSample{name + "_sum", labels, summary.GetSampleSum(), "gauge"})
+			samples = append(samples, prometheusSample{name + "_count", labels, float64(summary.GetSampleCount()), "counter"})
+		default:
+			samples = append(samples, prometheusSample{name, labels, metric.GetUntyped().GetValue(), "gauge"})
+		}
+	}
+	return samples
+}
+
+// Gets the resource instance for the labels of a sample, as label=value pairs sorted by label; returns an empty string when there are no labels
+func getPrometheusInstance(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for label, value := range labels {
+		pairs = append(pairs, label+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Parses a comma-separated list of selectors; commas within the label matchers are allowed
+func parsePrometheusSelectors(value string) ([]prometheusSelector, error) {
+	selectors := make([]prometheusSelector, 0)
+	for _, text := range splitPrometheusList(value) {
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		selector := prometheusSelector{name: text}
+		if idx := strings.Index(text, "{"); idx >= 0 {
+			if !strings.HasSuffix(text, "}") {
+				return nil, fmt.Errorf("invalid selector %s, missing closing brace", text)
+			}
+			selector.name = strings.TrimSpace(text[:idx])
+			for _, matcher := range splitPrometheusList(text[idx+1 : len(text)-1]) {
+				if strings.TrimSpace(matcher) == "" {
+					continue
+				}
+				m, err := parsePrometheusMatcher(matcher)
+				if err != nil {
+					return nil, fmt.Errorf("invalid selector %s: %v", text, err)
+				}
+				selector.matchers = append(selector.matchers, m)
+			}
+		}
+		if selector.name == "" {
+			return nil, fmt.Errorf("invalid selector %s, metric name required", text)
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// Parses a label matcher, like code=~"5.."
+func parsePrometheusMatcher(text string) (prometheusMatcher, error) {
+	parts := prometheusMatcherRegexp.FindStringSubmatch(text)
+	if parts == nil {
+		return prometheusMatcher{}, fmt.Errorf("invalid label matcher %s", strings.TrimSpace(text))
+	}
+	value, err := strconv.Unquote(`"` + parts[3] + `"`)
+	if err != nil {
+		return prometheusMatcher{}, fmt.Errorf("invalid value on label matcher %s: %v", strings.TrimSpace(text), err)
+	}
+	matcher := prometheusMatcher{label: parts[1], op: parts[2], value: value}
+	if matcher.op == "=~" || matcher.op == "!~" {
+		if matcher.regexp, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return prometheusMatcher{}, fmt.Errorf("invalid expression on label matcher %s: %v", strings.TrimSpace(text), err)
+		}
+	}
+	return matcher, nil
+}
+
+// Splits a list by the commas that are not within braces or quotes
+func splitPrometheusList(value string) []string {
+	var items []string
+	depth, quoted, escaped, start := 0, false, false, 0
+	for i, c := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == '{' && !quoted:
+			depth++
+		case c == '}' && !quoted:
+			depth--
+		case c == ',' && !quoted && depth == 0:
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+// Returns true when the labels satisfy all the matchers; a missing label has an empty value, as in Prometheus
+func (selector prometheusSelector) matches(labels map[string]string) bool {
+	for _, m := range selector.matchers {
+		value := labels[m.label]
+		var ok bool
+		switch m.op {
+		case "=":
+			ok = value == m.value
+		case "!=":
+			ok = value != m.value
+		case "=~":
+			ok = m.regexp.MatchString(value)
+		case "!~":
+			ok = !m.regexp.MatchString(value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	RegisterCollector(&PrometheusCollector{})
+}
@@ -0,0 +1,121 @@
+package collectors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+const prometheusMetrics = `# HELP process_open_fds Number of open file descriptors.
+# TYPE process_open_fds gauge
+process_open_fds 42
+# HELP http_requests_total Total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 1027
+http_requests_total{method="GET",code="500"} 3
+http_requests_total{method="POST",code="200"} 12
+# HELP http_request_duration_seconds Duration of the HTTP requests.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.1"} 800
+http_request_duration_seconds_bucket{le="1"} 1000
+http_request_duration_seconds_bucket{le="+Inf"} 1042
+http_request_duration_seconds_sum 95.5
+http_request_duration_seconds_count 1042
+`
+
+func TestParsePrometheusSelectors(t *testing.T) {
+	selectors, err := parsePrometheusSelectors(`process_open_fds, http_requests_total{method="GET",code=~"5.."}`)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(selectors))
+	assert.Equal(t, "process_open_fds", selectors[0].name)
+	assert.Equal(t, "http_requests_total", selectors[1].name)
+	assert.Equal(t, 2, len(selectors[1].matchers))
+	assert.Assert(t, selectors[1].matches(map[string]string{"method": "GET", "code": "503"}))
+	assert.Assert(t, !selectors[1].matches(map[string]string{"method": "GET", "code": "200"}))
+	assert.Assert(t, !selectors[1].matches(map[string]string{"code": "500"}))
+
+	selectors, err = parsePrometheusSelectors(`up{job!="node",path="a,b\"c"}`)
+	assert.NilError(t, err)
+	assert.Equal(t, `a,b"c`, selectors[0].matchers[1].value)
+
+	_, err = parsePrometheusSelectors(`up{job~"node"}`)
+	assert.ErrorContains(t, err, "invalid label matcher")
+	_, err = parsePrometheusSelectors(`up{job="node"`)
+	assert.ErrorContains(t, err, "missing closing brace")
+	_, err = parsePrometheusSelectors(`up{code=~"("}`)
+	assert.ErrorContains(t, err, "invalid expression")
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/metrics", r.URL.Path)
+		fmt.Fprint(w, prometheusMetrics)
+	}))
+	defer server.Close()
+
+	request := &api.CollectorRequestDTO{
+		CollectionAgent: &api.CollectionAgentDTO{IPAddress: "127.0.0.1", NodeID: 1, NodeLabel: "app01"},
+		Attributes: []api.CollectionAttributeDTO{
+			{Key: "url", Content: server.URL + "/metrics"},
+			{Key: "metrics", Content: `process_open_fds,http_requests_total{method="GET"},http_request_duration_seconds{le!="+Inf"}`},
+			{Key: "resource-type", Content: "appMetrics"},
+		},
+	}
+	collector := &PrometheusCollector{}
+	response := collector.Collect(request)
+	assert.Equal(t, "", response.Error)
+
+	values := make(map[string]string)
+	for _, resource := range response.CollectionSet.Resources {
+		instance := "node"
+		if r, ok := resource.ResourceType.(*api.GenericTypeResourceDTO); ok {
+			assert.Equal(t, "appMetrics", r.Name)
+			instance = r.Instance
+		}
+		for _, attr := range resource.NumericAttributes {
+			assert.Equal(t, "prometheus", attr.Group)
+			values[instance+"/"+attr.Name+"/"+attr.Type] = attr.Value
+		}
+	}
+	assert.DeepEqual(t, map[string]string{
+		"node/process_open_fds/gauge":                         "42",
+		"node/http_request_duration_seconds_sum/gauge":        "95.5",
+		"node/http_request_duration_seconds_count/counter":    "1042",
+		"le=0.1/http_request_duration_seconds_bucket/counter": "800",
+		"le=1/http_request_duration_seconds_bucket/counter":   "1000",
+		"code=200,method=GET/http_requests_total/counter":     "1027",
+		"code=500,method=GET/http_requests_total/counter":     "3",
+	}, values)
+
+	request.Attributes[1].Content = "missing_metric"
+	response = collector.Collect(request)
+	assert.Equal(t, "none of the 1 selected metrics found", response.Error)
+
+	request.Attributes = append(request.Attributes, api.CollectionAttributeDTO{Key: "source-port-range", Content: "x"})
+	response = collector.Collect(request)
+	assert.Equal(t, "invalid port range x, expected min-max between 1 and 65535", response.Error)
+}
+
+func TestPrometheusCollectorSSLVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, prometheusMetrics)
+	}))
+	defer server.Close()
+
+	request := &api.CollectorRequestDTO{
+		CollectionAgent: &api.CollectionAgentDTO{IPAddress: "127.0.0.1", NodeID: 1, NodeLabel: "app01"},
+		Attributes: []api.CollectionAttributeDTO{
+			{Key: "url", Content: server.URL + "/metrics"},
+			{Key: "metrics", Content: "process_open_fds"},
+		},
+	}
+	collector := &PrometheusCollector{}
+	assert.Assert(t, collector.Collect(request).Error != "") // The certificate of the test server is self-signed
+
+	request.Attributes = append(request.Attributes, api.CollectionAttributeDTO{Key: "ssl-verify", Content: "False"})
+	assert.Equal(t, "", collector.Collect(request).Error)
+}
@@ -41,3 +41,12 @@ func GetAllCollectors() []api.ServiceCollector {
 	}
 	return modules
 }
+
+// StopAllCollectors stops the registered collectors that hold resources
+func StopAllCollectors() {
+	for _, collector := range GetAllCollectors() {
+		if module, ok := collector.(api.StoppableModule); ok {
+			module.Stop()
+		}
+	}
+}
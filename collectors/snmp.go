@@ -0,0 +1,155 @@
+package collectors
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/snmp"
+	"github.com/gosnmp/gosnmp"
+)
+
+const snmpCollectionAttr = "snmpCollection"
+
+// SNMPCollector represents a collector implementation
+type SNMPCollector struct {
+}
+
+// GetID gets the collector ID (simple class name from its Java counterpart)
+func (collector *SNMPCollector) GetID() string {
+	return "SnmpCollector"
+}
+
+// Collect execute the collector request and return the collection response
+// The agent settings are taken from the request attributes, and the MIB objects from the snmpCollection attribute.
+func (collector *SNMPCollector) Collect(request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	return collector.CollectWithContext(context.Background(), request)
+}
+
+// CollectWithContext executes the collection like Collect, until the context is done
+func (collector *SNMPCollector) CollectWithContext(ctx context.Context, request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	response := &api.CollectorResponseDTO{}
+	collection := &api.SNMPCollection{}
+	if err := xml.Unmarshal([]byte(request.GetAttributeValue(snmpCollectionAttr, "")), collection); err != nil {
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("cannot parse %s: %v", snmpCollectionAttr, err))
+		return response
+	}
+	client, err := snmp.Acquire(collector.getAgent(request))
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	defer snmp.Release(client)
+	builder, err := collector.collect(ctx, client, request.CollectionAgent, collection)
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	response.SetCollectionSet(builder)
+	return response
+}
+
+// Builds the SNMP agent from the request attributes, using the same names as the snmp-config.xml in OpenNMS
+func (collector *SNMPCollector) getAgent(request *api.CollectorRequestDTO) *api.SNMPAgentDTO {
+	agent := &api.SNMPAgentDTO{
+		Address:        request.CollectionAgent.IPAddress,
+		Port:           request.GetAttributeValueAsInt("port", 161),
+		Timeout:        int(request.GetTimeout().Milliseconds()),
+		Retries:        request.GetAttributeValueAsInt("retries", 2),
+		MaxRepetitions: request.GetAttributeValueAsInt("max-repetitions", 2),
+		ReadCommunity:  request.GetAttributeValue("read-community", "public"),
+		SecurityName:   request.GetAttributeValue("security-name", ""),
+		SecurityLevel:  request.GetAttributeValueAsInt("security-level", 1),
+		AuthProtocol:   strings.ToUpper(request.GetAttributeValue("auth-protocol", "")),
+		AuthPassPhrase: request.GetAttributeValue("auth-passphrase", ""),
+		PrivProtocol:   strings.ToUpper(request.GetAttributeValue("privacy-protocol", "")),
+		PrivPassPhrase: request.GetAttributeValue("privacy-passphrase", ""),
+		ContextName:    request.GetAttributeValue("context-name", ""),
+	}
+	switch strings.ToLower(request.GetAttributeValue("version", "v2c")) {
+	case "v1", "1":
+		agent.Version = 1
+	case "v3", "3":
+		agent.Version = 3
+	default:
+		agent.Version = 2
+	}
+	return agent
+}
+
+func (collector *SNMPCollector) collect(ctx context.Context, client api.SNMPHandler, agent *api.CollectionAgentDTO, collection *api.SNMPCollection) (*api.CollectionSetBuilder, error) {
+	builder := api.NewCollectionSetBuilder(agent)
+	nodeResource := api.NewNodeResource(agent)
+	tableResources := make(map[string]*api.CollectionResourceDTO)
+	for _, group := range collection.Groups {
+		for _, obj := range group.MibObjects {
+			oid := "." + strings.TrimPrefix(obj.OID, ".")
+			if obj.IsScalar() {
+				packet, err := client.Get(oid + "." + obj.Instance)
+				if err != nil {
+					return nil, fmt.Errorf("cannot get %s from %s: %v", obj.Alias, client.Target(), err)
+				}
+				for _, pdu := range packet.Variables {
+					if value, ok := collector.getValue(pdu); ok {
+						builder.WithAttribute(nodeResource, group.Name, obj.Alias, value, obj.GetAttributeType())
+					}
+				}
+				continue
+			}
+			err := client.BulkWalk(oid, func(pdu gosnmp.SnmpPDU) error {
+				value, ok := collector.getValue(pdu)
+				if !ok || !strings.HasPrefix(pdu.Name, oid+".") {
+					return nil
+				}
+				index := strings.TrimPrefix(pdu.Name, oid+".")
+				key := obj.Instance + "/" + index
+				resource, ok := tableResources[key]
+				if !ok {
+					resource = collector.getTableResource(agent, obj.Instance, index)
+					tableResources[key] = resource
+				}
+				builder.WithAttribute(resource, group.Name, obj.Alias, value, obj.GetAttributeType())
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("cannot walk %s from %s: %v", obj.Alias, client.Target(), err)
+			}
+		}
+	}
+	log.FromContext(ctx).Debugf("Collected %d table resources from %s", len(tableResources), client.Target())
+	return builder, nil
+}
+
+func (collector *SNMPCollector) getTableResource(agent *api.CollectionAgentDTO, resourceType string, index string) *api.CollectionResourceDTO {
+	if resourceType == "ifIndex" {
+		return api.NewInterfaceResource(agent, index)
+	}
+	return api.NewGenericResource(agent, resourceType, index)
+}
+
+// Gets the value of a PDU as a string; returns false when the PDU has no value
+func (collector *SNMPCollector) getValue(pdu gosnmp.SnmpPDU) (string, bool) {
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return "", false
+	case gosnmp.OctetString:
+		if data, ok := pdu.Value.([]byte); ok {
+			return string(data), true
+		}
+		return "", false
+	case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		if data, ok := pdu.Value.(string); ok {
+			return data, true
+		}
+		return "", false
+	default:
+		return gosnmp.ToBigInt(pdu.Value).String(), true
+	}
+}
+
+func init() {
+	RegisterCollector(&SNMPCollector{})
+}
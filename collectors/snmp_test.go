@@ -0,0 +1,103 @@
+package collectors
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+	"github.com/gosnmp/gosnmp"
+	"gotest.tools/v3/assert"
+)
+
+var mockSNMPCollection = `
+<snmp-collection name="default">
+	<groups>
+		<group name="mib2-tcp">
+			<mibObj oid=".1.3.6.1.2.1.6.5" instance="0" alias="tcpActiveOpens" type="Counter32"/>
+		</group>
+		<group name="mib2-X-interfaces">
+			<mibObj oid=".1.3.6.1.2.1.31.1.1.1.1" instance="ifIndex" alias="ifName" type="string"/>
+			<mibObj oid=".1.3.6.1.2.1.31.1.1.1.6" instance="ifIndex" alias="ifHCInOctets" type="Counter64"/>
+		</group>
+		<group name="hrStorage">
+			<mibObj oid=".1.3.6.1.2.1.25.2.3.1.6" instance="hrStorageIndex" alias="hrStorageUsed" type="gauge"/>
+		</group>
+	</groups>
+</snmp-collection>
+`
+
+func TestSNMPCollector(t *testing.T) {
+	collection := &api.SNMPCollection{}
+	assert.NilError(t, xml.Unmarshal([]byte(mockSNMPCollection), collection))
+	assert.Equal(t, 3, len(collection.Groups))
+
+	client := &tools.MockSNMPClient{
+		GetMap: map[string]*gosnmp.SnmpPacket{
+			".1.3.6.1.2.1.6.5.0": {Variables: []gosnmp.SnmpPDU{{Name: ".1.3.6.1.2.1.6.5.0", Type: gosnmp.Counter32, Value: uint(1000)}}},
+		},
+		WalkMap: map[string][]gosnmp.SnmpPDU{
+			".1.3.6.1.2.1.31.1.1.1.1": {
+				{Name: ".1.3.6.1.2.1.31.1.1.1.1.1", Type: gosnmp.OctetString, Value: []byte("lo")},
+				{Name: ".1.3.6.1.2.1.31.1.1.1.1.2", Type: gosnmp.OctetString, Value: []byte("eth0")},
+			},
+			".1.3.6.1.2.1.31.1.1.1.6": {
+				{Name: ".1.3.6.1.2.1.31.1.1.1.6.1", Type: gosnmp.Counter64, Value: uint64(100)},
+				{Name: ".1.3.6.1.2.1.31.1.1.1.6.2", Type: gosnmp.Counter64, Value: uint64(200)},
+			},
+			".1.3.6.1.2.1.25.2.3.1.6": {
+				{Name: ".1.3.6.1.2.1.25.2.3.1.6.31", Type: gosnmp.Integer, Value: 4096},
+			},
+		},
+	}
+	agent := &api.CollectionAgentDTO{NodeID: 1, IPAddress: "127.0.0.1"}
+	collector := &SNMPCollector{}
+	builder, err := collector.collect(context.Background(), client, agent, collection)
+	assert.NilError(t, err)
+	set := builder.Build()
+	assert.Equal(t, 4, len(set.Resources)) // node, two interfaces, and one storage
+
+	for _, resource := range set.Resources {
+		switch r := resource.ResourceType.(type) {
+		case *api.NodeLevelResourceDTO:
+			assert.Equal(t, 1, len(resource.NumericAttributes))
+			assert.Equal(t, "1000", resource.NumericAttributes[0].Value)
+			assert.Equal(t, "counter", resource.NumericAttributes[0].Type)
+		case *api.InterfaceLevelResourceDTO:
+			assert.Equal(t, 1, len(resource.StringAttributes))
+			assert.Equal(t, 1, len(resource.NumericAttributes))
+			if r.IntfName == "2" {
+				assert.Equal(t, "eth0", resource.StringAttributes[0].Value)
+				assert.Equal(t, "200", resource.NumericAttributes[0].Value)
+			}
+		case *api.GenericTypeResourceDTO:
+			assert.Equal(t, "hrStorageIndex", r.Name)
+			assert.Equal(t, "31", r.Instance)
+			assert.Equal(t, "4096", resource.NumericAttributes[0].Value)
+			assert.Equal(t, "gauge", resource.NumericAttributes[0].Type)
+		}
+	}
+}
+
+func TestSNMPCollectorAgent(t *testing.T) {
+	request := &api.CollectorRequestDTO{
+		CollectionAgent: &api.CollectionAgentDTO{IPAddress: "10.0.0.1"},
+		Attributes: []api.CollectionAttributeDTO{
+			{Key: "version", Content: "v3"},
+			{Key: "port", Content: "1161"},
+			{Key: "timeout", Content: "3000"},
+			{Key: "security-name", Content: "opennms"},
+			{Key: "security-level", Content: "3"},
+			{Key: "auth-protocol", Content: "sha"},
+			{Key: "privacy-protocol", Content: "aes"},
+		},
+	}
+	agent := (&SNMPCollector{}).getAgent(request)
+	assert.Equal(t, 3, agent.Version)
+	assert.Equal(t, 1161, agent.Port)
+	assert.Equal(t, 3000, agent.Timeout)
+	assert.Equal(t, "SHA", agent.AuthProtocol)
+	assert.Equal(t, "AES", agent.PrivProtocol)
+	assert.Equal(t, gosnmp.AuthPriv, agent.GetV3Flags())
+}
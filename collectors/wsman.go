@@ -0,0 +1,314 @@
+package collectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/go-ntlmssp"
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+	"github.com/antchfx/xmlquery"
+	"github.com/google/uuid"
+)
+
+const (
+	wsmanActionGet       = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Get"
+	wsmanActionEnumerate = "http://schemas.xmlsoap.org/ws/2004/09/enumeration/Enumerate"
+	wsmanActionPull      = "http://schemas.xmlsoap.org/ws/2004/09/enumeration/Pull"
+	wsmanMaxElements     = 100
+)
+
+// The SOAP envelope for WS-Man requests; the arguments are the URL, the resource URI, the action, the message ID, the selector set, and the body
+const wsmanEnvelope = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:n="http://schemas.xmlsoap.org/ws/2004/09/enumeration" xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd">
+<s:Header>
+<a:To>%s</a:To>
+<w:ResourceURI s:mustUnderstand="true">%s</w:ResourceURI>
+<a:ReplyTo><a:Address s:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:Address></a:ReplyTo>
+<a:Action s:mustUnderstand="true">%s</a:Action>
+<w:MaxEnvelopeSize s:mustUnderstand="true">512000</w:MaxEnvelopeSize>
+<a:MessageID>uuid:%s</a:MessageID>
+<w:OperationTimeout>PT60S</w:OperationTimeout>%s
+</s:Header>
+<s:Body>%s</s:Body>
+</s:Envelope>`
+
+// WsManCollector represents a collector implementation for WS-Management (e.g. WinRM on Windows)
+type WsManCollector struct {
+}
+
+// wsmanInstance represents the properties of a CIM instance
+type wsmanInstance map[string]string
+
+// GetID gets the collector ID (simple class name from its Java counterpart)
+func (collector *WsManCollector) GetID() string {
+	return "WsManCollector"
+}
+
+// Collect execute the collector request and return the collection response.
+// The resource-uri attribute defines the CIM class; with selectors (e.g. Name=C:,DriveType=3) a single instance is retrieved via Get, otherwise all the instances are enumerated.
+// When the instance-property attribute is set, each instance is a table resource of the given resource-type, indexed by that property; otherwise, the first instance is collected at the node level.
+func (collector *WsManCollector) Collect(request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	return collector.CollectWithContext(context.Background(), request)
+}
+
+// CollectWithContext executes the collection like Collect, until the context is done
+func (collector *WsManCollector) CollectWithContext(ctx context.Context, request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	response := &api.CollectorResponseDTO{}
+	resourceURI := request.GetAttributeValue("resource-uri", "")
+	if resourceURI == "" {
+		response.MarkAsFailed(request.CollectionAgent, fmt.Errorf("missing resource-uri attribute"))
+		return response
+	}
+	client, err := collector.getClient(request)
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	defer client.close()
+	var instances []wsmanInstance
+	if selectors := request.GetAttributeValue("selectors", ""); selectors != "" {
+		instances, err = client.get(ctx, resourceURI, selectors)
+	} else {
+		instances, err = client.enumerate(ctx, resourceURI)
+	}
+	if err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	builder := api.NewCollectionSetBuilder(request.CollectionAgent)
+	if err := collector.fillCollectionSet(request, builder, resourceURI, instances); err != nil {
+		response.MarkAsFailed(request.CollectionAgent, err)
+		return response
+	}
+	response.SetCollectionSet(builder)
+	return response
+}
+
+// Adds the instance properties as attributes of the collection set.
+// The attributes attribute (e.g. FreeSpace,Size:gauge,VolumeName:string) selects the properties and their types; when missing, all the numeric properties are collected as gauges.
+func (collector *WsManCollector) fillCollectionSet(request *api.CollectorRequestDTO, builder *api.CollectionSetBuilder, resourceURI string, instances []wsmanInstance) error {
+	group := request.GetAttributeValue("group", resourceURI[strings.LastIndex(resourceURI, "/")+1:])
+	instanceProperty := request.GetAttributeValue("instance-property", "")
+	resourceType := request.GetAttributeValue("resource-type", group)
+	attributes := parseWsManAttributes(request.GetAttributeValue("attributes", ""))
+	for _, instance := range instances {
+		resource := api.NewNodeResource(request.CollectionAgent)
+		if instanceProperty != "" {
+			index := instance[instanceProperty]
+			if index == "" {
+				return fmt.Errorf("cannot find a value for instance property %s", instanceProperty)
+			}
+			resource = api.NewGenericResource(request.CollectionAgent, resourceType, index)
+		}
+		if len(attributes) == 0 {
+			for name, value := range instance {
+				if _, err := strconv.ParseFloat(value, 64); err == nil {
+					builder.WithAttribute(resource, group, name, value, "gauge")
+				}
+			}
+		} else {
+			for name, attrType := range attributes {
+				if value, ok := instance[name]; ok {
+					builder.WithAttribute(resource, group, name, value, attrType)
+				}
+			}
+		}
+		if instanceProperty == "" {
+			break
+		}
+	}
+	return nil
+}
+
+// Builds the WS-Man client from the request attributes.
+// The url defaults to http://<ip>:5985/wsman, and the auth-method can be basic (default) or ntlm.
+func (collector *WsManCollector) getClient(request *api.CollectorRequestDTO) (*wsmanClient, error) {
+	target := request.GetAttributeValue("url", "")
+	if target == "" {
+		target = fmt.Sprintf("http://%s/wsman", net.JoinHostPort(request.CollectionAgent.IPAddress, "5985"))
+	} else {
+		target = tools.ExpandURL(target, request.CollectionAgent.IPAddress)
+	}
+	client := &wsmanClient{
+		url:      target,
+		username: request.GetAttributeValue("username", ""),
+		password: request.GetAttributeValue("password", ""),
+		http:     tools.GetPersistentHTTPClient(request.GetAttributeValue("ssl-verify", "true") == "false", request.GetTimeout(), nil), // NTLM authenticates the connection
+	}
+	switch strings.ToLower(request.GetAttributeValue("auth-method", "basic")) {
+	case "basic":
+	case "ntlm":
+		client.http.Transport = ntlmssp.Negotiator{RoundTripper: client.http.Transport}
+	default:
+		return nil, fmt.Errorf("invalid auth-method %s, expected basic or ntlm", request.GetAttributeValue("auth-method", ""))
+	}
+	return client, nil
+}
+
+// Parses the list of attributes to collect, in name[:type] format
+func parseWsManAttributes(value string) map[string]string {
+	attributes := make(map[string]string)
+	for _, attr := range strings.Split(value, ",") {
+		if attr = strings.TrimSpace(attr); attr == "" {
+			continue
+		}
+		parts := strings.SplitN(attr, ":", 2)
+		attrType := "gauge"
+		if len(parts) == 2 {
+			attrType = strings.ToLower(strings.TrimSpace(parts[1]))
+		}
+		attributes[strings.TrimSpace(parts[0])] = attrType
+	}
+	return attributes
+}
+
+// wsmanClient represents a minimal WS-Management client
+type wsmanClient struct {
+	url      string
+	username string
+	password string
+	http     *http.Client
+}
+
+// Closes the connections kept alive during the collection (through the NTLM negotiator when present)
+func (client *wsmanClient) close() {
+	transport := client.http.Transport
+	if negotiator, ok := transport.(ntlmssp.Negotiator); ok {
+		transport = negotiator.RoundTripper
+	}
+	if t, ok := transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+}
+
+// Retrieves a single instance of a given resource, identified by the selectors (e.g. Name=C:,DriveType=3)
+func (client *wsmanClient) get(ctx context.Context, resourceURI string, selectors string) ([]wsmanInstance, error) {
+	selectorSet := new(bytes.Buffer)
+	selectorSet.WriteString("\n<w:SelectorSet>")
+	for _, selector := range strings.Split(selectors, ",") {
+		parts := strings.SplitN(selector, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid selector %s, expected name=value", selector)
+		}
+		selectorSet.WriteString(`<w:Selector Name="`)
+		xml.EscapeText(selectorSet, []byte(strings.TrimSpace(parts[0])))
+		selectorSet.WriteString(`">`)
+		xml.EscapeText(selectorSet, []byte(strings.TrimSpace(parts[1])))
+		selectorSet.WriteString(`</w:Selector>`)
+	}
+	selectorSet.WriteString("</w:SelectorSet>")
+	doc, err := client.invoke(ctx, resourceURI, wsmanActionGet, selectorSet.String(), "")
+	if err != nil {
+		return nil, err
+	}
+	body := xmlquery.FindOne(doc, "//*[local-name()='Body']/*")
+	if body == nil {
+		return nil, fmt.Errorf("empty WS-Man response")
+	}
+	return []wsmanInstance{getWsManInstance(body)}, nil
+}
+
+// Retrieves all the instances of a given resource, pulling until the end of the sequence
+func (client *wsmanClient) enumerate(ctx context.Context, resourceURI string) ([]wsmanInstance, error) {
+	body := fmt.Sprintf("<n:Enumerate><w:OptimizeEnumeration/><w:MaxElements>%d</w:MaxElements></n:Enumerate>", wsmanMaxElements)
+	action := wsmanActionEnumerate
+	instances := make([]wsmanInstance, 0)
+	for {
+		doc, err := client.invoke(ctx, resourceURI, action, "", body)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range xmlquery.Find(doc, "//*[local-name()='Items']/*") {
+			instances = append(instances, getWsManInstance(item))
+		}
+		if xmlquery.FindOne(doc, "//*[local-name()='EndOfSequence']") != nil {
+			return instances, nil
+		}
+		enumContext := xmlquery.FindOne(doc, "//*[local-name()='EnumerationContext']")
+		if enumContext == nil || enumContext.InnerText() == "" {
+			return instances, nil
+		}
+		escaped := new(bytes.Buffer)
+		xml.EscapeText(escaped, []byte(enumContext.InnerText()))
+		body = fmt.Sprintf("<n:Pull><n:EnumerationContext>%s</n:EnumerationContext><n:MaxElements>%d</n:MaxElements></n:Pull>", escaped.String(), wsmanMaxElements)
+		action = wsmanActionPull
+	}
+}
+
+// Sends a WS-Man request and parses the response; returns an error with the reason when the server responds with a SOAP fault
+func (client *wsmanClient) invoke(ctx context.Context, resourceURI string, action string, headers string, body string) (*xmlquery.Node, error) {
+	to, uri := new(bytes.Buffer), new(bytes.Buffer)
+	xml.EscapeText(to, []byte(client.url))
+	xml.EscapeText(uri, []byte(resourceURI))
+	envelope := fmt.Sprintf(wsmanEnvelope, to.String(), uri.String(), action, uuid.New().String(), headers, body)
+	log.FromContext(ctx).Debugf("Sending WS-Man request %s for %s to %s", action, resourceURI, client.url)
+	httpreq, err := http.NewRequestWithContext(ctx, "POST", client.url, strings.NewReader(envelope))
+	if err != nil {
+		return nil, err
+	}
+	httpreq.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	if client.username != "" {
+		httpreq.SetBasicAuth(client.username, client.password)
+	}
+	httpres, err := client.http.Do(httpreq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpres.Body.Close()
+	data, err := ioutil.ReadAll(httpres.Body)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := xmlquery.Parse(bytes.NewReader(data))
+	if err != nil {
+		if httpres.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("WS-Man request failed with status %d", httpres.StatusCode)
+		}
+		return nil, fmt.Errorf("cannot parse WS-Man response: %v", err)
+	}
+	if fault := xmlquery.FindOne(doc, "//*[local-name()='Fault']"); fault != nil {
+		reason := xmlquery.FindOne(fault, ".//*[local-name()='Reason']")
+		if reason == nil {
+			reason = fault
+		}
+		return nil, fmt.Errorf("WS-Man fault: %s", strings.TrimSpace(reason.InnerText()))
+	}
+	if httpres.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("WS-Man request failed with status %d", httpres.StatusCode)
+	}
+	return doc, nil
+}
+
+// Gets the properties of a CIM instance, skipping the nil ones
+func getWsManInstance(node *xmlquery.Node) wsmanInstance {
+	instance := make(wsmanInstance)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xmlquery.ElementNode || isWsManNil(child) {
+			continue
+		}
+		instance[child.Data] = strings.TrimSpace(child.InnerText())
+	}
+	return instance
+}
+
+// Returns true when the property has the xsi:nil attribute
+func isWsManNil(node *xmlquery.Node) bool {
+	for _, attr := range node.Attr {
+		if attr.Name.Local == "nil" && attr.Value == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	RegisterCollector(&WsManCollector{})
+}
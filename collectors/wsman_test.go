@@ -0,0 +1,108 @@
+package collectors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+const wsmanResponse = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:n="http://schemas.xmlsoap.org/ws/2004/09/enumeration" xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd" xmlns:p="http://schemas.microsoft.com/wbem/wsman/1/wmi/root/cimv2/Win32_LogicalDisk" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+<s:Header/>
+<s:Body>%s</s:Body>
+</s:Envelope>`
+
+func TestWsManCollectorEnumerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		request := string(data)
+		assert.Assert(t, strings.Contains(request, "root/cimv2/Win32_LogicalDisk"))
+		if strings.Contains(request, "enumeration/Enumerate") {
+			fmt.Fprintf(w, wsmanResponse, `<n:EnumerateResponse><n:EnumerationContext>ctx-1</n:EnumerationContext><w:Items>
+<p:Win32_LogicalDisk><p:DeviceID>C:</p:DeviceID><p:FreeSpace>1024</p:FreeSpace><p:Size>4096</p:Size><p:VolumeName>System</p:VolumeName></p:Win32_LogicalDisk>
+</w:Items></n:EnumerateResponse>`)
+			return
+		}
+		assert.Assert(t, strings.Contains(request, "<n:EnumerationContext>ctx-1</n:EnumerationContext>"))
+		fmt.Fprintf(w, wsmanResponse, `<n:PullResponse><n:Items>
+<p:Win32_LogicalDisk><p:DeviceID>D:</p:DeviceID><p:FreeSpace xsi:nil="true"/><p:Size>8192</p:Size><p:VolumeName>Data</p:VolumeName></p:Win32_LogicalDisk>
+</n:Items><n:EndOfSequence/></n:PullResponse>`)
+	}))
+	defer server.Close()
+
+	request := &api.CollectorRequestDTO{
+		CollectionAgent: &api.CollectionAgentDTO{IPAddress: "127.0.0.1", NodeID: 1, NodeLabel: "win01"},
+		Attributes: []api.CollectionAttributeDTO{
+			{Key: "url", Content: server.URL + "/wsman"},
+			{Key: "username", Content: "admin"},
+			{Key: "password", Content: "secret"},
+			{Key: "resource-uri", Content: "http://schemas.microsoft.com/wbem/wsman/1/wmi/root/cimv2/Win32_LogicalDisk"},
+			{Key: "instance-property", Content: "DeviceID"},
+			{Key: "resource-type", Content: "wsLogicalDisk"},
+		},
+	}
+	collector := &WsManCollector{}
+	response := collector.Collect(request)
+	assert.Equal(t, "", response.Error)
+	assert.Equal(t, 2, len(response.CollectionSet.Resources))
+	attributes := 0
+	for _, resource := range response.CollectionSet.Resources {
+		assert.Equal(t, 0, len(resource.StringAttributes)) // Only the numeric properties
+		attributes += len(resource.NumericAttributes)
+	}
+	assert.Equal(t, 3, attributes) // The nil property is ignored
+
+	request.Attributes[1].Content = "guest"
+	response = collector.Collect(request)
+	assert.ErrorContains(t, fmt.Errorf(response.Error), "status 401")
+}
+
+func TestWsManCollectorGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		request := string(data)
+		if !strings.Contains(request, `<w:Selector Name="Name">Spooler</w:Selector>`) {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, wsmanResponse, `<s:Fault><s:Reason><s:Text>The WS-Management service cannot process the request</s:Text></s:Reason></s:Fault>`)
+			return
+		}
+		fmt.Fprintf(w, wsmanResponse, `<p:Win32_Service><p:Name>Spooler</p:Name><p:ProcessId>1234</p:ProcessId><p:State>Running</p:State></p:Win32_Service>`)
+	}))
+	defer server.Close()
+
+	request := &api.CollectorRequestDTO{
+		CollectionAgent: &api.CollectionAgentDTO{IPAddress: "127.0.0.1", NodeID: 1, NodeLabel: "win01"},
+		Attributes: []api.CollectionAttributeDTO{
+			{Key: "url", Content: server.URL + "/wsman"},
+			{Key: "resource-uri", Content: "http://schemas.microsoft.com/wbem/wsman/1/wmi/root/cimv2/Win32_Service"},
+			{Key: "selectors", Content: "Name=Spooler"},
+			{Key: "attributes", Content: "ProcessId,State:string"},
+		},
+	}
+	collector := &WsManCollector{}
+	response := collector.Collect(request)
+	assert.Equal(t, "", response.Error)
+	assert.Equal(t, 1, len(response.CollectionSet.Resources))
+	resource := response.CollectionSet.Resources[0]
+	assert.Equal(t, 1, len(resource.NumericAttributes))
+	assert.Equal(t, 1, len(resource.StringAttributes))
+	assert.Equal(t, "Win32_Service", resource.NumericAttributes[0].Group)
+	assert.Equal(t, "Running", resource.StringAttributes[0].Value)
+
+	request.Attributes[2].Content = "Name=Unknown"
+	response = collector.Collect(request)
+	assert.ErrorContains(t, fmt.Errorf(response.Error), "WS-Man fault: The WS-Management service cannot process the request")
+
+	request.Attributes = append(request.Attributes, api.CollectionAttributeDTO{Key: "auth-method", Content: "kerberos"})
+	response = collector.Collect(request)
+	assert.ErrorContains(t, fmt.Errorf(response.Error), "invalid auth-method")
+}
@@ -39,6 +39,9 @@ type XPathNode struct {
 func (n *XPathNode) GetContent() string {
 	switch o := n.impl.(type) {
 	case *xmlquery.Node:
+		if o == nil {
+			return ""
+		}
 		return o.InnerText()
 	case *jsonquery.Node:
 		if o == nil {
@@ -89,19 +92,30 @@ func (q *XPathQuery) Query(parent *XPathNode, xpath string) (*XPathNode, error)
 	switch q.kind {
 	case "css":
 		p := parent.impl.(*html.Node)
-		sel, err := cascadia.Compile(xpath)
-		if err == nil {
-			node = sel.MatchFirst(p)
+		var sel cascadia.Selector
+		if sel, err = cascadia.Compile(xpath); err == nil {
+			if n := sel.MatchFirst(p); n != nil {
+				node = n
+			}
 		}
 	case "xml":
 		p := parent.impl.(*xmlquery.Node)
-		node, err = xmlquery.Query(p, xpath)
+		var n *xmlquery.Node
+		if n, err = xmlquery.Query(p, xpath); n != nil {
+			node = n
+		}
 	case "html":
 		p := parent.impl.(*html.Node)
-		node, err = htmlquery.Query(p, xpath)
+		var n *html.Node
+		if n, err = htmlquery.Query(p, xpath); n != nil {
+			node = n
+		}
 	case "json":
 		p := parent.impl.(*jsonquery.Node)
-		node, err = jsonquery.Query(p, xpath)
+		var n *jsonquery.Node
+		if n, err = jsonquery.Query(p, xpath); n != nil {
+			node = n
+		}
 	default:
 		return nil, fmt.Errorf("cannot find implementation")
 	}
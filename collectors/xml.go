@@ -1,6 +1,7 @@
 package collectors
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"regexp"
@@ -25,6 +26,11 @@ func (collector *XMLCollector) GetID() string {
 
 // Collect execute the collector request and return the collection response
 func (collector *XMLCollector) Collect(request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
+	return collector.CollectWithContext(context.Background(), request)
+}
+
+// CollectWithContext executes the collection like Collect, until the context is done
+func (collector *XMLCollector) CollectWithContext(ctx context.Context, request *api.CollectorRequestDTO) *api.CollectorResponseDTO {
 	response := &api.CollectorResponseDTO{}
 	xmlCollection := &api.XMLCollection{}
 	err := xml.Unmarshal([]byte(request.GetAttributeValue(xmlCollectionAttr, "")), xmlCollection)
@@ -40,20 +46,20 @@ func (collector *XMLCollector) Collect(request *api.CollectorRequestDTO) *api.Co
 			response.MarkAsFailed(request.CollectionAgent, err)
 			return response
 		}
-		log.Debugf("Executing an HTTP GET against %s", src.URL)
+		log.FromContext(ctx).Debugf("Executing an HTTP GET against %s", src.URL)
 		if doc, err := collector.getDocument(querier, src, request.GetTimeout()); err != nil {
 			response.MarkAsFailed(request.CollectionAgent, err)
 			return response
-		} else if err := collector.fillCollectionSet(querier, src, builder, doc); err != nil {
+		} else if err := collector.fillCollectionSet(ctx, querier, src, builder, doc); err != nil {
 			response.MarkAsFailed(request.CollectionAgent, err)
 			return response
 		}
 	}
-	response.CollectionSet = builder.Build()
+	response.SetCollectionSet(builder)
 	return response
 }
 
-func (collector *XMLCollector) fillCollectionSet(querier XPathQuerier, src api.XMLSource, builder *api.CollectionSetBuilder, doc *XPathNode) error {
+func (collector *XMLCollector) fillCollectionSet(ctx context.Context, querier XPathQuerier, src api.XMLSource, builder *api.CollectionSetBuilder, doc *XPathNode) error {
 	re, _ := regexp.Compile(`[.\d]+`)
 	for _, group := range src.Groups {
 		resources, err := querier.QueryAll(doc, group.ResourceXPath)
@@ -62,7 +68,7 @@ func (collector *XMLCollector) fillCollectionSet(querier XPathQuerier, src api.X
 		}
 		timestamp := collector.getTimestamp(group, doc)
 		for _, resource := range resources {
-			name, err := collector.getResourceName(querier, group, resource)
+			name, err := collector.getResourceName(ctx, querier, group, resource)
 			if err != nil {
 				return err
 			}
@@ -86,18 +92,18 @@ func (collector *XMLCollector) fillCollectionSet(querier XPathQuerier, src api.X
 	return nil
 }
 
-func (collector *XMLCollector) getResourceName(querier XPathQuerier, group api.XMLGroup, node *XPathNode) (string, error) {
+func (collector *XMLCollector) getResourceName(ctx context.Context, querier XPathQuerier, group api.XMLGroup, node *XPathNode) (string, error) {
 	if group.HasMultipleResourceKeys() {
 		keys := make([]string, 0)
 		for _, key := range group.ResourceKey.KeyXPaths {
-			if keyNode, err := querier.Query(node, key); err != nil {
+			if keyNode, err := querier.Query(node, key); err == nil {
 				keys = append(keys, keyNode.GetContent())
 			}
 		}
 		return strings.Join(keys, "_"), nil
 	}
 	if group.KeyXPath == "" {
-		log.Debugf("Assuming node level resource")
+		log.FromContext(ctx).Debugf("Assuming node level resource")
 		return "node", nil
 	}
 	keyNode, err := querier.Query(node, group.KeyXPath)
@@ -113,20 +119,12 @@ func (collector *XMLCollector) getTimestamp(group api.XMLGroup, node *XPathNode)
 }
 
 func (collector *XMLCollector) getCollectionResource(agent *api.CollectionAgentDTO, instance string, resourceType string, timestamp *api.Timestamp) *api.CollectionResourceDTO {
-	nodeType := &api.NodeLevelResourceDTO{
-		NodeID: agent.NodeID,
-	}
 	if resourceType == "node" {
-		return &api.CollectionResourceDTO{ResourceType: nodeType}
-	}
-	return &api.CollectionResourceDTO{
-		ResourceType: &api.GenericTypeResourceDTO{
-			Node:      nodeType,
-			Name:      resourceType,
-			Timestamp: timestamp,
-			Instance:  instance,
-		},
+		return api.NewNodeResource(agent)
 	}
+	resource := api.NewGenericResource(agent, resourceType, instance)
+	resource.ResourceType.(*api.GenericTypeResourceDTO).Timestamp = timestamp
+	return resource
 }
 
 func (collector *XMLCollector) getDocument(querier XPathQuerier, src api.XMLSource, timeout time.Duration) (*XPathNode, error) {
@@ -137,7 +135,7 @@ func (collector *XMLCollector) getDocument(querier XPathQuerier, src api.XMLSour
 	if t := src.GetRequest().GetParameterAsInt("timeout"); t > 0 {
 		timeout = time.Duration(t) * time.Microsecond
 	}
-	client := tools.GetHTTPClient(src.SkipSSL(), timeout)
+	client := tools.GetHTTPClient(src.SkipSSL(), timeout, nil)
 	httpres, err := client.Do(httpreq)
 	if err != nil {
 		return nil, err
@@ -90,6 +90,7 @@ func TestXmlCollector(t *testing.T) {
 		case *api.GenericTypeResourceDTO:
 			fmt.Println("Found generic-index resource")
 			assert.Equal(t, o.Name, "sensorFan")
+			assert.Assert(t, strings.HasPrefix(o.Instance, "Rack "))
 			assert.Equal(t, 1, len(r.NumericAttributes))
 		default:
 			t.FailNow()
@@ -0,0 +1,117 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+)
+
+// DNSDetector represents a detector implementation
+type DNSDetector struct {
+}
+
+// GetID gets the detector ID (simple class name from its Java counterpart)
+func (detector *DNSDetector) GetID() string {
+	return "DnsDetector"
+}
+
+// Detect execute the DNS detector request and return the detection response
+// The query is sent directly to the target IP acting as the DNS server, or to the system resolver when use-system-resolver is true.
+// The resolved values are included in the resolved-value attribute of the response, separated by commas.
+func (detector *DNSDetector) Detect(request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	return detector.DetectWithContext(context.Background(), request)
+}
+
+// DetectWithContext executes the detection like Detect, until the context is done
+func (detector *DNSDetector) DetectWithContext(ctx context.Context, request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	results := &api.DetectorResponseDTO{Detected: false}
+	lookup := request.GetAttributeValue("lookup", "localhost")
+	recordType := strings.ToUpper(request.GetAttributeValue("record-type", "A"))
+	server := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "53"))
+	useSystemResolver := request.GetAttributeValue("use-system-resolver", "false") == "true"
+	var values []string
+	err := WithRetries(ctx, request, func(ctx context.Context) error {
+		var err error
+		if useSystemResolver {
+			values, err = detector.lookup(ctx, net.DefaultResolver, lookup, recordType)
+		} else {
+			values, err = tools.QueryDNS(ctx, server, lookup, recordType, request.GetTimeout())
+		}
+		if err != nil {
+			log.FromContext(ctx).Debugf("DNS detection attempt for %s record of %s failed: %v", recordType, lookup, err)
+			if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+				return tools.StopRetries(err)
+			}
+		}
+		return err
+	})
+	if err == nil && len(values) == 0 {
+		err = fmt.Errorf("no %s record found for %s", recordType, lookup)
+	}
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	results.Detected = true
+	results.Attributes = append(results.Attributes, api.DetectorAttributeDTO{Key: "resolved-value", Value: strings.Join(values, ",")})
+	return results
+}
+
+// Performs the lookup through the system resolver
+func (detector *DNSDetector) lookup(ctx context.Context, resolver *net.Resolver, lookup string, recordType string) ([]string, error) {
+	var values []string
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, lookup)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			values = append(values, ip.String())
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, lookup)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, cname)
+	case "MX":
+		records, err := resolver.LookupMX(ctx, lookup)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range records {
+			values = append(values, mx.Host)
+		}
+	case "NS":
+		records, err := resolver.LookupNS(ctx, lookup)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range records {
+			values = append(values, ns.Host)
+		}
+	case "TXT":
+		records, err := resolver.LookupTXT(ctx, lookup)
+		if err != nil {
+			return nil, err
+		}
+		values = records
+	default:
+		return nil, tools.StopRetries(fmt.Errorf("%w %s", tools.ErrUnsupportedRecordType, recordType))
+	}
+	return values, nil
+}
+
+func init() {
+	RegisterDetector(&DNSDetector{})
+}
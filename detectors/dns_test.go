@@ -0,0 +1,100 @@
+package detectors
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"golang.org/x/net/dns/dnsmessage"
+	"gotest.tools/v3/assert"
+)
+
+// Starts a DNS server that resolves www.example.com. to 10.0.0.1, and everything else to NXDOMAIN
+func startDNSServer(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buffer[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			question := query.Questions[0]
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if question.Name.String() != "www.example.com." {
+				reply.RCode = dnsmessage.RCodeNameError
+			} else if question.Type == dnsmessage.TypeA {
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+				}}
+			}
+			data, _ := reply.Pack()
+			conn.WriteTo(data, addr)
+		}
+	}()
+	return conn
+}
+
+func TestDnsDetector(t *testing.T) {
+	conn := startDNSServer(t)
+	defer conn.Close()
+	port := strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+	detector := &DNSDetector{}
+	request := func(lookup string, recordType string) *api.DetectorRequestDTO {
+		return &api.DetectorRequestDTO{
+			IPAddress: "127.0.0.1",
+			DetectorAttributes: []api.DetectorAttributeDTO{
+				{Key: "port", Value: port},
+				{Key: "lookup", Value: lookup},
+				{Key: "record-type", Value: recordType},
+				{Key: "timeout", Value: "500"},
+				{Key: "retries", Value: "1"},
+			},
+		}
+	}
+
+	response := detector.Detect(request("www.example.com", "A"))
+	assert.Equal(t, true, response.Detected)
+	assert.Equal(t, 1, len(response.Attributes))
+	assert.Equal(t, "10.0.0.1", response.Attributes[0].Value)
+
+	response = detector.Detect(request("missing.example.com", "A"))
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, response.Error != "")
+
+	response = detector.Detect(request("www.example.com", "SOA"))
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, strings.Contains(response.Error, "unsupported record type"))
+
+	// The default lookup is not answered from /etc/hosts
+	response = detector.Detect(request("localhost", "A"))
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, strings.Contains(response.Error, "NXDOMAIN"), response.Error)
+}
+
+func TestDnsDetectorWithoutServer(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	port := strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+	conn.Close() // Nothing listens on the port from now on
+	response := (&DNSDetector{}).Detect(&api.DetectorRequestDTO{
+		IPAddress: "127.0.0.1",
+		DetectorAttributes: []api.DetectorAttributeDTO{
+			{Key: "port", Value: port},
+			{Key: "timeout", Value: "500"},
+		},
+	})
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, response.Error != "")
+}
@@ -3,8 +3,10 @@ package detectors
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -12,6 +14,13 @@ import (
 	"github.com/agalue/gominion/tools"
 )
 
+// The defaults of the parameters of the HTTP detectors (the scheme and the port depend on the detector)
+var httpDetectorDefaults = map[string]string{
+	"authUser":     "admin",
+	"authPassword": "admin",
+	"maxRetCode":   "399",
+}
+
 // HTTPDetector represents a detector implementation
 type HTTPDetector struct {
 	ID          string
@@ -25,99 +34,132 @@ func (detector *HTTPDetector) GetID() string {
 }
 
 // Detect execute the HTTP detector request and return the detection response
+// The final status code is included in the status-code attribute of the response when the server replies.
 func (detector *HTTPDetector) Detect(request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
-	results := &api.DetectorResponseDTO{
-		Detected: detector.isDetected(request),
+	results := &api.DetectorResponseDTO{}
+	statusCode, err := detector.detect(request)
+	if statusCode > 0 {
+		results.Attributes = append(results.Attributes, api.DetectorAttributeDTO{Key: "status-code", Value: strconv.Itoa(statusCode)})
+	}
+	if err != nil {
+		results.Error = err.Error()
+	} else {
+		results.Detected = true
 	}
 	return results
 }
 
-func (detector *HTTPDetector) getClient(request *api.DetectorRequestDTO) *http.Client {
-	useSSLFilter, _ := strconv.ParseBool(request.GetAttributeValue("useSSLFilter", "false"))
-	return tools.GetHTTPClient(useSSLFilter, request.GetTimeout())
-}
-
-func (detector *HTTPDetector) isDetected(request *api.DetectorRequestDTO) bool {
-	if detector.ID != "WebDetector" {
-		return detector.isDetectedSimple(request)
+// Builds the HTTP client; redirects are followed only when the follow-redirects attribute is true.
+// Certificates are verified unless ssl-verify is false (or the legacy useSSLFilter is true).
+func (detector *HTTPDetector) getClient(request *api.DetectorRequestDTO) (*http.Client, error) {
+	params := request.GetParameters(httpDetectorDefaults)
+	sslVerify := params.GetBool("ssl-verify", !params.GetBool("useSSLFilter", false))
+	ports, err := tools.ParsePortRange(params.Get(tools.SourcePortRangeAttribute))
+	if err != nil {
+		return nil, err
+	}
+	client := tools.GetHTTPClient(!sslVerify, request.GetTimeout(), ports)
+	if !params.GetBool("follow-redirects", false) {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
 	}
+	return client, nil
+}
 
-	client := detector.getClient(request)
+// Performs the HTTP request and returns the status code, and an error when the service is not detected
+func (detector *HTTPDetector) detect(request *api.DetectorRequestDTO) (int, error) {
 	u := url.URL{
-		Scheme: request.GetAttributeValue("scheme", detector.Scheme),
-		Host:   detector.getHost(request),
-		Path:   request.GetAttributeValue("path", "/"),
-	}
-	queryString := request.GetAttributeValue("queryString", "")
-	if queryString != "" {
-		u.RawQuery = queryString
+		Scheme:   request.GetAttributeValue("scheme", detector.Scheme),
+		Host:     detector.getHost(request),
+		Path:     request.GetAttributeValue("path", request.GetAttributeValue("url", "/")),
+		RawQuery: request.GetAttributeValue("queryString", ""),
 	}
 	httpreq, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
-		return false
+		return 0, err
 	}
-	authEnabled, _ := strconv.ParseBool(request.GetAttributeValue("authEnabled", "false"))
-	if authEnabled {
-		user := request.GetAttributeValue("authUser", "admin")
-		passwd := request.GetAttributeValue("authPassword", "admin")
-		httpreq.SetBasicAuth(user, passwd)
+	params := request.GetParameters(httpDetectorDefaults)
+	if params.GetBool("authEnabled", false) {
+		httpreq.SetBasicAuth(params.Get("authUser"), params.Get("authPassword"))
 	}
-	userAgent := request.GetAttributeValue("userAgent", "")
+	userAgent := params.Get("userAgent")
 	if userAgent != "" {
 		httpreq.Header.Set("User-Agent", userAgent)
 	}
-	virtualHost := request.GetAttributeValue("virtualHost", "")
+	virtualHost := params.Get("virtualHost")
 	if virtualHost != "" {
-		httpreq.Header.Set("Host", virtualHost)
+		httpreq.Host = virtualHost
+	}
+	client, err := detector.getClient(request)
+	if err != nil {
+		return 0, err
 	}
 	response, err := client.Do(httpreq)
 	if err != nil {
-		return false
+		return 0, err
 	}
+	defer response.Body.Close()
 	min, max := detector.getResponseRange(request)
 	if response.StatusCode < min || response.StatusCode > max {
-		return false
+		return response.StatusCode, fmt.Errorf("response code %d out of expected range: %d-%d", response.StatusCode, min, max)
+	}
+	matcher, err := detector.getResponseMatcher(request)
+	if err != nil {
+		return response.StatusCode, err
 	}
-	responseText := request.GetAttributeValue("responseText", "")
-	if responseText != "" {
+	if matcher != nil {
 		data, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			return false
+			return response.StatusCode, err
 		}
-		if !strings.Contains(string(data), responseText) {
-			return false
+		if !matcher(string(data)) {
+			return response.StatusCode, fmt.Errorf("response text doesn't match the expected content")
 		}
 	}
-	return true
-}
-
-func (detector *HTTPDetector) getResponseRange(request *api.DetectorRequestDTO) (int, int) {
-	responseRange := request.GetAttributeValue("responseRange", "100-399")
-	return tools.ParseHTTPResponseRange(responseRange)
+	return response.StatusCode, nil
 }
 
-func (detector *HTTPDetector) getHost(request *api.DetectorRequestDTO) string {
-	port := request.GetAttributeValue("port", fmt.Sprintf("%d", detector.DefaultPort))
-	return request.IPAddress + ":" + port
+// Returns a function to verify the response body, or nil when there is nothing to verify.
+// The response-text attribute is a regular expression, while the legacy responseText is a substring, or a regular expression when prefixed with ~.
+func (detector *HTTPDetector) getResponseMatcher(request *api.DetectorRequestDTO) (func(string) bool, error) {
+	text := request.GetAttributeValue("responseText", "")
+	if pattern := request.GetAttributeValue("response-text", ""); pattern != "" {
+		text = "~" + pattern
+	}
+	if text == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(text, "~") {
+		exp, err := regexp.Compile(text[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid response text expression: %v", err)
+		}
+		return exp.MatchString, nil
+	}
+	return func(body string) bool {
+		return strings.Contains(body, text)
+	}, nil
 }
 
-func (detector *HTTPDetector) isDetectedSimple(request *api.DetectorRequestDTO) bool {
-	maxRetCode, _ := strconv.Atoi(request.GetAttributeValue("maxRetCode", "399"))
-	checkRetCode, _ := strconv.ParseBool(request.GetAttributeValue("checkRetCode", "false"))
-	u := url.URL{
-		Scheme: detector.Scheme,
-		Host:   detector.getHost(request),
-		Path:   request.GetAttributeValue("url", "/"),
+// Returns the expected response code range.
+// The WebDetector defaults to 100-399, while the HttpDetector and HttpsDetector accept any response unless checkRetCode is true (in which case the response must be lower than maxRetCode).
+func (detector *HTTPDetector) getResponseRange(request *api.DetectorRequestDTO) (int, int) {
+	if responseRange := request.GetAttributeValue("responseRange", ""); responseRange != "" {
+		return tools.ParseHTTPResponseRange(responseRange)
 	}
-	client := detector.getClient(request)
-	response, err := client.Get(u.String())
-	if err != nil {
-		return false
+	if detector.ID == "WebDetector" {
+		return 100, 399
 	}
-	if checkRetCode {
-		return response.StatusCode < maxRetCode
+	if params := request.GetParameters(httpDetectorDefaults); params.GetBool("checkRetCode", false) {
+		return 0, params.GetInt("maxRetCode", 399) - 1
 	}
-	return true
+	return 0, 999
+}
+
+func (detector *HTTPDetector) getHost(request *api.DetectorRequestDTO) string {
+	port := request.GetAttributeValue("port", fmt.Sprintf("%d", detector.DefaultPort))
+	return net.JoinHostPort(request.IPAddress, port)
 }
 
 func init() {
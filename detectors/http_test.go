@@ -0,0 +1,90 @@
+package detectors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func newHTTPDetectorRequest(t *testing.T, serverURL string, attributes ...api.DetectorAttributeDTO) *api.DetectorRequestDTO {
+	u, err := url.Parse(serverURL)
+	assert.NilError(t, err)
+	return &api.DetectorRequestDTO{
+		IPAddress:          u.Hostname(),
+		DetectorAttributes: append([]api.DetectorAttributeDTO{{Key: "port", Value: u.Port()}}, attributes...),
+	}
+}
+
+func getStatusCode(response *api.DetectorResponseDTO) string {
+	for _, attr := range response.Attributes {
+		if attr.Key == "status-code" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func TestHttpDetector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/status", http.StatusMovedPermanently)
+		case "/status":
+			w.Write([]byte("<html><body>Server is running: version 1.2</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	detector := &HTTPDetector{ID: "HttpDetector", Scheme: "http", DefaultPort: 80}
+
+	response := detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "path", Value: "/status"},
+		api.DetectorAttributeDTO{Key: "response-text", Value: "version [0-9.]+"}))
+	assert.Equal(t, true, response.Detected)
+	assert.Equal(t, "200", getStatusCode(response))
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "path", Value: "/status"},
+		api.DetectorAttributeDTO{Key: "response-text", Value: "stopped"}))
+	assert.Equal(t, false, response.Detected)
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "path", Value: "/old"},
+		api.DetectorAttributeDTO{Key: "responseRange", Value: "200-299"}))
+	assert.Equal(t, false, response.Detected)
+	assert.Equal(t, "301", getStatusCode(response))
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "path", Value: "/old"},
+		api.DetectorAttributeDTO{Key: "responseRange", Value: "200-299"},
+		api.DetectorAttributeDTO{Key: "follow-redirects", Value: "true"}))
+	assert.Equal(t, true, response.Detected)
+	assert.Equal(t, "200", getStatusCode(response))
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "path", Value: "/missing"},
+		api.DetectorAttributeDTO{Key: "checkRetCode", Value: "true"}))
+	assert.Equal(t, false, response.Detected)
+	assert.Equal(t, "404", getStatusCode(response))
+}
+
+func TestHttpsDetector(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+	detector := &HTTPDetector{ID: "HttpsDetector", Scheme: "https", DefaultPort: 443}
+
+	response := detector.Detect(newHTTPDetectorRequest(t, server.URL))
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, response.Error != "")
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL, api.DetectorAttributeDTO{Key: "ssl-verify", Value: "false"}))
+	assert.Equal(t, true, response.Detected)
+	assert.Equal(t, "200", getStatusCode(response))
+}
@@ -0,0 +1,83 @@
+package detectors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+)
+
+// The data source used when the url attribute is not set
+const defaultJdbcURL = "jdbc:postgresql://${ipaddr}:5432/opennms"
+
+// JDBCDetector represents a detector implementation
+type JDBCDetector struct {
+}
+
+// GetID gets the detector ID (simple class name from its Java counterpart)
+func (detector *JDBCDetector) GetID() string {
+	return "JdbcDetector"
+}
+
+// Detect execute the JDBC detector request and return the detection response
+// The data source is defined by the driver (or dbDriver), url, user and password attributes, as for the JDBC collector.
+// The service is detected when the connection succeeds and, if the query attribute is set, the query runs successfully.
+func (detector *JDBCDetector) Detect(request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	return detector.DetectWithContext(context.Background(), request)
+}
+
+// DetectWithContext executes the detection like Detect, until the context is done
+func (detector *JDBCDetector) DetectWithContext(ctx context.Context, request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	results := &api.DetectorResponseDTO{Detected: false}
+	driver, dsn, err := tools.GetDataSource(
+		request.GetAttributeValue("driver", request.GetAttributeValue("dbDriver", "")),
+		request.GetAttributeValue("url", defaultJdbcURL),
+		request.IPAddress,
+		request.GetAttributeValue("user", ""),
+		request.GetAttributeValue("password", ""))
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	query := request.GetAttributeValue("query", "")
+	err = WithRetries(ctx, request, func(ctx context.Context) error {
+		err := detector.detect(ctx, driver, dsn, query)
+		if err != nil {
+			log.FromContext(ctx).Debugf("JDBC detection attempt against %s failed: %v", request.IPAddress, err)
+		}
+		return err
+	})
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	results.Detected = true
+	results.Attributes = append(results.Attributes, api.DetectorAttributeDTO{Key: "driver", Value: driver})
+	return results
+}
+
+func (detector *JDBCDetector) detect(ctx context.Context, driver string, dsn string, query string) error {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return tools.StopRetries(fmt.Errorf("cannot open %s connection: %v", driver, err))
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("cannot connect to %s database: %v", driver, err)
+	}
+	if query == "" {
+		return nil
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %v", err)
+	}
+	return rows.Close()
+}
+
+func init() {
+	RegisterDetector(&JDBCDetector{})
+}
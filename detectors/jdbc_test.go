@@ -0,0 +1,58 @@
+package detectors
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestJdbcDetector(t *testing.T) {
+	db, mock, err := sqlmock.NewWithDSN("jdbc-detector-test", sqlmock.MonitorPingsOption(true))
+	assert.NilError(t, err)
+	defer db.Close()
+
+	detector := &JDBCDetector{}
+	request := func(query string) *api.DetectorRequestDTO {
+		return &api.DetectorRequestDTO{
+			IPAddress: "127.0.0.1",
+			DetectorAttributes: []api.DetectorAttributeDTO{
+				{Key: "driver", Value: "sqlmock"},
+				{Key: "url", Value: "jdbc-detector-test"},
+				{Key: "query", Value: query},
+				{Key: "retries", Value: "0"},
+			},
+		}
+	}
+
+	mock.ExpectPing()
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+	response := detector.Detect(request("SELECT 1"))
+	assert.Equal(t, "", response.Error)
+	assert.Assert(t, response.Detected)
+	assert.DeepEqual(t, []api.DetectorAttributeDTO{{Key: "driver", Value: "sqlmock"}}, response.Attributes)
+
+	mock.ExpectPing()
+	mock.ExpectQuery("SELECT 1").WillReturnError(fmt.Errorf("permission denied"))
+	response = detector.Detect(request("SELECT 1"))
+	assert.Assert(t, !response.Detected)
+	assert.Assert(t, strings.Contains(response.Error, "permission denied"))
+
+	mock.ExpectPing().WillReturnError(fmt.Errorf("connection refused"))
+	response = detector.Detect(request(""))
+	assert.Assert(t, !response.Detected)
+	assert.Assert(t, strings.Contains(response.Error, "connection refused"))
+	assert.NilError(t, mock.ExpectationsWereMet())
+}
+
+func TestJdbcDetectorInvalidDataSource(t *testing.T) {
+	response := (&JDBCDetector{}).Detect(&api.DetectorRequestDTO{
+		IPAddress:          "127.0.0.1",
+		DetectorAttributes: []api.DetectorAttributeDTO{{Key: "url", Value: "jdbc:oracle:thin:@${ipaddr}:1521:orcl"}},
+	})
+	assert.Assert(t, !response.Detected)
+	assert.Assert(t, strings.Contains(response.Error, "cannot find a driver"))
+}
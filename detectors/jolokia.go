@@ -0,0 +1,146 @@
+package detectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+)
+
+// The MBean and attribute that identify the JVM behind the Jolokia agent
+const (
+	jmxRuntimeMBean     = "java.lang:type=Runtime"
+	jmxRuntimeAttribute = "VmName"
+)
+
+// JolokiaDetector represents a detector implementation for the Jolokia agent (the JMX-HTTP bridge) of a JVM, as Go cannot speak RMI or JMXMP.
+type JolokiaDetector struct {
+}
+
+// jolokiaOperation represents a Jolokia read or search request
+type jolokiaOperation struct {
+	Type      string `json:"type"`
+	MBean     string `json:"mbean"`
+	Attribute string `json:"attribute,omitempty"`
+}
+
+// jolokiaResult represents the response of a Jolokia operation
+type jolokiaResult struct {
+	Status int             `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+// GetID gets the detector ID (there is no Java counterpart)
+func (detector *JolokiaDetector) GetID() string {
+	return "JolokiaDetector"
+}
+
+// Detect execute the Jolokia detector request and return the detection response
+// The service is detected when the Jolokia agent at jolokia-url answers and, if the object attribute is set, an MBean matches that object name.
+// The name of the JVM is included in the jvm-name attribute of the response when the agent exposes it.
+func (detector *JolokiaDetector) Detect(request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	return detector.DetectWithContext(context.Background(), request)
+}
+
+// DetectWithContext executes the detection like Detect, until the context is done
+func (detector *JolokiaDetector) DetectWithContext(ctx context.Context, request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	results := &api.DetectorResponseDTO{Detected: false}
+	params := request.GetParameters(nil)
+	ports, err := tools.ParsePortRange(params.Get(tools.SourcePortRangeAttribute))
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	url := detector.getURL(request)
+	client := tools.GetHTTPClient(!params.GetBool("ssl-verify", true), request.GetTimeout(), ports)
+	objectName := request.GetAttributeValue("object", "")
+	var vmName string
+	err = WithRetries(ctx, request, func(ctx context.Context) error {
+		var err error
+		vmName, err = detector.detect(ctx, client, url, request, objectName)
+		if err != nil {
+			log.FromContext(ctx).Debugf("Jolokia detection attempt against %s failed: %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	results.Detected = true
+	if vmName != "" {
+		results.Attributes = append(results.Attributes, api.DetectorAttributeDTO{Key: "jvm-name", Value: vmName})
+	}
+	return results
+}
+
+// Reads the name of the JVM, and searches for the MBean when the object name is not empty, with a single Jolokia bulk request
+func (detector *JolokiaDetector) detect(ctx context.Context, client *http.Client, url string, request *api.DetectorRequestDTO, objectName string) (string, error) {
+	operations := []jolokiaOperation{{Type: "read", MBean: jmxRuntimeMBean, Attribute: jmxRuntimeAttribute}}
+	if objectName != "" {
+		operations = append(operations, jolokiaOperation{Type: "search", MBean: objectName})
+	}
+	data, err := json.Marshal(operations)
+	if err != nil {
+		return "", err
+	}
+	httpreq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return "", tools.StopRetries(err)
+	}
+	httpreq.Header.Set("Content-Type", "application/json")
+	if user := request.GetAttributeValue("username", ""); user != "" {
+		httpreq.SetBasicAuth(user, request.GetAttributeValue("password", ""))
+	}
+	httpres, err := client.Do(httpreq)
+	if err != nil {
+		return "", err
+	}
+	defer httpres.Body.Close()
+	if httpres.StatusCode == http.StatusUnauthorized || httpres.StatusCode == http.StatusForbidden {
+		return "", tools.StopRetries(fmt.Errorf("jolokia request rejected with status %d", httpres.StatusCode))
+	}
+	if httpres.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("jolokia request failed with status %d", httpres.StatusCode)
+	}
+	results := make([]jolokiaResult, 0, len(operations))
+	if err := json.NewDecoder(httpres.Body).Decode(&results); err != nil {
+		return "", fmt.Errorf("cannot parse jolokia response: %v", err)
+	}
+	if len(results) != len(operations) {
+		return "", fmt.Errorf("expected %d jolokia responses, got %d", len(operations), len(results))
+	}
+	var vmName string
+	if results[0].Status == http.StatusOK {
+		json.Unmarshal(results[0].Value, &vmName) // Optional, the agent might not expose the runtime MBean
+	}
+	if objectName != "" {
+		var names []string
+		if results[1].Status != http.StatusOK {
+			return "", tools.StopRetries(fmt.Errorf("cannot search MBean %s: %s", objectName, results[1].Error))
+		}
+		if err := json.Unmarshal(results[1].Value, &names); err != nil || len(names) == 0 {
+			return "", tools.StopRetries(fmt.Errorf("MBean %s not found", objectName))
+		}
+	}
+	return vmName, nil
+}
+
+// Gets the Jolokia URL from the request attributes (defaults to http://<ip>:<jolokia-port>/jolokia); the port attribute is the RMI port of JSR-160, so it is ignored
+func (detector *JolokiaDetector) getURL(request *api.DetectorRequestDTO) string {
+	url := request.GetAttributeValue("jolokia-url", "")
+	if url == "" {
+		url = fmt.Sprintf("http://${ipaddr}:%s/jolokia", request.GetAttributeValue("jolokia-port", "8778"))
+	}
+	return tools.ExpandURL(url, request.IPAddress)
+}
+
+func init() {
+	RegisterDetector(&JolokiaDetector{})
+}
@@ -0,0 +1,83 @@
+package detectors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestJolokiaDetector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, passwd, _ := r.BasicAuth(); user != "admin" || passwd != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		operations := make([]jolokiaOperation, 0)
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&operations))
+		results := make([]map[string]interface{}, 0)
+		for _, op := range operations {
+			switch {
+			case op.Type == "read" && op.MBean == jmxRuntimeMBean:
+				results = append(results, map[string]interface{}{"status": 200, "value": "OpenJDK 64-Bit Server VM"})
+			case op.Type == "search" && op.MBean == "java.lang:type=Memory":
+				results = append(results, map[string]interface{}{"status": 200, "value": []string{"java.lang:type=Memory"}})
+			default:
+				results = append(results, map[string]interface{}{"status": 200, "value": []string{}})
+			}
+		}
+		json.NewEncoder(w).Encode(results)
+	}))
+	defer server.Close()
+
+	detector := &JolokiaDetector{}
+	request := func(attributes ...api.DetectorAttributeDTO) *api.DetectorRequestDTO {
+		return newHTTPDetectorRequest(t, server.URL, append([]api.DetectorAttributeDTO{
+			{Key: "jolokia-url", Value: server.URL + "/jolokia"},
+			{Key: "retries", Value: "0"},
+		}, attributes...)...)
+	}
+	username := api.DetectorAttributeDTO{Key: "username", Value: "admin"}
+	password := api.DetectorAttributeDTO{Key: "password", Value: "secret"}
+
+	response := detector.Detect(request(username, password))
+	assert.Equal(t, "", response.Error)
+	assert.Assert(t, response.Detected)
+	assert.DeepEqual(t, []api.DetectorAttributeDTO{{Key: "jvm-name", Value: "OpenJDK 64-Bit Server VM"}}, response.Attributes)
+
+	response = detector.Detect(request(username, password, api.DetectorAttributeDTO{Key: "object", Value: "java.lang:type=Memory"}))
+	assert.Assert(t, response.Detected)
+
+	response = detector.Detect(request(username, password, api.DetectorAttributeDTO{Key: "object", Value: "com.example:type=Missing"}))
+	assert.Assert(t, !response.Detected)
+	assert.Equal(t, "MBean com.example:type=Missing not found", response.Error)
+
+	response = detector.Detect(request())
+	assert.Assert(t, !response.Detected)
+	assert.Assert(t, strings.Contains(response.Error, "status 401"))
+}
+
+func TestJolokiaDetectorURL(t *testing.T) {
+	detector := &JolokiaDetector{}
+	request := &api.DetectorRequestDTO{IPAddress: "fe80::1"}
+	assert.Equal(t, "http://[fe80::1]:8778/jolokia", detector.getURL(request))
+
+	request = &api.DetectorRequestDTO{
+		IPAddress:          "10.0.0.1",
+		DetectorAttributes: []api.DetectorAttributeDTO{{Key: "jolokia-url", Value: "https://${ipaddr}:8443/jmx"}},
+	}
+	assert.Equal(t, "https://10.0.0.1:8443/jmx", detector.getURL(request))
+
+	request = &api.DetectorRequestDTO{
+		IPAddress: "10.0.0.1",
+		DetectorAttributes: []api.DetectorAttributeDTO{
+			{Key: "port", Value: "1099"}, // The RMI port of JSR-160 is ignored
+			{Key: "jolokia-port", Value: "8080"},
+		},
+	}
+	assert.Equal(t, "http://10.0.0.1:8080/jolokia", detector.getURL(request))
+}
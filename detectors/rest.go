@@ -0,0 +1,188 @@
+package detectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+	"github.com/antchfx/jsonquery"
+	"github.com/antchfx/xmlquery"
+)
+
+// The defaults of the parameters of the REST detector
+var restDetectorDefaults = map[string]string{
+	"port":           "80",
+	"method":         "GET",
+	"response-range": "200-299",
+}
+
+// RESTDetector represents a detector implementation for services whose readiness is reported in the body of an XML or JSON response
+type RESTDetector struct {
+}
+
+// GetID gets the detector ID (there is no Java counterpart)
+func (detector *RESTDetector) GetID() string {
+	return "RestDetector"
+}
+
+// Detect execute the REST detector request and return the detection response.
+// The service is detected when the status code is within response-range, and the XPath expression evaluated against the body matches the expected value.
+// The evaluated value is included in the value attribute of the response, even when it doesn't match.
+func (detector *RESTDetector) Detect(request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	return detector.DetectWithContext(context.Background(), request)
+}
+
+// DetectWithContext executes the detection like Detect, until the context is done
+func (detector *RESTDetector) DetectWithContext(ctx context.Context, request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	results := &api.DetectorResponseDTO{Detected: false}
+	params := request.GetParameters(restDetectorDefaults)
+	expression := params.Get("expression")
+	if expression == "" {
+		results.Error = "expression attribute required"
+		return results
+	}
+	matcher, err := detector.getMatcher(params.Get("expected"))
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	ports, err := tools.ParsePortRange(params.Get(tools.SourcePortRangeAttribute))
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	url := detector.getURL(request, params)
+	client := tools.GetHTTPClient(!params.GetBool("ssl-verify", true), request.GetTimeout(), ports)
+	var value string
+	var found bool
+	err = WithRetries(ctx, request, func(ctx context.Context) error {
+		var err error
+		value, found, err = detector.evaluate(ctx, client, url, params, expression)
+		if err == nil && !found {
+			err = fmt.Errorf("expression %s not found on the response", expression)
+		} else if err == nil && !matcher(value) {
+			err = fmt.Errorf("value %q doesn't match the expected %q", value, params.Get("expected"))
+		}
+		if err != nil {
+			log.FromContext(ctx).Debugf("REST detection attempt against %s failed: %v", url, err)
+		}
+		return err
+	})
+	if found {
+		results.Attributes = append(results.Attributes, api.DetectorAttributeDTO{Key: "value", Value: value})
+	}
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	results.Detected = true
+	return results
+}
+
+// Sends the request, and evaluates the expression against the body.
+// Returns the content of the first node matching the expression, and whether there was one.
+func (detector *RESTDetector) evaluate(ctx context.Context, client *http.Client, url string, params *api.Parameters, expression string) (string, bool, error) {
+	var body io.Reader
+	if data := params.Get("body"); data != "" {
+		body = strings.NewReader(data)
+	}
+	httpreq, err := http.NewRequestWithContext(ctx, strings.ToUpper(params.Get("method")), url, body)
+	if err != nil {
+		return "", false, tools.StopRetries(err)
+	}
+	if body != nil {
+		httpreq.Header.Set("Content-Type", params.GetString("content-type", "application/json"))
+	}
+	if user := params.Get("username"); user != "" {
+		httpreq.SetBasicAuth(user, params.Get("password"))
+	}
+	httpres, err := client.Do(httpreq)
+	if err != nil {
+		return "", false, err
+	}
+	defer httpres.Body.Close()
+	min, max := tools.ParseHTTPResponseRange(params.Get("response-range"))
+	if httpres.StatusCode < min || httpres.StatusCode > max {
+		return "", false, fmt.Errorf("response code %d out of range %d-%d", httpres.StatusCode, min, max)
+	}
+	data, err := ioutil.ReadAll(httpres.Body)
+	if err != nil {
+		return "", false, err
+	}
+	if detector.getFormat(params, httpres) == "json" {
+		doc, err := jsonquery.Parse(bytes.NewReader(data))
+		if err != nil {
+			return "", false, fmt.Errorf("cannot parse JSON response: %v", err)
+		}
+		node, err := jsonquery.Query(doc, expression)
+		if err != nil {
+			return "", false, tools.StopRetries(fmt.Errorf("invalid expression %s: %v", expression, err))
+		}
+		if node == nil {
+			return "", false, nil
+		}
+		return node.InnerText(), true, nil
+	}
+	doc, err := xmlquery.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", false, fmt.Errorf("cannot parse XML response: %v", err)
+	}
+	node, err := xmlquery.Query(doc, expression)
+	if err != nil {
+		return "", false, tools.StopRetries(fmt.Errorf("invalid expression %s: %v", expression, err))
+	}
+	if node == nil {
+		return "", false, nil
+	}
+	return node.InnerText(), true, nil
+}
+
+// Gets the format of the response from the format attribute (json or xml), or from its content type (defaults to xml)
+func (detector *RESTDetector) getFormat(params *api.Parameters, httpres *http.Response) string {
+	if format := strings.ToLower(params.Get("format")); format == "json" || format == "xml" {
+		return format
+	}
+	if mediaType, _, err := mime.ParseMediaType(httpres.Header.Get("Content-Type")); err == nil && strings.HasSuffix(mediaType, "json") {
+		return "json"
+	}
+	return "xml"
+}
+
+// Returns a function to verify the evaluated value against the expected one; it is a regular expression when prefixed with ~, and any value matches when it is empty
+func (detector *RESTDetector) getMatcher(expected string) (func(string) bool, error) {
+	if expected == "" {
+		return func(string) bool { return true }, nil
+	}
+	if strings.HasPrefix(expected, "~") {
+		exp, err := regexp.Compile(expected[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected expression: %v", err)
+		}
+		return exp.MatchString, nil
+	}
+	return func(value string) bool {
+		return strings.TrimSpace(value) == expected
+	}, nil
+}
+
+// Gets the URL from the url attribute, replacing ${ipaddr}; defaults to the root of the server on the given port
+func (detector *RESTDetector) getURL(request *api.DetectorRequestDTO, params *api.Parameters) string {
+	url := params.Get("url")
+	if url == "" {
+		url = fmt.Sprintf("http://${ipaddr}:%d/", params.GetInt("port", 80))
+	}
+	return tools.ExpandURL(url, request.IPAddress)
+}
+
+func init() {
+	RegisterDetector(&RESTDetector{})
+}
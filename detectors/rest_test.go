@@ -0,0 +1,93 @@
+package detectors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func getResponseValue(response *api.DetectorResponseDTO) string {
+	for _, attr := range response.Attributes {
+		if attr.Key == "value" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func TestRestDetector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"status": "UP", "components": {"db": {"status": "DOWN"}}}`))
+		case "/status.xml":
+			w.Header().Set("Content-Type", "text/xml")
+			w.Write([]byte(`<server><state>ready</state><version>2.4.1</version></server>`))
+		case "/echo":
+			if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"ok": true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	detector := &RESTDetector{}
+
+	response := detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "url", Value: server.URL + "/health"},
+		api.DetectorAttributeDTO{Key: "expression", Value: "/status"},
+		api.DetectorAttributeDTO{Key: "expected", Value: "UP"}))
+	assert.Equal(t, true, response.Detected, response.Error)
+	assert.Equal(t, "UP", getResponseValue(response))
+
+	// The evaluated value is returned when it doesn't match
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "url", Value: server.URL + "/health"},
+		api.DetectorAttributeDTO{Key: "expression", Value: "/components/db/status"},
+		api.DetectorAttributeDTO{Key: "expected", Value: "UP"}))
+	assert.Equal(t, false, response.Detected)
+	assert.Equal(t, "DOWN", getResponseValue(response))
+	assert.Assert(t, strings.Contains(response.Error, "doesn't match"), response.Error)
+
+	u, err := url.Parse(server.URL)
+	assert.NilError(t, err)
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "url", Value: "http://${ipaddr}:" + u.Port() + "/status.xml"},
+		api.DetectorAttributeDTO{Key: "expression", Value: "//version"},
+		api.DetectorAttributeDTO{Key: "expected", Value: "~^2\\."}))
+	assert.Equal(t, true, response.Detected, response.Error)
+	assert.Equal(t, "2.4.1", getResponseValue(response))
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "url", Value: server.URL + "/status.xml"},
+		api.DetectorAttributeDTO{Key: "expression", Value: "//uptime"}))
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, strings.Contains(response.Error, "not found"), response.Error)
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "url", Value: server.URL + "/echo"},
+		api.DetectorAttributeDTO{Key: "method", Value: "post"},
+		api.DetectorAttributeDTO{Key: "body", Value: `{"ping": 1}`},
+		api.DetectorAttributeDTO{Key: "format", Value: "json"},
+		api.DetectorAttributeDTO{Key: "expression", Value: "/ok"},
+		api.DetectorAttributeDTO{Key: "expected", Value: "true"}))
+	assert.Equal(t, true, response.Detected, response.Error)
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL,
+		api.DetectorAttributeDTO{Key: "url", Value: server.URL + "/missing"},
+		api.DetectorAttributeDTO{Key: "expression", Value: "/status"}))
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, strings.Contains(response.Error, "response code 404"), response.Error)
+
+	response = detector.Detect(newHTTPDetectorRequest(t, server.URL))
+	assert.Equal(t, "expression attribute required", response.Error)
+}
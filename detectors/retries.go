@@ -0,0 +1,19 @@
+package detectors
+
+import (
+	"context"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// WithRetries executes a detection attempt honoring the retries, timeout and retry-interval attributes of the request.
+// Returns nil when an attempt succeeds, or the error of the last one.
+// Attempts can return errors wrapped by tools.StopRetries to stop retrying.
+func WithRetries(ctx context.Context, request *api.DetectorRequestDTO, attempt func(ctx context.Context) error) error {
+	_, err := tools.WithRetries(ctx, request.GetRetries(), request.GetTimeout(), request.GetRetryInterval(), func(ctx context.Context) (time.Duration, error) {
+		return 0, attempt(ctx)
+	})
+	return err
+}
@@ -3,9 +3,11 @@ package detectors
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/snmp"
 	"github.com/gosnmp/gosnmp"
 )
 
@@ -21,6 +23,7 @@ func (detector *SNMPDetector) GetID() string {
 }
 
 // Detect execute the SNMP detector request and return the detection response
+// The service is detected when the agent returns a value for the oid attribute (sysObjectID by default), that matches the vbvalue regex when provided.
 func (detector *SNMPDetector) Detect(request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
 	oid := request.GetAttributeValue("oid", defaultOID)
 	isTable := request.GetAttributeValue("isTable", "false")
@@ -28,11 +31,11 @@ func (detector *SNMPDetector) Detect(request *api.DetectorRequestDTO) *api.Detec
 	expectedValue := request.GetAttributeValue("vbvalue", "")
 
 	agent := detector.getAgent(request)
-	client := agent.GetSNMPClient()
-	if err := client.Connect(); err != nil {
+	client, err := snmp.Acquire(agent)
+	if err != nil {
 		return &api.DetectorResponseDTO{Error: err.Error()}
 	}
-	defer client.Disconnect()
+	defer snmp.Release(client)
 
 	return detector.detect(client, oid, matchType, isTable, expectedValue)
 }
@@ -42,7 +45,9 @@ func (detector *SNMPDetector) detect(client api.SNMPHandler, oid string, matchTy
 	if strings.ToLower(isTable) == "true" {
 		var returnedValues []string
 		err := client.BulkWalk(oid, func(pdu gosnmp.SnmpPDU) error {
-			returnedValues = append(returnedValues, fmt.Sprintf("%v", pdu.Value))
+			if value, ok := detector.getValue(pdu); ok {
+				returnedValues = append(returnedValues, value)
+			}
 			return nil
 		})
 		if err == nil {
@@ -56,8 +61,9 @@ func (detector *SNMPDetector) detect(client api.SNMPHandler, oid string, matchTy
 		}
 		if result, err := client.Get(oid); err == nil {
 			if len(result.Variables) == 1 {
-				value := fmt.Sprintf("%v", result.Variables[0].Value)
-				response = detector.isServiceDetected(matchType, []string{value}, expectedValue)
+				if value, ok := detector.getValue(result.Variables[0]); ok {
+					response = detector.isServiceDetected(matchType, []string{value}, expectedValue)
+				}
 			}
 		} else {
 			response.Error = err.Error()
@@ -123,31 +129,64 @@ func (detector *SNMPDetector) matchExist(exp *regexp.Regexp, returnedValues []st
 	return false
 }
 
+// Builds the SNMP agent from the runtime attributes (sent by OpenNMS from snmp-config.xml), falling back to the detector attributes
 func (detector *SNMPDetector) getAgent(request *api.DetectorRequestDTO) *api.SNMPAgentDTO {
+	get := func(key string, defaultValue string) string {
+		if value := request.GetRuntimeAttributeValue(key); value != "" {
+			return value
+		}
+		return request.GetAttributeValue(key, defaultValue)
+	}
+	getInt := func(key string, defaultValue int) int {
+		if value, err := strconv.Atoi(get(key, "")); err == nil {
+			return value
+		}
+		return defaultValue
+	}
 	agent := &api.SNMPAgentDTO{
-		Address:         request.GetRuntimeAttributeValue("address"),
-		Port:            request.GetRuntimeAttributeValueAsInt("port"),
-		Timeout:         request.GetRuntimeAttributeValueAsInt("timeout"),
-		Retries:         request.GetRuntimeAttributeValueAsInt("retries"),
-		MaxVarsPerPdu:   request.GetRuntimeAttributeValueAsInt("max-vars-per-pdu"),
-		MaxRepetitions:  request.GetRuntimeAttributeValueAsInt("max-repetitions"),
-		MaxRequestSize:  request.GetRuntimeAttributeValueAsInt("max-request-size"),
-		Version:         request.GetRuntimeAttributeValueAsInt("version"),
-		SecurityLevel:   request.GetRuntimeAttributeValueAsInt("security-level"),
-		SecurityName:    request.GetRuntimeAttributeValue("security-name"),
-		ReadCommunity:   request.GetRuntimeAttributeValue("read-community"),
-		WriteCommunity:  request.GetRuntimeAttributeValue("write-community"),
-		AuthPassPhrase:  request.GetRuntimeAttributeValue("auth-passphrase"),
-		AuthProtocol:    request.GetRuntimeAttributeValue("auth-protocol"),
-		PrivPassPhrase:  request.GetRuntimeAttributeValue("priv-passphrase"),
-		PrivProtocol:    request.GetRuntimeAttributeValue("priv-protocol"),
-		ContextName:     request.GetRuntimeAttributeValue("context-name"),
-		EngineID:        request.GetRuntimeAttributeValue("engine-id"),
-		ContextEngineID: request.GetRuntimeAttributeValue("context-engine-id"),
+		Address:         get("address", request.IPAddress),
+		Port:            getInt("port", 161),
+		Timeout:         getInt("timeout", int(request.GetTimeout().Milliseconds())),
+		Retries:         getInt("retries", request.GetRetries()),
+		MaxVarsPerPdu:   getInt("max-vars-per-pdu", 10),
+		MaxRepetitions:  getInt("max-repetitions", 2),
+		MaxRequestSize:  getInt("max-request-size", 65535),
+		SecurityLevel:   getInt("security-level", 1),
+		SecurityName:    get("security-name", ""),
+		ReadCommunity:   get("read-community", "public"),
+		WriteCommunity:  get("write-community", "private"),
+		AuthPassPhrase:  get("auth-passphrase", ""),
+		AuthProtocol:    strings.ToUpper(get("auth-protocol", "")),
+		PrivPassPhrase:  get("priv-passphrase", ""),
+		PrivProtocol:    strings.ToUpper(get("priv-protocol", "")),
+		ContextName:     get("context-name", ""),
+		EngineID:        get("engine-id", ""),
+		ContextEngineID: get("context-engine-id", ""),
+	}
+	switch strings.ToLower(get("version", "")) {
+	case "v1", "1":
+		agent.Version = 1
+	case "v3", "3":
+		agent.Version = 3
+	default:
+		agent.Version = 2
 	}
 	return agent
 }
 
+// Gets the value of a PDU as a string; returns false when the agent has no value for the requested OID
+func (detector *SNMPDetector) getValue(pdu gosnmp.SnmpPDU) (string, bool) {
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+		return "", false
+	case gosnmp.OctetString:
+		if data, ok := pdu.Value.([]byte); ok {
+			return string(data), true
+		}
+	}
+	return fmt.Sprintf("%v", pdu.Value), true
+}
+
 func init() {
 	RegisterDetector(&SNMPDetector{})
 }
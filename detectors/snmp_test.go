@@ -3,6 +3,7 @@ package detectors
 import (
 	"testing"
 
+	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/tools"
 	"github.com/gosnmp/gosnmp"
 	"gotest.tools/v3/assert"
@@ -109,3 +110,50 @@ func TestSnmpDetectorAll(t *testing.T) {
 	assert.Equal(t, false, response.Detected)
 	assert.Assert(t, response.Error != "")
 }
+
+func TestSnmpDetectorValues(t *testing.T) {
+	client := &tools.MockSNMPClient{
+		GetMap: make(map[string]*gosnmp.SnmpPacket),
+	}
+	client.GetMap[defaultOID] = &gosnmp.SnmpPacket{
+		Variables: []gosnmp.SnmpPDU{
+			{Type: gosnmp.OctetString, Value: []byte("Cisco IOS Software, C2960")},
+		},
+	}
+	client.GetMap[".1.3.6.1.4.1.9.9.9.0"] = &gosnmp.SnmpPacket{
+		Variables: []gosnmp.SnmpPDU{
+			{Type: gosnmp.NoSuchObject},
+		},
+	}
+	snmpDetector := new(SNMPDetector)
+
+	response := snmpDetector.detect(client, defaultOID, "", "false", "^Cisco IOS")
+	assert.Equal(t, true, response.Detected)
+
+	response = snmpDetector.detect(client, ".1.3.6.1.4.1.9.9.9.0", "", "false", "")
+	assert.Equal(t, false, response.Detected)
+}
+
+func TestSnmpDetectorAgent(t *testing.T) {
+	request := &api.DetectorRequestDTO{
+		IPAddress: "10.0.0.1",
+		DetectorAttributes: []api.DetectorAttributeDTO{
+			{Key: "version", Value: "v3"},
+			{Key: "security-name", Value: "opennms"},
+			{Key: "security-level", Value: "3"},
+			{Key: "auth-protocol", Value: "sha"},
+			{Key: "port", Value: "1161"},
+		},
+		RuntimeAttributes: []api.DetectorAttributeDTO{
+			{Key: "port", Value: "161"},
+		},
+	}
+	agent := new(SNMPDetector).getAgent(request)
+	assert.Equal(t, "10.0.0.1", agent.Address)
+	assert.Equal(t, 161, agent.Port)
+	assert.Equal(t, 3, agent.Version)
+	assert.Equal(t, "opennms", agent.SecurityName)
+	assert.Equal(t, 3, agent.SecurityLevel)
+	assert.Equal(t, "SHA", agent.AuthProtocol)
+	assert.Equal(t, "public", agent.ReadCommunity)
+}
@@ -0,0 +1,75 @@
+package detectors
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/tools"
+)
+
+// SSHDetector represents a detector implementation
+type SSHDetector struct {
+}
+
+// GetID gets the detector ID (simple class name from its Java counterpart)
+func (detector *SSHDetector) GetID() string {
+	return "SshDetector"
+}
+
+// Detect execute the SSH detector request and return the detection response
+// The service is detected when the server sends a valid SSH 2.0 identification string that, if the banner attribute is set, contains it (or matches it when it starts with ~).
+func (detector *SSHDetector) Detect(request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	return detector.DetectWithContext(context.Background(), request)
+}
+
+// DetectWithContext executes the detection like Detect, until the context is done
+func (detector *SSHDetector) DetectWithContext(ctx context.Context, request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	results := &api.DetectorResponseDTO{Detected: false}
+	servAddr := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "22"))
+	banner := request.GetAttributeValue("banner", "")
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	timeout := request.GetTimeout()
+	var serverVersion string
+	err = WithRetries(ctx, request, func(ctx context.Context) error {
+		var err error
+		serverVersion, err = detector.detect(ctx, servAddr, ports, banner, timeout)
+		if err != nil {
+			log.FromContext(ctx).Debugf("SSH detection attempt against %s failed: %v", servAddr, err)
+		}
+		return err
+	})
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+	results.Detected = true
+	results.Attributes = append(results.Attributes, api.DetectorAttributeDTO{Key: "banner", Value: serverVersion})
+	return results
+}
+
+func (detector *SSHDetector) detect(ctx context.Context, servAddr string, ports *tools.PortRange, banner string, timeout time.Duration) (string, error) {
+	conn, err := tools.DialContext(ctx, "tcp", servAddr, 0, ports)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	serverVersion, err := tools.ReadSSHBanner(conn, timeout)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tools.MatchBanner(serverVersion, banner); err != nil {
+		return "", tools.StopRetries(err)
+	}
+	return serverVersion, nil
+}
+
+func init() {
+	RegisterDetector(&SSHDetector{})
+}
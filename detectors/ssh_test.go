@@ -0,0 +1,39 @@
+package detectors
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestSSHDetector(t *testing.T) {
+	listener := startBannerServer(t, "Welcome\r\nSSH-2.0-OpenSSH_8.4p1 Debian-5\r\n")
+	defer listener.Close()
+	detector := &SSHDetector{}
+	request := &api.DetectorRequestDTO{
+		IPAddress: "127.0.0.1",
+		DetectorAttributes: []api.DetectorAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)},
+			{Key: "banner", Value: "OpenSSH"},
+			{Key: "timeout", Value: "500"},
+			{Key: "retries", Value: "0"},
+		},
+	}
+	response := detector.Detect(request)
+	assert.Equal(t, true, response.Detected, response.Error)
+	assert.Equal(t, "SSH-2.0-OpenSSH_8.4p1 Debian-5", response.Attributes[0].Value)
+
+	request.DetectorAttributes[1].Value = "~^SSH-2\\.0-dropbear"
+	response = detector.Detect(request)
+	assert.Equal(t, false, response.Detected)
+
+	telnet := startBannerServer(t, "Ubuntu 20.04 LTS\r\nlogin: ")
+	defer telnet.Close()
+	request.DetectorAttributes[0].Value = strconv.Itoa(telnet.Addr().(*net.TCPAddr).Port)
+	request.DetectorAttributes[1].Value = ""
+	response = detector.Detect(request)
+	assert.Equal(t, false, response.Detected)
+}
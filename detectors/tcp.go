@@ -1,10 +1,13 @@
 package detectors
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/tools"
 )
 
@@ -18,32 +21,50 @@ func (detector *TCPDetector) GetID() string {
 }
 
 // Detect execute the TCP detector request and return the detection response
+// The service is detected when the connection succeeds and, if the banner attribute is set, the first message received contains it (or matches it when it starts with ~).
 func (detector *TCPDetector) Detect(request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
-	results := &api.DetectorResponseDTO{Detected: false}
+	return detector.DetectWithContext(context.Background(), request)
+}
 
-	servAddr := fmt.Sprintf("%s:%s", request.IPAddress, request.GetAttributeValue("port", "23"))
-	tcpAddr, err := net.ResolveTCPAddr("tcp", servAddr)
+// DetectWithContext executes the detection like Detect, until the context is done
+func (detector *TCPDetector) DetectWithContext(ctx context.Context, request *api.DetectorRequestDTO) *api.DetectorResponseDTO {
+	results := &api.DetectorResponseDTO{Detected: false}
+	servAddr := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "23"))
+	banner := request.GetAttributeValue("banner", "")
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
 	if err != nil {
 		results.Error = err.Error()
 		return results
 	}
-
 	timeout := request.GetTimeout()
-	dialer := net.Dialer{Timeout: timeout}
-	conn, err := dialer.Dial("tcp", tcpAddr.String())
+	err = WithRetries(ctx, request, func(ctx context.Context) error {
+		err := detector.detect(ctx, servAddr, ports, banner, timeout)
+		if err != nil {
+			log.FromContext(ctx).Debugf("TCP detection attempt against %s failed: %v", servAddr, err)
+		}
+		return err
+	})
 	if err != nil {
 		results.Error = err.Error()
 		return results
 	}
-	defer conn.Close()
+	results.Detected = true
+	return results
+}
 
-	banner := request.GetAttributeValue("banner", "")
-	if ok, err := tools.NetMessageContains(conn, timeout, banner); ok {
-		results.Detected = true
-	} else {
-		results.Error = err.Error()
+func (detector *TCPDetector) detect(ctx context.Context, servAddr string, ports *tools.PortRange, banner string, timeout time.Duration) error {
+	conn, err := tools.DialContext(ctx, "tcp", servAddr, 0, ports)
+	if err != nil {
+		return err
 	}
-	return results
+	defer conn.Close()
+	if ok, err := tools.NetMessageContains(conn, timeout, banner); !ok {
+		if err == nil {
+			err = fmt.Errorf("banner %s not found", banner)
+		}
+		return err
+	}
+	return nil
 }
 
 func init() {
@@ -0,0 +1,75 @@
+package detectors
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func startBannerServer(t *testing.T, banner string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(banner))
+			conn.Close()
+		}
+	}()
+	return listener
+}
+
+func TestTcpDetector(t *testing.T) {
+	listener := startBannerServer(t, "220 smtp.example.com ESMTP Postfix\r\n")
+	defer listener.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	detector := &TCPDetector{}
+	request := func(banner string) *api.DetectorRequestDTO {
+		return &api.DetectorRequestDTO{
+			IPAddress: "127.0.0.1",
+			DetectorAttributes: []api.DetectorAttributeDTO{
+				{Key: "port", Value: port},
+				{Key: "banner", Value: banner},
+				{Key: "timeout", Value: "500"},
+				{Key: "retries", Value: "1"},
+			},
+		}
+	}
+
+	response := detector.Detect(request(""))
+	assert.Equal(t, true, response.Detected)
+
+	response = detector.Detect(request("ESMTP"))
+	assert.Equal(t, true, response.Detected)
+
+	response = detector.Detect(request("~^220 .*Postfix"))
+	assert.Equal(t, true, response.Detected)
+
+	response = detector.Detect(request("~^554"))
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, response.Error != "")
+}
+
+func TestTcpDetectorConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+	request := &api.DetectorRequestDTO{
+		IPAddress: "127.0.0.1",
+		DetectorAttributes: []api.DetectorAttributeDTO{
+			{Key: "port", Value: port},
+			{Key: "retries", Value: "2"},
+		},
+	}
+	assert.Equal(t, 2, request.GetRetries())
+	response := (&TCPDetector{}).Detect(request)
+	assert.Equal(t, false, response.Detected)
+	assert.Assert(t, response.Error != "")
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// contextKey is the key of the logger within a context
+type contextKey struct{}
+
+// Logger represents a child logger that adds a set of fields to every message (e.g. the ID of an RPC request)
+type Logger struct {
+	log *zap.SugaredLogger
+}
+
+// WithFields returns a child logger of the global logger with the given key/value pairs
+func WithFields(keysAndValues ...interface{}) *Logger {
+	if log == nil {
+		return &Logger{}
+	}
+	return &Logger{log.With(keysAndValues...)}
+}
+
+// NewContext returns a copy of the context that carries the given logger
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or the global logger when there is none
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*Logger); ok && logger != nil {
+			return logger
+		}
+	}
+	return &Logger{log}
+}
+
+// WithFields returns a child logger with the given key/value pairs in addition to the existing ones
+func (l *Logger) WithFields(keysAndValues ...interface{}) *Logger {
+	if l.log == nil {
+		return l
+	}
+	return &Logger{l.log.With(keysAndValues...)}
+}
+
+// Errorf logs a formatted error message
+func (l *Logger) Errorf(format string, params ...interface{}) {
+	if l.log != nil {
+		l.log.Errorf(format, params...)
+	}
+}
+
+// Warnf logs a formatted warn message
+func (l *Logger) Warnf(format string, params ...interface{}) {
+	if l.log != nil {
+		l.log.Warnf(format, params...)
+	}
+}
+
+// Infof logs a formatted info message
+func (l *Logger) Infof(format string, params ...interface{}) {
+	if l.log != nil {
+		l.log.Infof(format, params...)
+	}
+}
+
+// Debugf logs a formatted debug message
+func (l *Logger) Debugf(format string, params ...interface{}) {
+	if l.log != nil {
+		l.log.Debugf(format, params...)
+	}
+}
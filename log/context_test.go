@@ -0,0 +1,33 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gotest.tools/v3/assert"
+)
+
+func TestLoggerFromContext(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log = zap.New(core).Sugar()
+	defer func() { log = nil }()
+
+	ctx := NewContext(context.Background(), WithFields("rpcId", "001", "module", "Poller"))
+	FromContext(ctx).Debugf("Executing monitor %s", "TcpMonitor")
+	FromContext(context.Background()).Infof("Untagged")
+
+	entries := logs.All()
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "Executing monitor TcpMonitor", entries[0].Message)
+	assert.DeepEqual(t, map[string]interface{}{"rpcId": "001", "module": "Poller"}, entries[0].ContextMap())
+	assert.Equal(t, 0, len(entries[1].Context))
+}
+
+func TestLoggerWithoutGlobalLogger(t *testing.T) {
+	logger := WithFields("rpcId", "001").WithFields("module", "DNS")
+	logger.Errorf("Nothing to log")
+	FromContext(NewContext(context.Background(), logger)).Debugf("Nothing to log")
+}
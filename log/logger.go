@@ -2,10 +2,12 @@ package log
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var logger *zap.Logger
@@ -56,22 +58,39 @@ func GetSugaredLogger() *zap.SugaredLogger {
 	return log
 }
 
-// InitLogger initializes colorized logger
-func InitLogger(logLevel string) {
+// FileOutput represents a log file, rotated when it reaches its maximum size
+type FileOutput struct {
+	Path       string
+	MaxSize    int // In megabytes
+	MaxBackups int // 0 keeps all the rotated files
+	MaxAge     int // In days, 0 keeps the rotated files regardless of their age
+}
+
+// InitLogger initializes the logger with the given format: either a colorized console output (the default), or json.
+// The messages are written to stdout, or to the given file when it is not nil (without colors).
+func InitLogger(logLevel string, logFormat string, file *FileOutput) {
 	level := getLogLevel(logLevel)
+	encoding := "console"
+	encodeLevel := zapcore.CapitalColorLevelEncoder
+	if strings.ToLower(logFormat) == "json" {
+		encoding = "json"
+		encodeLevel = zapcore.LowercaseLevelEncoder
+	} else if file != nil {
+		encodeLevel = zapcore.CapitalLevelEncoder
+	}
 	config := zap.Config{
 		Level:             level,
 		Development:       false,
 		DisableStacktrace: true,
 		DisableCaller:     true,
-		Encoding:          "console",
+		Encoding:          encoding,
 		EncoderConfig: zapcore.EncoderConfig{
 			TimeKey:        "ts",
 			LevelKey:       "level",
 			NameKey:        "logger",
 			MessageKey:     "msg",
 			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.CapitalColorLevelEncoder,
+			EncodeLevel:    encodeLevel,
 			EncodeTime:     zapcore.ISO8601TimeEncoder,
 			EncodeDuration: zapcore.StringDurationEncoder,
 			EncodeCaller:   zapcore.ShortCallerEncoder,
@@ -79,6 +98,11 @@ func InitLogger(logLevel string) {
 		OutputPaths:      []string{"stdout"},
 		ErrorOutputPaths: []string{"stderr"},
 	}
+	if file != nil {
+		logger = newFileLogger(config, file)
+		log = logger.Sugar()
+		return
+	}
 	var err error
 	logger, err = config.Build()
 	if err != nil {
@@ -87,6 +111,21 @@ func InitLogger(logLevel string) {
 	log = logger.Sugar()
 }
 
+// Builds a logger that writes to a file rotated by lumberjack, with the encoder and level of the given configuration
+func newFileLogger(config zap.Config, file *FileOutput) *zap.Logger {
+	encoder := zapcore.NewConsoleEncoder(config.EncoderConfig)
+	if config.Encoding == "json" {
+		encoder = zapcore.NewJSONEncoder(config.EncoderConfig)
+	}
+	writer := &lumberjack.Logger{
+		Filename:   file.Path,
+		MaxSize:    file.MaxSize,
+		MaxBackups: file.MaxBackups,
+		MaxAge:     file.MaxAge,
+	}
+	return zap.New(zapcore.NewCore(encoder, zapcore.AddSync(writer), config.Level), zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+}
+
 // InitProdLogger initializes production logger
 func InitProdLogger(logLevel string) {
 	config := zap.NewProductionConfig()
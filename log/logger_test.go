@@ -0,0 +1,27 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestInitLoggerWithFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gominion.log")
+	InitLogger("info", "console", &FileOutput{Path: path, MaxSize: 1, MaxBackups: 2})
+	defer func() { logger, log = nil, nil }()
+
+	Debugf("Not logged")
+	Infof("Starting Minion %s", "minion1")
+	assert.NilError(t, logger.Sync())
+
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	content := string(data)
+	assert.Assert(t, strings.Contains(content, "INFO\tStarting Minion minion1"))
+	assert.Assert(t, !strings.Contains(content, "Not logged"))
+	assert.Assert(t, !strings.Contains(content, "\x1b["), "colors must not be written to the file")
+}
@@ -0,0 +1,84 @@
+package monitors
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/snmp"
+)
+
+// The columns of the bgpPeerTable from the BGP4-MIB, indexed by the IP address of the peer
+const (
+	bgpPeerStateOID       = ".1.3.6.1.2.1.15.3.1.2"
+	bgpPeerAdminStatusOID = ".1.3.6.1.2.1.15.3.1.3"
+	bgpPeerRemoteAsOID    = ".1.3.6.1.2.1.15.3.1.9"
+)
+
+const bgpEstablished = 6
+
+var bgpPeerStates = map[int64]string{1: "idle", 2: "connect", 3: "active", 4: "opensent", 5: "openconfirm", 6: "established"}
+
+var bgpAdminStatuses = map[int64]string{1: "stop", 2: "start"}
+
+// BGPSessionMonitor represents a Monitor implementation for the state of a BGP session via SNMP
+type BGPSessionMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *BGPSessionMonitor) GetID() string {
+	return "BgpSessionMonitor"
+}
+
+// Poll execute the BGP session monitor request and return the poller response
+// The service is up when the session with the peer from the bgpPeerIp attribute is established.
+func (monitor *BGPSessionMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	agent := &api.SNMPAgentDTO{}
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	if err := xml.Unmarshal([]byte(request.GetAttributeContent("agent")), agent); err != nil {
+		response.Status.Unknown(err.Error())
+		return response
+	}
+	peer := request.GetAttributeValue("bgpPeerIp", "")
+	if ip := net.ParseIP(peer); ip == nil || ip.To4() == nil {
+		response.Status.Down(fmt.Sprintf("invalid bgpPeerIp %q, expected the IPv4 address of the peer", peer))
+		return response
+	}
+	overrideAgentSettings(request, agent)
+	client, err := snmp.Acquire(agent)
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	defer snmp.Release(client)
+	return monitor.poll(client, peer)
+}
+
+func (monitor *BGPSessionMonitor) poll(client api.SNMPHandler, peer string) *api.PollerResponseDTO {
+	start := time.Now()
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	state, err := getSNMPInteger(client, bgpPeerStateOID+"."+peer)
+	if err != nil {
+		response.Status.Down(fmt.Sprintf("cannot get the state of BGP peer %s: %v", peer, err))
+		return response
+	}
+	if state == bgpEstablished {
+		response.Status.Up(time.Since(start).Seconds())
+		return response
+	}
+	reason := fmt.Sprintf("BGP session with peer %s is %s", peer, describeSNMPInteger(bgpPeerStates, state))
+	if as, err := getSNMPInteger(client, bgpPeerRemoteAsOID+"."+peer); err == nil {
+		reason = fmt.Sprintf("BGP session with peer %s (AS %d) is %s", peer, as, describeSNMPInteger(bgpPeerStates, state))
+	}
+	if admin, err := getSNMPInteger(client, bgpPeerAdminStatusOID+"."+peer); err == nil {
+		reason += fmt.Sprintf(", admin status %s", describeSNMPInteger(bgpAdminStatuses, admin))
+	}
+	response.Status.Down(reason)
+	return response
+}
+
+func init() {
+	RegisterMonitor(&BGPSessionMonitor{})
+}
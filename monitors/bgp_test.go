@@ -0,0 +1,66 @@
+package monitors
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+	"github.com/gosnmp/gosnmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestBGPSessionMonitor(t *testing.T) {
+	peer := "10.0.0.2"
+	integer := func(value int) *gosnmp.SnmpPacket {
+		return &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{{Type: gosnmp.Integer, Value: value}}}
+	}
+	client := &tools.MockSNMPClient{
+		GetMap: map[string]*gosnmp.SnmpPacket{
+			bgpPeerStateOID + "." + peer:       integer(6),
+			bgpPeerAdminStatusOID + "." + peer: integer(2),
+			bgpPeerRemoteAsOID + "." + peer:    integer(65001),
+		},
+	}
+	monitor := &BGPSessionMonitor{}
+
+	response := monitor.poll(client, peer)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+
+	client.GetMap[bgpPeerStateOID+"."+peer] = integer(3)
+	response = monitor.poll(client, peer)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "BGP session with peer 10.0.0.2 (AS 65001) is active(3), admin status start(2)", response.Status.Reason)
+
+	client.GetMap[bgpPeerStateOID+".10.0.0.3"] = &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{{Type: gosnmp.NoSuchInstance}}}
+	response = monitor.poll(client, "10.0.0.3")
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "no such instance"))
+}
+
+func TestBGPSessionMonitorInvalidPeer(t *testing.T) {
+	monitor := &BGPSessionMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "agent", Content: "<snmp-agent-dto><address>127.0.0.1</address></snmp-agent-dto>"},
+			{Key: "bgpPeerIp", Value: "peer1"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "invalid bgpPeerIp"))
+}
+
+func TestOverrideAgentSettings(t *testing.T) {
+	agent := &api.SNMPAgentDTO{Timeout: 1800, Retries: 1}
+	overrideAgentSettings(&api.PollerRequestDTO{}, agent)
+	assert.Equal(t, 1800, agent.Timeout)
+	assert.Equal(t, 1, agent.Retries)
+
+	request := &api.PollerRequestDTO{Attributes: []api.PollerAttributeDTO{{Key: "timeout", Value: "5000"}, {Key: "retry", Value: "3"}}}
+	overrideAgentSettings(request, agent)
+	assert.Equal(t, int(5*time.Second/time.Millisecond), agent.Timeout)
+	assert.Equal(t, 3, agent.Retries)
+}
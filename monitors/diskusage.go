@@ -0,0 +1,135 @@
+package monitors
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/snmp"
+	"github.com/gosnmp/gosnmp"
+)
+
+// The columns of the hrStorageTable from the HOST-RESOURCES-MIB
+const (
+	hrStorageDescrOID = ".1.3.6.1.2.1.25.2.3.1.3"
+	hrStorageSizeOID  = ".1.3.6.1.2.1.25.2.3.1.5"
+	hrStorageUsedOID  = ".1.3.6.1.2.1.25.2.3.1.6"
+)
+
+const defaultDiskThreshold = 85.0
+
+// DiskUsageMonitor represents a Monitor implementation for the usage of the storage of a host via SNMP
+type DiskUsageMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *DiskUsageMonitor) GetID() string {
+	return "DiskUsageMonitor"
+}
+
+// Poll execute the disk usage monitor request and return the poller response
+// The service is down when the used percentage of any storage whose description matches the disk attribute exceeds the threshold attribute.
+// Otherwise, the reason has the usage of the matched storages, and the disk-usage property the highest one.
+func (monitor *DiskUsageMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	agent := &api.SNMPAgentDTO{}
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	if err := xml.Unmarshal([]byte(request.GetAttributeContent("agent")), agent); err != nil {
+		response.Status.Unknown(err.Error())
+		return response
+	}
+	expression := request.GetAttributeValue("disk", "")
+	if expression == "" {
+		response.Status.Down("disk attribute required")
+		return response
+	}
+	disk, err := regexp.Compile(expression)
+	if err != nil {
+		response.Status.Down(fmt.Sprintf("invalid disk expression: %v", err))
+		return response
+	}
+	threshold, err := strconv.ParseFloat(request.GetAttributeValue("threshold", strconv.FormatFloat(defaultDiskThreshold, 'f', -1, 64)), 64)
+	if err != nil || threshold < 0 || threshold > 100 {
+		response.Status.Down(fmt.Sprintf("invalid threshold %s, expected a percentage", request.GetAttributeValue("threshold", "")))
+		return response
+	}
+	overrideAgentSettings(request, agent)
+	client, err := snmp.Acquire(agent)
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	defer snmp.Release(client)
+	return monitor.poll(client, disk, threshold)
+}
+
+func (monitor *DiskUsageMonitor) poll(client api.SNMPHandler, disk *regexp.Regexp, threshold float64) *api.PollerResponseDTO {
+	start := time.Now()
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	storages := make(map[string]string) // Description per index
+	err := client.BulkWalk(hrStorageDescrOID, func(pdu gosnmp.SnmpPDU) error {
+		descr := getSNMPString(pdu.Value)
+		if disk.MatchString(descr) {
+			storages[strings.TrimPrefix(pdu.Name, hrStorageDescrOID+".")] = descr
+		}
+		return nil
+	})
+	if err != nil {
+		response.Status.Down(fmt.Sprintf("cannot walk the storage table: %v", err))
+		return response
+	}
+	if len(storages) == 0 {
+		response.Status.Down(fmt.Sprintf("no storage matches %s", disk))
+		return response
+	}
+	indexes := make([]string, 0, len(storages))
+	for index := range storages {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return storages[indexes[i]] < storages[indexes[j]] })
+	usages := make([]string, 0, len(indexes))
+	highest := 0.0
+	for _, index := range indexes {
+		descr := storages[index]
+		usage, err := monitor.getUsage(client, index)
+		if err != nil {
+			response.Status.Down(fmt.Sprintf("cannot get the usage of %s: %v", descr, err))
+			return response
+		}
+		if usage > threshold {
+			response.Status.Down(fmt.Sprintf("usage of %s is %.2f%%, exceeding the threshold of %g%%", descr, usage, threshold))
+			return response
+		}
+		usages = append(usages, fmt.Sprintf("%s is %.2f%%", descr, usage))
+		highest = math.Max(highest, usage)
+	}
+	response.Status.Up(time.Since(start).Seconds())
+	response.Status.Reason = "usage of " + strings.Join(usages, ", ")
+	response.Status.SetProperty("disk-usage", highest)
+	return response
+}
+
+// Returns the used percentage of the storage with the given index
+func (monitor *DiskUsageMonitor) getUsage(client api.SNMPHandler, index string) (float64, error) {
+	size, err := getSNMPInteger(client, hrStorageSizeOID+"."+index)
+	if err != nil {
+		return 0, err
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("the size is unknown")
+	}
+	used, err := getSNMPInteger(client, hrStorageUsedOID+"."+index)
+	if err != nil {
+		return 0, err
+	}
+	return float64(used) * 100 / float64(size), nil
+}
+
+func init() {
+	RegisterMonitor(&DiskUsageMonitor{})
+}
@@ -0,0 +1,69 @@
+package monitors
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+	"github.com/gosnmp/gosnmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestDiskUsageMonitor(t *testing.T) {
+	integer := func(value int) *gosnmp.SnmpPacket {
+		return &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{{Type: gosnmp.Integer, Value: value}}}
+	}
+	client := &tools.MockSNMPClient{
+		WalkMap: map[string][]gosnmp.SnmpPDU{
+			hrStorageDescrOID: {
+				{Name: hrStorageDescrOID + ".1", Type: gosnmp.OctetString, Value: []byte("Physical memory")},
+				{Name: hrStorageDescrOID + ".31", Type: gosnmp.OctetString, Value: []byte("/")},
+				{Name: hrStorageDescrOID + ".36", Type: gosnmp.OctetString, Value: []byte("/var")},
+			},
+		},
+		GetMap: map[string]*gosnmp.SnmpPacket{
+			hrStorageSizeOID + ".1":  integer(1000),
+			hrStorageUsedOID + ".1":  integer(990),
+			hrStorageSizeOID + ".31": integer(2000),
+			hrStorageUsedOID + ".31": integer(1000),
+			hrStorageSizeOID + ".36": integer(4000),
+			hrStorageUsedOID + ".36": integer(3700),
+		},
+	}
+	monitor := &DiskUsageMonitor{}
+
+	response := monitor.poll(client, regexp.MustCompile("^/$"), 85)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+	assert.Equal(t, "usage of / is 50.00%", response.Status.Reason)
+	assert.Equal(t, 50.0, response.Status.GetPropertyValue("disk-usage"))
+
+	response = monitor.poll(client, regexp.MustCompile("^/"), 85)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "usage of /var is 92.50%, exceeding the threshold of 85%", response.Status.Reason)
+
+	response = monitor.poll(client, regexp.MustCompile("^/var$"), 95)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+
+	response = monitor.poll(client, regexp.MustCompile("^/"), 95)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+	assert.Equal(t, "usage of / is 50.00%, /var is 92.50%", response.Status.Reason)
+	assert.Equal(t, 92.5, response.Status.GetPropertyValue("disk-usage"))
+
+	response = monitor.poll(client, regexp.MustCompile("^/opt$"), 85)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "no storage matches ^/opt$", response.Status.Reason)
+}
+
+func TestDiskUsageMonitorWithoutDisk(t *testing.T) {
+	monitor := &DiskUsageMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "agent", Content: "<snmp-agent-dto><address>127.0.0.1</address></snmp-agent-dto>"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "disk attribute required", response.Status.Reason)
+}
@@ -0,0 +1,56 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// DNSMonitor represents the DNS Monitor implementation
+type DNSMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *DNSMonitor) GetID() string {
+	return "DnsMonitor"
+}
+
+// Poll execute the DNS monitor request and return the poller response.
+// The query is sent directly to the node IP acting as the DNS server.
+func (monitor *DNSMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the DNS monitor request until the context is done, and return the poller response.
+func (monitor *DNSMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	lookup := request.GetAttributeValue("lookup", "localhost")
+	recordType := strings.ToUpper(request.GetAttributeValue("record-type", "A"))
+	server := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "53"))
+	timeout := request.GetTimeout()
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		start := time.Now()
+		values, err := tools.QueryDNS(ctx, server, lookup, recordType, timeout)
+		if err == nil && len(values) == 0 {
+			return 0, tools.StopRetries(fmt.Errorf("no %s record found for %s on %s", recordType, lookup, server))
+		}
+		if err == nil {
+			return time.Since(start), nil
+		}
+		if errors.Is(err, tools.ErrDNSNameNotFound) {
+			return 0, tools.StopRetries(fmt.Errorf("NXDOMAIN: %s record for %s not found on %s", recordType, lookup, server))
+		}
+		return 0, fmt.Errorf("cannot resolve %s record for %s on %s: %w", recordType, lookup, server, err)
+	})
+	return response
+}
+
+func init() {
+	RegisterMonitor(&DNSMonitor{})
+}
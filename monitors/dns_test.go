@@ -0,0 +1,77 @@
+package monitors
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"golang.org/x/net/dns/dnsmessage"
+	"gotest.tools/v3/assert"
+)
+
+func TestDNSMonitor(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer conn.Close()
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			size, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buffer[:size]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			question := query.Questions[0]
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if question.Name.String() != "www.example.com." {
+				reply.RCode = dnsmessage.RCodeNameError
+			} else if question.Type == dnsmessage.TypeA {
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+				}}
+			}
+			data, _ := reply.Pack()
+			conn.WriteTo(data, addr)
+		}
+	}()
+
+	monitor := &DNSMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)},
+			{Key: "lookup", Value: "www.example.com"},
+			{Key: "timeout", Value: "500"},
+			{Key: "retry", Value: "0"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+
+	// The default lookup is not answered from /etc/hosts
+	request.Attributes[1].Value = "localhost"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "NXDOMAIN"), response.Status.Reason)
+
+	request.Attributes[1].Value = "www.example.com"
+	request.Attributes = append(request.Attributes, api.PollerAttributeDTO{Key: "record-type", Value: "AAAA"})
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "no AAAA record found"), response.Status.Reason)
+
+	conn.Close() // Nothing listens on the port from now on
+	request.Attributes = request.Attributes[:4]
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "cannot resolve"), response.Status.Reason)
+}
@@ -0,0 +1,95 @@
+package monitors
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agalue/gominion/api"
+)
+
+// EWMAAttribute is the attribute of a request that enables the smoothing of the response time, with the weight of the latest sample (between 0 and 1)
+const EWMAAttribute = "response-time-ewma"
+
+// The time after which the average of a service that is no longer polled is discarded
+const defaultEWMATTL = time.Hour
+
+// The cache used by SmoothResponseTime
+var defaultEWMACache = NewEWMACache(defaultEWMATTL)
+
+// ewmaEntry represents the average response time of a service
+type ewmaEntry struct {
+	value     float64
+	updatedAt time.Time
+}
+
+// EWMACache keeps the exponentially weighted moving average of the response times per service.
+// The averages not updated within the TTL are discarded, so the next sample starts a new average.
+type EWMACache struct {
+	ttl       time.Duration
+	entries   map[string]*ewmaEntry
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+// NewEWMACache creates a new cache with the given TTL
+func NewEWMACache(ttl time.Duration) *EWMACache {
+	return &EWMACache{
+		ttl:       ttl,
+		entries:   make(map[string]*ewmaEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Update adds a sample to the average of the given key, and returns the new average
+func (cache *EWMACache) Update(key string, sample float64, alpha float64) float64 {
+	now := time.Now()
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if now.Sub(cache.lastSweep) > cache.ttl {
+		cache.evict(now)
+	}
+	entry, ok := cache.entries[key]
+	if !ok || now.Sub(entry.updatedAt) > cache.ttl {
+		entry = &ewmaEntry{value: sample}
+		cache.entries[key] = entry
+	} else {
+		entry.value = alpha*sample + (1-alpha)*entry.value
+	}
+	entry.updatedAt = now
+	return entry.value
+}
+
+// Size returns the number of averages in the cache
+func (cache *EWMACache) Size() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return len(cache.entries)
+}
+
+// Removes the expired entries; the caller must hold the lock
+func (cache *EWMACache) evict(now time.Time) {
+	for key, entry := range cache.entries {
+		if now.Sub(entry.updatedAt) > cache.ttl {
+			delete(cache.entries, key)
+		}
+	}
+	cache.lastSweep = now
+}
+
+// SmoothResponseTime replaces the response time of an available service with its moving average when the response-time-ewma attribute is set.
+// The raw response time is kept on the response-time-raw property. Unavailable services don't update the average.
+func SmoothResponseTime(request *api.PollerRequestDTO, status *api.PollStatus) {
+	if status == nil || status.StatusCode != api.ServiceAvailableCode {
+		return
+	}
+	alpha, err := strconv.ParseFloat(request.GetAttributeValue(EWMAAttribute, ""), 64)
+	if err != nil || alpha <= 0 || alpha > 1 {
+		return
+	}
+	key := request.NodeID + "|" + request.IPAddress + "|" + request.ServiceName
+	value := defaultEWMACache.Update(key, status.ResponseTime, alpha)
+	status.SetProperty("response-time-raw", status.ResponseTime)
+	status.SetProperty("response-time", value)
+	status.ResponseTime = value
+}
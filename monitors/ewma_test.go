@@ -0,0 +1,51 @@
+package monitors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestEWMACache(t *testing.T) {
+	cache := NewEWMACache(50 * time.Millisecond)
+	assert.Equal(t, 10.0, cache.Update("a", 10, 0.5))
+	assert.Equal(t, 15.0, cache.Update("a", 20, 0.5))
+	assert.Equal(t, 1.0, cache.Update("b", 1, 0.5))
+	assert.Equal(t, 2, cache.Size())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 4.0, cache.Update("a", 4, 0.5)) // Expired, starts again
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestSmoothResponseTime(t *testing.T) {
+	request := &api.PollerRequestDTO{
+		IPAddress:   "10.0.0.1",
+		NodeID:      "1",
+		ServiceName: "HTTP",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "response-time-ewma", Value: "0.25"},
+		},
+	}
+	status := &api.PollStatus{}
+	SmoothResponseTime(request, status.Up(0.1))
+	assert.Equal(t, 0.1, status.ResponseTime)
+
+	status = &api.PollStatus{}
+	SmoothResponseTime(request, status.Up(0.5))
+	assert.Equal(t, 0.2, status.ResponseTime)
+	assert.Equal(t, 0.2, status.GetPropertyValue("response-time"))
+	assert.Equal(t, 0.5, status.GetPropertyValue("response-time-raw"))
+
+	status = &api.PollStatus{}
+	status.Down("timeout")
+	SmoothResponseTime(request, status)
+	assert.Equal(t, 0.0, status.ResponseTime)
+
+	request.Attributes[0].Value = "false"
+	status = &api.PollStatus{}
+	SmoothResponseTime(request, status.Up(1))
+	assert.Equal(t, 1.0, status.ResponseTime)
+}
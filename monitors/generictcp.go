@@ -0,0 +1,127 @@
+package monitors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// GenericTCPMonitor represents a Monitor implementation for arbitrary request-response TCP protocols (e.g. WHOIS or finger)
+type GenericTCPMonitor struct {
+}
+
+// GetID gets the monitor ID (there is no Java counterpart)
+func (monitor *GenericTCPMonitor) GetID() string {
+	return "GenericTcpMonitor"
+}
+
+// Poll execute the generic TCP monitor request and return the the poller response.
+// The response time is the duration of the exchange, from sending the request until the response is read.
+func (monitor *GenericTCPMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the generic TCP monitor request until the context is done, and return the the poller response.
+// After connecting to port, the monitor sends the request attribute (if any), reads until the delimiter (a LF by default), and matches the response against the response regular expression (if any).
+// The request and the delimiter accept escape sequences like \r\n.
+func (monitor *GenericTCPMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	port := request.GetAttributeValue("port", "")
+	if port == "" {
+		response.Status.Down("port required")
+		return response
+	}
+	servAddr := net.JoinHostPort(request.IPAddress, port)
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	var matcher *regexp.Regexp
+	if pattern := request.GetAttributeValue("response", ""); pattern != "" {
+		if matcher, err = regexp.Compile(pattern); err != nil {
+			response.Status.Down(fmt.Sprintf("invalid response expression: %v", err))
+			return response
+		}
+	}
+	payload := []byte(unescape(request.GetAttributeValue("request", "")))
+	delimiter := []byte(unescape(request.GetAttributeValue("delimiter", "\\n")))
+	maxSize := request.GetAttributeValueAsInt("max-response-size", tools.DefaultBannerSize)
+	timeout := request.GetTimeout()
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		return monitor.check(ctx, servAddr, ports, timeout, payload, delimiter, maxSize, matcher)
+	})
+	return response
+}
+
+func (monitor *GenericTCPMonitor) check(ctx context.Context, servAddr string, ports *tools.PortRange, timeout time.Duration, payload []byte, delimiter []byte, maxSize int, matcher *regexp.Regexp) (time.Duration, error) {
+	conn, err := dialTCP(ctx, servAddr, ports, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	start := time.Now()
+	if len(payload) > 0 {
+		if _, err := conn.Write(payload); err != nil {
+			return 0, err
+		}
+	}
+	reply, err := readUntil(conn, delimiter, maxSize)
+	if err != nil {
+		return 0, err
+	}
+	duration := time.Since(start)
+	if matcher != nil && !matcher.Match(reply) {
+		return 0, tools.StopRetries(fmt.Errorf("response %q doesn't match %s", reply, matcher))
+	}
+	return duration, nil
+}
+
+// Reads until the delimiter (which is not included in the result), the end of the stream, or the maximum size.
+// When the delimiter is empty, it reads until the end of the stream.
+func readUntil(reader io.Reader, delimiter []byte, maxSize int) ([]byte, error) {
+	data := make([]byte, 0, 512)
+	buffer := make([]byte, 512)
+	for {
+		n, err := reader.Read(buffer)
+		data = append(data, buffer[:n]...)
+		if len(delimiter) > 0 {
+			if idx := bytes.Index(data, delimiter); idx >= 0 {
+				return data[:idx], nil
+			}
+		}
+		if len(data) >= maxSize {
+			return data[:maxSize], nil
+		}
+		if err == io.EOF {
+			if len(data) == 0 {
+				return nil, fmt.Errorf("connection closed without a response")
+			}
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Interprets the escape sequences of a parameter (e.g. \r\n); returns the parameter as is when they are invalid
+func unescape(value string) string {
+	if s, err := strconv.Unquote(`"` + strings.ReplaceAll(value, `"`, `\"`) + `"`); err == nil {
+		return s
+	}
+	return value
+}
+
+func init() {
+	RegisterMonitor(&GenericTCPMonitor{})
+}
@@ -0,0 +1,73 @@
+package monitors
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestGenericTCPMonitor(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			query, _ := bufio.NewReader(conn).ReadString('\n')
+			conn.Write([]byte("% WHOIS server\r\ndomain: " + strings.TrimSpace(query) + "\r\nstatus: active\r\n"))
+			conn.Close()
+		}
+	}()
+
+	monitor := &GenericTCPMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)},
+			{Key: "request", Value: "example.com\\r\\n"},
+			{Key: "delimiter", Value: ""},
+			{Key: "response", Value: "(?m)^domain: example\\.com"},
+			{Key: "retry", Value: "0"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+
+	request.Attributes[2].Value = "\\r\\n"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, `response "% WHOIS server" doesn't match (?m)^domain: example\.com`, response.Status.Reason)
+
+	request.Attributes[3].Value = "^% WHOIS"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+
+	request.Attributes[3].Value = "(unbalanced"
+	response = monitor.Poll(request)
+	assert.Assert(t, strings.HasPrefix(response.Status.Reason, "invalid response expression"))
+}
+
+func TestReadUntil(t *testing.T) {
+	data, err := readUntil(strings.NewReader("220 ready\r\nmore"), []byte("\r\n"), 1024)
+	assert.NilError(t, err)
+	assert.Equal(t, "220 ready", string(data))
+
+	data, err = readUntil(strings.NewReader("no delimiter"), []byte("\n"), 1024)
+	assert.NilError(t, err)
+	assert.Equal(t, "no delimiter", string(data))
+
+	data, err = readUntil(strings.NewReader("truncated response"), []byte("\n"), 9)
+	assert.NilError(t, err)
+	assert.Equal(t, "truncated", string(data))
+
+	_, err = readUntil(strings.NewReader(""), []byte("\n"), 1024)
+	assert.ErrorContains(t, err, "without a response")
+}
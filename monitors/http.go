@@ -1,10 +1,13 @@
 package monitors
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -27,44 +30,59 @@ func (monitor *HTTPMonitor) GetID() string {
 
 // Poll execute the HTTP monitor request and return the the poller response
 func (monitor *HTTPMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the HTTP monitor request until the context is done, and return the the poller response
+// Connection errors are retried, while an unexpected status code or response text stops the retries.
+func (monitor *HTTPMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
 	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
-	start := time.Now()
-	client := monitor.getClient(request)
-	httpreq, err := monitor.getHTTPRequest(request)
+	client, err := monitor.getClient(request)
 	if err != nil {
 		response.Status.Down(err.Error())
 		return response
 	}
-	httpres, err := client.Do(httpreq)
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		start := time.Now()
+		if err := monitor.check(ctx, client, request); err != nil {
+			return 0, err
+		}
+		return time.Since(start), nil
+	})
+	return response
+}
+
+// Sends the HTTP request, and verifies the status code and the response text
+func (monitor *HTTPMonitor) check(ctx context.Context, client *http.Client, request *api.PollerRequestDTO) error {
+	httpreq, err := monitor.getHTTPRequest(request)
+	if err != nil {
+		return tools.StopRetries(err)
+	}
+	httpres, err := client.Do(httpreq.WithContext(ctx))
 	if err != nil {
-		response.Status.Down(err.Error())
-		return response
+		return err
 	}
+	defer httpres.Body.Close()
 	min, max := monitor.getResponseRange(request)
 	if httpres.StatusCode < min || httpres.StatusCode > max {
-		response.Status.Down(fmt.Sprintf("Response code %d out of expected range: %d-%d", httpres.StatusCode, min, max))
-		return response
+		return tools.StopRetries(fmt.Errorf("Response code %d out of expected range: %d-%d", httpres.StatusCode, min, max))
 	}
 	responseText := request.GetAttributeValue("response-text", "")
 	if responseText != "" {
 		data, err := ioutil.ReadAll(httpres.Body)
 		if err != nil {
-			response.Status.Down(err.Error())
-			return response
+			return err
 		}
-		if !strings.Contains(string(data), responseText) {
-			response.Status.Down(fmt.Sprintf("Response doesn't containt text %s", responseText))
-			return response
+		if ok, err := monitor.matchResponseText(string(data), responseText); !ok {
+			return tools.StopRetries(err)
 		}
 	}
-	duration := time.Since(start)
-	response.Status.Up(duration.Seconds())
-	return response
+	return nil
 }
 
 func (monitor *HTTPMonitor) getURL(request *api.PollerRequestDTO) *url.URL {
 	u := &url.URL{
-		Scheme: monitor.Scheme,
+		Scheme: request.GetAttributeValue("scheme", monitor.Scheme),
 		Host:   monitor.getHost(request),
 		Path:   request.GetAttributeValue("url", "/"),
 	}
@@ -77,7 +95,8 @@ func (monitor *HTTPMonitor) getURL(request *api.PollerRequestDTO) *url.URL {
 
 func (monitor *HTTPMonitor) getHTTPRequest(request *api.PollerRequestDTO) (*http.Request, error) {
 	u := monitor.getURL(request)
-	httpreq, err := http.NewRequest("GET", u.String(), nil)
+	method := strings.ToUpper(request.GetAttributeValue("method", http.MethodGet))
+	httpreq, err := http.NewRequest(method, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +113,7 @@ func (monitor *HTTPMonitor) getHTTPRequest(request *api.PollerRequestDTO) (*http
 			httpreq.SetBasicAuth(user, passwd)
 		}
 	}
-	userAgent := request.GetAttributeValue("userAgent", "")
+	userAgent := request.GetAttributeValue("user-agent", request.GetAttributeValue("userAgent", ""))
 	if userAgent != "" {
 		httpreq.Header.Set("User-Agent", userAgent)
 	}
@@ -110,14 +129,23 @@ func (monitor *HTTPMonitor) getHTTPRequest(request *api.PollerRequestDTO) (*http
 	return httpreq, nil
 }
 
-func (monitor *HTTPMonitor) getClient(request *api.PollerRequestDTO) *http.Client {
+func (monitor *HTTPMonitor) getClient(request *api.PollerRequestDTO) (*http.Client, error) {
 	useSSLFilter, _ := strconv.ParseBool(request.GetAttributeValue("use-ssl-filter", "false"))
-	return tools.GetHTTPClient(useSSLFilter, request.GetTimeout())
+	sslVerify, _ := strconv.ParseBool(request.GetAttributeValue("ssl-verify", "true"))
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
+	if err != nil {
+		return nil, err
+	}
+	dscp, err := tools.ParseDSCP(request.GetAttributeValue(tools.DSCPAttribute, ""))
+	if err != nil {
+		return nil, err
+	}
+	return tools.GetHTTPClientWithDSCP(useSSLFilter || !sslVerify, request.GetTimeout(), ports, dscp), nil
 }
 
 func (monitor *HTTPMonitor) getHost(request *api.PollerRequestDTO) string {
 	port := request.GetAttributeValue("port", fmt.Sprintf("%d", monitor.DefaultPort))
-	return request.IPAddress + ":" + port
+	return net.JoinHostPort(request.IPAddress, port)
 }
 
 func (monitor *HTTPMonitor) getResponseRange(request *api.PollerRequestDTO) (int, int) {
@@ -125,10 +153,29 @@ func (monitor *HTTPMonitor) getResponseRange(request *api.PollerRequestDTO) (int
 	if url := request.GetAttributeValue("url", "/"); url == "/" {
 		defaultRange = "100-499"
 	}
-	responseRange := request.GetAttributeValue("response", defaultRange)
+	responseRange := request.GetAttributeValue("response", request.GetAttributeValue("response-range", defaultRange))
 	return tools.ParseHTTPResponseRange(responseRange)
 }
 
+// Verifies if the response contains the expected text.
+// When the expected text starts with "~", it is treated as a regular expression.
+func (monitor *HTTPMonitor) matchResponseText(data string, responseText string) (bool, error) {
+	if strings.HasPrefix(responseText, "~") {
+		exp, err := regexp.Compile(responseText[1:])
+		if err != nil {
+			return false, fmt.Errorf("invalid response text expression %s: %v", responseText, err)
+		}
+		if exp.MatchString(data) {
+			return true, nil
+		}
+		return false, fmt.Errorf("Response doesn't match expression %s", responseText[1:])
+	}
+	if strings.Contains(data, responseText) {
+		return true, nil
+	}
+	return false, fmt.Errorf("Response doesn't contain text %s", responseText)
+}
+
 func init() {
 	RegisterMonitor(&HTTPMonitor{Name: "HttpMonitor", Scheme: "http", DefaultPort: 80})
 	RegisterMonitor(&HTTPMonitor{Name: "HttpsMonitor", Scheme: "https", DefaultPort: 443})
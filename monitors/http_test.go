@@ -0,0 +1,88 @@
+package monitors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestHTTPMonitor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "Server Status: OK; User-Agent: %s", r.Header.Get("User-Agent"))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NilError(t, err)
+
+	monitor := &HTTPMonitor{Name: "HttpMonitor", Scheme: "http", DefaultPort: 80}
+	request := &api.PollerRequestDTO{
+		IPAddress: u.Hostname(),
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: u.Port()},
+			{Key: "url", Value: "/status"},
+			{Key: "user-agent", Value: "gominion"},
+			{Key: "response-text", Value: "~Status: \\w+; User-Agent: gominion"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+
+	request.Attributes[3].Value = "Status: FAILED"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+
+	request.Attributes[1].Value = "/missing"
+	request.Attributes[3].Value = ""
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+}
+
+func TestHTTPMonitorRetries(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 && r.URL.Path == "/flaky" {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close() // The first request fails without a response
+			return
+		}
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NilError(t, err)
+
+	monitor := &HTTPMonitor{Name: "HttpMonitor", Scheme: "http", DefaultPort: 80}
+	request := &api.PollerRequestDTO{
+		IPAddress: u.Hostname(),
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: u.Port()},
+			{Key: "url", Value: "/flaky"},
+			{Key: "retry", Value: "2"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	// An unexpected status code is not retried
+	atomic.StoreInt32(&hits, 0)
+	request.Attributes[1].Value = "/missing"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "Response code 404 out of expected range: 100-399", response.Status.Reason)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
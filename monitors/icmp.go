@@ -17,14 +17,38 @@ func (monitor *ICMPMonitor) GetID() string {
 }
 
 // Poll execute the ICMP monitor request and return the the poller response
+// The service is down when no reply was received, or when the packet loss percentage exceeds the allowed-loss parameter.
 func (monitor *ICMPMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
 	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
-	if duration, err := tools.Ping(request.IPAddress, request.GetTimeout()); err == nil {
-		response.Status.Up(duration.Seconds())
-	} else {
+	options := tools.PingOptions{
+		Count:      request.GetRetries() + 1,
+		PacketSize: request.GetAttributeValueAsInt("packet-size", 0),
+		Timeout:    request.GetTimeout(),
+	}
+	allowedLoss := float64(request.GetAttributeValueAsInt("allowed-loss", 100))
+	dscp, err := tools.ParseDSCP(request.GetAttributeValue(tools.DSCPAttribute, ""))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	options.DSCP = dscp
+	stats, err := tools.PingWithOptions(request.IPAddress, options)
+	if err != nil {
 		msg := fmt.Sprintf("Error while executing ICMP against %s: %v", request.IPAddress, err)
 		response.Status.Down(msg)
+		return response
+	}
+	if stats.PacketsRecv == 0 {
+		msg := fmt.Sprintf("No ICMP response received from %s after %d attempts", request.IPAddress, stats.PacketsSent)
+		response.Status.Down(msg)
+		return response
+	}
+	if stats.PacketLoss > allowedLoss {
+		msg := fmt.Sprintf("ICMP packet loss against %s is %.2f%%, exceeding the allowed %.2f%%", request.IPAddress, stats.PacketLoss, allowedLoss)
+		response.Status.Down(msg)
+		return response
 	}
+	response.Status.Up(stats.AvgRtt.Seconds())
 	return response
 }
 
@@ -0,0 +1,111 @@
+package monitors
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// The defaults of the parameters of the LDAP monitor (the port depends on ssl)
+var ldapDefaults = map[string]string{
+	"ssl":      "false",
+	"starttls": "false",
+	"filter":   "(objectClass=*)",
+}
+
+// LDAPMonitor represents a Monitor implementation for directory servers
+type LDAPMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *LDAPMonitor) GetID() string {
+	return "LdapMonitor"
+}
+
+// Poll execute the LDAP monitor request and return the the poller response.
+// The response time is the duration of the bind and the optional search.
+func (monitor *LDAPMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the LDAP monitor request until the context is done, and return the the poller response.
+// The bind is anonymous unless the dn attribute is set. The search runs only when the base-dn attribute is set.
+func (monitor *LDAPMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	params := request.GetParameters(ldapDefaults)
+	ssl := params.GetBool("ssl", false)
+	port := 389
+	if ssl {
+		port = 636
+	}
+	servAddr := net.JoinHostPort(request.IPAddress, strconv.Itoa(params.GetInt("port", port)))
+	starttls := params.GetBool("starttls", false)
+	dn := params.Get("dn")
+	password := params.Get("password")
+	baseDN := params.Get("base-dn")
+	filter := params.Get("filter")
+	ports, err := tools.ParsePortRange(params.Get(tools.SourcePortRangeAttribute))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	timeout := request.GetTimeout()
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		start := time.Now()
+		l, err := monitor.connect(ctx, servAddr, ports, timeout, ssl, starttls)
+		if err != nil {
+			return 0, err
+		}
+		defer l.Close()
+		if dn == "" {
+			err = l.UnauthenticatedBind("")
+		} else {
+			err = l.Bind(dn, password)
+		}
+		if err == nil && baseDN != "" {
+			_, err = l.Search(ldap.NewSearchRequest(baseDN, ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 1, 0, true, filter, []string{"1.1"}, nil))
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+				err = nil
+			}
+		}
+		var ldapErr *ldap.Error
+		if errors.As(err, &ldapErr) && ldapErr.ResultCode < ldap.ErrorNetwork {
+			return 0, tools.StopRetries(err) // The server rejected the request
+		}
+		return time.Since(start), err
+	})
+	return response
+}
+
+func (monitor *LDAPMonitor) connect(ctx context.Context, servAddr string, ports *tools.PortRange, timeout time.Duration, ssl bool, starttls bool) (*ldap.Conn, error) {
+	conn, err := dialTCP(ctx, servAddr, ports, timeout)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(servAddr)
+	config := &tls.Config{ServerName: host, InsecureSkipVerify: true}
+	if ssl {
+		conn = tls.Client(conn, config)
+	}
+	l := ldap.NewConn(conn, ssl)
+	l.SetTimeout(timeout)
+	l.Start()
+	if starttls && !ssl {
+		if err := l.StartTLS(config); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func init() {
+	RegisterMonitor(&LDAPMonitor{})
+}
@@ -0,0 +1,90 @@
+package monitors
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"gotest.tools/v3/assert"
+)
+
+// Starts a minimal LDAP server that accepts anonymous binds and the given password, and answers searches with no entries
+func startLDAPServer(t *testing.T, password string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	reply := func(conn net.Conn, id int64, op int, code int, message string) {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(op), nil, "")
+		result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, ""))
+		packet.AppendChild(result)
+		conn.Write(packet.Bytes())
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				for {
+					packet, err := ber.ReadPacket(conn)
+					if err != nil || len(packet.Children) < 2 {
+						return
+					}
+					id := packet.Children[0].Value.(int64)
+					request := packet.Children[1]
+					switch request.Tag {
+					case ldap.ApplicationBindRequest:
+						if pw := request.Children[2].Data.String(); pw == "" || pw == password {
+							reply(conn, id, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+						} else {
+							reply(conn, id, ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials, "invalid password")
+						}
+					case ldap.ApplicationSearchRequest:
+						reply(conn, id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess, "")
+					default:
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+func TestLDAPMonitor(t *testing.T) {
+	listener := startLDAPServer(t, "secret")
+	defer listener.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	monitor := &LDAPMonitor{}
+	request := func(attributes ...api.PollerAttributeDTO) *api.PollerRequestDTO {
+		return &api.PollerRequestDTO{
+			IPAddress:  "127.0.0.1",
+			Attributes: append([]api.PollerAttributeDTO{{Key: "port", Value: port}, {Key: "timeout", Value: "1000"}}, attributes...),
+		}
+	}
+
+	response := monitor.Poll(request())
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+
+	response = monitor.Poll(request(
+		api.PollerAttributeDTO{Key: "dn", Value: "cn=admin,dc=example,dc=com"},
+		api.PollerAttributeDTO{Key: "password", Value: "secret"},
+		api.PollerAttributeDTO{Key: "base-dn", Value: "dc=example,dc=com"}))
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+	assert.Assert(t, response.Status.ResponseTime > 0)
+
+	response = monitor.Poll(request(
+		api.PollerAttributeDTO{Key: "dn", Value: "cn=admin,dc=example,dc=com"},
+		api.PollerAttributeDTO{Key: "password", Value: "wrong"}))
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "LDAP Result Code 49"))
+}
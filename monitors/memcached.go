@@ -0,0 +1,83 @@
+package monitors
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// MemcachedMonitor represents a Monitor implementation for memcached servers
+type MemcachedMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *MemcachedMonitor) GetID() string {
+	return "MemcachedMonitor"
+}
+
+// Poll execute the memcached monitor request and return the the poller response.
+// The response time is the duration of the command exchange.
+func (monitor *MemcachedMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the memcached monitor request until the context is done, and return the the poller response.
+// The command attribute can be either version (the default) or stats.
+func (monitor *MemcachedMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	servAddr := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "11211"))
+	command := strings.ToLower(request.GetAttributeValue("command", "version"))
+	if command != "version" && command != "stats" {
+		response.Status.Down(fmt.Sprintf("invalid command %s, expected version or stats", command))
+		return response
+	}
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	timeout := request.GetTimeout()
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		return monitor.check(ctx, servAddr, ports, timeout, command)
+	})
+	return response
+}
+
+func (monitor *MemcachedMonitor) check(ctx context.Context, servAddr string, ports *tools.PortRange, timeout time.Duration, command string) (time.Duration, error) {
+	conn, err := dialTCP(ctx, servAddr, ports, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	start := time.Now()
+	if _, err := conn.Write([]byte(command + "\r\n")); err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case command == "version" && strings.HasPrefix(line, "VERSION "):
+			return time.Since(start), nil
+		case command == "stats" && line == "END":
+			return time.Since(start), nil
+		case command == "stats" && strings.HasPrefix(line, "STAT "):
+			continue
+		}
+		return 0, fmt.Errorf("unexpected reply to %s: %s", command, line)
+	}
+}
+
+func init() {
+	RegisterMonitor(&MemcachedMonitor{})
+}
@@ -0,0 +1,58 @@
+package monitors
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestMemcachedMonitor(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				command, _ := bufio.NewReader(conn).ReadString('\n')
+				switch command {
+				case "version\r\n":
+					conn.Write([]byte("VERSION 1.6.9\r\n"))
+				case "stats\r\n":
+					conn.Write([]byte("STAT pid 1\r\nSTAT uptime 100\r\nEND\r\n"))
+				default:
+					conn.Write([]byte("ERROR\r\n"))
+				}
+			}(conn)
+		}
+	}()
+
+	monitor := &MemcachedMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)},
+			{Key: "command", Value: "version"},
+			{Key: "retry", Value: "0"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+
+	request.Attributes[1].Value = "stats"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+
+	request.Attributes[1].Value = "flush_all"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "invalid command flush_all, expected version or stats", response.Status.Reason)
+}
@@ -0,0 +1,112 @@
+package monitors
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// The size of an NTP packet without extensions
+const ntpPacketSize = 48
+
+// The seconds between the NTP epoch (1900) and the Unix epoch (1970)
+const ntpEpochOffset = 2208988800
+
+// NTPMonitor represents a Monitor implementation for time servers
+type NTPMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *NTPMonitor) GetID() string {
+	return "NtpMonitor"
+}
+
+// Poll execute the NTP monitor request and return the the poller response.
+// The response time is the round-trip time of an SNTP request.
+func (monitor *NTPMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the NTP monitor request until the context is done, and return the the poller response.
+// The service is down when the server replies with a kiss-of-death, or with a stratum above the stratum-max attribute (if set).
+func (monitor *NTPMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	servAddr := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "123"))
+	stratumMax := request.GetAttributeValueAsInt("stratum-max", 0)
+	var stratum int
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		var duration time.Duration
+		var err error
+		stratum, duration, err = monitor.query(ctx, servAddr)
+		if err != nil {
+			return 0, err
+		}
+		if stratumMax > 0 && stratum > stratumMax {
+			return 0, tools.StopRetries(fmt.Errorf("stratum %d is above the maximum of %d", stratum, stratumMax))
+		}
+		return duration, nil
+	})
+	if response.Status.StatusCode == api.ServiceAvailableCode {
+		response.Status.SetProperty("stratum", float64(stratum))
+	}
+	return response
+}
+
+// Sends an SNTP request, and returns the stratum of the server and the round-trip time
+func (monitor *NTPMonitor) query(ctx context.Context, servAddr string) (int, time.Duration, error) {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "udp", servAddr)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	start := time.Now()
+	req := make([]byte, ntpPacketSize)
+	req[0] = 0x23 // LI = 0, VN = 4, Mode = 3 (client)
+	transmit := getNtpTimestamp(start)
+	binary.BigEndian.PutUint64(req[40:], transmit)
+	if _, err := conn.Write(req); err != nil {
+		return 0, 0, err
+	}
+	res := make([]byte, ntpPacketSize)
+	for {
+		size, err := conn.Read(res)
+		if err != nil {
+			return 0, 0, err
+		}
+		// Ignores stale or unrelated replies, as the originate timestamp must match the request
+		if size >= ntpPacketSize && binary.BigEndian.Uint64(res[24:]) == transmit {
+			break
+		}
+	}
+	duration := time.Since(start)
+	if mode := res[0] & 0x07; mode != 4 {
+		return 0, 0, fmt.Errorf("unexpected NTP mode %d on reply", mode)
+	}
+	stratum := int(res[1])
+	if stratum == 0 {
+		code := string(bytes.TrimRight(res[12:16], "\x00"))
+		return 0, 0, tools.StopRetries(fmt.Errorf("kiss-of-death received with code %s", code))
+	}
+	return stratum, duration, nil
+}
+
+// Converts a time to the NTP timestamp format (seconds since 1900 and fraction)
+func getNtpTimestamp(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func init() {
+	RegisterMonitor(&NTPMonitor{})
+}
@@ -0,0 +1,63 @@
+package monitors
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestNTPMonitor(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer conn.Close()
+	stratum := int32(2)
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			size, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			if size < ntpPacketSize {
+				continue
+			}
+			reply := make([]byte, ntpPacketSize)
+			reply[0] = 0x24 // LI = 0, VN = 4, Mode = 4 (server)
+			reply[1] = byte(atomic.LoadInt32(&stratum))
+			if reply[1] == 0 {
+				copy(reply[12:], "RATE")
+			}
+			copy(reply[24:32], buffer[40:48])
+			conn.WriteTo(reply, addr)
+		}
+	}()
+
+	monitor := &NTPMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)},
+			{Key: "stratum-max", Value: "3"},
+			{Key: "retry", Value: "0"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+	assert.Equal(t, 2.0, response.Status.GetPropertyValue("stratum"))
+
+	request.Attributes[1].Value = "1"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "above the maximum"))
+
+	atomic.StoreInt32(&stratum, 0)
+	request.Attributes[2].Value = "2"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "kiss-of-death received with code RATE"))
+}
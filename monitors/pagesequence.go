@@ -0,0 +1,236 @@
+package monitors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// Matches the variables to replace on the page definitions, like ${ipaddr}
+var pageVariableRegexp = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// pageDefinition represents a step of a page sequence
+type pageDefinition struct {
+	Name             string            `json:"name"`
+	Method           string            `json:"method"`
+	Scheme           string            `json:"scheme"`
+	Host             string            `json:"host"`
+	Port             int               `json:"port"`
+	Path             string            `json:"path"`
+	Query            string            `json:"query"`
+	Headers          map[string]string `json:"headers"`
+	Parameters       map[string]string `json:"parameters"`
+	ResponseRange    string            `json:"response-range"`
+	SuccessMatch     string            `json:"success-match"`
+	FailureMatch     string            `json:"failure-match"`
+	SessionVariables []struct {
+		Name       string `json:"name"`
+		MatchGroup int    `json:"match-group"`
+	} `json:"session-variables"`
+}
+
+// Gets the name of the step for the error messages
+func (page *pageDefinition) getName(index int) string {
+	if page.Name != "" {
+		return page.Name
+	}
+	return fmt.Sprintf("page %d", index+1)
+}
+
+// PageSequenceMonitor represents a Monitor implementation for multi-step web transactions
+type PageSequenceMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *PageSequenceMonitor) GetID() string {
+	return "PageSequenceMonitor"
+}
+
+// Poll execute the page sequence monitor request and return the the poller response.
+// The response time is the total time of the sequence.
+func (monitor *PageSequenceMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the page sequence monitor request until the context is done, and return the the poller response.
+// The pages are defined as a JSON array on the page-sequence attribute, and share the cookies. The timeout applies to each page.
+func (monitor *PageSequenceMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	pages, err := monitor.getPages(request)
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	timeout := request.GetTimeout()
+	skipSSL := request.GetAttributeValue("ssl-verify", "true") == "false"
+	duration, err := tools.WithRetries(ctx, request.GetRetries(), timeout*time.Duration(len(pages)), request.GetRetryInterval(), func(ctx context.Context) (time.Duration, error) {
+		client := tools.GetPersistentHTTPClient(skipSSL, timeout, ports) // The connections are reused across the pages of the sequence
+		defer client.CloseIdleConnections()
+		client.Jar, _ = cookiejar.New(nil) // Never fails without options
+		variables := map[string]string{
+			"ipaddr":    request.IPAddress,
+			"nodeid":    request.NodeID,
+			"nodelabel": request.NodeLabel,
+		}
+		start := time.Now()
+		for i, page := range pages {
+			if err := monitor.execute(ctx, client, request, page, variables); err != nil {
+				return 0, fmt.Errorf("%s failed: %v", page.getName(i), err)
+			}
+		}
+		return time.Since(start), nil
+	})
+	if err != nil {
+		response.Status.Down(err.Error())
+	} else {
+		response.Status.Up(duration.Seconds())
+	}
+	return response
+}
+
+// Parses the page definitions from the page-sequence attribute (either as value or content)
+func (monitor *PageSequenceMonitor) getPages(request *api.PollerRequestDTO) ([]pageDefinition, error) {
+	data := request.GetAttributeValue("page-sequence", "")
+	if data == "" {
+		data = request.GetAttributeContent("page-sequence")
+	}
+	if strings.TrimSpace(data) == "" {
+		return nil, fmt.Errorf("page-sequence attribute is required")
+	}
+	pages := make([]pageDefinition, 0)
+	if err := json.Unmarshal([]byte(data), &pages); err != nil {
+		return nil, fmt.Errorf("invalid page-sequence: %v", err)
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("page-sequence has no pages")
+	}
+	for i, page := range pages {
+		for _, expression := range []string{page.SuccessMatch, page.FailureMatch} {
+			if _, err := regexp.Compile(expression); err != nil {
+				return nil, fmt.Errorf("invalid expression on %s: %v", page.getName(i), err)
+			}
+		}
+	}
+	return pages, nil
+}
+
+// Executes a page, and captures the session variables on success
+func (monitor *PageSequenceMonitor) execute(ctx context.Context, client *http.Client, request *api.PollerRequestDTO, page pageDefinition, variables map[string]string) error {
+	httpreq, err := monitor.getHTTPRequest(ctx, request, page, variables)
+	if err != nil {
+		return err
+	}
+	httpres, err := client.Do(httpreq)
+	if err != nil {
+		return err
+	}
+	defer httpres.Body.Close()
+	data, err := ioutil.ReadAll(httpres.Body)
+	if err != nil {
+		return err
+	}
+	min, max := tools.ParseHTTPResponseRange(page.ResponseRange)
+	if httpres.StatusCode < min || httpres.StatusCode > max {
+		return fmt.Errorf("response code %d out of expected range: %d-%d", httpres.StatusCode, min, max)
+	}
+	text := string(data)
+	if page.FailureMatch != "" && regexp.MustCompile(page.FailureMatch).MatchString(text) {
+		return fmt.Errorf("response matches failure expression %s", page.FailureMatch)
+	}
+	if page.SuccessMatch == "" {
+		return nil
+	}
+	groups := regexp.MustCompile(page.SuccessMatch).FindStringSubmatch(text)
+	if groups == nil {
+		return fmt.Errorf("response doesn't match expression %s", page.SuccessMatch)
+	}
+	for _, v := range page.SessionVariables {
+		if v.MatchGroup < 0 || v.MatchGroup >= len(groups) {
+			return fmt.Errorf("match group %d for variable %s doesn't exist", v.MatchGroup, v.Name)
+		}
+		variables[v.Name] = groups[v.MatchGroup]
+	}
+	return nil
+}
+
+// Builds the HTTP request of a page, replacing the variables captured by the previous pages
+func (monitor *PageSequenceMonitor) getHTTPRequest(ctx context.Context, request *api.PollerRequestDTO, page pageDefinition, variables map[string]string) (*http.Request, error) {
+	expand := func(value string) string {
+		return pageVariableRegexp.ReplaceAllStringFunc(value, func(match string) string {
+			if v, ok := variables[match[2:len(match)-1]]; ok {
+				return v
+			}
+			return match
+		})
+	}
+	scheme := expand(page.Scheme)
+	if scheme == "" {
+		scheme = "http"
+	}
+	host := strings.Trim(expand(page.Host), "[]")
+	if host == "" {
+		host = request.IPAddress
+	}
+	if page.Port > 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(page.Port))
+	} else {
+		host = tools.GetURLHost(host)
+	}
+	path := expand(page.Path)
+	if path == "" {
+		path = "/"
+	}
+	u := &url.URL{Scheme: scheme, Host: host, Path: path, RawQuery: expand(page.Query)}
+	method := strings.ToUpper(page.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	form := url.Values{}
+	for key, value := range page.Parameters {
+		form.Set(key, expand(value))
+	}
+	var body string
+	if method == http.MethodPost {
+		body = form.Encode()
+	} else if len(form) > 0 {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += form.Encode()
+	}
+	httpreq, err := http.NewRequestWithContext(ctx, method, u.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodPost {
+		httpreq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for key, value := range page.Headers {
+		httpreq.Header.Set(key, expand(value))
+	}
+	if hostName := httpreq.Header.Get("Host"); hostName != "" {
+		httpreq.Host = hostName
+	}
+	return httpreq, nil
+}
+
+func init() {
+	RegisterMonitor(&PageSequenceMonitor{})
+}
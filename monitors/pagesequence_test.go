@@ -0,0 +1,67 @@
+package monitors
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestPageSequenceMonitor(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, `<input name="token" value="abc123">`)
+			return
+		}
+		if r.FormValue("user") != "admin" || r.FormValue("token") != "abc123" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+		fmt.Fprint(w, "Welcome")
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "Dashboard")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	sequence := `[
+		{"name": "login form", "port": %[1]s, "path": "/login", "success-match": "name=\"token\" value=\"([^\"]+)\"",
+		 "session-variables": [{"name": "token", "match-group": 1}]},
+		{"name": "login", "port": %[1]s, "path": "/login", "method": "POST", "parameters": {"user": "%[2]s", "token": "${token}"},
+		 "response-range": "200-299", "success-match": "Welcome"},
+		{"name": "home", "port": %[1]s, "path": "/home", "response-range": "200-299", "failure-match": "Error"}
+	]`
+	monitor := &PageSequenceMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "page-sequence", Value: fmt.Sprintf(sequence, port, "admin")},
+			{Key: "retry", Value: "0"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+
+	request.Attributes[0].Value = fmt.Sprintf(sequence, port, "guest")
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "login failed: response code 403"))
+
+	request.Attributes[0].Value = "{}"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "invalid page-sequence"))
+}
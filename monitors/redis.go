@@ -0,0 +1,103 @@
+package monitors
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// RedisMonitor represents a Monitor implementation for Redis servers
+type RedisMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *RedisMonitor) GetID() string {
+	return "RedisMonitor"
+}
+
+// Poll execute the Redis monitor request and return the the poller response.
+// The response time is the duration of the PING exchange.
+func (monitor *RedisMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the Redis monitor request until the context is done, and return the the poller response.
+// When the password attribute is set, the monitor authenticates before sending the PING.
+func (monitor *RedisMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	servAddr := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "6379"))
+	password := request.GetAttributeValue("password", "")
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	timeout := request.GetTimeout()
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		return monitor.check(ctx, servAddr, ports, timeout, password)
+	})
+	return response
+}
+
+func (monitor *RedisMonitor) check(ctx context.Context, servAddr string, ports *tools.PortRange, timeout time.Duration, password string) (time.Duration, error) {
+	conn, err := dialTCP(ctx, servAddr, ports, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if password != "" {
+		if err := monitor.command(conn, reader, "+OK", "AUTH", password); err != nil {
+			if replyErr, ok := err.(*redisReplyError); ok && (strings.HasPrefix(replyErr.reply, "-ERR") || strings.HasPrefix(replyErr.reply, "-WRONGPASS")) {
+				return 0, tools.StopRetries(err) // The credentials were rejected, so retrying won't help
+			}
+			return 0, err
+		}
+	}
+	start := time.Now()
+	if err := monitor.command(conn, reader, "+PONG", "PING"); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// Sends a command using the RESP protocol, and verifies the reply
+func (monitor *RedisMonitor) command(conn net.Conn, reader *bufio.Reader, expected string, args ...string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(sb.String())); err != nil {
+		return err
+	}
+	reply, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	reply = strings.TrimRight(reply, "\r\n")
+	if reply != expected {
+		return &redisReplyError{command: args[0], reply: reply}
+	}
+	return nil
+}
+
+// redisReplyError is returned when the reply to a command is not the expected one, like an error reply
+type redisReplyError struct {
+	command string
+	reply   string
+}
+
+func (e *redisReplyError) Error() string {
+	return fmt.Sprintf("unexpected reply to %s: %s", e.command, e.reply)
+}
+
+func init() {
+	RegisterMonitor(&RedisMonitor{})
+}
@@ -0,0 +1,119 @@
+package monitors
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+// Replies to AUTH and PING, expecting the commands as RESP arrays
+func serveRedis(conn net.Conn, password string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := password == ""
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		count, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+		args := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			reader.ReadString('\n') // Bulk string length
+			arg, _ := reader.ReadString('\n')
+			args = append(args, strings.TrimSpace(arg))
+		}
+		switch {
+		case args[0] == "AUTH" && len(args) == 2 && args[1] == password:
+			authenticated = true
+			conn.Write([]byte("+OK\r\n"))
+		case args[0] == "AUTH":
+			conn.Write([]byte("-WRONGPASS invalid username-password pair\r\n"))
+		case !authenticated:
+			conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+		default:
+			conn.Write([]byte("+PONG\r\n"))
+		}
+	}
+}
+
+func TestRedisMonitor(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveRedis(conn, "secret")
+		}
+	}()
+
+	monitor := &RedisMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)},
+			{Key: "password", Value: "secret"},
+			{Key: "retry", Value: "0"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+
+	request.Attributes[1].Value = "wrong"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "unexpected reply to AUTH: -WRONGPASS invalid username-password pair", response.Status.Reason)
+
+	request.Attributes[1].Value = ""
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "unexpected reply to PING: -NOAUTH Authentication required.", response.Status.Reason)
+}
+
+func TestRedisMonitorRetries(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+	var accepted int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if atomic.AddInt32(&accepted, 1) == 1 {
+				conn.Close() // The first AUTH fails with a network error
+				continue
+			}
+			go serveRedis(conn, "secret")
+		}
+	}()
+
+	monitor := &RedisMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)},
+			{Key: "password", Value: "secret"},
+			{Key: "retry", Value: "2"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&accepted))
+
+	request.Attributes[1].Value = "wrong"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&accepted)) // A rejected AUTH is not retried
+}
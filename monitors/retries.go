@@ -0,0 +1,25 @@
+package monitors
+
+import (
+	"context"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// WithRetries executes a poll attempt honoring the retry, timeout and retry-interval attributes of the request, and returns the resulting status.
+// The service is up with the response time of the successful attempt, or down with the error of the last one.
+// The times of the failed attempts are not added, as OpenNMS reports the response time of the service, not how long it took to reach it
+// (the sum would mostly measure the timeouts and the retry-interval).
+// Attempts can return errors wrapped by tools.StopRetries to stop retrying.
+func WithRetries(ctx context.Context, request *api.PollerRequestDTO, attempt func(ctx context.Context) (time.Duration, error)) *api.PollStatus {
+	status := &api.PollStatus{}
+	duration, err := tools.WithRetries(ctx, request.GetRetries(), request.GetTimeout(), request.GetRetryInterval(), attempt)
+	if err != nil {
+		status.Down(err.Error())
+	} else {
+		status.Up(duration.Seconds())
+	}
+	return status
+}
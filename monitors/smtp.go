@@ -0,0 +1,91 @@
+package monitors
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// SMTPMonitor represents a Monitor implementation for mail servers
+type SMTPMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *SMTPMonitor) GetID() string {
+	return "SmtpMonitor"
+}
+
+// Poll execute the SMTP monitor request and return the the poller response.
+// The response time is the duration of the whole SMTP exchange.
+func (monitor *SMTPMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the SMTP monitor request until the context is done, and return the the poller response.
+func (monitor *SMTPMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	servAddr := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "25"))
+	hostname := request.GetAttributeValue("hostname", "")
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	starttls := request.GetAttributeValue("starttls", "false") == "true"
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	timeout := request.GetTimeout()
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		duration, err := monitor.check(ctx, servAddr, ports, timeout, hostname, starttls)
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) {
+			return 0, fmt.Errorf("SMTP reply code %d: %s", protoErr.Code, protoErr.Msg)
+		}
+		return duration, err
+	})
+	return response
+}
+
+func (monitor *SMTPMonitor) check(ctx context.Context, servAddr string, ports *tools.PortRange, timeout time.Duration, hostname string, starttls bool) (time.Duration, error) {
+	start := time.Now()
+	conn, err := dialTCP(ctx, servAddr, ports, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	client, err := smtp.NewClient(conn, hostname) // Expects the 220 greeting
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	if err := client.Hello(hostname); err != nil { // EHLO, falling back to HELO
+		return 0, err
+	}
+	if starttls {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return 0, fmt.Errorf("the server does not support STARTTLS")
+		}
+		host, _, _ := net.SplitHostPort(servAddr)
+		if err := client.StartTLS(&tls.Config{ServerName: host, InsecureSkipVerify: true}); err != nil {
+			return 0, err
+		}
+	}
+	if err := client.Quit(); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+func init() {
+	RegisterMonitor(&SMTPMonitor{})
+}
@@ -0,0 +1,71 @@
+package monitors
+
+import (
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func startSMTPServer(t *testing.T, greeting string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			tp := textproto.NewConn(conn)
+			tp.PrintfLine(greeting)
+			for {
+				line, err := tp.ReadLine()
+				if err != nil {
+					break
+				}
+				if strings.HasPrefix(line, "EHLO") || strings.HasPrefix(line, "HELO") {
+					tp.PrintfLine("250 test.local")
+				} else if line == "QUIT" {
+					tp.PrintfLine("221 Bye")
+					break
+				} else {
+					tp.PrintfLine("502 Command not implemented")
+				}
+			}
+			tp.Close()
+		}
+	}()
+	return listener
+}
+
+func TestSMTPMonitor(t *testing.T) {
+	monitor := &SMTPMonitor{}
+
+	listener := startSMTPServer(t, "220 test.local ESMTP")
+	defer listener.Close()
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)},
+			{Key: "hostname", Value: "minion.local"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+
+	request.Attributes = append(request.Attributes, api.PollerAttributeDTO{Key: "starttls", Value: "true"})
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "STARTTLS"))
+
+	rejecting := startSMTPServer(t, "554 No SMTP service here")
+	defer rejecting.Close()
+	request.Attributes[0].Value = strconv.Itoa(rejecting.Addr().(*net.TCPAddr).Port)
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "554"))
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/snmp"
 	"github.com/gosnmp/gosnmp"
 )
 
@@ -35,9 +36,8 @@ func (monitor *SNMPMonitor) Poll(request *api.PollerRequestDTO) *api.PollerRespo
 		matchstr := strings.ToLower(request.GetAttributeValue("match-all", "false"))
 		minimum := request.GetAttributeValueAsInt("minimum", 0)
 		maximum := request.GetAttributeValueAsInt("maximum", 0)
-		client := agent.GetSNMPClient()
-		if err := client.Connect(); err == nil {
-			defer client.Disconnect()
+		if client, err := snmp.Acquire(agent); err == nil {
+			defer snmp.Release(client)
 			response = monitor.poll(client, oid, matchstr, walkstr, operator, operand, minimum, maximum)
 		} else {
 			response.Status.Down(err.Error())
@@ -131,6 +131,48 @@ func (monitor *SNMPMonitor) walk(client api.SNMPHandler, oid string) ([]string,
 	return returnedValues, err
 }
 
+// Applies the timeout and retries of the poller request to the SNMP agent, when the request has them
+func overrideAgentSettings(request *api.PollerRequestDTO, agent *api.SNMPAgentDTO) {
+	if request.GetAttributeValue("timeout", "") != "" {
+		agent.Timeout = int(request.GetTimeout() / time.Millisecond)
+	}
+	if request.GetAttributeValue("retry", request.GetAttributeValue("retries", "")) != "" {
+		agent.Retries = request.GetRetries()
+	}
+}
+
+// Gets the value of an integer OID; returns an error when the agent doesn't have it
+func getSNMPInteger(client api.SNMPHandler, oid string) (int64, error) {
+	result, err := client.Get(oid)
+	if err != nil {
+		return 0, err
+	}
+	if result == nil || len(result.Variables) != 1 {
+		return 0, fmt.Errorf("no response for %s", oid)
+	}
+	pdu := result.Variables[0]
+	if pdu.Type == gosnmp.NoSuchInstance || pdu.Type == gosnmp.NoSuchObject || pdu.Value == nil {
+		return 0, fmt.Errorf("no such instance %s", oid)
+	}
+	return gosnmp.ToBigInt(pdu.Value).Int64(), nil
+}
+
+// Describes an integer value with the name of its enumeration, like up(1)
+func describeSNMPInteger(names map[int64]string, value int64) string {
+	if name, ok := names[value]; ok {
+		return fmt.Sprintf("%s(%d)", name, value)
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+// Gets the text of an SNMP value, like an OctetString
+func getSNMPString(value interface{}) string {
+	if bytes, ok := value.([]byte); ok {
+		return string(bytes)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
 func (monitor *SNMPMonitor) meetsCriteria(result string, operator string, operand string) bool {
 	if result == "" {
 		return false
@@ -0,0 +1,119 @@
+package monitors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHMonitor represents a Monitor implementation for SSH servers
+type SSHMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *SSHMonitor) GetID() string {
+	return "SshMonitor"
+}
+
+// Poll execute the SSH monitor request and return the the poller response.
+// The response time covers the connection and the banner, plus the authentication when required.
+func (monitor *SSHMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the SSH monitor request until the context is done, and return the the poller response.
+// When require-auth is true, the monitor completes the handshake and authenticates with the username and password attributes.
+func (monitor *SSHMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	servAddr := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "22"))
+	banner := request.GetAttributeValue("banner", "")
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	timeout := request.GetTimeout()
+	var config *ssh.ClientConfig
+	if request.GetAttributeValue("require-auth", "false") == "true" {
+		if config, err = monitor.getClientConfig(request, timeout); err != nil {
+			response.Status.Down(err.Error())
+			return response
+		}
+	}
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		start := time.Now()
+		conn, err := dialTCP(ctx, servAddr, ports, timeout)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		var serverVersion string
+		if config == nil {
+			if serverVersion, err = tools.ReadSSHBanner(conn, timeout); err != nil {
+				return 0, err
+			}
+		} else {
+			sshConn, chans, reqs, err := ssh.NewClientConn(conn, servAddr, config)
+			if err != nil {
+				if strings.Contains(err.Error(), "unable to authenticate") || strings.Contains(err.Error(), "host key rejected") {
+					return 0, tools.StopRetries(err)
+				}
+				return 0, err
+			}
+			defer ssh.NewClient(sshConn, chans, reqs).Close()
+			serverVersion = string(sshConn.ServerVersion())
+		}
+		if _, err := tools.MatchBanner(serverVersion, banner); err != nil {
+			return 0, tools.StopRetries(err)
+		}
+		return time.Since(start), nil
+	})
+	return response
+}
+
+// Builds the client configuration, with the host key policy defined by the host-key-fingerprint or known-hosts attributes (any key is accepted by default)
+func (monitor *SSHMonitor) getClientConfig(request *api.PollerRequestDTO, timeout time.Duration) (*ssh.ClientConfig, error) {
+	username := request.GetAttributeValue("username", "")
+	if username == "" {
+		return nil, fmt.Errorf("username required for SSH authentication")
+	}
+	config := &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{ssh.Password(request.GetAttributeValue("password", ""))},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         timeout,
+	}
+	if fingerprint := request.GetAttributeValue("host-key-fingerprint", ""); fingerprint != "" {
+		config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if received := ssh.FingerprintSHA256(key); received != fingerprint {
+				return fmt.Errorf("fingerprint %s doesn't match %s", received, fingerprint)
+			}
+			return nil
+		}
+	} else if path := request.GetAttributeValue("known-hosts", ""); path != "" {
+		callback, err := knownhosts.New(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load known hosts: %v", err)
+		}
+		config.HostKeyCallback = callback
+	}
+	verify := config.HostKeyCallback
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := verify(hostname, remote, key); err != nil {
+			return fmt.Errorf("host key rejected: %v", err)
+		}
+		return nil
+	}
+	return config, nil
+}
+
+func init() {
+	RegisterMonitor(&SSHMonitor{})
+}
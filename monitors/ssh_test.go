@@ -0,0 +1,93 @@
+package monitors
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+)
+
+// Starts an SSH server that accepts the given credentials, and closes the connections after the handshake
+func startSSHServer(t *testing.T, username string, password string) (net.Listener, ssh.PublicKey) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	signer, err := ssh.NewSignerFromKey(private)
+	assert.NilError(t, err)
+	config := &ssh.ServerConfig{
+		ServerVersion: "SSH-2.0-OpenSSH_8.4",
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == username && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", c.User())
+		},
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if sshConn, chans, reqs, err := ssh.NewServerConn(conn, config); err == nil {
+					go ssh.DiscardRequests(reqs)
+					go func() {
+						for ch := range chans {
+							ch.Reject(ssh.Prohibited, "no sessions")
+						}
+					}()
+					sshConn.Wait()
+				}
+			}(conn)
+		}
+	}()
+	return listener, signer.PublicKey()
+}
+
+func TestSSHMonitor(t *testing.T) {
+	listener, hostKey := startSSHServer(t, "opennms", "secret")
+	defer listener.Close()
+
+	monitor := &SSHMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: "127.0.0.1",
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)},
+			{Key: "banner", Value: "OpenSSH"},
+			{Key: "retry", Value: "0"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+
+	request.Attributes = append(request.Attributes,
+		api.PollerAttributeDTO{Key: "require-auth", Value: "true"},
+		api.PollerAttributeDTO{Key: "username", Value: "opennms"},
+		api.PollerAttributeDTO{Key: "password", Value: "secret"},
+		api.PollerAttributeDTO{Key: "host-key-fingerprint", Value: ssh.FingerprintSHA256(hostKey)},
+	)
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode, response.Status.Reason)
+
+	request.Attributes[5].Value = "wrong"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "unable to authenticate"))
+
+	request.Attributes[5].Value = "secret"
+	request.Attributes[6].Value = "SHA256:invalid"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "host key rejected"))
+}
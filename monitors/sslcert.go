@@ -0,0 +1,104 @@
+package monitors
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+)
+
+// The defaults of the parameters of the SSL certificate monitor
+var sslCertDefaults = map[string]string{
+	"port":       "443",
+	"days":       "7",
+	"cert-index": "0",
+}
+
+// SSLCertMonitor represents a Monitor implementation to verify the expiration of TLS certificates
+type SSLCertMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *SSLCertMonitor) GetID() string {
+	return "SSLCertMonitor"
+}
+
+// Poll execute the SSL certificate monitor request and return the the poller response.
+// The certificate is selected from the presented chain by the cert-index parameter (0 is the leaf).
+// The service is down when it expires within the amount of days specified by the days parameter.
+func (monitor *SSLCertMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the SSL certificate monitor request until the context is done, and return the the poller response.
+func (monitor *SSLCertMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	params := request.GetParameters(sslCertDefaults)
+	servAddr := net.JoinHostPort(request.IPAddress, strconv.Itoa(params.GetInt("port", 443)))
+	days := params.GetInt("days", 7)
+	index := params.GetInt("cert-index", 0)
+	config := &tls.Config{
+		ServerName:         params.Get("server-name"),
+		InsecureSkipVerify: true, // Only the expiration date matters
+	}
+	ports, err := tools.ParsePortRange(params.Get(tools.SourcePortRangeAttribute))
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	var state *tls.ConnectionState
+	timeout := request.GetTimeout()
+	status := WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		var duration time.Duration
+		var err error
+		state, duration, err = monitor.handshake(ctx, servAddr, ports, timeout, config)
+		return duration, err
+	})
+	if status.StatusCode != api.ServiceAvailableCode {
+		response.Status = status
+		return response
+	}
+	if index < 0 || index >= len(state.PeerCertificates) {
+		response.Status.Down(fmt.Sprintf("certificate index %d is out of range, the server presented %d certificates", index, len(state.PeerCertificates)))
+		return response
+	}
+	cert := state.PeerCertificates[index]
+	remaining := int(math.Floor(time.Until(cert.NotAfter).Hours() / 24))
+	if remaining < days {
+		response.Status.Down(fmt.Sprintf("certificate %q expires in %d days (at %s), below the threshold of %d days", cert.Subject.CommonName, remaining, cert.NotAfter.Format(time.RFC3339), days))
+		return response
+	}
+	response.Status = status
+	response.Status.SetProperty("days", float64(remaining))
+	return response
+}
+
+func (monitor *SSLCertMonitor) handshake(ctx context.Context, servAddr string, ports *tools.PortRange, timeout time.Duration, config *tls.Config) (*tls.ConnectionState, time.Duration, error) {
+	start := time.Now()
+	conn, err := tools.DialContext(ctx, "tcp", servAddr, timeout, ports)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	deadline := start.Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, 0, err
+	}
+	state := tlsConn.ConnectionState()
+	return &state, time.Since(start), nil
+}
+
+func init() {
+	RegisterMonitor(&SSLCertMonitor{})
+}
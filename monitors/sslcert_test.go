@@ -0,0 +1,46 @@
+package monitors
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestSSLCertMonitor(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NilError(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	assert.NilError(t, err)
+
+	monitor := &SSLCertMonitor{}
+	request := &api.PollerRequestDTO{
+		IPAddress: host,
+		Attributes: []api.PollerAttributeDTO{
+			{Key: "port", Value: port},
+			{Key: "days", Value: "30"},
+			{Key: "server-name", Value: "example.com"},
+		},
+	}
+	response := monitor.Poll(request)
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+	assert.Assert(t, response.Status.GetPropertyValue("days") > 30)
+
+	// The certificate used by httptest expires in 2084
+	request.Attributes[1].Value = "100000"
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "expires in"))
+
+	request.Attributes = append(request.Attributes, api.PollerAttributeDTO{Key: "cert-index", Value: "5"})
+	response = monitor.Poll(request)
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, strings.Contains(response.Status.Reason, "out of range"))
+}
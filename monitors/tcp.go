@@ -1,7 +1,7 @@
 package monitors
 
 import (
-	"fmt"
+	"context"
 	"net"
 	"time"
 
@@ -18,34 +18,78 @@ func (monitor *TCPMonitor) GetID() string {
 	return "TcpMonitor"
 }
 
-// Poll execute the TCP monitor request and return the the poller response
+// Poll execute the TCP monitor request and return the the poller response.
+// The response time is the time it takes to establish the connection.
 func (monitor *TCPMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	return monitor.PollWithContext(context.Background(), request)
+}
+
+// PollWithContext execute the TCP monitor request until the context is done, and return the the poller response.
+func (monitor *TCPMonitor) PollWithContext(ctx context.Context, request *api.PollerRequestDTO) *api.PollerResponseDTO {
 	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
-	start := time.Now()
-	servAddr := fmt.Sprintf("%s:%s", request.IPAddress, request.GetAttributeValue("port", "23"))
+	servAddr := net.JoinHostPort(request.IPAddress, request.GetAttributeValue("port", "23"))
 	tcpAddr, err := net.ResolveTCPAddr("tcp", servAddr)
 	if err != nil {
 		response.Status.Down(err.Error())
 		return response
 	}
-	timeout := request.GetTimeout()
-	dialer := net.Dialer{Timeout: timeout}
-	conn, err := dialer.Dial("tcp", tcpAddr.String())
+	ports, err := tools.ParsePortRange(request.GetAttributeValue(tools.SourcePortRangeAttribute, ""))
 	if err != nil {
 		response.Status.Down(err.Error())
 		return response
 	}
-	defer conn.Close()
-
-	banner := request.GetAttributeValue("banner", "")
-	if ok, err := tools.NetMessageContains(conn, timeout, banner); ok {
-		response.Status.Up(time.Since(start).Seconds())
-	} else {
+	dscp, err := tools.ParseDSCP(request.GetAttributeValue(tools.DSCPAttribute, ""))
+	if err != nil {
 		response.Status.Down(err.Error())
+		return response
 	}
+	timeout := request.GetTimeout()
+	banner := request.GetAttributeValue("banner", "")
+	bannerSize := request.GetAttributeValueAsInt("banner-size", tools.DefaultBannerSize)
+	response.Status = WithRetries(ctx, request, func(ctx context.Context) (time.Duration, error) {
+		return monitor.check(ctx, tcpAddr, ports, dscp, timeout, banner, bannerSize)
+	})
 	return response
 }
 
+func (monitor *TCPMonitor) check(ctx context.Context, tcpAddr *net.TCPAddr, ports *tools.PortRange, dscp int, timeout time.Duration, banner string, bannerSize int) (time.Duration, error) {
+	start := time.Now()
+	conn, err := tools.DialContextWithDSCP(ctx, "tcp", tcpAddr.String(), timeout, ports, dscp)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	duration := time.Since(start)
+	// Interrupts the banner verification when the context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	if _, err := tools.NetMessageContainsN(conn, timeout, banner, bannerSize); err != nil {
+		return 0, err
+	}
+	return duration, nil
+}
+
+// Connects to a TCP service, setting the deadline of the exchange from the timeout or the context (whichever comes first)
+func dialTCP(ctx context.Context, servAddr string, ports *tools.PortRange, timeout time.Duration) (net.Conn, error) {
+	conn, err := tools.DialContext(ctx, "tcp", servAddr, timeout, ports)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	return conn, nil
+}
+
 func init() {
 	RegisterMonitor(&TCPMonitor{})
 }
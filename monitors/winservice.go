@@ -0,0 +1,113 @@
+package monitors
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/snmp"
+	"github.com/gosnmp/gosnmp"
+)
+
+// The columns of the svSvcTable from the LanMgr-Mib-II-MIB, which lists the started Windows services
+const (
+	svSvcNameOID           = ".1.3.6.1.4.1.77.1.2.3.1.1"
+	svSvcOperatingStateOID = ".1.3.6.1.4.1.77.1.2.3.1.3"
+)
+
+// The columns of the hrSWRunTable from the HOST-RESOURCES-MIB, for agents without the svSvcTable
+const (
+	hrSWRunNameOID   = ".1.3.6.1.2.1.25.4.2.1.2"
+	hrSWRunStatusOID = ".1.3.6.1.2.1.25.4.2.1.7"
+)
+
+const (
+	svSvcActive   = 1
+	hrSWRunActive = 1
+)
+
+var svSvcOperatingStates = map[int64]string{1: "active", 2: "continue-pending", 3: "pause-pending", 4: "paused"}
+
+var hrSWRunStatuses = map[int64]string{1: "running", 2: "runnable", 3: "notRunnable", 4: "invalid"}
+
+// WinServiceMonitor represents a Monitor implementation for the state of a Windows service via SNMP
+type WinServiceMonitor struct {
+}
+
+// GetID gets the monitor ID (simple class name from its Java counterpart)
+func (monitor *WinServiceMonitor) GetID() string {
+	return "Win32ServiceMonitor"
+}
+
+// Poll execute the Windows service monitor request and return the poller response
+// The service is up when the Windows service from the service-name attribute is active.
+func (monitor *WinServiceMonitor) Poll(request *api.PollerRequestDTO) *api.PollerResponseDTO {
+	agent := &api.SNMPAgentDTO{}
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	if err := xml.Unmarshal([]byte(request.GetAttributeContent("agent")), agent); err != nil {
+		response.Status.Unknown(err.Error())
+		return response
+	}
+	name := request.GetAttributeValue("service-name", "Server")
+	overrideAgentSettings(request, agent)
+	client, err := snmp.Acquire(agent)
+	if err != nil {
+		response.Status.Down(err.Error())
+		return response
+	}
+	defer snmp.Release(client)
+	return monitor.poll(client, name)
+}
+
+// Finds the service on the svSvcTable, falling back to the running software of the HOST-RESOURCES-MIB when it is not there (e.g. the agent doesn't implement the table)
+func (monitor *WinServiceMonitor) poll(client api.SNMPHandler, name string) *api.PollerResponseDTO {
+	start := time.Now()
+	response := &api.PollerResponseDTO{Status: &api.PollStatus{}}
+	index, found, err := monitor.find(client, svSvcNameOID, name)
+	if err != nil {
+		response.Status.Down(fmt.Sprintf("cannot walk the service table: %v", err))
+		return response
+	}
+	stateOID, states, active := svSvcOperatingStateOID, svSvcOperatingStates, int64(svSvcActive)
+	if !found {
+		index, found, err = monitor.find(client, hrSWRunNameOID, name)
+		if err != nil {
+			response.Status.Down(fmt.Sprintf("cannot walk the running software table: %v", err))
+			return response
+		}
+		stateOID, states, active = hrSWRunStatusOID, hrSWRunStatuses, hrSWRunActive
+	}
+	if !found {
+		response.Status.Down(fmt.Sprintf("service %s is not running", name))
+		return response
+	}
+	state, err := getSNMPInteger(client, stateOID+"."+index)
+	if err != nil {
+		response.Status.Down(fmt.Sprintf("cannot get the state of service %s: %v", name, err))
+		return response
+	}
+	if state != active {
+		response.Status.Down(fmt.Sprintf("service %s is %s", name, describeSNMPInteger(states, state)))
+		return response
+	}
+	response.Status.Up(time.Since(start).Seconds())
+	return response
+}
+
+// Walks a column of names, and returns the index of the entry that matches the given name (ignoring case, as Windows does)
+func (monitor *WinServiceMonitor) find(client api.SNMPHandler, oid string, name string) (string, bool, error) {
+	var index string
+	err := client.BulkWalk(oid, func(pdu gosnmp.SnmpPDU) error {
+		if index == "" && strings.EqualFold(getSNMPString(pdu.Value), name) {
+			index = strings.TrimPrefix(pdu.Name, oid+".")
+		}
+		return nil
+	})
+	return index, index != "", err
+}
+
+func init() {
+	RegisterMonitor(&WinServiceMonitor{})
+}
@@ -0,0 +1,56 @@
+package monitors
+
+import (
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+	"github.com/gosnmp/gosnmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestWinServiceMonitor(t *testing.T) {
+	integer := func(value int) *gosnmp.SnmpPacket {
+		return &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{{Type: gosnmp.Integer, Value: value}}}
+	}
+	client := &tools.MockSNMPClient{
+		WalkMap: map[string][]gosnmp.SnmpPDU{
+			svSvcNameOID: {
+				{Name: svSvcNameOID + ".6.83.101.114.118.101.114", Type: gosnmp.OctetString, Value: []byte("Server")},
+				{Name: svSvcNameOID + ".7.83.112.111.111.108.101.114", Type: gosnmp.OctetString, Value: []byte("Spooler")},
+			},
+		},
+		GetMap: map[string]*gosnmp.SnmpPacket{
+			svSvcOperatingStateOID + ".6.83.101.114.118.101.114":     integer(1),
+			svSvcOperatingStateOID + ".7.83.112.111.111.108.101.114": integer(4),
+		},
+	}
+	monitor := &WinServiceMonitor{}
+
+	response := monitor.poll(client, "server")
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+
+	response = monitor.poll(client, "Spooler")
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "service Spooler is paused(4)", response.Status.Reason)
+
+	response = monitor.poll(client, "DHCP Client")
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "service DHCP Client is not running", response.Status.Reason)
+}
+
+func TestWinServiceMonitorWithHostResources(t *testing.T) {
+	client := &tools.MockSNMPClient{
+		WalkMap: map[string][]gosnmp.SnmpPDU{
+			hrSWRunNameOID: {
+				{Name: hrSWRunNameOID + ".812", Type: gosnmp.OctetString, Value: []byte("svchost.exe")},
+			},
+		},
+		GetMap: map[string]*gosnmp.SnmpPacket{
+			hrSWRunStatusOID + ".812": {Variables: []gosnmp.SnmpPDU{{Type: gosnmp.Integer, Value: 3}}},
+		},
+	}
+	response := (&WinServiceMonitor{}).poll(client, "svchost.exe")
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Equal(t, "service svchost.exe is notRunnable(3)", response.Status.Reason)
+}
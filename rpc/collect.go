@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 
@@ -21,23 +22,40 @@ func (module *CollectorClientRPCModule) GetID() string {
 
 // Execute executes the collection request synchronously and return the response
 func (module *CollectorClientRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return module.ExecuteWithContext(context.Background(), request)
+}
+
+// ExecuteWithContext executes the collection request synchronously and return the response
+func (module *CollectorClientRPCModule) ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	logger := log.FromContext(ctx)
 	req := &api.CollectorRequestDTO{}
 	if err := xml.Unmarshal(request.RpcContent, req); err != nil {
-		response := &api.CollectorResponseDTO{Error: getError(request, err)}
-		return transformResponse(request, response)
+		return module.ErrorResponse(request, err)
 	}
 	collectorID := req.GetCollector()
 	response := &api.CollectorResponseDTO{}
-	log.Infof("Executing %s collector against %s", collectorID, req.CollectionAgent.IPAddress)
-	if collector, ok := collectors.GetCollector(collectorID); ok {
-		response = collector.Collect(req)
+	logger.Infof("Executing %s collector against %s", collectorID, req.CollectionAgent.IPAddress)
+	if address, err := api.NormalizeIPAddress(req.CollectionAgent.IPAddress, req.GetAttributeValue("resolve-hostname", "true") == "true"); err != nil {
+		response.MarkAsFailed(req.CollectionAgent, err)
+	} else if collector, ok := collectors.GetCollector(collectorID); ok {
+		req.CollectionAgent.IPAddress = address
+		if c, ok := collector.(api.ContextServiceCollector); ok {
+			response = c.CollectWithContext(ctx, req)
+		} else {
+			response = collector.Collect(req)
+		}
 	} else {
 		response.Error = getError(request, fmt.Errorf("cannot find implementation for collector %s", collectorID))
 	}
-	log.Infof("Sending collection of %s from %s", response.GetStatus(), req.CollectionAgent.IPAddress)
+	logger.Infof("Sending collection of %s from %s", response.GetStatus(), req.CollectionAgent.IPAddress)
 	return transformResponse(request, response)
 }
 
+// ErrorResponse builds the response for a collection request that failed with the given error
+func (module *CollectorClientRPCModule) ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	return transformResponse(request, &api.CollectorResponseDTO{Error: getError(request, err)})
+}
+
 func init() {
 	api.RegisterRPCModule(&CollectorClientRPCModule{})
 }
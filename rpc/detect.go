@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 
@@ -21,23 +22,40 @@ func (module *DetectorClientRPCModule) GetID() string {
 
 // Execute executes the detection request synchronously and return the response
 func (module *DetectorClientRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return module.ExecuteWithContext(context.Background(), request)
+}
+
+// ExecuteWithContext executes the detection request synchronously and return the response
+func (module *DetectorClientRPCModule) ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	logger := log.FromContext(ctx)
 	req := &api.DetectorRequestDTO{}
 	if err := xml.Unmarshal(request.RpcContent, req); err != nil {
-		response := &api.DetectorResponseDTO{Error: getError(request, err)}
-		return transformResponse(request, response)
+		return module.ErrorResponse(request, err)
 	}
 	detectorID := req.GetDetector()
 	response := &api.DetectorResponseDTO{}
-	log.Infof("Executing detector %s against %s", detectorID, req.IPAddress)
-	if monitor, ok := detectors.GetDetector(detectorID); ok {
-		response = monitor.Detect(req)
+	logger.Infof("Executing detector %s against %s", detectorID, req.IPAddress)
+	if address, err := api.NormalizeIPAddress(req.IPAddress, req.GetAttributeValue("resolve-hostname", "true") == "true"); err != nil {
+		response.Error = err.Error()
+	} else if monitor, ok := detectors.GetDetector(detectorID); ok {
+		req.IPAddress = address
+		if d, ok := monitor.(api.ContextServiceDetector); ok {
+			response = d.DetectWithContext(ctx, req)
+		} else {
+			response = monitor.Detect(req)
+		}
 	} else {
 		response.Error = getError(request, fmt.Errorf("cannot find implementation for detector %s", detectorID))
 	}
-	log.Infof("Sending detection status %s on %s as %s", detectorID, req.IPAddress, response.GetStatus())
+	logger.Infof("Sending detection status %s on %s as %s", detectorID, req.IPAddress, response.GetStatus())
 	return transformResponse(request, response)
 }
 
+// ErrorResponse builds the response for a detection request that failed with the given error
+func (module *DetectorClientRPCModule) ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	return transformResponse(request, &api.DetectorResponseDTO{Error: getError(request, err)})
+}
+
 func init() {
 	api.RegisterRPCModule(&DetectorClientRPCModule{})
 }
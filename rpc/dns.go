@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"net"
@@ -21,10 +22,15 @@ func (module *DNSLookupClientRPCModule) GetID() string {
 
 // Execute executes the DNS request synchronously and return the response
 func (module *DNSLookupClientRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return module.ExecuteWithContext(context.Background(), request)
+}
+
+// ExecuteWithContext executes the DNS request synchronously and return the response
+func (module *DNSLookupClientRPCModule) ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	logger := log.FromContext(ctx)
 	req := &api.DNSLookupRequestDTO{}
 	if err := xml.Unmarshal(request.RpcContent, req); err != nil {
-		response := &api.DNSLookupResponseDTO{Error: getError(request, err)}
-		return transformResponse(request, response)
+		return module.ErrorResponse(request, err)
 	}
 	response := &api.DNSLookupResponseDTO{}
 	if req.QueryType == "LOOKUP" {
@@ -44,10 +50,15 @@ func (module *DNSLookupClientRPCModule) Execute(request *ipc.RpcRequestProto) *i
 	} else {
 		response.Error = getError(request, fmt.Errorf("invalid query type: %s", req.QueryType))
 	}
-	log.Debugf("Sending DNS %s response for %s as %s", req.QueryType, req.HostRequest, response.HostResponse)
+	logger.Debugf("Sending DNS %s response for %s as %s", req.QueryType, req.HostRequest, response.HostResponse)
 	return transformResponse(request, response)
 }
 
+// ErrorResponse builds the response for a DNS lookup request that failed with the given error
+func (module *DNSLookupClientRPCModule) ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	return transformResponse(request, &api.DNSLookupResponseDTO{Error: getError(request, err)})
+}
+
 func init() {
 	api.RegisterRPCModule(&DNSLookupClientRPCModule{})
 }
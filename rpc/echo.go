@@ -1,7 +1,9 @@
 package rpc
 
 import (
+	"context"
 	"encoding/xml"
+	"fmt"
 	"time"
 
 	"github.com/agalue/gominion/api"
@@ -20,13 +22,30 @@ func (module *EchoRPCModule) GetID() string {
 
 // Execute executes the echo request synchronously and return the response
 func (module *EchoRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return module.ExecuteWithContext(context.Background(), request)
+}
+
+// ExecuteWithContext executes the echo request synchronously and return the response
+// The requested delay (in milliseconds) is interrupted when the context is done, and requests with the throw flag get a failure response, like OpenNMS does to test error handling.
+func (module *EchoRPCModule) ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
 	req := &api.EchoRequest{}
 	if err := xml.Unmarshal(request.RpcContent, req); err != nil {
-		response := &api.EchoResponse{Error: getError(request, err)}
-		return transformResponse(request, response)
+		return module.ErrorResponse(request, err)
 	}
 	if req.Delay > 0 {
-		time.Sleep(time.Duration(req.Delay) * time.Microsecond)
+		select {
+		case <-time.After(time.Duration(req.Delay) * time.Millisecond):
+		case <-ctx.Done():
+			return module.ErrorResponse(request, ctx.Err())
+		}
+	}
+	if req.ShouldThrow {
+		response := &api.EchoResponse{
+			ID:    req.ID,
+			Error: getError(request, fmt.Errorf("%s", req.Message)),
+		}
+		log.Debugf("Sending echo failure for ID %v as requested", response.ID)
+		return transformResponse(request, response)
 	}
 	response := &api.EchoResponse{
 		ID:      req.ID,
@@ -37,6 +56,11 @@ func (module *EchoRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcRespo
 	return transformResponse(request, response)
 }
 
+// ErrorResponse builds the response for an echo request that failed with the given error
+func (module *EchoRPCModule) ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	return transformResponse(request, &api.EchoResponse{Error: getError(request, err)})
+}
+
 func init() {
 	api.RegisterRPCModule(&EchoRPCModule{})
 }
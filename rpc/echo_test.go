@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestEchoExecute(t *testing.T) {
+	module := &EchoRPCModule{}
+	echo := func(ctx context.Context, req *api.EchoRequest) *api.EchoResponse {
+		content, err := xml.Marshal(req)
+		assert.NilError(t, err)
+		response := module.ExecuteWithContext(ctx, &ipc.RpcRequestProto{ModuleId: "Echo", RpcId: "001", RpcContent: content})
+		result := &api.EchoResponse{}
+		assert.NilError(t, xml.Unmarshal(response.RpcContent, result))
+		return result
+	}
+
+	start := time.Now()
+	response := echo(context.Background(), &api.EchoRequest{ID: 10, Message: "Test Message", Body: "Test Body", Delay: 50})
+	elapsed := time.Since(start)
+	assert.Assert(t, elapsed >= 50*time.Millisecond && elapsed < time.Second, elapsed)
+	assert.Equal(t, int64(10), response.ID)
+	assert.Equal(t, "Test Message", response.Message)
+	assert.Equal(t, "Test Body", response.Body)
+	assert.Equal(t, "", response.Error)
+
+	response = echo(context.Background(), &api.EchoRequest{ID: 11, Message: "Boom", ShouldThrow: true})
+	assert.Equal(t, int64(11), response.ID)
+	assert.Assert(t, strings.HasSuffix(response.Error, ": Boom"))
+	assert.Equal(t, "", response.Message)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	response = echo(ctx, &api.EchoRequest{ID: 12, Message: "Slow", Delay: int64(time.Minute / time.Millisecond)})
+	assert.Assert(t, time.Since(start) < time.Second)
+	assert.Assert(t, strings.Contains(response.Error, "deadline exceeded"))
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/protobuf/ipc"
+)
+
+// HealthCheckRPCModule represents the RPC Module implementation for the Minion health check
+type HealthCheckRPCModule struct {
+}
+
+// GetID gets the module ID
+func (module *HealthCheckRPCModule) GetID() string {
+	return "Health"
+}
+
+// Execute reports the status of the Minion subsystems as a JSON document
+// When a module has failed (e.g. a Sink listener died), the document also carries the error, like the failure responses of the other modules.
+func (module *HealthCheckRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	health := api.GetHealth()
+	if !health.IsHealthy() {
+		ids := make([]string, 0, len(health.FailedModules))
+		for id := range health.FailedModules {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		health.Error = getError(request, fmt.Errorf("failed modules: %s", strings.Join(ids, ", ")))
+	}
+	log.Debugf("Sending health status as %s", health.Status)
+	return module.healthResponse(request, health)
+}
+
+// ErrorResponse builds the response for a health check that failed with the given error
+func (module *HealthCheckRPCModule) ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	return module.healthResponse(request, &api.MinionHealthDTO{Status: api.HealthStatusFailed, Error: getError(request, err)})
+}
+
+// Builds the RPC Response with the health status as JSON
+func (module *HealthCheckRPCModule) healthResponse(request *ipc.RpcRequestProto, health *api.MinionHealthDTO) *ipc.RpcResponseProto {
+	bytes, err := json.Marshal(health)
+	if err != nil {
+		log.Errorf("Cannot parse health status: %v", err)
+		bytes, _ = json.Marshal(&api.MinionHealthDTO{Status: api.HealthStatusFailed, Error: getError(request, err)})
+	}
+	return &ipc.RpcResponseProto{
+		ModuleId:   request.ModuleId,
+		Location:   request.Location,
+		SystemId:   request.SystemId,
+		RpcId:      request.RpcId,
+		RpcContent: bytes,
+	}
+}
+
+func init() {
+	api.RegisterRPCModule(&HealthCheckRPCModule{})
+}
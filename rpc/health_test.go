@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestHealthCheckExecute(t *testing.T) {
+	module := &HealthCheckRPCModule{}
+	request := &ipc.RpcRequestProto{ModuleId: "Health", RpcId: "001"}
+	check := func(response *ipc.RpcResponseProto) *api.MinionHealthDTO {
+		assert.Assert(t, response != nil)
+		assert.Equal(t, "001", response.RpcId)
+		health := &api.MinionHealthDTO{}
+		assert.NilError(t, json.Unmarshal(response.RpcContent, health))
+		return health
+	}
+
+	health := check(module.Execute(request))
+	assert.Equal(t, api.HealthStatusOK, health.Status)
+	assert.Equal(t, "", health.Error)
+
+	api.ReportModuleFailure("Syslog", fmt.Errorf("use of closed network connection"))
+	defer api.ClearModuleFailure("Syslog")
+	health = check(module.Execute(request))
+	assert.Equal(t, api.HealthStatusFailed, health.Status)
+	assert.Equal(t, "use of closed network connection", health.FailedModules["Syslog"])
+	assert.Assert(t, strings.HasSuffix(health.Error, ": failed modules: Syslog"), health.Error)
+
+	health = check(module.ErrorResponse(request, fmt.Errorf("request expired")))
+	assert.Equal(t, api.HealthStatusFailed, health.Status)
+	assert.Assert(t, strings.HasSuffix(health.Error, ": request expired"), health.Error)
+}
@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/collectors"
+	"github.com/agalue/gominion/detectors"
+	"github.com/agalue/gominion/log"
+	"github.com/agalue/gominion/monitors"
+	"github.com/agalue/gominion/protobuf/ipc"
+)
+
+// InventoryRPCModule represents the RPC Module implementation for the inventory of the Minion modules
+type InventoryRPCModule struct {
+}
+
+// GetID gets the module ID
+func (module *InventoryRPCModule) GetID() string {
+	return "Inventory"
+}
+
+// Execute reports the version of the Minion and the IDs of its modules as a JSON document
+func (module *InventoryRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	inventory := GetInventory()
+	bytes, err := json.Marshal(inventory)
+	if err != nil {
+		log.Errorf("Cannot parse inventory: %v", err)
+		return nil
+	}
+	log.Debugf("Sending inventory of version %s", inventory.Version)
+	return &ipc.RpcResponseProto{
+		ModuleId:   request.ModuleId,
+		Location:   request.Location,
+		SystemId:   request.SystemId,
+		RpcId:      request.RpcId,
+		RpcContent: bytes,
+	}
+}
+
+// GetInventory returns the version of the Minion and the sorted IDs of its modules
+func GetInventory() *api.MinionInventoryDTO {
+	inventory := &api.MinionInventoryDTO{
+		Version:     api.GetVersion(),
+		SinkModules: api.GetSinkModules(),
+	}
+	for _, m := range api.GetAllRPCModules() {
+		inventory.RPCModules = append(inventory.RPCModules, m.GetID())
+	}
+	for _, m := range collectors.GetAllCollectors() {
+		inventory.Collectors = append(inventory.Collectors, m.GetID())
+	}
+	for _, m := range detectors.GetAllDetectors() {
+		inventory.Detectors = append(inventory.Detectors, m.GetID())
+	}
+	for _, m := range monitors.GetAllMonitors() {
+		inventory.Monitors = append(inventory.Monitors, m.GetID())
+	}
+	for _, ids := range [][]string{inventory.RPCModules, inventory.Collectors, inventory.Detectors, inventory.Monitors} {
+		sort.Strings(ids)
+	}
+	return inventory
+}
+
+func init() {
+	api.RegisterRPCModule(&InventoryRPCModule{})
+}
@@ -0,0 +1,37 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+	"gotest.tools/v3/assert"
+)
+
+func TestInventoryModule(t *testing.T) {
+	api.SetVersion("1.2.3")
+	defer api.SetVersion("unknown")
+	module := &InventoryRPCModule{}
+	response := module.Execute(&ipc.RpcRequestProto{ModuleId: "Inventory", RpcId: "001", SystemId: "minion1", Location: "Test"})
+	assert.Equal(t, "001", response.RpcId)
+	assert.Equal(t, "minion1", response.SystemId)
+
+	inventory := &api.MinionInventoryDTO{}
+	assert.NilError(t, json.Unmarshal(response.RpcContent, inventory))
+	assert.Equal(t, "1.2.3", inventory.Version)
+	assert.Assert(t, contains(inventory.RPCModules, "Inventory"))
+	assert.Assert(t, contains(inventory.RPCModules, "Poller"))
+	assert.Assert(t, contains(inventory.Collectors, "JdbcCollector"))
+	assert.Assert(t, contains(inventory.Detectors, "TcpDetector"))
+	assert.Assert(t, contains(inventory.Monitors, "LdapMonitor"))
+}
+
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
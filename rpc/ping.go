@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 
@@ -21,10 +22,15 @@ func (module *PingProxyRPCModule) GetID() string {
 
 // Execute executes the ping request synchronously and return the response
 func (module *PingProxyRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return module.ExecuteWithContext(context.Background(), request)
+}
+
+// ExecuteWithContext executes the ping request synchronously and return the response
+func (module *PingProxyRPCModule) ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	logger := log.FromContext(ctx)
 	req := &api.PingRequest{}
 	if err := xml.Unmarshal(request.RpcContent, req); err != nil {
-		response := &api.PingResponse{Error: getError(request, err)}
-		return transformResponse(request, response)
+		return module.ErrorResponse(request, err)
 	}
 	response := &api.PingResponse{}
 	if duration, err := tools.Ping(req.Address, req.GetTimeout()); err == nil {
@@ -32,10 +38,15 @@ func (module *PingProxyRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.Rpc
 	} else {
 		response.Error = getError(request, fmt.Errorf("cannot ping address %s: %v", req.Address, err))
 	}
-	log.Debugf("Sending Ping response for %s", req.Address)
+	logger.Debugf("Sending Ping response for %s", req.Address)
 	return transformResponse(request, response)
 }
 
+// ErrorResponse builds the response for a ping request that failed with the given error
+func (module *PingProxyRPCModule) ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	return transformResponse(request, &api.PingResponse{Error: getError(request, err)})
+}
+
 func init() {
 	api.RegisterRPCModule(&PingProxyRPCModule{})
 }
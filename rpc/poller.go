@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 
@@ -21,23 +22,43 @@ func (module *PollerClientRPCModule) GetID() string {
 
 // Execute executes the polling request synchronously and return the response
 func (module *PollerClientRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return module.ExecuteWithContext(context.Background(), request)
+}
+
+// ExecuteWithContext executes the polling request synchronously and return the response
+// Monitors that implement api.ContextServiceMonitor stop polling when the context is done.
+func (module *PollerClientRPCModule) ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	logger := log.FromContext(ctx)
 	req := &api.PollerRequestDTO{}
 	if err := xml.Unmarshal(request.RpcContent, req); err != nil {
-		response := &api.PollerResponseDTO{Error: getError(request, err)}
-		return transformResponse(request, response)
+		return module.ErrorResponse(request, err)
 	}
 	response := &api.PollerResponseDTO{}
 	monitorID := req.GetMonitor()
-	log.Debugf("Executing monitor %s for service %s through %s", monitorID, req.ServiceName, req.IPAddress)
-	if monitor, ok := monitors.GetMonitor(monitorID); ok {
-		response = monitor.Poll(req)
+	logger.Debugf("Executing monitor %s for service %s through %s", monitorID, req.ServiceName, req.IPAddress)
+	if address, err := api.NormalizeIPAddress(req.IPAddress, req.GetAttributeValue("resolve-hostname", "true") == "true"); err != nil {
+		response.Status = &api.PollStatus{}
+		response.Status.Down(err.Error())
+	} else if monitor, ok := monitors.GetMonitor(monitorID); ok {
+		req.IPAddress = address
+		if m, ok := monitor.(api.ContextServiceMonitor); ok {
+			response = m.PollWithContext(ctx, req)
+		} else {
+			response = monitor.Poll(req)
+		}
+		monitors.SmoothResponseTime(req, response.Status)
 	} else {
 		response.Error = getError(request, fmt.Errorf("cannot find implementation for monitor %s", monitorID))
 	}
-	log.Debugf("Sending polling status of %s on %s as %s", req.ServiceName, req.IPAddress, response.Status.StatusName)
+	logger.Debugf("Sending polling status of %s on %s as %s", req.ServiceName, req.IPAddress, response.Status.StatusName)
 	return transformResponse(request, response)
 }
 
+// ErrorResponse builds the response for a polling request that failed with the given error
+func (module *PollerClientRPCModule) ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	return transformResponse(request, &api.PollerResponseDTO{Error: getError(request, err)})
+}
+
 func init() {
 	api.RegisterRPCModule(&PollerClientRPCModule{})
 }
@@ -0,0 +1,39 @@
+package rpc
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPollerInvalidAddress(t *testing.T) {
+	module := &PollerClientRPCModule{}
+	poll := func(address string) *api.PollerResponseDTO {
+		req := &api.PollerRequestDTO{
+			ClassName:   "org.opennms.netmgt.poller.monitors.Jsr160Monitor",
+			ServiceName: "JMX-Minion",
+			IPAddress:   address,
+			Attributes:  []api.PollerAttributeDTO{{Key: "resolve-hostname", Value: "false"}},
+		}
+		content, err := xml.Marshal(req)
+		assert.NilError(t, err)
+		response := module.Execute(&ipc.RpcRequestProto{ModuleId: "Poller", RpcId: "001", RpcContent: content})
+		result := &api.PollerResponseDTO{}
+		assert.NilError(t, xml.Unmarshal(response.RpcContent, result))
+		return result
+	}
+
+	response := poll("server01.example.com")
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+	assert.Assert(t, response.Status.Reason != "")
+
+	response = poll("2001:db8::zz")
+	assert.Equal(t, api.ServiceUnavailableCode, response.Status.StatusCode)
+
+	response = poll("::ffff:127.0.0.1") // Normalized as 127.0.0.1 before polling
+	assert.Equal(t, api.ServiceAvailableCode, response.Status.StatusCode)
+}
@@ -1,12 +1,14 @@
 package rpc
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/protobuf/ipc"
+	"github.com/agalue/gominion/snmp"
 	"github.com/agalue/gominion/tools"
 	"github.com/gosnmp/gosnmp"
 )
@@ -22,27 +24,31 @@ func (module *SNMPProxyRPCModule) GetID() string {
 
 // Execute executes the SNMP request synchronously and return the response
 func (module *SNMPProxyRPCModule) Execute(request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	return module.ExecuteWithContext(context.Background(), request)
+}
+
+// ExecuteWithContext executes the SNMP request synchronously and return the response
+func (module *SNMPProxyRPCModule) ExecuteWithContext(ctx context.Context, request *ipc.RpcRequestProto) *ipc.RpcResponseProto {
+	logger := log.FromContext(ctx)
 	req := &api.SNMPRequestDTO{}
 	if err := xml.Unmarshal(request.RpcContent, req); err != nil {
-		response := &api.SNMPMultiResponseDTO{Error: getError(request, err)}
-		return transformResponse(request, response)
+		return module.ErrorResponse(request, err)
 	}
-	client := req.Agent.GetSNMPClient()
-	if err := client.Connect(); err != nil {
-		response := &api.SNMPMultiResponseDTO{Error: getError(request, err)}
-		return transformResponse(request, response)
+	client, err := snmp.Acquire(&req.Agent)
+	if err != nil {
+		return module.ErrorResponse(request, err)
 	}
-	defer client.Disconnect()
-	return transformResponse(request, module.getResponse(client, req))
+	defer snmp.Release(client)
+	return transformResponse(request, module.getResponse(logger, client, req))
 }
 
-func (module *SNMPProxyRPCModule) getResponse(client api.SNMPHandler, req *api.SNMPRequestDTO) *api.SNMPMultiResponseDTO {
+func (module *SNMPProxyRPCModule) getResponse(logger *log.Logger, client api.SNMPHandler, req *api.SNMPRequestDTO) *api.SNMPMultiResponseDTO {
 	response := &api.SNMPMultiResponseDTO{}
 	for _, walk := range req.Walks {
-		if r, err := module.snmpWalk(client, walk); err == nil {
+		if r, err := module.snmpWalk(logger, client, walk); err == nil {
 			response.AddResponse(r)
 		} else {
-			log.Errorf(err.Error())
+			logger.Errorf(err.Error())
 			response.Error = err.Error()
 			break
 		}
@@ -50,9 +56,9 @@ func (module *SNMPProxyRPCModule) getResponse(client api.SNMPHandler, req *api.S
 	return response
 }
 
-func (module *SNMPProxyRPCModule) snmpWalk(client api.SNMPHandler, walk api.SNMPWalkRequestDTO) (*api.SNMPResponseDTO, error) {
+func (module *SNMPProxyRPCModule) snmpWalk(logger *log.Logger, client api.SNMPHandler, walk api.SNMPWalkRequestDTO) (*api.SNMPResponseDTO, error) {
 	response := &api.SNMPResponseDTO{CorrelationID: walk.CorrelationID}
-	log.Debugf("Executing %d snmpwalk %s against %s", len(walk.OIDs), client.Version(), client.Target())
+	logger.Debugf("Executing %d snmpwalk %s against %s", len(walk.OIDs), client.Version(), client.Target())
 	for _, oid := range walk.OIDs {
 		effectiveOid := tools.GetOidToWalk(oid, walk.Instance)
 		err := client.BulkWalk(effectiveOid, func(pdu gosnmp.SnmpPDU) error {
@@ -63,10 +69,15 @@ func (module *SNMPProxyRPCModule) snmpWalk(client api.SNMPHandler, walk api.SNMP
 			return nil, fmt.Errorf("cannot execute snmpwalk for %s: %v", effectiveOid, err)
 		}
 	}
-	log.Debugf("Sending %d snmpwalk responses from %s", len(response.Results), client.Target())
+	logger.Debugf("Sending %d snmpwalk responses from %s", len(response.Results), client.Target())
 	return response, nil
 }
 
+// ErrorResponse builds the response for an SNMP request that failed with the given error
+func (module *SNMPProxyRPCModule) ErrorResponse(request *ipc.RpcRequestProto, err error) *ipc.RpcResponseProto {
+	return transformResponse(request, &api.SNMPMultiResponseDTO{Error: getError(request, err)})
+}
+
 func init() {
 	api.RegisterRPCModule(&SNMPProxyRPCModule{})
 }
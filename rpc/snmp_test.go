@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/tools"
 	"github.com/gosnmp/gosnmp"
 	"gotest.tools/v3/assert"
@@ -208,7 +209,7 @@ func TestSNMPResponse(t *testing.T) {
 	}
 
 	module := new(SNMPProxyRPCModule)
-	response := module.getResponse(client, req)
+	response := module.getResponse(log.WithFields(), client, req)
 	bytes, err := xml.MarshalIndent(response, "", "	")
 	assert.NilError(t, err)
 	fmt.Println(string(bytes))
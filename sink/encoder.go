@@ -0,0 +1,72 @@
+package sink
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/telemetry"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Encoder builds the content of a Sink message from the data received by a listener, using the envelope expected by the consumer on OpenNMS
+type Encoder interface {
+	Encode(config *api.MinionConfig, sourceAddress string, sourcePort uint32, data [][]byte) ([]byte, error)
+}
+
+// TelemetryEncoder wraps the data on a TelemetryMessageLog, as expected by the telemetry adapters; this is the default encoder
+type TelemetryEncoder struct {
+}
+
+// Encode builds a TelemetryMessageLog with a message per data entry, all of them with the current timestamp
+func (encoder *TelemetryEncoder) Encode(config *api.MinionConfig, sourceAddress string, sourcePort uint32, data [][]byte) ([]byte, error) {
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	logMsg := &telemetry.TelemetryMessageLog{
+		SystemId:      &config.ID,
+		Location:      &config.Location,
+		SourceAddress: &sourceAddress,
+		SourcePort:    &sourcePort,
+		Message:       make([]*telemetry.TelemetryMessage, len(data)),
+	}
+	for i := 0; i < len(data); i++ {
+		logMsg.Message[i] = &telemetry.TelemetryMessage{
+			Timestamp: &now,
+			Bytes:     data[i],
+		}
+	}
+	bytes, err := proto.Marshal(logMsg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot serialize telemetry message: %v", err)
+	}
+	return bytes, nil
+}
+
+var defaultEncoder Encoder = &TelemetryEncoder{}
+var encoderRegistryMap map[string]Encoder = make(map[string]Encoder)
+var encoderRegistryMutex = sync.RWMutex{}
+
+// RegisterEncoder registers the encoder for the listeners of a given parser (simple class name)
+func RegisterEncoder(parser string, encoder Encoder) {
+	encoderRegistryMutex.Lock()
+	encoderRegistryMap[parser] = encoder
+	encoderRegistryMutex.Unlock()
+}
+
+// UnregisterEncoder removes the encoder of a given parser, so its listeners use the default one
+func UnregisterEncoder(parser string) {
+	encoderRegistryMutex.Lock()
+	delete(encoderRegistryMap, parser)
+	encoderRegistryMutex.Unlock()
+}
+
+// GetEncoder gets the encoder for the listeners of a given parser; returns the TelemetryEncoder when there is none registered
+func GetEncoder(parser string) Encoder {
+	encoderRegistryMutex.RLock()
+	defer encoderRegistryMutex.RUnlock()
+	if encoder, ok := encoderRegistryMap[parser]; ok {
+		return encoder
+	}
+	return defaultEncoder
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/telemetry"
+
+	"google.golang.org/protobuf/proto"
+
+	"gotest.tools/v3/assert"
+)
+
+type lineEncoder struct{}
+
+func (encoder *lineEncoder) Encode(config *api.MinionConfig, sourceAddress string, sourcePort uint32, data [][]byte) ([]byte, error) {
+	return []byte(fmt.Sprintf("%s:%d %d", sourceAddress, sourcePort, len(data))), nil
+}
+
+func TestTelemetryEncoder(t *testing.T) {
+	port := uint32(50000)
+	ipaddr := "10.0.0.1"
+	config := &api.MinionConfig{ID: "minion1", Location: "Test"}
+	object := Person{FirstName: "Alejandro", LastName: "Galue"}
+	data, err := xml.Marshal(object)
+	assert.NilError(t, err)
+
+	encoder := &TelemetryEncoder{}
+	bytes, err := encoder.Encode(config, ipaddr, port, [][]byte{data})
+	assert.NilError(t, err)
+
+	telemetry := &telemetry.TelemetryMessageLog{}
+	err = proto.Unmarshal(bytes, telemetry)
+	assert.NilError(t, err)
+	assert.Equal(t, port, telemetry.GetSourcePort())
+	assert.Equal(t, ipaddr, telemetry.GetSourceAddress())
+	assert.Equal(t, 1, len(telemetry.Message))
+
+	msg := telemetry.Message[0]
+	received := &Person{}
+	err = xml.Unmarshal(msg.Bytes, received)
+	assert.NilError(t, err)
+	assert.Equal(t, object.FirstName, received.FirstName)
+}
+
+func TestSendEncoded(t *testing.T) {
+	sink := new(api.MockBroker)
+	config := &api.MinionConfig{ID: "minion1", Location: "Test"}
+	RegisterEncoder("LineParser", &lineEncoder{})
+	defer UnregisterEncoder("LineParser")
+
+	assert.NilError(t, sendEncoded("Test", "Test", "LineParser", config, sink, "10.0.0.1", 2003, [][]byte{[]byte("a"), []byte("b")}))
+	assert.NilError(t, sendEncoded("Test", "Test", UDPForwardParser, config, sink, "10.0.0.1", 2003, [][]byte{[]byte("a")}))
+
+	messages := sink.GetMessages()
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "10.0.0.1:2003 2", string(messages[0].Content))
+	telemetry := &telemetry.TelemetryMessageLog{}
+	assert.NilError(t, proto.Unmarshal(messages[1].Content, telemetry))
+	assert.Equal(t, 1, len(telemetry.Message))
+}
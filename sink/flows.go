@@ -2,10 +2,8 @@ package sink
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net"
-	"runtime"
 	"strconv"
 	"sync"
 	"time"
@@ -38,7 +36,7 @@ const UDPIpfixParser = "IpfixUdpParser"
 const UDPSFlowParser = "SFlowUdpParser"
 
 // TCPIpfixParser represents the TCP IPFIX parser name
-const TCPIpfixParser = "IpfixUdpParser"
+const TCPIpfixParser = "IpfixTcpParser"
 
 // Custom Logger implementation for goflow
 type flowLogger struct{}
@@ -79,7 +77,8 @@ type NetflowModule struct {
 	sink      api.Sink
 	config    *api.MinionConfig
 	listener  *api.MinionListener
-	conn      *net.UDPConn
+	server    *udpServer
+	tcp       *tcpServer
 	processor *decoder.Processor
 	stopping  bool
 	resolver  *dnscache.Resolver
@@ -91,6 +90,11 @@ func (module *NetflowModule) GetID() string {
 	return module.name
 }
 
+// GetParsers gets the parsers supported by the flow modules
+func (module *NetflowModule) GetParsers() []string {
+	return []string{UDPNetflow5Parser, UDPNetflow9Parser, UDPIpfixParser, TCPIpfixParser}
+}
+
 // Start initiates a Netflow UDP receiver
 func (module *NetflowModule) Start(config *api.MinionConfig, sink api.Sink) error {
 	module.stopping = false
@@ -101,74 +105,55 @@ func (module *NetflowModule) Start(config *api.MinionConfig, sink api.Sink) erro
 		log.Warnf("Flow Module %s disabled", module.name)
 		return nil
 	}
-	var err error
-	if module.conn, err = createUDPListener(module.listener.Port); err != nil {
-		return err
-	}
 	var handler = module.getDecoderHandler()
 	if handler == nil {
 		log.Warnf("Flow Module %s disabled", module.name)
 		return nil
 	}
+	setRateLimit("Telemetry-"+module.listener.Name, module.listener)
+	if module.listener.Is(TCPIpfixParser) {
+		log.Infof("Starting %s flow receiver on port TCP %d", module.name, module.listener.Port)
+		module.initDNSResolver()
+		module.initCircuitBreaker()
+		module.startProcessor(handler)
+		return module.startTCPServer()
+	}
 	log.Infof("Starting %s flow receiver on port UDP %d", module.name, module.listener.Port)
 	module.initDNSResolver()
 	module.initCircuitBreaker()
 	module.startProcessor(handler)
 
-	localIP := module.conn.LocalAddr().String()
-
-	go func() {
-		payload := make([]byte, 9000)
-		for {
-			size, pktAddr, err := module.conn.ReadFromUDP(payload)
-			if err != nil {
-				if !module.stopping {
-					log.Errorf("%s Cannot read from UDP: %s", module.name, err)
-				}
-				continue
-			}
-			payloadCut := make([]byte, size)
-			copy(payloadCut, payload[0:size])
-			baseMessage := goflow.BaseMessage{
-				Src:     pktAddr.IP,
-				Port:    pktAddr.Port,
-				Payload: payloadCut,
-			}
-			module.processor.ProcessMessage(baseMessage)
-			if module.config.StatsPort > 0 {
-				goflow.MetricTrafficBytes.With(
-					prometheus.Labels{
-						"remote_ip":   pktAddr.IP.String(),
-						"remote_port": strconv.Itoa(pktAddr.Port),
-						"local_ip":    localIP,
-						"local_port":  strconv.Itoa(module.listener.Port),
-						"type":        module.goflowID,
-					}).
-					Add(float64(size))
-				goflow.MetricTrafficPackets.With(
-					prometheus.Labels{
-						"remote_ip":   pktAddr.IP.String(),
-						"remote_port": strconv.Itoa(pktAddr.Port),
-						"local_ip":    localIP,
-						"local_port":  strconv.Itoa(module.listener.Port),
-						"type":        module.goflowID,
-					}).
-					Inc()
-				goflow.MetricPacketSizeSum.With(
-					prometheus.Labels{
-						"remote_ip":   pktAddr.IP.String(),
-						"remote_port": strconv.Itoa(pktAddr.Port),
-						"local_ip":    localIP,
-						"local_port":  strconv.Itoa(module.listener.Port),
-						"type":        module.goflowID,
-					}).
-					Observe(float64(size))
-			}
-		}
-	}()
+	var err error
+	if module.server, err = newUDPServer(module.config, module.name, module.config.GetBindAddress(module.listener), getListenerIPVersion(module.listener), module.listener.Port, getListenerWorkers(module.config, module.listener), getListenerReadBuffer(module.listener)); err != nil {
+		return err
+	}
+	module.server.serve(9000, module.processPacket)
 	return nil
 }
 
+// Passes a UDP packet to the flow processor, and updates the goflow metrics when enabled
+func (module *NetflowModule) processPacket(pktAddr *net.UDPAddr, data []byte) {
+	countReceived(module.config, module.listener.Name, module.listener.GetParser(), len(data))
+	module.processor.ProcessMessage(goflow.BaseMessage{
+		Src:     pktAddr.IP,
+		Port:    pktAddr.Port,
+		Payload: data,
+	})
+	if module.config.StatsPort == 0 {
+		return
+	}
+	labels := prometheus.Labels{
+		"remote_ip":   pktAddr.IP.String(),
+		"remote_port": strconv.Itoa(pktAddr.Port),
+		"local_ip":    module.server.conns[0].LocalAddr().String(),
+		"local_port":  strconv.Itoa(module.listener.Port),
+		"type":        module.goflowID,
+	}
+	goflow.MetricTrafficBytes.With(labels).Add(float64(len(data)))
+	goflow.MetricTrafficPackets.With(labels).Inc()
+	goflow.MetricPacketSizeSum.With(labels).Observe(float64(len(data)))
+}
+
 // Stop shutdowns the sink module
 func (module *NetflowModule) Stop() {
 	log.Warnf("Stopping %s flow receiver", module.name)
@@ -176,34 +161,28 @@ func (module *NetflowModule) Stop() {
 	if module.processor != nil {
 		module.processor.Stop()
 	}
-	if module.conn != nil {
-		module.conn.Close()
+	if module.server != nil {
+		module.server.stop()
+	}
+	if module.tcp != nil {
+		module.tcp.stop()
 	}
 }
 
 // Publish represents the Transport interface implementation used by goflow
 func (module *NetflowModule) Publish(msgs []*goflowMsg.FlowMessage) {
+	defer recoverPanic(module.config, module.name)
 	messages := make([][]byte, len(msgs))
 	sourceAddress := ""
 	for idx, flowmsg := range msgs {
 		if sourceAddress == "" {
 			sourceAddress = net.IP(flowmsg.SamplerAddress).String()
 		}
-		if flowmsg.Type == goflowMsg.FlowMessage_SFLOW_5 {
-			// DEBUG: start
-			if bytes, err := json.MarshalIndent(flowmsg, "", "  "); err != nil {
-				log.Debugf("SFlow message received %s", string(bytes))
-			}
-			// DEBUG: end
-		} else {
-			msg := module.convertToNetflow(flowmsg)
-			buffer, _ := proto.Marshal(msg)
-			messages[idx] = buffer
-		}
-	}
-	if bytes := wrapMessageToTelemetry(module.config, sourceAddress, uint32(module.listener.Port), messages); bytes != nil {
-		sendBytes("Telemetry-"+module.listener.Name, module.config, module.sink, bytes)
+		msg := module.convertToNetflow(flowmsg)
+		buffer, _ := proto.Marshal(msg)
+		messages[idx] = buffer
 	}
+	sendEncoded("Telemetry-"+module.listener.Name, module.listener.Name, module.listener.GetParser(), module.config, module.sink, sourceAddress, uint32(module.listener.Port), messages)
 }
 
 func (module *NetflowModule) getDecoderHandler() decoder.DecoderFunc {
@@ -215,24 +194,31 @@ func (module *NetflowModule) getDecoderHandler() decoder.DecoderFunc {
 			Transport: module,
 			Logger:    flowLogger{},
 		}
-		return netflow.DecodeFlow
-	} else if module.listener.Is(UDPNetflow9Parser) || module.listener.Is(UDPIpfixParser) {
+		return module.recoverDecoder(netflow.DecodeFlow)
+	} else if module.listener.Is(UDPNetflow9Parser) || module.listener.Is(UDPIpfixParser) || module.listener.Is(TCPIpfixParser) {
 		netflow := goflow.StateNetFlow{
 			Transport: module,
 			Logger:    flowLogger{},
 		}
 		netflow.InitTemplates()
-		return netflow.DecodeFlow
-	} else if module.listener.Is(UDPSFlowParser) {
-		sflow := goflow.StateSFlow{
-			Transport: module,
-			Logger:    flowLogger{},
-		}
-		return sflow.DecodeFlow
+		return module.recoverDecoder(netflow.DecodeFlow)
 	}
 	return nil
 }
 
+// Wraps a decoder so a panic while decoding a malformed packet is recovered, and reported to the error callback of the processor as an error
+func (module *NetflowModule) recoverDecoder(handler decoder.DecoderFunc) decoder.DecoderFunc {
+	return func(msg interface{}) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				reportPanic(module.config, module.name, r)
+				err = fmt.Errorf("%s decoder panicked: %v", module.name, r)
+			}
+		}()
+		return handler(msg)
+	}
+}
+
 func (module *NetflowModule) startProcessor(handler decoder.DecoderFunc) {
 	if module.processor != nil {
 		return
@@ -319,14 +305,7 @@ func (module *NetflowModule) isReverseDNSEnabled() bool {
 }
 
 func (module *NetflowModule) getWorkers() int {
-	value, ok := module.listener.Properties["workers"]
-	if ok {
-		w, err := strconv.Atoi(value)
-		if err != nil && w > 0 {
-			return w
-		}
-	}
-	return runtime.NumCPU()
+	return getListenerDecoders(module.config, module.listener)
 }
 
 func (module *NetflowModule) convertToNetflow(flowmsg *goflowMsg.FlowMessage) *netflow.FlowMessage {
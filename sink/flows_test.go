@@ -0,0 +1,27 @@
+package sink
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/agalue/gominion/api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/v3/assert"
+)
+
+func TestRecoverDecoder(t *testing.T) {
+	module := &NetflowModule{name: "Netflow-Panic", config: &api.MinionConfig{ID: "minion1"}}
+	handler := module.recoverDecoder(func(msg interface{}) error {
+		var template map[string]int
+		template["missing"]++ // A malformed packet that crashes the decoder
+		return nil
+	})
+	err := handler(nil)
+	assert.ErrorContains(t, err, "Netflow-Panic decoder panicked")
+	assert.Equal(t, 1.0, testutil.ToFloat64(sinkModulePanicked.WithLabelValues("minion1", "Netflow-Panic")))
+
+	handler = module.recoverDecoder(func(msg interface{}) error {
+		return fmt.Errorf("invalid packet")
+	})
+	assert.ErrorContains(t, handler(nil), "invalid packet")
+}
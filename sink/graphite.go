@@ -0,0 +1,188 @@
+package sink
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Graphite lines forwarded to OpenNMS, and lines discarded because they are not valid
+var (
+	graphiteLinesForwarded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "onms_graphite_lines_forwarded",
+		Help: "The total number of Graphite lines forwarded per listener",
+	}, []string{"listener"})
+	graphiteParseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "onms_graphite_parse_errors",
+		Help: "The total number of invalid Graphite lines per listener",
+	}, []string{"listener"})
+)
+
+func init() {
+	prometheus.MustRegister(graphiteLinesForwarded, graphiteParseErrors)
+}
+
+// GraphiteTCPModule represents the Graphite plaintext protocol receiver via TCP
+// It reads newline-delimited metrics from each connection, and forwards them to OpenNMS in batches
+type GraphiteTCPModule struct {
+	name     string
+	sink     api.Sink
+	config   *api.MinionConfig
+	listener *api.MinionListener
+	server   *tcpServer
+	stopping bool
+}
+
+// GetID gets the ID of the sink module
+func (module *GraphiteTCPModule) GetID() string {
+	return module.name
+}
+
+// GetParsers gets the parsers supported by the sink module
+func (module *GraphiteTCPModule) GetParsers() []string {
+	return []string{UDPForwardParser}
+}
+
+// Start initiates a Graphite TCP receiver
+func (module *GraphiteTCPModule) Start(config *api.MinionConfig, sink api.Sink) error {
+	module.listener = config.GetListener(module.name)
+	if module.listener == nil || !module.listener.Is(UDPForwardParser) {
+		log.Warnf("Graphite Module %s disabled", module.name)
+		return nil
+	}
+
+	module.stopping = false
+	module.sink = sink
+	module.config = config
+	setRateLimit("Telemetry-"+module.listener.Name, module.listener)
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", module.listener.Port))
+	if err != nil {
+		return fmt.Errorf("cannot listen on TCP port %d: %s", module.listener.Port, err)
+	}
+	module.server = newTCPServer(listener)
+	log.Infof("Starting %s receiver on port TCP %d", module.name, module.listener.Port)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if module.stopping {
+					return
+				}
+				log.Errorf("%s cannot accept TCP connection: %s", module.name, err)
+				if isFatalListenerError(err) {
+					api.ReportModuleFailure(module.name, err)
+					return
+				}
+				continue
+			}
+			if module.server.add(conn, module.getPropertyAsInt("maxConnections", 64)) {
+				go module.handleConnection(conn)
+			} else {
+				log.Warnf("%s rejecting connection from %s", module.name, conn.RemoteAddr())
+				conn.Close()
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop shutdowns the sink module
+func (module *GraphiteTCPModule) Stop() {
+	log.Warnf("Stopping %s receiver", module.name)
+	module.stopping = true
+	if module.server != nil {
+		module.server.stop()
+	}
+}
+
+// Reads lines from a connection, and flushes them when the batch is full or the flush interval elapses
+func (module *GraphiteTCPModule) handleConnection(conn net.Conn) {
+	defer recoverPanic(module.config, module.name)
+	defer module.server.remove(conn)
+	defer conn.Close()
+	remoteAddr := conn.RemoteAddr().(*net.TCPAddr)
+	flushInterval := time.Duration(module.getPropertyAsInt("flushInterval", 1000)) * time.Millisecond
+	maxBatchSize := module.getPropertyAsInt("maxBatchSize", 100)
+
+	lines := make(chan []byte)
+	go func() {
+		defer recoverPanic(module.config, module.name)
+		defer close(lines)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			countReceived(module.config, module.listener.Name, module.listener.GetParser(), len(scanner.Bytes()))
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			if err := parseGraphiteLine(line); err != nil {
+				log.Debugf("%s discarding line from %s: %v", module.name, remoteAddr.IP, err)
+				graphiteParseErrors.WithLabelValues(module.name).Inc()
+				continue
+			}
+			lines <- []byte(line)
+		}
+	}()
+
+	batch := make([][]byte, 0, maxBatchSize)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				module.flush(remoteAddr, batch)
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) >= maxBatchSize {
+				module.flush(remoteAddr, batch)
+				batch = make([][]byte, 0, maxBatchSize)
+			}
+		case <-ticker.C:
+			module.flush(remoteAddr, batch)
+			batch = make([][]byte, 0, maxBatchSize)
+		}
+	}
+}
+
+func (module *GraphiteTCPModule) flush(remoteAddr *net.TCPAddr, batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := sendEncoded("Telemetry-"+module.listener.Name, module.listener.Name, module.listener.GetParser(), module.config, module.sink, remoteAddr.IP.String(), uint32(remoteAddr.Port), batch); err == nil {
+		graphiteLinesForwarded.WithLabelValues(module.name).Add(float64(len(batch)))
+	}
+}
+
+func (module *GraphiteTCPModule) getPropertyAsInt(property string, defaultValue int) int {
+	if value, ok := module.listener.Properties[property]; ok {
+		if v, err := strconv.Atoi(value); err == nil && v > 0 {
+			return v
+		}
+	}
+	return defaultValue
+}
+
+// Validates a line of the Graphite plaintext protocol: metric value timestamp
+func parseGraphiteLine(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+	if _, err := strconv.ParseFloat(fields[1], 64); err != nil {
+		return fmt.Errorf("invalid value %s", fields[1])
+	}
+	if _, err := strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return fmt.Errorf("invalid timestamp %s", fields[2])
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/telemetry"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"google.golang.org/protobuf/proto"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseGraphiteLine(t *testing.T) {
+	assert.NilError(t, parseGraphiteLine("servers.web01.cpu.load 0.75 1602000000"))
+	assert.ErrorContains(t, parseGraphiteLine("servers.web01.cpu.load 0.75"), "expected 3 fields")
+	assert.ErrorContains(t, parseGraphiteLine("servers.web01.cpu.load high 1602000000"), "invalid value")
+	assert.ErrorContains(t, parseGraphiteLine("servers.web01.cpu.load 0.75 now"), "invalid timestamp")
+}
+
+func TestGraphiteTCPModule(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &GraphiteTCPModule{name: "Graphite-TCP"}
+	config := &api.MinionConfig{
+		ID:       "minion1",
+		Location: "Test",
+		Listeners: []api.MinionListener{
+			{Name: "Graphite-TCP", Port: 32003, Parser: "ForwardParser", Properties: map[string]string{"maxBatchSize": "2"}},
+		},
+	}
+	assert.NilError(t, module.Start(config, sink))
+	defer module.Stop()
+
+	errors := testutil.ToFloat64(graphiteParseErrors.WithLabelValues("Graphite-TCP"))
+	conn, err := net.Dial("tcp", "127.0.0.1:32003")
+	assert.NilError(t, err)
+	conn.Write([]byte("servers.web01.cpu.load 0.75 1602000000\nwrong line\nservers.web01.cpu.idle 99 1602000000\nservers.web01.mem.free 1024 1602000000\n"))
+	conn.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, errors+1, testutil.ToFloat64(graphiteParseErrors.WithLabelValues("Graphite-TCP")))
+	messages := sink.GetMessages()
+	assert.Equal(t, 2, len(messages)) // One full batch, and the remaining line flushed when the connection was closed
+	assert.Equal(t, "Telemetry-Graphite-TCP", messages[0].ModuleId)
+	logMsg := &telemetry.TelemetryMessageLog{}
+	assert.NilError(t, proto.Unmarshal(messages[0].Content, logMsg))
+	assert.Equal(t, 2, len(logMsg.Message))
+	assert.Equal(t, "servers.web01.cpu.load 0.75 1602000000", string(logMsg.Message[0].Bytes))
+}
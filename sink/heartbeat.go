@@ -1,36 +1,69 @@
 package sink
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/log"
 )
 
+// The default interval between heartbeats
+const defaultHeartbeatInterval = 30 * time.Second
+
 // HeartbeatModule represents the heartbeat module
-type HeartbeatModule struct{}
+type HeartbeatModule struct {
+	stop chan struct{}
+	done chan struct{}
+}
 
 // GetID gets the ID of the sink module
 func (module *HeartbeatModule) GetID() string {
 	return "Heartbeat"
 }
 
-// Start initiates a blocking loop that sends heartbeats to OpenNMS
+// Start initiates a background loop that sends heartbeats to OpenNMS.
+// The interval is taken from the interval-ms property of the listener named Heartbeat (defaults to 30 seconds).
 func (module *HeartbeatModule) Start(config *api.MinionConfig, sink api.Sink) error {
-	log.Infof("Starting Sink Heartbeat Module")
-	go func() {
+	interval := module.getInterval(config)
+	log.Infof("Starting Sink Heartbeat Module, sending heartbeats every %s", interval)
+	module.stop = make(chan struct{})
+	module.done = make(chan struct{})
+	go func(stop chan struct{}, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
 		for {
 			log.Infof("Sending heartbeat for Minion with id %s at location %s", config.ID, config.Location)
 			sendXMLResponse(module.GetID(), config, sink, module.getIdentity(config))
-			time.Sleep(30 * time.Second)
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
 		}
-	}()
+	}(module.stop, module.done)
 	return nil
 }
 
 // Stop shutdowns the sink module
 func (module *HeartbeatModule) Stop() {
 	log.Warnf("Stopping Sink Heartbeat Module")
+	if module.stop != nil {
+		close(module.stop)
+		<-module.done
+		module.stop = nil
+	}
+}
+
+// Gets the interval between heartbeats from the listener properties
+func (module *HeartbeatModule) getInterval(config *api.MinionConfig) time.Duration {
+	if listener := config.GetListener(module.GetID()); listener != nil {
+		if value, err := strconv.Atoi(listener.Properties["interval-ms"]); err == nil && value > 0 {
+			return time.Duration(value) * time.Millisecond
+		}
+	}
+	return defaultHeartbeatInterval
 }
 
 func (module *HeartbeatModule) getIdentity(config *api.MinionConfig) *api.MinionIdentityDTO {
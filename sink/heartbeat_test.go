@@ -0,0 +1,38 @@
+package sink
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestHeartbeatModule(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &HeartbeatModule{}
+	config := &api.MinionConfig{
+		ID:       "minion1",
+		Location: "Test",
+		Listeners: []api.MinionListener{
+			{Name: "Heartbeat", Properties: map[string]string{"interval-ms": "50"}},
+		},
+	}
+	assert.Equal(t, 50*time.Millisecond, module.getInterval(config))
+	assert.Equal(t, defaultHeartbeatInterval, module.getInterval(&api.MinionConfig{}))
+
+	assert.NilError(t, module.Start(config, sink))
+	messages := sink.WaitForMessages(2, time.Second)
+	module.Stop()
+	assert.Assert(t, len(messages) >= 2)
+	identity := &api.MinionIdentityDTO{}
+	assert.NilError(t, xml.Unmarshal(messages[0].Content, identity))
+	assert.Equal(t, "minion1", identity.ID)
+	assert.Equal(t, "Test", identity.Location)
+
+	// No heartbeats are sent after stopping the module
+	count := len(sink.GetMessages())
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, count, len(sink.GetMessages()))
+}
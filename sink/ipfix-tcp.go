@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+
+	goflow "github.com/cloudflare/goflow/v3/utils"
+)
+
+// The size of the IPFIX message header (RFC 7011)
+const ipfixHeaderSize = 16
+
+// Starts a TCP listener that accepts long-lived connections from IPFIX exporters.
+// Each connection is handled by its own goroutine, and closed after the idle timeout.
+func (module *NetflowModule) startTCPServer() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", module.listener.Port))
+	if err != nil {
+		return fmt.Errorf("cannot listen on TCP port %d: %s", module.listener.Port, err)
+	}
+	module.tcp = newTCPServer(listener)
+	idleTimeout := module.getIdleTimeout()
+	maxConnections := module.getMaxConnections()
+	log.Infof("%s accepting up to %d connections with an idle timeout of %s", module.name, maxConnections, idleTimeout)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if module.stopping {
+					return
+				}
+				log.Errorf("%s cannot accept TCP connection: %s", module.name, err)
+				if isFatalListenerError(err) {
+					api.ReportModuleFailure(module.name, err)
+					return
+				}
+				continue
+			}
+			if !module.tcp.add(conn, maxConnections) {
+				log.Warnf("%s rejecting connection from %s, the maximum of %d connections was reached", module.name, conn.RemoteAddr(), maxConnections)
+				conn.Close()
+				continue
+			}
+			go module.handleTCPConnection(conn, idleTimeout)
+		}
+	}()
+	return nil
+}
+
+// Reads length-framed IPFIX messages from a TCP connection and passes them to the flow processor
+func (module *NetflowModule) handleTCPConnection(conn net.Conn, idleTimeout time.Duration) {
+	defer recoverPanic(module.config, module.name)
+	defer module.tcp.remove(conn)
+	defer conn.Close()
+	remoteAddr := conn.RemoteAddr().(*net.TCPAddr)
+	log.Debugf("%s accepted connection from %s", module.name, remoteAddr)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		payload, err := readIpfixMessage(conn)
+		if err != nil {
+			if err != io.EOF && !module.stopping {
+				log.Warnf("%s closing connection from %s: %v", module.name, remoteAddr, err)
+			}
+			return
+		}
+		countReceived(module.config, module.listener.Name, module.listener.GetParser(), len(payload))
+		module.processor.ProcessMessage(goflow.BaseMessage{
+			Src:     remoteAddr.IP,
+			Port:    remoteAddr.Port,
+			Payload: payload,
+		})
+	}
+}
+
+// Reads a single IPFIX message, using the length from its header
+func readIpfixMessage(reader io.Reader) ([]byte, error) {
+	header := make([]byte, ipfixHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if version := binary.BigEndian.Uint16(header[0:2]); version != 10 {
+		return nil, fmt.Errorf("invalid IPFIX version %d", version)
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if length < ipfixHeaderSize {
+		return nil, fmt.Errorf("invalid IPFIX message length %d", length)
+	}
+	message := make([]byte, length)
+	copy(message, header)
+	if _, err := io.ReadFull(reader, message[ipfixHeaderSize:]); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (module *NetflowModule) getIdleTimeout() time.Duration {
+	if value, ok := module.listener.Properties["idleTimeout"]; ok {
+		if t, err := strconv.Atoi(value); err == nil && t > 0 {
+			return time.Duration(t) * time.Millisecond
+		}
+	}
+	return 5 * time.Minute
+}
+
+func (module *NetflowModule) getMaxConnections() int {
+	if value, ok := module.listener.Properties["maxConnections"]; ok {
+		if c, err := strconv.Atoi(value); err == nil && c > 0 {
+			return c
+		}
+	}
+	return 64
+}
@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReadIpfixMessage(t *testing.T) {
+	header := []byte{0, 10, 0, 20, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1}
+	body := []byte{0, 2, 0, 4}
+	stream := bytes.NewReader(append(append(append([]byte{}, header...), body...), header[:4]...))
+
+	message, err := readIpfixMessage(stream)
+	assert.NilError(t, err)
+	assert.Equal(t, 20, len(message))
+	assert.DeepEqual(t, body, message[16:])
+
+	_, err = readIpfixMessage(stream) // Truncated message
+	assert.Assert(t, err != nil)
+
+	_, err = readIpfixMessage(bytes.NewReader([]byte{0, 9, 0, 20, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1}))
+	assert.ErrorContains(t, err, "invalid IPFIX version 9")
+}
+
+func TestIpfixTCPMaxConnections(t *testing.T) {
+	module := &NetflowModule{name: "IPFIX-TCP", goflowID: "NetFlow"}
+	config := &api.MinionConfig{
+		ID:       "minion1",
+		Location: "Test",
+		Listeners: []api.MinionListener{
+			{Name: "IPFIX-TCP", Port: 34739, Parser: "IpfixTcpParser", Properties: map[string]string{"maxConnections": "1"}},
+		},
+	}
+	assert.NilError(t, module.Start(config, &api.MockBroker{}))
+	defer module.Stop()
+
+	first, err := net.Dial("tcp", "127.0.0.1:34739")
+	assert.NilError(t, err)
+	defer first.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	second, err := net.Dial("tcp", "127.0.0.1:34739")
+	assert.NilError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.Assert(t, err != nil) // Closed by the server
+}
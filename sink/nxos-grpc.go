@@ -4,21 +4,60 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/protobuf/mdt_dialout"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
+// NxosGrpcParser represents the NX-OS gRPC parser name
+const NxosGrpcParser = "NxosGrpcParser"
+
+// The default number of messages waiting to be forwarded, the default number of forwarders, and the default time to wait for them on shutdown
+const (
+	defaultNxosQueueSize       = 1024
+	defaultNxosWorkers         = 4
+	defaultNxosShutdownTimeout = 5000
+)
+
+// nxosMessage represents a telemetry message received from a device, waiting to be forwarded
+type nxosMessage struct {
+	ipaddr string
+	data   []byte
+}
+
 // NxosGrpcModule represents the Cisco Nexus NX-OS Telemetry module via gRPC
+// It starts a gRPC server for every listener with the NX-OS parser, so devices can be served on different ports.
 type NxosGrpcModule struct {
+	sink    api.Sink
+	config  *api.MinionConfig
+	servers []*nxosServer
+}
+
+// nxosServer represents the gRPC server of an NX-OS listener
+// Received messages are queued and forwarded by a pool of workers, so a slow broker doesn't stall the device sessions.
+type nxosServer struct {
 	mdt_dialout.UnimplementedGRPCMdtDialoutServer
-	sink   api.Sink
-	config *api.MinionConfig
-	server *grpc.Server
-	port   int
+	module   *NxosGrpcModule
+	server   *grpc.Server
+	health   *health.Server // Only when the grpc-reflection property is enabled
+	port     int
+	listener string
+	timeout  time.Duration
+	queue    chan nxosMessage
+	stop     chan struct{}
+	wg       sync.WaitGroup
 }
 
 // GetID gets the ID of the sink module
@@ -26,70 +65,220 @@ func (module *NxosGrpcModule) GetID() string {
 	return "NXOS"
 }
 
-// Start initiates a gRPC Server for NX-OS telemetry
-func (module *NxosGrpcModule) Start(config *api.MinionConfig, sink api.Sink) error {
-	listener := config.GetListenerByParser("NxosGrpcParser")
-	if listener == nil || listener.Port == 0 {
-		log.Warnf("NX-OS Telemetry Module disabled")
-		return nil
-	}
+// GetParsers gets the parsers supported by the sink module
+func (module *NxosGrpcModule) GetParsers() []string {
+	return []string{NxosGrpcParser}
+}
 
+// Start initiates a gRPC Server for NX-OS telemetry on every listener whose parser is NxosGrpcParser
+// The queue-size and workers properties of each listener set the capacity of its queue (defaults to 1024 messages) and the number of forwarders (defaults to 4).
+// TLS is enabled when the listener has the tls-cert-path and tls-key-path properties, and mutual TLS when it also has tls-client-ca-path.
+// The shutdown-timeout-ms property sets how long Stop waits for the device sessions and the queued messages (defaults to 5 seconds).
+// When the grpc-reflection property is true, the server also exposes the gRPC reflection and health checking services.
+// The servers started before a failure are stopped.
+func (module *NxosGrpcModule) Start(config *api.MinionConfig, sink api.Sink) error {
 	module.config = config
 	module.sink = sink
-	module.port = listener.Port
+	module.servers = nil
+	for _, listener := range config.GetListenersByParser(NxosGrpcParser) {
+		if listener.Port == 0 {
+			continue
+		}
+		if len(module.servers) == 0 {
+			setRateLimit(module.GetID(), &listener) // The listeners share the Sink module, so the first one sets the limit
+		}
+		server, err := module.startServer(&listener)
+		if err != nil {
+			module.Stop()
+			return err
+		}
+		module.servers = append(module.servers, server)
+	}
+	if len(module.servers) == 0 {
+		log.Warnf("NX-OS Telemetry Module disabled")
+	}
+	return nil
+}
 
-	module.server = grpc.NewServer()
-	mdt_dialout.RegisterGRPCMdtDialoutServer(module.server, module)
+// Starts the gRPC server and the workers for a given listener
+func (module *NxosGrpcModule) startServer(listener *api.MinionListener) (*nxosServer, error) {
+	options, err := getNxosServerOptions(listener)
+	if err != nil {
+		return nil, err
+	}
+	srv := &nxosServer{
+		module:   module,
+		port:     listener.Port,
+		listener: listener.Name,
+		timeout:  time.Duration(getNxosProperty(listener, "shutdown-timeout-ms", defaultNxosShutdownTimeout)) * time.Millisecond,
+	}
+	srv.server = grpc.NewServer(options...)
+	mdt_dialout.RegisterGRPCMdtDialoutServer(srv.server, srv)
+	if listener.Properties["grpc-reflection"] == "true" {
+		srv.health = health.NewServer()
+		srv.health.SetServingStatus(mdt_dialout.GRPCMdtDialout_ServiceDesc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+		grpc_health_v1.RegisterHealthServer(srv.server, srv.health)
+		reflection.Register(srv.server)
+	}
+	srv.startWorkers(getNxosProperty(listener, "queue-size", defaultNxosQueueSize), getListenerWorkers(module.config, listener))
 
-	log.Infof("Starting NX-OS telemetry gRPC server on port %d", listener.Port)
+	log.Infof("Starting NX-OS telemetry gRPC server %s on port %d", listener.Name, listener.Port)
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", listener.Port))
 	if err != nil {
-		return fmt.Errorf("Error cannot start TCP listener: %s", err)
+		srv.stopWorkers()
+		return nil, fmt.Errorf("Error cannot start TCP listener: %s", err)
 	}
 	go func() {
-		if err := module.server.Serve(lis); err != nil {
-			log.Errorf("Cannot serve NX-OS gRPC: %v", err)
+		if err := srv.server.Serve(lis); err != nil {
+			log.Errorf("Cannot serve NX-OS gRPC %s: %v", srv.listener, err)
+			api.ReportModuleFailure(module.GetID(), err)
 		}
 	}()
-	return nil
+	return srv, nil
+}
+
+// Gets the gRPC server options from the listener properties, with the TLS credentials when configured
+func getNxosServerOptions(listener *api.MinionListener) ([]grpc.ServerOption, error) {
+	cfg, err := getServerTLSConfig("NX-OS", listener)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(cfg))}, nil
 }
 
-// Stop shutdowns the sink module
+// Stop shutdowns the sink module, and all its gRPC servers in parallel
 func (module *NxosGrpcModule) Stop() {
-	log.Warnf("Stopping NX-OS telemetry gRPC server")
-	if module.server != nil {
-		module.server.Stop()
+	log.Warnf("Stopping NX-OS telemetry gRPC servers")
+	wg := &sync.WaitGroup{}
+	for _, srv := range module.servers {
+		wg.Add(1)
+		go func(srv *nxosServer) {
+			defer wg.Done()
+			srv.shutdown()
+		}(srv)
+	}
+	wg.Wait()
+	module.servers = nil
+}
+
+// Stops the gRPC server gracefully, so the devices can finish their sessions, and then waits for the workers to forward the queued messages.
+// Both are bounded by the shutdown timeout; the sessions still open after it are closed, and the messages still queued are discarded.
+func (srv *nxosServer) shutdown() {
+	deadline := time.Now().Add(srv.timeout)
+	if srv.health != nil {
+		srv.health.Shutdown() // Reports NOT_SERVING while draining, so probes stop sending devices here
+	}
+	stopped := make(chan struct{})
+	go func() {
+		srv.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(srv.timeout):
+		log.Warnf("NX-OS sessions on %s still open after %s, closing them", srv.listener, srv.timeout)
+		srv.server.Stop()
+		<-stopped
+	}
+	for len(srv.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pending := len(srv.queue); pending > 0 {
+		log.Warnf("Discarding %d NX-OS messages from %s not forwarded in %s", pending, srv.listener, srv.timeout)
+	}
+	srv.stopWorkers()
+}
+
+// Starts the workers that forward the queued messages to OpenNMS
+func (srv *nxosServer) startWorkers(queueSize int, workers int) {
+	log.Infof("Forwarding NX-OS telemetry from %s with %d workers, queueing up to %d messages", srv.listener, workers, queueSize)
+	srv.queue = make(chan nxosMessage, queueSize)
+	srv.stop = make(chan struct{})
+	for i := 0; i < workers; i++ {
+		srv.wg.Add(1)
+		go func(queue chan nxosMessage, stop chan struct{}) {
+			defer srv.wg.Done()
+			for {
+				select {
+				case msg := <-queue:
+					srv.forward(msg)
+				case <-stop:
+					return
+				}
+			}
+		}(srv.queue, srv.stop)
+	}
+}
+
+// Stops the workers, discarding the queued messages
+func (srv *nxosServer) stopWorkers() {
+	if srv.stop != nil {
+		close(srv.stop)
+		srv.wg.Wait()
+		srv.stop = nil
+	}
+}
+
+// Queues a message for delivery; drops it when the queue is full
+func (srv *nxosServer) enqueue(msg nxosMessage) {
+	select {
+	case srv.queue <- msg:
+	default:
+		log.Warnf("NX-OS queue of %s is full, dropping message from %s", srv.listener, msg.ipaddr)
+		sinkMsgDropped.WithLabelValues(srv.module.config.ID, srv.module.GetID()).Inc()
+		countDropped(srv.module.config, srv.listener, NxosGrpcParser)
+	}
+}
+
+// Forwards a message to OpenNMS
+func (srv *nxosServer) forward(msg nxosMessage) {
+	module := srv.module
+	defer recoverPanic(module.config, module.GetID())
+	sendEncoded(module.GetID(), srv.listener, NxosGrpcParser, module.config, module.sink, msg.ipaddr, uint32(srv.port), [][]byte{msg.data})
+}
+
+// Gets a positive integer from the listener properties
+func getNxosProperty(listener *api.MinionListener, property string, defaultValue int) int {
+	if value, ok := listener.Properties[property]; ok {
+		if v, err := strconv.Atoi(value); err == nil && v > 0 {
+			return v
+		}
+		log.Warnf("Invalid NX-OS %s %s, using %d", property, value, defaultValue)
 	}
+	return defaultValue
 }
 
 // MdtDialout implements Cisco NX-OS streaming telemetry service
-func (module *NxosGrpcModule) MdtDialout(stream mdt_dialout.GRPCMdtDialout_MdtDialoutServer) error {
+// The stream is released when the client closes it or it fails; errors reported by the device on a given message are logged, and the stream continues.
+func (srv *nxosServer) MdtDialout(stream mdt_dialout.GRPCMdtDialout_MdtDialoutServer) error {
+	module := srv.module
 	ipaddr := "127.0.0.1"
 	peer, peerOK := peer.FromContext(stream.Context())
 	if peerOK {
 		log.Debugf("Accepted Cisco MDT GRPC dialout connection from %s", peer.Addr)
 		ipaddr = peer.Addr.String()
 	}
+	defer log.Warnf("Terminating NX-OS handler for %s", ipaddr)
+	defer recoverPanic(module.config, module.GetID())
 	for {
 		dialoutArgs, err := stream.Recv()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			log.Errorf("Dialout receive error from client %s: %s", ipaddr, dialoutArgs.Errors)
+			if status.Code(err) != codes.Canceled {
+				log.Errorf("Dialout receive error from client %s: %v", ipaddr, err)
+			}
 			return err
 		}
-		if len(dialoutArgs.Data) == 0 && len(dialoutArgs.Errors) != 0 {
+		if len(dialoutArgs.Errors) != 0 {
 			log.Errorf("Dialout error from client %s: %s", ipaddr, dialoutArgs.Errors)
-			break
 		}
-		log.Debugf("Received request with ID %d of %d bytes from %s", dialoutArgs.ReqId, len(dialoutArgs.Data), ipaddr)
-		messages := make([][]byte, 1)
-		messages[0] = dialoutArgs.Data
-		if bytes := wrapMessageToTelemetry(module.config, ipaddr, uint32(module.port), messages); bytes != nil {
-			sendBytes(module.GetID(), module.config, module.sink, bytes)
+		if len(dialoutArgs.Data) == 0 {
+			continue
 		}
+		log.Debugf("Received request with ID %d of %d bytes from %s", dialoutArgs.ReqId, len(dialoutArgs.Data), ipaddr)
+		countReceived(module.config, srv.listener, NxosGrpcParser, len(dialoutArgs.Data))
+		srv.enqueue(nxosMessage{ipaddr: ipaddr, data: dialoutArgs.Data})
 	}
-	log.Warnf("Terminating NX-OS handler")
-	return nil
 }
@@ -0,0 +1,345 @@
+package sink
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/ipc"
+	"github.com/agalue/gominion/protobuf/mdt_dialout"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"gotest.tools/v3/assert"
+)
+
+// Writes a self-signed certificate and its key to the given directory, and returns their paths
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NilError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+	certPath := filepath.Join(dir, "server.crt")
+	keyPath := filepath.Join(dir, "server.key")
+	assert.NilError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NilError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certPath, keyPath
+}
+
+func TestGetNxosServerOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxos")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeTestCertificate(t, dir)
+
+	options, err := getNxosServerOptions(&api.MinionListener{Name: "NXOS"})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(options))
+
+	_, err = getNxosServerOptions(&api.MinionListener{Name: "NXOS", Properties: map[string]string{"tls-cert-path": certPath}})
+	assert.ErrorContains(t, err, "requires both")
+
+	_, err = getNxosServerOptions(&api.MinionListener{Name: "NXOS", Properties: map[string]string{"tls-cert-path": certPath, "tls-key-path": certPath}})
+	assert.ErrorContains(t, err, "cannot load NX-OS server certificate")
+
+	_, err = getNxosServerOptions(&api.MinionListener{Name: "NXOS", Properties: map[string]string{"tls-cert-path": certPath, "tls-key-path": keyPath, "tls-client-ca-path": keyPath}})
+	assert.ErrorContains(t, err, "no certificates found")
+
+	options, err = getNxosServerOptions(&api.MinionListener{Name: "NXOS", Properties: map[string]string{"tls-cert-path": certPath, "tls-key-path": keyPath, "tls-client-ca-path": certPath}})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(options))
+}
+
+func TestNxosGrpcModuleWithTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxos")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeTestCertificate(t, dir)
+
+	sink := &api.MockBroker{}
+	module := &NxosGrpcModule{}
+	config := &api.MinionConfig{
+		ID:       "minion1",
+		Location: "Test",
+		Listeners: []api.MinionListener{
+			{Name: "NXOS", Port: 35001, Parser: "NxosGrpcParser", Properties: map[string]string{"tls-cert-path": certPath, "tls-key-path": keyPath}},
+		},
+	}
+	assert.NilError(t, module.Start(config, sink))
+	defer module.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
+	conn, err := grpc.DialContext(ctx, "127.0.0.1:35001", grpc.WithTransportCredentials(creds), grpc.WithBlock())
+	assert.NilError(t, err)
+	defer conn.Close()
+	stream, err := mdt_dialout.NewGRPCMdtDialoutClient(conn).MdtDialout(ctx)
+	assert.NilError(t, err)
+	assert.NilError(t, stream.Send(&mdt_dialout.MdtDialoutArgs{ReqId: 1, Data: []byte("telemetry")}))
+	messages := sink.WaitForMessages(1, 2*time.Second)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "NXOS", messages[0].ModuleId)
+	stream.CloseSend()
+
+	// Plain-text clients are rejected
+	plainCtx, plainCancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer plainCancel()
+	_, err = grpc.DialContext(plainCtx, "127.0.0.1:35001", grpc.WithInsecure(), grpc.WithBlock())
+	assert.Assert(t, err != nil)
+}
+
+func TestNxosGrpcModuleWithMultipleListeners(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &NxosGrpcModule{}
+	config := &api.MinionConfig{
+		ID:       "minion1",
+		Location: "Test",
+		Listeners: []api.MinionListener{
+			{Name: "NXOS", Port: 35002, Parser: "NxosGrpcParser", Properties: map[string]string{"shutdown-timeout-ms": "100"}},
+			{Name: "NXOS-Fabric2", Port: 35003, Parser: "NxosGrpcParser", Properties: map[string]string{"workers": "2", "shutdown-timeout-ms": "100"}},
+		},
+	}
+	assert.NilError(t, module.Start(config, sink))
+	assert.Equal(t, 2, len(module.servers))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, port := range []int{35002, 35003} {
+		conn, err := grpc.DialContext(ctx, fmt.Sprintf("127.0.0.1:%d", port), grpc.WithInsecure(), grpc.WithBlock())
+		assert.NilError(t, err)
+		defer conn.Close()
+		stream, err := mdt_dialout.NewGRPCMdtDialoutClient(conn).MdtDialout(ctx)
+		assert.NilError(t, err)
+		assert.NilError(t, stream.Send(&mdt_dialout.MdtDialoutArgs{ReqId: 1, Data: []byte("telemetry")}))
+	}
+	assert.Equal(t, 2, len(sink.WaitForMessages(2, 2*time.Second)))
+
+	module.Stop()
+	assert.Equal(t, 0, len(module.servers))
+	_, err := grpc.DialContext(ctx, "127.0.0.1:35003", grpc.WithInsecure(), grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
+	assert.Assert(t, err != nil)
+
+	// A failure on any listener stops the servers already started
+	config.Listeners[1].Properties = map[string]string{"tls-cert-path": "/missing.crt"}
+	assert.ErrorContains(t, module.Start(config, sink), "requires both")
+	assert.Equal(t, 0, len(module.servers))
+}
+
+func TestNxosGrpcModuleWithReflection(t *testing.T) {
+	module := &NxosGrpcModule{}
+	config := &api.MinionConfig{
+		ID:       "minion1",
+		Location: "Test",
+		Listeners: []api.MinionListener{
+			{Name: "NXOS", Port: 35006, Parser: "NxosGrpcParser", Properties: map[string]string{"grpc-reflection": "true", "shutdown-timeout-ms": "100"}},
+			{Name: "NXOS-Plain", Port: 35007, Parser: "NxosGrpcParser", Properties: map[string]string{"shutdown-timeout-ms": "100"}},
+		},
+	}
+	assert.NilError(t, module.Start(config, &api.MockBroker{}))
+	defer module.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, "127.0.0.1:35006", grpc.WithInsecure(), grpc.WithBlock())
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	healthClient := grpc_health_v1.NewHealthClient(conn)
+	for _, service := range []string{"", "mdt_dialout.gRPCMdtDialout"} {
+		res, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		assert.NilError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, res.Status)
+	}
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	assert.NilError(t, err)
+	assert.NilError(t, stream.Send(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}}))
+	res, err := stream.Recv()
+	assert.NilError(t, err)
+	services := make(map[string]bool)
+	for _, service := range res.GetListServicesResponse().GetService() {
+		services[service.Name] = true
+	}
+	assert.Assert(t, services["mdt_dialout.gRPCMdtDialout"])
+	assert.Assert(t, services["grpc.health.v1.Health"])
+
+	// Without the property, the services are not exposed
+	plain, err := grpc.DialContext(ctx, "127.0.0.1:35007", grpc.WithInsecure(), grpc.WithBlock())
+	assert.NilError(t, err)
+	defer plain.Close()
+	_, err = grpc_health_v1.NewHealthClient(plain).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	// The health service reports NOT_SERVING while the server is stopping
+	module.servers[0].health.Shutdown()
+	check, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.NilError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, check.Status)
+}
+
+// blockingSink blocks every Send until released
+type blockingSink struct {
+	api.MockBroker
+	release chan struct{}
+}
+
+func (sink *blockingSink) Send(msg *ipc.SinkMessage) error {
+	<-sink.release
+	return sink.MockBroker.Send(msg)
+}
+
+func TestNxosGrpcModuleGracefulStop(t *testing.T) {
+	start := func(port int, sink api.Sink) (*NxosGrpcModule, mdt_dialout.GRPCMdtDialout_MdtDialoutClient) {
+		module := &NxosGrpcModule{}
+		config := &api.MinionConfig{
+			ID:       "minion1",
+			Location: "Test",
+			Listeners: []api.MinionListener{
+				{Name: "NXOS", Port: port, Parser: "NxosGrpcParser", Properties: map[string]string{"workers": "1", "shutdown-timeout-ms": "300"}},
+			},
+		}
+		assert.NilError(t, module.Start(config, sink))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+		conn, err := grpc.DialContext(ctx, fmt.Sprintf("127.0.0.1:%d", port), grpc.WithInsecure(), grpc.WithBlock())
+		assert.NilError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		stream, err := mdt_dialout.NewGRPCMdtDialoutClient(conn).MdtDialout(ctx)
+		assert.NilError(t, err)
+		return module, stream
+	}
+
+	// The queued messages are forwarded before stopping
+	sink := &blockingSink{release: make(chan struct{})}
+	module, stream := start(35004, sink)
+	for i := 1; i <= 3; i++ {
+		assert.NilError(t, stream.Send(&mdt_dialout.MdtDialoutArgs{ReqId: int64(i), Data: []byte("telemetry")}))
+	}
+	stream.CloseSend()
+	time.Sleep(100 * time.Millisecond)
+	time.AfterFunc(100*time.Millisecond, func() { close(sink.release) })
+	module.Stop()
+	assert.Equal(t, 3, len(sink.GetMessages()))
+
+	// The sessions still open are closed after the timeout
+	mock := &api.MockBroker{}
+	module, stream = start(35005, mock)
+	assert.NilError(t, stream.Send(&mdt_dialout.MdtDialoutArgs{ReqId: 1, Data: []byte("telemetry")}))
+	assert.Equal(t, 1, len(mock.WaitForMessages(1, time.Second)))
+	begin := time.Now()
+	module.Stop()
+	elapsed := time.Since(begin)
+	assert.Assert(t, elapsed >= 300*time.Millisecond && elapsed < 2*time.Second, "stopped after %s", elapsed)
+}
+
+func TestNxosGrpcModuleBackpressure(t *testing.T) {
+	metrics := api.NewMetrics()
+	SetMetrics(metrics)
+	defer SetMetrics(nil)
+	sink := &blockingSink{release: make(chan struct{})}
+	module := &nxosServer{module: &NxosGrpcModule{sink: sink, config: &api.MinionConfig{ID: "minion1", Location: "Test"}}, listener: "NXOS"}
+	module.startWorkers(1, 1)
+	defer module.stopWorkers()
+
+	dropped := testutil.ToFloat64(sinkMsgDropped.WithLabelValues("minion1", "NXOS"))
+	module.enqueue(nxosMessage{ipaddr: "10.0.0.1", data: []byte("first")}) // Taken by the worker, which blocks on Send
+	time.Sleep(50 * time.Millisecond)
+	module.enqueue(nxosMessage{ipaddr: "10.0.0.1", data: []byte("second")}) // Queued
+	module.enqueue(nxosMessage{ipaddr: "10.0.0.1", data: []byte("third")})  // Dropped
+	assert.Equal(t, dropped+1, testutil.ToFloat64(sinkMsgDropped.WithLabelValues("minion1", "NXOS")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TelemetryPacketsDropped.WithLabelValues("minion1", "NXOS", NxosGrpcParser)))
+
+	close(sink.release)
+	assert.Equal(t, 2, len(sink.WaitForMessages(2, time.Second)))
+}
+
+// fakeDialoutStream returns the given messages, and then the given error
+type fakeDialoutStream struct {
+	grpc.ServerStream
+	messages []*mdt_dialout.MdtDialoutArgs
+	err      error
+}
+
+func (stream *fakeDialoutStream) Context() context.Context {
+	return context.Background()
+}
+
+func (stream *fakeDialoutStream) Recv() (*mdt_dialout.MdtDialoutArgs, error) {
+	if len(stream.messages) == 0 {
+		return nil, stream.err
+	}
+	msg := stream.messages[0]
+	stream.messages = stream.messages[1:]
+	return msg, nil
+}
+
+func (stream *fakeDialoutStream) Send(*mdt_dialout.MdtDialoutArgs) error {
+	return nil
+}
+
+func TestNxosMdtDialoutTermination(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &nxosServer{module: &NxosGrpcModule{sink: sink, config: &api.MinionConfig{ID: "minion1", Location: "Test"}}, listener: "NXOS"}
+	module.startWorkers(10, 1)
+	defer module.stopWorkers()
+
+	run := func(stream *fakeDialoutStream) error {
+		result := make(chan error, 1)
+		go func() {
+			result <- module.MdtDialout(stream)
+		}()
+		select {
+		case err := <-result:
+			return err
+		case <-time.After(time.Second):
+			t.Fatal("the dialout handler didn't exit")
+			return nil
+		}
+	}
+
+	err := run(&fakeDialoutStream{
+		messages: []*mdt_dialout.MdtDialoutArgs{
+			{ReqId: 1, Errors: "sensor path not found"}, // Reported by the device, the stream continues
+			{ReqId: 2, Data: []byte("telemetry")},
+		},
+		err: io.EOF,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(sink.WaitForMessages(1, time.Second)))
+
+	err = run(&fakeDialoutStream{err: fmt.Errorf("connection reset")})
+	assert.ErrorContains(t, err, "connection reset")
+}
@@ -0,0 +1,218 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The default interval between PDH samples
+const defaultPdhInterval = 60 * time.Second
+
+// errPdhUnsupported is returned when the performance counters are not available on the platform
+var errPdhUnsupported = errors.New("performance counters are only supported on Windows")
+
+// PDH samples forwarded to OpenNMS, and failed attempts to sample the counters
+var (
+	pdhSamplesForwarded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "onms_pdh_samples_forwarded",
+		Help: "The total number of Windows performance counter samples forwarded per listener",
+	}, []string{"listener"})
+	pdhCollectionErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "onms_pdh_collection_errors",
+		Help: "The total number of failed attempts to sample the Windows performance counters per listener",
+	}, []string{"listener"})
+)
+
+func init() {
+	prometheus.MustRegister(pdhSamplesForwarded, pdhCollectionErrors)
+}
+
+// pdhSample represents the value of a performance counter
+type pdhSample struct {
+	path  string
+	value float64
+}
+
+// pdhQuery represents a query for a set of performance counters (implemented per platform)
+type pdhQuery interface {
+	collect() ([]pdhSample, error)
+	close()
+}
+
+// PdhModule represents the Windows performance counters forwarder
+// It periodically samples the PDH counters defined on the listener, and forwards them to OpenNMS as Graphite lines via the Telemetry Sink API
+type PdhModule struct {
+	name     string
+	sink     api.Sink
+	config   *api.MinionConfig
+	listener *api.MinionListener
+	query    pdhQuery
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// GetID gets the ID of the sink module
+func (module *PdhModule) GetID() string {
+	return module.name
+}
+
+// GetParsers gets the parsers supported by the sink module
+func (module *PdhModule) GetParsers() []string {
+	return []string{UDPForwardParser}
+}
+
+// Start initiates the sampling of the performance counters.
+// The counters are taken from the counters property of the listener (separated by |), and sampled every interval-ms (defaults to 60 seconds).
+// On platforms other than Windows, the module does nothing.
+func (module *PdhModule) Start(config *api.MinionConfig, sink api.Sink) error {
+	module.listener = config.GetListener(module.name)
+	if module.listener == nil || !module.listener.Is(UDPForwardParser) {
+		log.Warnf("PDH Module %s disabled", module.name)
+		return nil
+	}
+	counters := module.getCounters()
+	if len(counters) == 0 {
+		return fmt.Errorf("%s requires at least one performance counter", module.name)
+	}
+	query, err := newPdhQuery(counters)
+	if err == errPdhUnsupported {
+		log.Warnf("PDH Module %s disabled: %v", module.name, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot initialize performance counters: %v", err)
+	}
+
+	module.sink = sink
+	module.config = config
+	module.query = query
+	setRateLimit("Telemetry-"+module.listener.Name, module.listener)
+	module.stop = make(chan struct{})
+	module.done = make(chan struct{})
+	interval := module.getInterval()
+	log.Infof("Starting %s, sampling %d performance counters every %s", module.name, len(counters), interval)
+	go func(stop chan struct{}, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				module.sample()
+			case <-stop:
+				return
+			}
+		}
+	}(module.stop, module.done)
+	return nil
+}
+
+// Stop shutdowns the sink module
+func (module *PdhModule) Stop() {
+	log.Warnf("Stopping %s", module.name)
+	if module.stop != nil {
+		close(module.stop)
+		<-module.done
+		module.stop = nil
+	}
+	if module.query != nil {
+		module.query.close()
+		module.query = nil
+	}
+}
+
+// Samples the counters and forwards them to OpenNMS
+func (module *PdhModule) sample() {
+	defer recoverPanic(module.config, module.name)
+	samples, err := module.query.collect()
+	if err != nil {
+		log.Errorf("%s cannot sample performance counters: %v", module.name, err)
+		pdhCollectionErrors.WithLabelValues(module.name).Inc()
+		return
+	}
+	lines := formatPdhSamples(module.getProperty("prefix", "windows"), samples, time.Now())
+	if len(lines) == 0 {
+		return
+	}
+	sourceAddress := module.getProperty("source-address", module.config.GetBindAddress(module.listener))
+	if sourceAddress == "" {
+		sourceAddress = "127.0.0.1"
+	}
+	if err := sendEncoded("Telemetry-"+module.listener.Name, module.listener.Name, module.listener.GetParser(), module.config, module.sink, sourceAddress, 0, lines); err == nil {
+		pdhSamplesForwarded.WithLabelValues(module.name).Add(float64(len(lines)))
+	}
+}
+
+// Gets the counter paths from the listener properties
+func (module *PdhModule) getCounters() []string {
+	counters := make([]string, 0)
+	for _, c := range strings.Split(module.listener.Properties["counters"], "|") {
+		if c = strings.TrimSpace(c); c != "" {
+			counters = append(counters, c)
+		}
+	}
+	return counters
+}
+
+// Gets the interval between samples from the listener properties
+func (module *PdhModule) getInterval() time.Duration {
+	if value, ok := module.listener.Properties["interval-ms"]; ok {
+		if v, err := strconv.Atoi(value); err == nil && v > 0 {
+			return time.Duration(v) * time.Millisecond
+		}
+		log.Warnf("Invalid %s interval %s, using %s", module.name, value, defaultPdhInterval)
+	}
+	return defaultPdhInterval
+}
+
+func (module *PdhModule) getProperty(property string, defaultValue string) string {
+	if value, ok := module.listener.Properties[property]; ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Builds the Graphite lines for the samples
+func formatPdhSamples(prefix string, samples []pdhSample, now time.Time) [][]byte {
+	lines := make([][]byte, 0, len(samples))
+	for _, s := range samples {
+		line := fmt.Sprintf("%s.%s %s %d", prefix, getPdhMetricName(s.path), strconv.FormatFloat(s.value, 'f', -1, 64), now.Unix())
+		lines = append(lines, []byte(line))
+	}
+	return lines
+}
+
+var pdhInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Converts a counter path to a Graphite metric name.
+// For instance, \Processor(_Total)\% Processor Time becomes processor.total.pct_processor_time
+func getPdhMetricName(path string) string {
+	if strings.HasPrefix(path, `\\`) {
+		// Remove the computer name of a path like \\HOST\Object\Counter
+		path = path[2:]
+		if i := strings.Index(path, `\`); i >= 0 {
+			path = path[i:]
+		}
+	}
+	name := strings.ToLower(path)
+	name = strings.ReplaceAll(name, "%", "pct")
+	name = strings.ReplaceAll(name, "(", ".")
+	name = strings.ReplaceAll(name, ")", "")
+	name = strings.ReplaceAll(name, `\`, ".")
+	name = pdhInvalidChars.ReplaceAllString(name, "_")
+	segments := make([]string, 0)
+	for _, s := range strings.Split(name, ".") {
+		if s = strings.Trim(s, "_"); s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return strings.Join(segments, ".")
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package sink
+
+// Performance counters are not available outside Windows
+func newPdhQuery(paths []string) (pdhQuery, error) {
+	return nil, errPdhUnsupported
+}
@@ -0,0 +1,45 @@
+package sink
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"gotest.tools/v3/assert"
+)
+
+func TestGetPdhMetricName(t *testing.T) {
+	assert.Equal(t, "processor.total.pct_processor_time", getPdhMetricName(`\Processor(_Total)\% Processor Time`))
+	assert.Equal(t, "memory.available_mbytes", getPdhMetricName(`\\SERVER01\Memory\Available MBytes`))
+	assert.Equal(t, "logicaldisk.c.pct_free_space", getPdhMetricName(`\LogicalDisk(C:)\% Free Space`))
+}
+
+func TestFormatPdhSamples(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	lines := formatPdhSamples("windows", []pdhSample{
+		{path: `\Processor(_Total)\% Processor Time`, value: 12.5},
+		{path: `\Memory\Available MBytes`, value: 2048},
+	}, now)
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "windows.processor.total.pct_processor_time 12.5 1600000000", string(lines[0]))
+	assert.Equal(t, "windows.memory.available_mbytes 2048 1600000000", string(lines[1]))
+	for _, line := range lines {
+		assert.NilError(t, parseGraphiteLine(string(line)))
+	}
+}
+
+func TestPdhModuleStart(t *testing.T) {
+	module := &PdhModule{name: "Windows-PDH"}
+	config := &api.MinionConfig{
+		Listeners: []api.MinionListener{{Name: "Windows-PDH", Parser: "ForwardParser"}},
+	}
+	assert.ErrorContains(t, module.Start(config, nil), "requires at least one performance counter")
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	config.Listeners[0].Properties = map[string]string{"counters": `\Processor(_Total)\% Processor Time`}
+	assert.NilError(t, module.Start(config, nil))
+	module.Stop()
+}
@@ -0,0 +1,92 @@
+//go:build windows
+// +build windows
+
+package sink
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Functions from the Performance Data Helper library
+var (
+	pdhLibrary                  = windows.NewLazySystemDLL("pdh.dll")
+	pdhOpenQuery                = pdhLibrary.NewProc("PdhOpenQueryW")
+	pdhAddEnglishCounter        = pdhLibrary.NewProc("PdhAddEnglishCounterW")
+	pdhCollectQueryData         = pdhLibrary.NewProc("PdhCollectQueryData")
+	pdhGetFormattedCounterValue = pdhLibrary.NewProc("PdhGetFormattedCounterValue")
+	pdhCloseQuery               = pdhLibrary.NewProc("PdhCloseQuery")
+)
+
+const (
+	pdhFmtDouble   = 0x00000200
+	pdhFmtNoCap100 = 0x00008000
+	pdhCStatusOK   = 0x00000000 // PDH_CSTATUS_VALID_DATA
+	pdhCStatusNew  = 0x00000001 // PDH_CSTATUS_NEW_DATA
+)
+
+// pdhFmtCounterValueDouble represents a PDH_FMT_COUNTERVALUE with a double value
+type pdhFmtCounterValueDouble struct {
+	CStatus     uint32
+	_           uint32 // The union is aligned to 8 bytes
+	DoubleValue float64
+}
+
+// windowsPdhQuery represents an open PDH query
+type windowsPdhQuery struct {
+	handle   uintptr
+	paths    []string
+	counters []uintptr
+}
+
+// Opens a PDH query with the given counter paths, in English regardless of the system language.
+// The query is collected once, as rate counters need two samples.
+func newPdhQuery(paths []string) (pdhQuery, error) {
+	if err := pdhLibrary.Load(); err != nil {
+		return nil, err
+	}
+	query := &windowsPdhQuery{}
+	if ret, _, _ := pdhOpenQuery.Call(0, 0, uintptr(unsafe.Pointer(&query.handle))); ret != 0 {
+		return nil, fmt.Errorf("cannot open query: PDH error 0x%x", ret)
+	}
+	for _, path := range paths {
+		p, err := windows.UTF16PtrFromString(path)
+		if err != nil {
+			query.close()
+			return nil, fmt.Errorf("invalid counter %s: %v", path, err)
+		}
+		var counter uintptr
+		if ret, _, _ := pdhAddEnglishCounter.Call(query.handle, uintptr(unsafe.Pointer(p)), 0, uintptr(unsafe.Pointer(&counter))); ret != 0 {
+			query.close()
+			return nil, fmt.Errorf("cannot add counter %s: PDH error 0x%x", path, ret)
+		}
+		query.paths = append(query.paths, path)
+		query.counters = append(query.counters, counter)
+	}
+	pdhCollectQueryData.Call(query.handle)
+	return query, nil
+}
+
+// Collects the query, and returns the counters with valid data
+func (query *windowsPdhQuery) collect() ([]pdhSample, error) {
+	if ret, _, _ := pdhCollectQueryData.Call(query.handle); ret != 0 {
+		return nil, fmt.Errorf("cannot collect query: PDH error 0x%x", ret)
+	}
+	samples := make([]pdhSample, 0, len(query.counters))
+	for i, counter := range query.counters {
+		var value pdhFmtCounterValueDouble
+		ret, _, _ := pdhGetFormattedCounterValue.Call(counter, pdhFmtDouble|pdhFmtNoCap100, 0, uintptr(unsafe.Pointer(&value)))
+		if ret != 0 || (value.CStatus != pdhCStatusOK && value.CStatus != pdhCStatusNew) {
+			continue
+		}
+		samples = append(samples, pdhSample{path: query.paths[i], value: value.DoubleValue})
+	}
+	return samples, nil
+}
+
+// Closes the query and its counters
+func (query *windowsPdhQuery) close() {
+	pdhCloseQuery.Call(query.handle)
+}
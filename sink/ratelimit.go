@@ -0,0 +1,91 @@
+package sink
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Sink messages discarded because the module exceeded its rate limit
+var sinkMsgRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "onms_sink_messages_rate_limited",
+	Help: "The total number of Sink messages discarded per module because of its rate limit",
+}, []string{"minion", "module"})
+
+// errRateLimited is returned by sendBytes when a message is discarded by the rate limiter of its module
+var errRateLimited = errors.New("rate limit exceeded")
+
+// The rate limiters per Sink module ID
+var (
+	rateLimiters      = make(map[string]*rateLimiter)
+	rateLimitersMutex sync.RWMutex
+)
+
+// rateLimiter is a token bucket that allows up to rate messages per second, with bursts of up to a second worth of messages (at least one).
+// It is safe to use from multiple goroutines.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	burst := math.Max(rate, 1)
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Takes a token from the bucket; returns false when there are none available
+func (limiter *rateLimiter) allow() bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	now := time.Now()
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.last = now
+	if limiter.tokens < 1 {
+		return false
+	}
+	limiter.tokens--
+	return true
+}
+
+// Configures the rate limiter of a Sink module from the rate-limit property of its listener, in messages per second.
+// The limiter is removed when the property is not set or invalid.
+func setRateLimit(moduleID string, listener *api.MinionListener) {
+	rateLimitersMutex.Lock()
+	defer rateLimitersMutex.Unlock()
+	delete(rateLimiters, moduleID)
+	value, ok := listener.Properties["rate-limit"]
+	if !ok {
+		return
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 {
+		log.Warnf("Ignoring invalid rate-limit %s for listener %s", value, listener.Name)
+		return
+	}
+	log.Infof("Limiting %s to %g messages per second", moduleID, rate)
+	rateLimiters[moduleID] = newRateLimiter(rate)
+}
+
+// Returns true if the module can send a message now; modules without a rate limit are always allowed
+func allowMessage(moduleID string) bool {
+	rateLimitersMutex.RLock()
+	limiter, ok := rateLimiters[moduleID]
+	rateLimitersMutex.RUnlock()
+	return !ok || limiter.allow()
+}
+
+func init() {
+	prometheus.MustRegister(sinkMsgRateLimited)
+}
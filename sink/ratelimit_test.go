@@ -0,0 +1,72 @@
+package sink
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/v3/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(100)
+	var allowed int32
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if limiter.allow() {
+					atomic.AddInt32(&allowed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Assert(t, allowed >= 100 && allowed < 150, "allowed %d", allowed) // The burst, plus the tokens added meanwhile
+
+	limiter = newRateLimiter(0.5)
+	assert.Assert(t, limiter.allow())
+	assert.Assert(t, !limiter.allow())
+}
+
+func TestSendBytesRateLimited(t *testing.T) {
+	sink := new(api.MockBroker)
+	config := &api.MinionConfig{ID: "minion1", Location: "Test"}
+	listener := &api.MinionListener{Name: "Graphite", Properties: map[string]string{"rate-limit": "2"}}
+	setRateLimit("Telemetry-Graphite", listener)
+	defer setRateLimit("Telemetry-Graphite", &api.MinionListener{})
+
+	for i := 0; i < 5; i++ {
+		sendBytes("Telemetry-Graphite", config, sink, []byte("test"))
+	}
+	assert.Equal(t, 2, len(sink.GetMessagesForModule("Telemetry-Graphite")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(sinkMsgRateLimited.WithLabelValues("minion1", "Telemetry-Graphite")))
+
+	time.Sleep(600 * time.Millisecond)
+	assert.NilError(t, sendBytes("Telemetry-Graphite", config, sink, []byte("test")))
+	assert.Equal(t, errRateLimited, sendBytes("Telemetry-Graphite", config, sink, []byte("test")))
+
+	setRateLimit("Telemetry-Graphite", &api.MinionListener{})
+	for i := 0; i < 5; i++ {
+		assert.NilError(t, sendBytes("Telemetry-Graphite", config, sink, []byte("test")))
+	}
+}
+
+func TestSendEncodedRateLimited(t *testing.T) {
+	metrics := api.NewMetrics()
+	SetMetrics(metrics)
+	defer SetMetrics(nil)
+	config := &api.MinionConfig{ID: "minion1", Location: "Test"}
+	setRateLimit("Telemetry-Graphite", &api.MinionListener{Name: "Graphite", Properties: map[string]string{"rate-limit": "1"}})
+	defer setRateLimit("Telemetry-Graphite", &api.MinionListener{})
+
+	for i := 0; i < 3; i++ {
+		sendEncoded("Telemetry-Graphite", "Graphite", UDPForwardParser, config, new(api.MockBroker), "10.0.0.1", 2003, [][]byte{[]byte("test")})
+	}
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.TelemetryPacketsDropped.WithLabelValues("minion1", "Graphite", UDPForwardParser)))
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package sink
+
+import "net"
+
+// The granted size of the receive buffer is unknown on this platform
+func getReadBuffer(conn *net.UDPConn) (int, bool) {
+	return 0, false
+}
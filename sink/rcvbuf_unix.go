@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package sink
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// Gets the size of the receive buffer granted by the kernel, which might be clamped (or doubled on Linux)
+func getReadBuffer(conn *net.UDPConn) (int, bool) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+	var size int
+	var opErr error
+	if err := raw.Control(func(fd uintptr) {
+		size, opErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF)
+	}); err != nil || opErr != nil {
+		return 0, false
+	}
+	return size, true
+}
@@ -12,13 +12,16 @@ func CreateSinkRegistry() *api.SinkRegistry {
 	registry.RegisterModule(&NetflowModule{name: "Netflow-5", goflowID: "NetFlowV5"})
 	registry.RegisterModule(&NetflowModule{name: "Netflow-9", goflowID: "NetFlow"})
 	registry.RegisterModule(&NetflowModule{name: "IPFIX", goflowID: "NetFlow"})
-	registry.RegisterModule(&NetflowModule{name: "SFlow", goflowID: "sFlow"})
+	registry.RegisterModule(&NetflowModule{name: "IPFIX-TCP", goflowID: "NetFlow"})
+	registry.RegisterModule(&SFlowModule{})
 
 	registry.RegisterModule(&HeartbeatModule{})
 	registry.RegisterModule(&NxosGrpcModule{})
 	registry.RegisterModule(&SyslogModule{})
 	registry.RegisterModule(&SnmpTrapModule{})
 	registry.RegisterModule(&UDPForwardModule{name: "Graphite"})
+	registry.RegisterModule(&GraphiteTCPModule{name: "Graphite-TCP"})
+	registry.RegisterModule(&PdhModule{name: "Windows-PDH"})
 
 	return registry
 }
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package sink
+
+import "syscall"
+
+// Without SO_REUSEPORT, all the workers share a single socket
+const reusePortAvailable = false
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return nil
+}
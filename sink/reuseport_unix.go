@@ -0,0 +1,24 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package sink
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// SO_REUSEPORT allows multiple sockets to bind to the same UDP port, with the kernel balancing datagrams across them
+const reusePortAvailable = true
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var opErr error
+	err := c.Control(func(fd uintptr) {
+		opErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
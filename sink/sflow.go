@@ -0,0 +1,62 @@
+package sink
+
+import (
+	"net"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+)
+
+// SFlowModule represents the sFlow receiver module
+// It starts a UDP Listener, and forwards the received datagrams to OpenNMS without alteration, as the parsing happens on the OpenNMS side.
+// The telemetry messages don't carry a content type; OpenNMS selects the sFlow adapter through the queue, which is the name of the listener.
+type SFlowModule struct {
+	sink     api.Sink
+	config   *api.MinionConfig
+	listener *api.MinionListener
+	server   *udpServer
+}
+
+// GetID gets the ID of the sink module
+func (module *SFlowModule) GetID() string {
+	return "SFlow"
+}
+
+// GetParsers gets the parsers supported by the sink module
+func (module *SFlowModule) GetParsers() []string {
+	return []string{UDPSFlowParser}
+}
+
+// Start initiates an sFlow UDP receiver for the listener whose parser is SFlowUdpParser
+func (module *SFlowModule) Start(config *api.MinionConfig, sink api.Sink) error {
+	module.listener = config.GetListenerByParser(UDPSFlowParser)
+	if module.listener == nil {
+		log.Warnf("Flow Module %s disabled", module.GetID())
+		return nil
+	}
+
+	var err error
+	module.sink = sink
+	module.config = config
+	setRateLimit("Telemetry-"+module.listener.Name, module.listener)
+
+	log.Infof("Starting %s flow receiver on port UDP %d", module.listener.Name, module.listener.Port)
+	if module.server, err = newUDPServer(module.config, module.listener.Name, module.config.GetBindAddress(module.listener), getListenerIPVersion(module.listener), module.listener.Port, getListenerWorkers(module.config, module.listener), getListenerReadBuffer(module.listener)); err != nil {
+		return err
+	}
+	module.server.serve(65535, func(pktAddr *net.UDPAddr, data []byte) {
+		log.Debugf("Received %d bytes from %s", len(data), pktAddr)
+		countReceived(module.config, module.listener.Name, UDPSFlowParser, len(data))
+		messages := [][]byte{data}
+		sendEncoded("Telemetry-"+module.listener.Name, module.listener.Name, UDPSFlowParser, module.config, module.sink, pktAddr.IP.String(), uint32(pktAddr.Port), messages)
+	})
+	return nil
+}
+
+// Stop shutdowns the sink module
+func (module *SFlowModule) Stop() {
+	log.Warnf("Stopping %s flow receiver", module.GetID())
+	if module.server != nil {
+		module.server.stop()
+	}
+}
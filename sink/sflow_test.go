@@ -0,0 +1,44 @@
+package sink
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/protobuf/telemetry"
+
+	"google.golang.org/protobuf/proto"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSFlowModule(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &SFlowModule{}
+	config := &api.MinionConfig{
+		ID:       "minion1",
+		Location: "Test",
+		Listeners: []api.MinionListener{
+			{Name: "sFlow-Core", Port: 36343, Parser: "org.opennms.netmgt.telemetry.protocols.sflow.parser.SFlowUdpParser"},
+		},
+	}
+	assert.NilError(t, module.Start(config, sink))
+	defer module.Stop()
+
+	datagram := []byte{0, 0, 0, 5, 0, 0, 0, 1, 10, 0, 0, 1}
+	conn, err := net.Dial("udp", "127.0.0.1:36343")
+	assert.NilError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(datagram)
+	assert.NilError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	messages := sink.GetMessages()
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "Telemetry-sFlow-Core", messages[0].ModuleId)
+	logMsg := &telemetry.TelemetryMessageLog{}
+	assert.NilError(t, proto.Unmarshal(messages[0].Content, logMsg))
+	assert.Equal(t, "127.0.0.1", logMsg.GetSourceAddress())
+	assert.DeepEqual(t, datagram, logMsg.Message[0].Bytes)
+}
@@ -1,23 +1,38 @@
 package sink
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
 	"encoding/base64"
 	"fmt"
+	"io"
+	"net"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/log"
-	"gopkg.in/mcuadros/go-syslog.v2"
 )
 
-// SyslogModule represents the heartbeat module
+// The maximum number of Syslog datagrams waiting to be forwarded
+const syslogQueueSize = 1024
+
+// The maximum size of a message received via TCP
+const syslogMaxMessageSize = 64 * 1024
+
+// The maximum number of digits of the octet count of a message, which is enough for the largest message
+const syslogMaxOctetCountDigits = 5
+
+// SyslogModule represents the Syslog receiver module
 type SyslogModule struct {
-	sink    api.Sink
-	config  *api.MinionConfig
-	server  *syslog.Server
-	channel syslog.LogPartsChannel
+	sink     api.Sink
+	config   *api.MinionConfig
+	conn     *net.UDPConn
+	queue    chan *api.SyslogMessageLogDTO
+	tcp      *tcpServer
+	stopping bool
 }
 
 // GetID gets the ID of the sink module
@@ -25,70 +40,302 @@ func (module *SyslogModule) GetID() string {
 	return "Syslog"
 }
 
-// Start initiates a Syslog UDP and TCP receiver
+// Start initiates a Syslog UDP and TCP receiver, or only one of them based on the protocol property of the Syslog listener (udp, tcp or tls).
+// Messages are forwarded as received, so both RFC3164 and RFC5424 messages are supported; via TCP, they are framed as defined by RFC6587.
 func (module *SyslogModule) Start(config *api.MinionConfig, sink api.Sink) error {
 	if config.SyslogPort == 0 {
 		log.Warnf("Syslog Module disabled")
 		return nil
 	}
 
-	log.Infof("Starting Syslog receiver on port UDP/TCP %d", config.SyslogPort)
-
 	module.config = config
 	module.sink = sink
+	module.stopping = false
 
-	listenAddr := fmt.Sprintf("0.0.0.0:%d", config.SyslogPort)
-	module.channel = make(syslog.LogPartsChannel)
-	module.server = syslog.NewServer()
-	module.server.SetFormat(syslog.Automatic)
-	module.server.SetHandler(syslog.NewChannelHandler(module.channel))
-	if err := module.server.ListenUDP(listenAddr); err != nil {
-		return fmt.Errorf("cannot start Syslog UDP listener: %s", err)
-	}
-	if err := module.server.ListenTCP(listenAddr); err != nil {
-		return fmt.Errorf("cannot start Syslog TCP listener: %s", err)
-	}
-	if err := module.server.Boot(); err != nil {
-		return fmt.Errorf("cannot boot Syslog server: %s", err)
-	}
-	go func(channel syslog.LogPartsChannel) {
-		for logParts := range channel {
-			if messageLog := module.buildMessageLog(logParts); messageLog != nil {
-				sendXMLResponse(module.GetID(), module.config, module.sink, messageLog)
+	protocol := module.getProtocol()
+	switch protocol {
+	case "":
+		log.Infof("Starting Syslog receiver on port UDP/TCP %d", config.SyslogPort)
+		if err := module.startUDPListener(); err != nil {
+			return fmt.Errorf("cannot start Syslog UDP listener: %s", err)
+		}
+		if err := module.startTCPListener(nil); err != nil {
+			module.stopping = true
+			module.conn.Close()
+			return fmt.Errorf("cannot start Syslog TCP listener: %s", err)
+		}
+	case "udp":
+		log.Infof("Starting Syslog receiver on port UDP %d", config.SyslogPort)
+		if err := module.startUDPListener(); err != nil {
+			return fmt.Errorf("cannot start Syslog UDP listener: %s", err)
+		}
+	case "tcp", "tls":
+		var tlsConfig *tls.Config
+		if protocol == "tls" {
+			var err error
+			if tlsConfig, err = getServerTLSConfig("Syslog", module.getListener()); err != nil {
+				return err
 			}
+			if tlsConfig == nil {
+				return fmt.Errorf("Syslog listener requires tls-cert-path and tls-key-path when the protocol is tls")
+			}
+		}
+		log.Infof("Starting Syslog receiver on port %s %d", strings.ToUpper(protocol), config.SyslogPort)
+		if err := module.startTCPListener(tlsConfig); err != nil {
+			return fmt.Errorf("cannot start Syslog %s listener: %s", strings.ToUpper(protocol), err)
 		}
-	}(module.channel)
+	default:
+		return fmt.Errorf("invalid Syslog protocol %s, expected udp, tcp or tls", protocol)
+	}
 	return nil
 }
 
+// Forwards a message, recovering from a panic so the receiver keeps processing messages
+func (module *SyslogModule) send(messageLog *api.SyslogMessageLogDTO) {
+	defer recoverPanic(module.config, module.GetID())
+	sendXMLResponse(module.GetID(), module.config, module.sink, messageLog)
+}
+
 // Stop shutdowns the sink module
 func (module *SyslogModule) Stop() {
 	log.Warnf("Stopping Syslog receiver")
-	if module.server != nil {
-		close(module.channel)
-		module.server.Kill()
+	module.stopping = true
+	if module.conn != nil {
+		module.conn.Close()
+	}
+	if module.tcp != nil {
+		module.tcp.stop()
 	}
 }
 
-func (module *SyslogModule) buildMessageLog(logParts map[string]interface{}) *api.SyslogMessageLogDTO {
-	if logParts["content"].(string) == "X" {
-		return nil
+// Gets the Syslog listener, which only carries properties, as the port is defined by syslogPort
+func (module *SyslogModule) getListener() *api.MinionListener {
+	if listener := module.config.GetListener(module.GetID()); listener != nil {
+		return listener
+	}
+	return &api.MinionListener{Name: module.GetID()}
+}
+
+// Gets the protocol property of the Syslog listener, or an empty string to receive via both UDP and TCP
+func (module *SyslogModule) getProtocol() string {
+	return strings.ToLower(module.getListener().Properties["protocol"])
+}
+
+// Gets the bind address from the Syslog listener properties or the global configuration
+func (module *SyslogModule) getBindAddress() string {
+	return module.config.GetBindAddress(module.config.GetListener(module.GetID()))
+}
+
+// Starts the UDP listener. Datagrams are queued for delivery, and dropped when the queue is full.
+func (module *SyslogModule) startUDPListener() error {
+	ipVersion := getListenerIPVersion(module.getListener())
+	network, _, err := getUDPAddress(module.getBindAddress(), ipVersion, module.config.SyslogPort)
+	if err != nil {
+		return err
+	}
+	if module.conn, err = createUDPListener(module.getBindAddress(), ipVersion, module.config.SyslogPort); err != nil {
+		return err
+	}
+	log.Infof("Syslog bound to UDP %s (%s)", module.conn.LocalAddr(), describeUDPNetwork(network))
+	if size := module.config.SyslogBufferSize; size > 0 {
+		if err := module.conn.SetReadBuffer(size); err != nil {
+			module.conn.Close()
+			return fmt.Errorf("cannot set receive buffer size to %d: %v", size, err)
+		}
+		log.Infof("Using a Syslog receive buffer of %d bytes", size)
+	}
+	module.queue = make(chan *api.SyslogMessageLogDTO, syslogQueueSize)
+	go func() {
+		for messageLog := range module.queue {
+			module.send(messageLog)
+		}
+	}()
+	go func() {
+		defer close(module.queue)
+		buffer := make([]byte, 65535)
+		for {
+			size, addr, err := module.conn.ReadFromUDP(buffer)
+			if err != nil {
+				if module.stopping {
+					return
+				}
+				log.Errorf("Cannot read Syslog datagram: %v", err)
+				if isFatalListenerError(err) {
+					api.ReportModuleFailure(module.GetID(), err)
+					return
+				}
+				continue
+			}
+			data := make([]byte, size)
+			copy(data, buffer[:size])
+			select {
+			case module.queue <- module.buildRawMessageLog(addr.IP, addr.Port, data):
+			default:
+				log.Warnf("Syslog queue is full, dropping message from %s", addr.IP)
+				sinkMsgDropped.WithLabelValues(module.config.ID, module.GetID()).Inc()
+			}
+		}
+	}()
+	return nil
+}
+
+// Starts the TCP listener, with TLS when the configuration is not nil. Each connection is handled by its own goroutine.
+func (module *SyslogModule) startTCPListener(tlsConfig *tls.Config) error {
+	listenAddr := net.JoinHostPort(module.getBindAddress(), strconv.Itoa(module.config.SyslogPort))
+	var listener net.Listener
+	var err error
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", listenAddr, tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", listenAddr)
+	}
+	if err != nil {
+		return err
+	}
+	module.tcp = newTCPServer(listener)
+	maxConnections := module.getMaxConnections()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if module.stopping {
+					return
+				}
+				log.Errorf("Cannot accept Syslog connection: %v", err)
+				if isFatalListenerError(err) {
+					api.ReportModuleFailure(module.GetID(), err)
+					return
+				}
+				continue
+			}
+			if !module.tcp.add(conn, maxConnections) {
+				log.Warnf("Rejecting Syslog connection from %s, the maximum of %d connections was reached", conn.RemoteAddr(), maxConnections)
+				conn.Close()
+				continue
+			}
+			go module.handleConnection(conn)
+		}
+	}()
+	return nil
+}
+
+// Reads the framed messages of a connection until it is closed, and forwards them
+func (module *SyslogModule) handleConnection(conn net.Conn) {
+	defer recoverPanic(module.config, module.GetID())
+	defer module.tcp.remove(conn)
+	defer conn.Close()
+	addr := conn.RemoteAddr().(*net.TCPAddr)
+	log.Debugf("Accepted Syslog connection from %s", addr)
+	reader := bufio.NewReader(conn)
+	idleTimeout := module.getIdleTimeout()
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		data, err := readSyslogFrame(reader)
+		if err != nil {
+			if err != io.EOF && !module.stopping {
+				log.Warnf("Closing Syslog connection from %s: %v", addr, err)
+			}
+			return
+		}
+		if len(data) > 0 {
+			module.send(module.buildRawMessageLog(addr.IP, addr.Port, data))
+		}
+	}
+}
+
+// Gets the maximum number of concurrent TCP connections from the maxConnections property of the Syslog listener (defaults to 64)
+func (module *SyslogModule) getMaxConnections() int {
+	if value, ok := module.getListener().Properties["maxConnections"]; ok {
+		if c, err := strconv.Atoi(value); err == nil && c > 0 {
+			return c
+		}
+	}
+	return 64
+}
+
+// Gets the time a TCP connection can stay without receiving a message from the idleTimeout property of the Syslog listener, in milliseconds (defaults to 5 minutes)
+func (module *SyslogModule) getIdleTimeout() time.Duration {
+	if value, ok := module.getListener().Properties["idleTimeout"]; ok {
+		if t, err := strconv.Atoi(value); err == nil && t > 0 {
+			return time.Duration(t) * time.Millisecond
+		}
+	}
+	return 5 * time.Minute
+}
+
+// Reads a Syslog message framed as defined by RFC6587.
+// With octet counting, the message is preceded by its length and a space; otherwise, it is terminated by a LF (non-transparent framing), and a trailing CR is removed.
+func readSyslogFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
 	}
-	clientParts := strings.Split(logParts["client"].(string), ":")
-	clientPort, _ := strconv.Atoi(clientParts[1])
+	if first[0] >= '1' && first[0] <= '9' {
+		size, err := readSyslogOctetCount(reader)
+		if err != nil {
+			return nil, err
+		}
+		if size > syslogMaxMessageSize {
+			return nil, fmt.Errorf("message of %d bytes exceeds the maximum of %d bytes", size, syslogMaxMessageSize)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("incomplete message: %v", err)
+		}
+		return data, nil
+	}
+	var data []byte
+	for {
+		line, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			if err == io.EOF && len(data) > 0 {
+				return data, nil // The last message doesn't require a trailer
+			}
+			return nil, err
+		}
+		data = append(data, line...)
+		if len(data) > syslogMaxMessageSize {
+			return nil, fmt.Errorf("message exceeds the maximum of %d bytes", syslogMaxMessageSize)
+		}
+		if !isPrefix {
+			return bytes.TrimRight(data, "\x00"), nil
+		}
+	}
+}
+
+// Reads the octet count that precedes a message and the space after it, without reading more digits than a valid count can have
+func readSyslogOctetCount(reader *bufio.Reader) (int, error) {
+	size := 0
+	for digits := 0; ; digits++ {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("incomplete octet count: %v", err)
+		}
+		if c == ' ' {
+			return size, nil
+		}
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid octet count, unexpected %q", c)
+		}
+		if digits == syslogMaxOctetCountDigits {
+			return 0, fmt.Errorf("octet count exceeds %d digits", syslogMaxOctetCountDigits)
+		}
+		size = size*10 + int(c-'0')
+	}
+}
+
+// Builds the message log for a message received from the given address, without alteration
+func (module *SyslogModule) buildRawMessageLog(ip net.IP, port int, data []byte) *api.SyslogMessageLogDTO {
+	log.Debugf("Received Syslog message from %s", ip)
 	messageLog := &api.SyslogMessageLogDTO{
 		Location:      module.config.Location,
 		SystemID:      module.config.ID,
-		SourceAddress: clientParts[0],
-		SourcePort:    clientPort,
-	}
-	timestamp := logParts["timestamp"].(time.Time)
-	log.Debugf("Received Syslog message from %s", messageLog.SourceAddress)
-	content := fmt.Sprintf("<%d>%s", logParts["priority"].(int), logParts["content"].(string))
-	message := api.SyslogMessageDTO{
-		Timestamp: timestamp.Format(api.TimeFormat),
-		Content:   []byte(base64.StdEncoding.EncodeToString([]byte(content))),
+		SourceAddress: ip.String(),
+		SourcePort:    port,
 	}
-	messageLog.AddMessage(message)
+	messageLog.AddMessage(api.SyslogMessageDTO{
+		Timestamp: time.Now().Format(api.TimeFormat),
+		Content:   []byte(base64.StdEncoding.EncodeToString(data)),
+	})
 	return messageLog
 }
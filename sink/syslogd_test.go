@@ -1,9 +1,16 @@
 package sink
 
 import (
+	"bufio"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,43 +19,163 @@ import (
 	"gotest.tools/v3/assert"
 )
 
-func TestSyslogBuildMessageLog(t *testing.T) {
-	logParts := make(map[string]interface{})
-	logParts["client"] = "127.0.0.1:64557"
-	logParts["content"] = ": 2020 Jul 29 17:07:29 EDT: %ETHPORT-5-IF_DOWN_LINK_FAILURE: Interface eth1 is down (Link failure)"
-	logParts["facility"] = 23
-	logParts["hostname"] = "127.0.0.1"
-	logParts["priority"] = 189
-	logParts["severity"] = 5
-	logParts["tags"] = ""
-	logParts["timestamp"] = time.Now()
-	logParts["tls_peer"] = ""
-
-	module := &SyslogModule{
-		config: &api.MinionConfig{
-			ID:       "minion1",
-			Location: "Test",
-		},
+func TestSyslogUDPListener(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &SyslogModule{}
+	config := &api.MinionConfig{
+		ID:               "minion1",
+		Location:         "Test",
+		SyslogPort:       31514,
+		SyslogBufferSize: 65536,
 	}
+	assert.NilError(t, module.Start(config, sink))
+	defer module.Stop()
 
-	logMsg := module.buildMessageLog(logParts)
-	bytes, err := xml.Marshal(logMsg)
+	message := "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8"
+	conn, err := net.Dial("udp", "127.0.0.1:31514")
 	assert.NilError(t, err)
-	fmt.Println(string(bytes))
+	defer conn.Close()
+	_, err = conn.Write([]byte(message))
+	assert.NilError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	messages := sink.GetMessages()
+	assert.Equal(t, 1, len(messages))
+	logMsg := &api.SyslogMessageLogDTO{}
+	assert.NilError(t, xml.Unmarshal(messages[0].Content, logMsg))
+	assert.Equal(t, "127.0.0.1", logMsg.SourceAddress)
+	decodedMsg, err := base64.StdEncoding.DecodeString(string(logMsg.Messages[0].Content))
+	assert.NilError(t, err)
+	assert.Equal(t, message, string(decodedMsg))
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	counted := "<34>1 2003-10-11T22:14:15.003Z mymachine su - - - 'su root' failed\nfor lonvick"
+	input := fmt.Sprintf("%d %s", len(counted), counted) +
+		"<13>Oct 11 22:14:15 mymachine app: first\r\n" +
+		"\n" +
+		"<13>Oct 11 22:14:15 mymachine app: last"
+	reader := bufio.NewReader(strings.NewReader(input))
+
+	data, err := readSyslogFrame(reader)
+	assert.NilError(t, err)
+	assert.Equal(t, counted, string(data)) // Octet counting allows LF within the message
+
+	data, err = readSyslogFrame(reader)
+	assert.NilError(t, err)
+	assert.Equal(t, "<13>Oct 11 22:14:15 mymachine app: first", string(data))
+
+	data, err = readSyslogFrame(reader)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(data))
+
+	data, err = readSyslogFrame(reader)
+	assert.NilError(t, err)
+	assert.Equal(t, "<13>Oct 11 22:14:15 mymachine app: last", string(data))
+
+	_, err = readSyslogFrame(reader)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = readSyslogFrame(bufio.NewReader(strings.NewReader("99999 <13>too long")))
+	assert.ErrorContains(t, err, "exceeds the maximum")
+
+	_, err = readSyslogFrame(bufio.NewReader(strings.NewReader(strings.Repeat("9", 1<<20))))
+	assert.ErrorContains(t, err, "octet count exceeds 5 digits")
+
+	_, err = readSyslogFrame(bufio.NewReader(strings.NewReader("12a <13>invalid")))
+	assert.ErrorContains(t, err, "invalid octet count")
+}
+
+func TestSyslogTCPIdleTimeout(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &SyslogModule{}
+	config := &api.MinionConfig{
+		ID:         "minion1",
+		Location:   "Test",
+		SyslogPort: 31516,
+		Listeners:  []api.MinionListener{{Name: "Syslog", Properties: map[string]string{"protocol": "tcp", "idleTimeout": "100"}}},
+	}
+	assert.NilError(t, module.Start(config, sink))
+	defer module.Stop()
 
-	if logMsg == nil {
-		t.FailNow()
-	} else {
-		assert.Equal(t, "Test", logMsg.Location)
-		assert.Equal(t, 1, len(logMsg.Messages))
+	conn, err := net.Dial("tcp", "127.0.0.1:31516")
+	assert.NilError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, "12") // An incomplete octet count keeps the frame open
+	assert.NilError(t, err)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err) // Closed by the server after the idle timeout
+}
+
+func TestSyslogTCPListener(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &SyslogModule{}
+	config := &api.MinionConfig{
+		ID:         "minion1",
+		Location:   "Test",
+		SyslogPort: 31515,
+		Listeners:  []api.MinionListener{{Name: "Syslog", Properties: map[string]string{"protocol": "tcp"}}},
+	}
+	assert.NilError(t, module.Start(config, sink))
+	defer module.Stop()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:31515")
+	assert.NilError(t, err)
+	message := "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed"
+	_, err = fmt.Fprintf(conn, "%d %s%s\n", len(message), message, message)
+	assert.NilError(t, err)
+	conn.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	messages := sink.GetMessages()
+	assert.Equal(t, 2, len(messages))
+	for _, msg := range messages {
+		logMsg := &api.SyslogMessageLogDTO{}
+		assert.NilError(t, xml.Unmarshal(msg.Content, logMsg))
+		assert.Equal(t, "127.0.0.1", logMsg.SourceAddress)
 		decodedMsg, err := base64.StdEncoding.DecodeString(string(logMsg.Messages[0].Content))
 		assert.NilError(t, err)
-		expectedMsg := fmt.Sprintf("<%d>%s", logParts["priority"], logParts["content"])
-		fmt.Printf("Expected message: %s\n", expectedMsg)
-		assert.Equal(t, expectedMsg, string(decodedMsg))
+		assert.Equal(t, message, string(decodedMsg))
+	}
+}
+
+func TestSyslogTLSListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeTestCertificate(t, dir)
+
+	module := &SyslogModule{}
+	config := &api.MinionConfig{
+		ID:         "minion1",
+		Location:   "Test",
+		SyslogPort: 31516,
+		Listeners:  []api.MinionListener{{Name: "Syslog", Properties: map[string]string{"protocol": "tls"}}},
 	}
+	assert.ErrorContains(t, module.Start(config, &api.MockBroker{}), "requires tls-cert-path and tls-key-path")
+
+	config.Listeners[0].Properties["protocol"] = "sctp"
+	assert.ErrorContains(t, module.Start(config, &api.MockBroker{}), "invalid Syslog protocol sctp")
 
-	logParts["content"] = "X"
-	logMsg = module.buildMessageLog(logParts)
-	assert.Assert(t, logMsg == nil)
+	sink := &api.MockBroker{}
+	config.Listeners[0].Properties = map[string]string{"protocol": "tls", "tls-cert-path": certPath, "tls-key-path": keyPath}
+	assert.NilError(t, module.Start(config, sink))
+	defer module.Stop()
+
+	conn, err := tls.Dial("tcp", "127.0.0.1:31516", &tls.Config{InsecureSkipVerify: true})
+	assert.NilError(t, err)
+	message := "<13>Oct 11 22:14:15 mymachine app: encrypted"
+	_, err = fmt.Fprintf(conn, "%d %s", len(message), message)
+	assert.NilError(t, err)
+	conn.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	messages := sink.GetMessages()
+	assert.Equal(t, 1, len(messages))
+	logMsg := &api.SyslogMessageLogDTO{}
+	assert.NilError(t, xml.Unmarshal(messages[0].Content, logMsg))
+	decodedMsg, err := base64.StdEncoding.DecodeString(string(logMsg.Messages[0].Content))
+	assert.NilError(t, err)
+	assert.Equal(t, message, string(decodedMsg))
 }
@@ -11,13 +11,36 @@ import (
 	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/tools"
 	"github.com/gosnmp/gosnmp"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Traps received, forwarded and dropped per SNMP version
+var (
+	trapsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "onms_trap_received",
+		Help: "The total number of SNMP traps received per version",
+	}, []string{"version"})
+	trapsForwarded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "onms_trap_forwarded",
+		Help: "The total number of SNMP traps forwarded to OpenNMS per version",
+	}, []string{"version"})
+	trapsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "onms_trap_dropped",
+		Help: "The total number of SNMP traps that couldn't be forwarded to OpenNMS per version",
+	}, []string{"version"})
+)
+
+func init() {
+	prometheus.MustRegister(trapsReceived, trapsForwarded, trapsDropped)
+}
+
 // SnmpTrapModule represents the SNMP trap receiver module
 type SnmpTrapModule struct {
 	sink     api.Sink
 	config   *api.MinionConfig
-	listener *gosnmp.TrapListener
+	params   *gosnmp.GoSNMP
+	conn     *net.UDPConn
+	stopping bool
 }
 
 // GetID gets the ID of the sink module
@@ -25,7 +48,8 @@ func (module *SnmpTrapModule) GetID() string {
 	return "Trap"
 }
 
-// Start initiates a Syslog UDP and TCP receiver
+// Start initiates an SNMP trap receiver
+// SNMPv3 is enabled when a listener named Trap defines the security-name property, and its ip-version property sets the address family of the socket.
 func (module *SnmpTrapModule) Start(config *api.MinionConfig, sink api.Sink) error {
 	if config.TrapPort == 0 {
 		log.Warnf("Trap Module disabled")
@@ -36,39 +60,124 @@ func (module *SnmpTrapModule) Start(config *api.MinionConfig, sink api.Sink) err
 
 	module.config = config
 	module.sink = sink
-	module.listener = gosnmp.NewTrapListener()
-	module.listener.OnNewTrap = module.trapHandler
-	module.listener.Params = gosnmp.Default
-
-	// Test Listener
-	lis, err := createUDPListener(config.TrapPort)
-	if err == nil {
-		lis.Close()
-	} else {
+	module.params = module.getParams()
+	module.stopping = false
+
+	listener := config.GetListener(module.GetID())
+	bindAddress := config.GetBindAddress(listener)
+	ipVersion := getListenerIPVersion(listener)
+	network, _, err := getUDPAddress(bindAddress, ipVersion, config.TrapPort)
+	if err != nil {
 		return err
 	}
-
-	// Start Trap Receiver
-	go func() {
-		err := module.listener.Listen(fmt.Sprintf("0.0.0.0:%d", config.TrapPort))
-		if err != nil {
-			log.Errorf("Cannot start SNMP trap listener: %s", err)
-		}
-	}()
+	if module.conn, err = createUDPListener(bindAddress, ipVersion, config.TrapPort); err != nil {
+		return err
+	}
+	log.Infof("SNMP Trap receiver bound to UDP %s (%s)", module.conn.LocalAddr(), describeUDPNetwork(network))
+	go module.receive(module.conn)
 	return nil
 }
 
 // Stop shutdowns the sink module
 func (module *SnmpTrapModule) Stop() {
 	log.Warnf("Stopping SNMP Trap receiver")
-	if module.listener != nil {
-		module.listener.Close()
+	module.stopping = true
+	if module.conn != nil {
+		module.conn.Close()
+	}
+}
+
+// Reads the traps from the socket until it is closed, answering the informs as gosnmp.TrapListener does
+func (module *SnmpTrapModule) receive(conn *net.UDPConn) {
+	buffer := make([]byte, 65535)
+	for {
+		size, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if module.stopping {
+				return
+			}
+			log.Errorf("Cannot read SNMP trap: %v", err)
+			if isFatalListenerError(err) {
+				api.ReportModuleFailure(module.GetID(), err)
+				return
+			}
+			continue
+		}
+		packet := module.params.UnmarshalTrap(buffer[:size], false)
+		if packet == nil {
+			log.Warnf("Ignoring invalid SNMP trap from %s", addr.IP)
+			continue
+		}
+		module.trapHandler(packet, addr)
+		if packet.PDUType == gosnmp.InformRequest {
+			module.acknowledge(conn, packet, addr)
+		}
+	}
+}
+
+// Sends the response to an inform, which carries the same variables
+func (module *SnmpTrapModule) acknowledge(conn *net.UDPConn, packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+	packet.PDUType = gosnmp.GetResponse
+	packet.Error = gosnmp.NoError
+	packet.ErrorIndex = 0
+	data, err := packet.MarshalMsg()
+	if err != nil {
+		log.Errorf("Cannot encode the response to the SNMP inform from %s: %v", addr.IP, err)
+		return
+	}
+	if _, err := conn.WriteToUDP(data, addr); err != nil {
+		log.Errorf("Cannot send the response to the SNMP inform from %s: %v", addr.IP, err)
+	}
+}
+
+// Gets the SNMP parameters for the trap listener
+// SNMPv1 and SNMPv2c traps are always accepted, while SNMPv3 traps require the user credentials from the Trap listener properties,
+// or from the Minion-level SNMPv3 user referenced by its security-name property.
+func (module *SnmpTrapModule) getParams() *gosnmp.GoSNMP {
+	listener := module.config.GetListener(module.GetID())
+	if listener == nil || listener.Properties["security-name"] == "" {
+		return gosnmp.Default
+	}
+	props := listener.Properties
+	agent := &api.SNMPAgentDTO{
+		SecurityName:   props["security-name"],
+		AuthProtocol:   strings.ToUpper(props["auth-protocol"]),
+		AuthPassPhrase: props["auth-passphrase"],
+		PrivProtocol:   strings.ToUpper(props["priv-protocol"]),
+		PrivPassPhrase: props["priv-passphrase"],
+	}
+	if user := api.GetSNMPv3User(agent.SecurityName); user != nil {
+		user.Apply(agent)
+	} else if level, err := strconv.Atoi(props["security-level"]); err == nil {
+		agent.SecurityLevel = level
+	} else if agent.PrivPassPhrase != "" {
+		agent.SecurityLevel = 3
+	} else if agent.AuthPassPhrase != "" {
+		agent.SecurityLevel = 2
+	} else {
+		agent.SecurityLevel = 1
+	}
+	log.Infof("Accepting SNMPv3 traps for user %s with security level %d", agent.SecurityName, agent.SecurityLevel)
+	return &gosnmp.GoSNMP{
+		Port:               gosnmp.Default.Port,
+		Transport:          gosnmp.Default.Transport,
+		Community:          gosnmp.Default.Community,
+		Version:            gosnmp.Version3,
+		Timeout:            gosnmp.Default.Timeout,
+		Retries:            gosnmp.Default.Retries,
+		ExponentialTimeout: gosnmp.Default.ExponentialTimeout,
+		MaxOids:            gosnmp.Default.MaxOids,
+		SecurityModel:      gosnmp.UserSecurityModel,
+		MsgFlags:           agent.GetV3Flags(),
+		SecurityParameters: agent.GetSecurityParameters(),
 	}
 }
 
 func (module *SnmpTrapModule) trapHandler(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+	defer recoverPanic(module.config, module.GetID())
 	version := fmt.Sprintf("v%s", packet.Version)
 	log.Debugf("Received SNMP%s trap (type: 0x%X) from %s", version, packet.PDUType, addr.IP)
+	trapsReceived.WithLabelValues(version).Inc()
 
 	trap := api.TrapDTO{
 		AgentAddress: addr.IP.String(),
@@ -113,7 +222,11 @@ func (module *SnmpTrapModule) trapHandler(packet *gosnmp.SnmpPacket, addr *net.U
 	}
 
 	trapLog.AddTrap(trap)
-	sendXMLResponse(module.GetID(), module.config, module.sink, trapLog)
+	if err := sendXMLResponse(module.GetID(), module.config, module.sink, trapLog); err == nil {
+		trapsForwarded.WithLabelValues(version).Inc()
+	} else {
+		trapsDropped.WithLabelValues(version).Inc()
+	}
 }
 
 func (module *SnmpTrapModule) extractTrapIdentity(pdu gosnmp.SnmpPDU) *api.TrapIdentityDTO {
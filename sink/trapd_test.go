@@ -0,0 +1,119 @@
+package sink
+
+import (
+	"encoding/xml"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/gosnmp/gosnmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTrapGetParams(t *testing.T) {
+	module := &SnmpTrapModule{config: &api.MinionConfig{}}
+	assert.Equal(t, gosnmp.Default, module.getParams())
+
+	module.config.Listeners = []api.MinionListener{
+		{
+			Name: "Trap",
+			Properties: map[string]string{
+				"security-name":   "opennms",
+				"auth-protocol":   "sha",
+				"auth-passphrase": "0p3nNMS!",
+			},
+		},
+	}
+	params := module.getParams()
+	assert.Equal(t, gosnmp.Version3, params.Version)
+	assert.Equal(t, gosnmp.AuthNoPriv, params.MsgFlags)
+	usm := params.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	assert.Equal(t, "opennms", usm.UserName)
+	assert.Equal(t, gosnmp.SHA, usm.AuthenticationProtocol)
+
+	api.SetSNMPv3Users([]api.SNMPv3User{{SecurityName: "opennms", AuthProtocol: "MD5", AuthPassPhrase: "secret1", PrivProtocol: "AES", PrivPassPhrase: "secret2"}})
+	defer api.SetSNMPv3Users(nil)
+	module.config.Listeners[0].Properties = map[string]string{"security-name": "opennms"}
+	params = module.getParams()
+	assert.Equal(t, gosnmp.AuthPriv, params.MsgFlags)
+	usm = params.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	assert.Equal(t, gosnmp.MD5, usm.AuthenticationProtocol)
+	assert.Equal(t, "secret2", usm.PrivacyPassphrase)
+}
+
+func TestTrapHandler(t *testing.T) {
+	sink := &api.MockBroker{}
+	module := &SnmpTrapModule{
+		sink:   sink,
+		config: &api.MinionConfig{ID: "minion1", Location: "Test"},
+	}
+	packet := &gosnmp.SnmpPacket{
+		Version:   gosnmp.Version2c,
+		Community: "public",
+		PDUType:   gosnmp.SNMPv2Trap,
+		Variables: []gosnmp.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(1000)},
+			{Name: ".1.3.6.1.6.3.1.1.4.1.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.6.3.1.1.5.3"},
+			{Name: ".1.3.6.1.2.1.2.2.1.1.1", Type: gosnmp.Integer, Value: 1},
+		},
+	}
+	before := testutil.ToFloat64(trapsForwarded.WithLabelValues("v2c"))
+	module.trapHandler(packet, &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 162})
+	assert.Equal(t, before+1, testutil.ToFloat64(trapsForwarded.WithLabelValues("v2c")))
+
+	messages := sink.GetMessages()
+	assert.Equal(t, 1, len(messages))
+	trapLog := &api.TrapLogDTO{}
+	assert.NilError(t, xml.Unmarshal(messages[0].Content, trapLog))
+	assert.Equal(t, "10.0.0.1", trapLog.TrapAddress)
+	assert.Equal(t, 1, len(trapLog.Messages))
+	assert.Equal(t, "public", trapLog.Messages[0].Community)
+	assert.Equal(t, 3, trapLog.Messages[0].PDULength)
+}
+
+func TestTrapModuleIPVersion(t *testing.T) {
+	sink := &api.MockBroker{}
+	config := &api.MinionConfig{
+		ID:        "minion1",
+		Location:  "Test",
+		TrapPort:  31620,
+		Listeners: []api.MinionListener{{Name: "Trap", Properties: map[string]string{"ip-version": "ipv6"}}},
+	}
+	module := &SnmpTrapModule{}
+	if err := module.Start(config, sink); err != nil {
+		t.Skipf("IPv6 is not available: %v", err)
+	}
+	defer module.Stop()
+
+	// The inform is answered, so SendTrap doesn't fail
+	client := &gosnmp.GoSNMP{Target: "::1", Port: 31620, Transport: "udp6", Community: "public", Version: gosnmp.Version2c, Timeout: time.Second}
+	assert.NilError(t, client.Connect())
+	defer client.Conn.Close()
+	_, err := client.SendTrap(gosnmp.SnmpTrap{
+		IsInform: true,
+		Variables: []gosnmp.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(1000)},
+			{Name: ".1.3.6.1.6.3.1.1.4.1.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.6.3.1.1.5.3"},
+		},
+	})
+	assert.NilError(t, err)
+	messages := sink.GetMessages()
+	assert.Equal(t, 1, len(messages))
+	trapLog := &api.TrapLogDTO{}
+	assert.NilError(t, xml.Unmarshal(messages[0].Content, trapLog))
+	assert.Equal(t, "::1", trapLog.TrapAddress)
+
+	// The socket is IPv6 only
+	conn, err := net.Dial("udp", "127.0.0.1:31620")
+	assert.NilError(t, err)
+	conn.Write([]byte("test"))
+	conn.Close()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, len(sink.GetMessages()))
+
+	config.Listeners[0].Properties["ip-version"] = "ipv5"
+	assert.ErrorContains(t, (&SnmpTrapModule{}).Start(config, sink), "invalid ip-version ipv5")
+}
@@ -16,7 +16,7 @@ type UDPForwardModule struct {
 	name     string
 	sink     api.Sink
 	config   *api.MinionConfig
-	conn     *net.UDPConn
+	server   *udpServer
 	stopping bool
 }
 
@@ -25,6 +25,11 @@ func (module *UDPForwardModule) GetID() string {
 	return module.name
 }
 
+// GetParsers gets the parsers supported by the sink module
+func (module *UDPForwardModule) GetParsers() []string {
+	return []string{UDPForwardParser}
+}
+
 // Start initiates a generic UDP receiver
 func (module *UDPForwardModule) Start(config *api.MinionConfig, sink api.Sink) error {
 	listener := config.GetListener(module.name)
@@ -37,32 +42,18 @@ func (module *UDPForwardModule) Start(config *api.MinionConfig, sink api.Sink) e
 	module.stopping = false
 	module.sink = sink
 	module.config = config
+	setRateLimit(module.GetID(), listener)
 
-	module.conn, err = createUDPListener(listener.Port)
-	if err != nil {
+	log.Infof("Starting %s receiver on port UDP %d", module.name, listener.Port)
+	if module.server, err = newUDPServer(module.config, module.name, module.config.GetBindAddress(listener), getListenerIPVersion(listener), listener.Port, getListenerWorkers(config, listener), getListenerReadBuffer(listener)); err != nil {
 		return err
 	}
-	log.Infof("Starting %s receiver on port UDP %d", module.name, listener.Port)
-	go func() {
-		payload := make([]byte, 1024)
-		for {
-			size, pktAddr, err := module.conn.ReadFromUDP(payload)
-			if err != nil {
-				if !module.stopping {
-					log.Errorf("%s cannot read from UDP: %s", module.name, err)
-				}
-				continue
-			}
-			payloadCut := make([]byte, size)
-			copy(payloadCut, payload[0:size])
-			log.Debugf("Received %d bytes from %s", size, pktAddr)
-			messages := make([][]byte, 1)
-			messages[0] = payloadCut
-			if bytes := wrapMessageToTelemetry(module.config, pktAddr.IP.String(), uint32(pktAddr.Port), messages); bytes != nil {
-				sendBytes(module.GetID(), module.config, module.sink, bytes)
-			}
-		}
-	}()
+	module.server.serve(65535, func(pktAddr *net.UDPAddr, data []byte) {
+		log.Debugf("Received %d bytes from %s", len(data), pktAddr)
+		countReceived(module.config, module.name, UDPForwardParser, len(data))
+		messages := [][]byte{data}
+		sendEncoded(module.GetID(), module.name, UDPForwardParser, module.config, module.sink, pktAddr.IP.String(), uint32(pktAddr.Port), messages)
+	})
 	return nil
 }
 
@@ -70,7 +61,7 @@ func (module *UDPForwardModule) Start(config *api.MinionConfig, sink api.Sink) e
 func (module *UDPForwardModule) Stop() {
 	log.Warnf("Stopping %s receiver", module.name)
 	module.stopping = true
-	if module.conn != nil {
-		module.conn.Close()
+	if module.server != nil {
+		module.server.stop()
 	}
 }
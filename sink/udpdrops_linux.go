@@ -0,0 +1,58 @@
+//go:build linux
+// +build linux
+
+package sink
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Gets the total number of datagrams dropped by the kernel on the UDP sockets bound to a given port, from /proc/net/udp and /proc/net/udp6
+func getUDPDrops(port int) (uint64, bool) {
+	var total uint64
+	found := false
+	for _, path := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		drops, ok := parseUDPDrops(file, port)
+		file.Close()
+		if ok {
+			total += drops
+			found = true
+		}
+	}
+	return total, found
+}
+
+// Parses the drops column of the sockets whose local port matches the given one
+func parseUDPDrops(r io.Reader, port int) (uint64, bool) {
+	var total uint64
+	found := false
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Skips the header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			continue
+		}
+		local := fields[1]
+		idx := strings.LastIndex(local, ":")
+		if idx < 0 {
+			continue
+		}
+		if p, err := strconv.ParseUint(local[idx+1:], 16, 16); err != nil || int(p) != port {
+			continue
+		}
+		if drops, err := strconv.ParseUint(fields[len(fields)-1], 10, 64); err == nil {
+			total += drops
+			found = true
+		}
+	}
+	return total, found
+}
@@ -0,0 +1,22 @@
+package sink
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseUDPDrops(t *testing.T) {
+	data := `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  123: 00000000:12BD 00000000:0000 07 00000000:00000000 00:00000000 00000000  1000        0 41231 2 0000000000000000 15
+  124: 00000000:12BD 00000000:0000 07 00000000:00000000 00:00000000 00000000  1000        0 41232 2 0000000000000000 5
+  125: 0100007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 20012 2 0000000000000000 0
+`
+	drops, ok := parseUDPDrops(strings.NewReader(data), 4797)
+	assert.Assert(t, ok)
+	assert.Equal(t, uint64(20), drops)
+
+	_, ok = parseUDPDrops(strings.NewReader(data), 2055)
+	assert.Assert(t, !ok)
+}
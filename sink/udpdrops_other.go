@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package sink
+
+// The socket drop statistics are not available on this platform
+func getUDPDrops(port int) (uint64, bool) {
+	return 0, false
+}
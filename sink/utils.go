@@ -1,29 +1,122 @@
 package sink
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
-	"time"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/log"
 	"github.com/agalue/gominion/protobuf/ipc"
-	"github.com/agalue/gominion/protobuf/telemetry"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Sink messages dropped by the modules, either because the broker cannot deliver them or because a local queue is full
+var sinkMsgDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "onms_sink_messages_dropped",
+	Help: "The total number of Sink messages dropped per module",
+}, []string{"minion", "module"})
+
+// Panics recovered while a Sink module was receiving or forwarding messages
+var sinkModulePanicked = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "onms_sink_module_panicked",
+	Help: "The total number of panics recovered while a Sink module was handling messages",
+}, []string{"minion", "module"})
+
+// Recovers from a panic of a Sink module, logging its stack trace so one bad module or message cannot crash the Minion.
+// It must be deferred by the function that handles the messages.
+func recoverPanic(config *api.MinionConfig, moduleID string) {
+	if r := recover(); r != nil {
+		reportPanic(config, moduleID, r)
+	}
+}
+
+// Logs a panic recovered from a Sink module with its stack trace, and counts it
+func reportPanic(config *api.MinionConfig, moduleID string, r interface{}) {
+	log.Errorf("%s recovered from panic: %v\n%s", moduleID, r, debug.Stack())
+	sinkModulePanicked.WithLabelValues(config.ID, moduleID).Inc()
+}
+
+// The metrics for the traffic of the telemetry listeners (nil when not set)
+var telemetryMetrics *api.Metrics
+
+// SetMetrics sets the metrics updated by the telemetry listeners; it must be called before starting the modules
+func SetMetrics(metrics *api.Metrics) {
+	telemetryMetrics = metrics
+}
+
+// Counts a packet of the given size received by a telemetry listener
+func countReceived(config *api.MinionConfig, listener string, parser string, size int) {
+	if telemetryMetrics == nil {
+		return
+	}
+	telemetryMetrics.TelemetryPacketsReceived.WithLabelValues(config.ID, listener, parser).Inc()
+	telemetryMetrics.TelemetryBytesReceived.WithLabelValues(config.ID, listener, parser).Add(float64(size))
+}
+
+// Counts a packet of a telemetry listener that was dropped before reaching OpenNMS
+func countDropped(config *api.MinionConfig, listener string, parser string) {
+	if telemetryMetrics == nil {
+		return
+	}
+	telemetryMetrics.TelemetryPacketsDropped.WithLabelValues(config.ID, listener, parser).Inc()
+}
 
-	"google.golang.org/protobuf/proto"
+// The UDP servers currently receiving, for the socket drop statistics
+var (
+	activeUDPServers      = make(map[*udpServer]bool)
+	activeUDPServersMutex sync.Mutex
 )
 
-func sendXMLResponse(moduleID string, config *api.MinionConfig, sink api.Sink, object interface{}) {
+// udpDropsCollector reports the datagrams dropped by the kernel on the sockets of the active UDP servers, where the OS exposes them
+type udpDropsCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c *udpDropsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *udpDropsCollector) Collect(ch chan<- prometheus.Metric) {
+	activeUDPServersMutex.Lock()
+	defer activeUDPServersMutex.Unlock()
+	for server := range activeUDPServers {
+		if drops, ok := getUDPDrops(server.port); ok {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(drops), server.name)
+		}
+	}
+}
+
+func init() {
+	prometheus.MustRegister(sinkMsgDropped)
+	prometheus.MustRegister(sinkModulePanicked)
+	prometheus.MustRegister(&udpDropsCollector{
+		desc: prometheus.NewDesc("onms_sink_udp_drops", "The total number of datagrams dropped by the kernel on the UDP sockets of a module, usually because the receive buffer is full", []string{"module"}, nil),
+	})
+}
+
+func sendXMLResponse(moduleID string, config *api.MinionConfig, sink api.Sink, object interface{}) error {
 	bytes, err := xml.MarshalIndent(object, "", "   ")
 	if err != nil {
 		log.Errorf("Cannot parse Sink API response: %v", err)
 	}
-	sendBytes(moduleID, config, sink, bytes)
+	return sendBytes(moduleID, config, sink, bytes)
 }
 
-func sendBytes(moduleID string, config *api.MinionConfig, sink api.Sink, bytes []byte) {
+func sendBytes(moduleID string, config *api.MinionConfig, sink api.Sink, bytes []byte) error {
 	msg := &ipc.SinkMessage{
 		MessageId: uuid.New().String(),
 		ModuleId:  moduleID,
@@ -32,44 +125,484 @@ func sendBytes(moduleID string, config *api.MinionConfig, sink api.Sink, bytes [
 		Content:   bytes,
 	}
 	if sink == nil {
-		return
+		return nil
+	}
+	if !allowMessage(moduleID) {
+		sinkMsgRateLimited.WithLabelValues(config.ID, moduleID).Inc()
+		return errRateLimited
 	}
 	if err := sink.Send(msg); err != nil {
 		log.Errorf("%s cannot send message via Sink API: %v", moduleID, err)
+		sinkMsgDropped.WithLabelValues(config.ID, moduleID).Inc()
+		return err
 	}
+	return nil
 }
 
-func wrapMessageToTelemetry(config *api.MinionConfig, sourceAddress string, sourcePort uint32, data [][]byte) []byte {
-	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
-	logMsg := &telemetry.TelemetryMessageLog{
-		SystemId:      &config.ID,
-		Location:      &config.Location,
-		SourceAddress: &sourceAddress,
-		SourcePort:    &sourcePort,
-		Message:       make([]*telemetry.TelemetryMessage, len(data)),
-	}
-	for i := 0; i < len(data); i++ {
-		logMsg.Message[i] = &telemetry.TelemetryMessage{
-			Timestamp: &now,
-			Bytes:     data[i],
-		}
+// Encodes the data received by a listener from a source with the encoder of the listener's parser, and sends it via the Sink API
+// Messages that cannot be encoded or sent, including the ones discarded by the rate limiter, are counted as dropped for the listener.
+func sendEncoded(moduleID string, listener string, parser string, config *api.MinionConfig, sink api.Sink, sourceAddress string, sourcePort uint32, data [][]byte) error {
+	bytes, err := GetEncoder(parser).Encode(config, sourceAddress, sourcePort, data)
+	if err == nil {
+		err = sendBytes(moduleID, config, sink, bytes)
+	} else {
+		log.Errorf("%s cannot encode message: %v", moduleID, err)
 	}
-	bytes, err := proto.Marshal(logMsg)
 	if err != nil {
-		log.Errorf("Cannot serialize telemetry message: %v", err)
-		return nil
+		countDropped(config, listener, parser)
 	}
-	return bytes
+	return err
 }
 
-func createUDPListener(port int) (*net.UDPConn, error) {
-	udpAddr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf(":%d", port))
+// Creates a UDP socket on the given bind address and port, for the address family of the given ip-version (see getUDPAddress)
+func createUDPListener(bindAddress string, ipVersion string, port int) (*net.UDPConn, error) {
+	network, addr, err := getUDPAddress(bindAddress, ipVersion, port)
+	if err != nil {
+		return nil, err
+	}
+	udpAddr, err := net.ResolveUDPAddr(network, addr)
 	if err != nil {
 		return nil, fmt.Errorf("cannot resolve address: %s", err)
 	}
-	conn, err := net.ListenUDP("udp4", udpAddr)
+	conn, err := net.ListenUDP(network, udpAddr)
 	if err != nil {
-		return nil, fmt.Errorf("cannot listen on UDP port %d: %s", port, err)
+		return nil, fmt.Errorf("cannot listen on UDP %s: %s", addr, err)
 	}
 	return conn, nil
 }
+
+// Gets the network and the address to listen on.
+// The ip-version sets the address family: ipv4 (udp4), ipv6 (udp6, IPv6 only), or dual (udp, IPv6 sockets also receiving IPv4 traffic).
+// When it is empty, the family is the one of the bind address, and an empty bind address means all the IPv4 interfaces.
+func getUDPAddress(bindAddress string, ipVersion string, port int) (string, string, error) {
+	network, err := getUDPNetwork(ipVersion)
+	if err != nil {
+		return "", "", err
+	}
+	if bindAddress == "" {
+		return network, fmt.Sprintf(":%d", port), nil
+	}
+	ip := net.ParseIP(bindAddress)
+	if ip == nil {
+		return "", "", fmt.Errorf("invalid bind address %s", bindAddress)
+	}
+	family := "udp4"
+	if ip.To4() == nil {
+		family = "udp6"
+	}
+	switch {
+	case ipVersion == "":
+		network = family
+	case network == "udp" && (family != "udp6" || !ip.IsUnspecified()):
+		return "", "", fmt.Errorf("ip-version dual requires an empty or :: bind address, not %s", bindAddress)
+	case network != "udp" && network != family:
+		return "", "", fmt.Errorf("bind address %s doesn't match ip-version %s", bindAddress, ipVersion)
+	}
+	return network, net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+}
+
+// Gets the UDP network for an ip-version (ipv4, ipv6 or dual); an empty ip-version means IPv4
+func getUDPNetwork(ipVersion string) (string, error) {
+	switch ipVersion {
+	case "", "ipv4":
+		return "udp4", nil
+	case "ipv6":
+		return "udp6", nil
+	case "dual":
+		return "udp", nil
+	}
+	return "", fmt.Errorf("invalid ip-version %s, expected ipv4, ipv6 or dual", ipVersion)
+}
+
+// Describes the address family of a UDP network for the logs
+func describeUDPNetwork(network string) string {
+	switch network {
+	case "udp4":
+		return "IPv4"
+	case "udp6":
+		return "IPv6"
+	}
+	return "dual-stack IPv4/IPv6"
+}
+
+// Gets the ip-version property of a listener, in lowercase; the listener can be nil
+func getListenerIPVersion(listener *api.MinionListener) string {
+	if listener == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(listener.Properties["ip-version"]))
+}
+
+// udpServer runs one or more workers reading datagrams from a UDP port.
+// Each worker has its own socket when SO_REUSEPORT is available; otherwise, all of them read from a single socket.
+type udpServer struct {
+	config   *api.MinionConfig
+	name     string
+	address  string
+	port     int
+	workers  int
+	conns    []*net.UDPConn
+	wg       sync.WaitGroup
+	stopping int32
+}
+
+// Handles a datagram; the data is a copy owned by the handler
+type udpHandler func(addr *net.UDPAddr, data []byte)
+
+// Creates the sockets of a UDP server with the given number of workers.
+// When readBuffer is greater than zero, it sets the size of the receive buffer (SO_RCVBUF) of the sockets.
+func newUDPServer(config *api.MinionConfig, name string, bindAddress string, ipVersion string, port int, workers int, readBuffer int) (*udpServer, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	network, addr, err := getUDPAddress(bindAddress, ipVersion, port)
+	if err != nil {
+		return nil, err
+	}
+	server := &udpServer{config: config, name: name, address: addr, port: port, workers: workers}
+	if workers == 1 || !reusePortAvailable {
+		conn, err := createUDPListener(bindAddress, ipVersion, port)
+		if err != nil {
+			return nil, err
+		}
+		server.conns = append(server.conns, conn)
+	} else {
+		config := net.ListenConfig{Control: reusePortControl}
+		for i := 0; i < workers; i++ {
+			conn, err := config.ListenPacket(context.Background(), network, addr)
+			if err != nil {
+				server.stop()
+				return nil, fmt.Errorf("cannot listen on UDP %s: %s", addr, err)
+			}
+			server.conns = append(server.conns, conn.(*net.UDPConn))
+		}
+	}
+	if readBuffer > 0 {
+		if err := server.setReadBuffer(readBuffer); err != nil {
+			server.stop()
+			return nil, err
+		}
+	}
+	log.Infof("%s bound to UDP %s (%s)", name, server.conns[0].LocalAddr(), describeUDPNetwork(network))
+	return server, nil
+}
+
+// Sets the size of the receive buffer of the sockets, logging the size granted by the kernel, which might be smaller (e.g. net.core.rmem_max on Linux)
+func (server *udpServer) setReadBuffer(size int) error {
+	for _, conn := range server.conns {
+		if err := conn.SetReadBuffer(size); err != nil {
+			return fmt.Errorf("cannot set the receive buffer of UDP %s to %d bytes: %v", server.address, size, err)
+		}
+	}
+	if granted, ok := getReadBuffer(server.conns[0]); ok {
+		log.Infof("%s requested a receive buffer of %d bytes, the kernel granted %d bytes", server.name, size, granted)
+	} else {
+		log.Infof("%s requested a receive buffer of %d bytes", server.name, size)
+	}
+	return nil
+}
+
+// Starts the workers, each of them with a buffer of the given size
+func (server *udpServer) serve(bufferSize int, handler udpHandler) {
+	log.Infof("%s receiving on UDP %s with %d workers", server.name, server.address, server.workers)
+	activeUDPServersMutex.Lock()
+	activeUDPServers[server] = true
+	activeUDPServersMutex.Unlock()
+	for i := 0; i < server.workers; i++ {
+		conn := server.conns[i%len(server.conns)]
+		server.wg.Add(1)
+		go func() {
+			defer server.wg.Done()
+			payload := make([]byte, bufferSize)
+			for {
+				size, pktAddr, err := conn.ReadFromUDP(payload)
+				if err != nil {
+					if atomic.LoadInt32(&server.stopping) == 1 {
+						return
+					}
+					log.Errorf("%s cannot read from UDP: %s", server.name, err)
+					if isFatalListenerError(err) {
+						api.ReportModuleFailure(server.name, err)
+						return
+					}
+					continue
+				}
+				payloadCut := make([]byte, size)
+				copy(payloadCut, payload[0:size])
+				server.handle(handler, pktAddr, payloadCut)
+			}
+		}()
+	}
+}
+
+// Checks whether an error of a listener socket is fatal, as the socket was closed while the module is running, so the loop must stop and report the failure.
+// Other errors (e.g. ICMP errors on UDP sockets, or running out of file descriptors) might go away, so they are retried.
+func isFatalListenerError(err error) bool {
+	return errors.Is(err, net.ErrClosed)
+}
+
+// Passes a datagram to the handler, recovering from a panic so the worker keeps receiving
+func (server *udpServer) handle(handler func(*net.UDPAddr, []byte), addr *net.UDPAddr, data []byte) {
+	defer recoverPanic(server.config, server.name)
+	handler(addr, data)
+}
+
+// Closes the sockets and waits for the workers to finish
+func (server *udpServer) stop() {
+	atomic.StoreInt32(&server.stopping, 1)
+	activeUDPServersMutex.Lock()
+	delete(activeUDPServers, server)
+	activeUDPServersMutex.Unlock()
+	for _, conn := range server.conns {
+		conn.Close()
+	}
+	server.wg.Wait()
+}
+
+// listenerWorkers represents the goroutines of a listener: the workers receiving (or forwarding) its messages, and the decoders of the flow listeners
+type listenerWorkers struct {
+	workers  int
+	decoders int
+}
+
+func (w listenerWorkers) total() int {
+	return w.workers + w.decoders
+}
+
+// Gets the number of workers that receive (or forward) the messages of a listener, from its workers property or the default of its parser.
+// When the Minion caps the workers of all the listeners (see maxListenerWorkers), the result doesn't exceed the share of the listener.
+func getListenerWorkers(config *api.MinionConfig, listener *api.MinionListener) int {
+	if share, ok := allocateListenerWorkers(config)[listener.Name]; ok {
+		return share.workers
+	}
+	return getRequestedWorkers(listener).workers
+}
+
+// Gets the number of decoders of a flow listener, like getListenerWorkers
+func getListenerDecoders(config *api.MinionConfig, listener *api.MinionListener) int {
+	if share, ok := allocateListenerWorkers(config)[listener.Name]; ok {
+		return share.decoders
+	}
+	return getRequestedWorkers(listener).decoders
+}
+
+// Gets the workers requested by a listener. The workers property applies to both the receivers and the decoders of the flow listeners,
+// which default to 1 and to the number of CPUs respectively (the IPFIX TCP listener has no receivers, as each connection has its own goroutine).
+func getRequestedWorkers(listener *api.MinionListener) listenerWorkers {
+	requested := 0
+	if value, ok := listener.Properties["workers"]; ok {
+		if w, err := strconv.Atoi(value); err == nil && w > 0 {
+			requested = w
+		}
+	}
+	orDefault := func(defaultValue int) int {
+		if requested > 0 {
+			return requested
+		}
+		return defaultValue
+	}
+	switch {
+	case listener.Is(TCPIpfixParser):
+		return listenerWorkers{decoders: orDefault(runtime.NumCPU())}
+	case listener.Is(UDPNetflow5Parser), listener.Is(UDPNetflow9Parser), listener.Is(UDPIpfixParser):
+		return listenerWorkers{workers: orDefault(1), decoders: orDefault(runtime.NumCPU())}
+	case listener.Is(NxosGrpcParser):
+		return listenerWorkers{workers: orDefault(defaultNxosWorkers)}
+	}
+	return listenerWorkers{workers: orDefault(1)}
+}
+
+// Shares maxListenerWorkers between the listeners, proportionally to the workers and decoders each one requests.
+// Every listener keeps at least one worker (and one decoder when it has them), and the rest of the cap is shared using the largest remainders,
+// so the total only exceeds the cap when there are more of those than the cap allows.
+// Returns nil when there is no limit, or when the listeners request no more workers than the limit.
+func allocateListenerWorkers(config *api.MinionConfig) map[string]listenerWorkers {
+	if config == nil || config.MaxListenerWorkers <= 0 {
+		return nil
+	}
+	type slot struct {
+		listener  string
+		decoders  bool
+		requested int
+		share     int
+		remainder int
+	}
+	var slots []*slot
+	total := 0
+	for i := range config.Listeners {
+		listener := &config.Listeners[i]
+		requested := getRequestedWorkers(listener)
+		if requested.workers > 0 {
+			slots = append(slots, &slot{listener: listener.Name, requested: requested.workers})
+		}
+		if requested.decoders > 0 {
+			slots = append(slots, &slot{listener: listener.Name, decoders: true, requested: requested.decoders})
+		}
+		total += requested.total()
+	}
+	if total <= config.MaxListenerWorkers {
+		return nil
+	}
+	budget := config.MaxListenerWorkers - len(slots) // What remains after the minimum of each slot
+	if budget < 0 {
+		budget = 0
+	}
+	excess := total - len(slots) // Zero when every slot requests one worker, so each one keeps its minimum
+	for _, s := range slots {
+		s.share = 1
+	}
+	if excess > 0 {
+		assigned := 0
+		for _, s := range slots {
+			s.share = 1 + (s.requested-1)*budget/excess
+			s.remainder = (s.requested - 1) * budget % excess
+			assigned += s.share - 1
+		}
+		byRemainder := make([]*slot, len(slots))
+		copy(byRemainder, slots)
+		sort.SliceStable(byRemainder, func(i, j int) bool {
+			return byRemainder[i].remainder > byRemainder[j].remainder
+		})
+		for _, s := range byRemainder {
+			if assigned >= budget {
+				break
+			}
+			if s.share < s.requested {
+				s.share++
+				assigned++
+			}
+		}
+	}
+	allocation := make(map[string]listenerWorkers, len(config.Listeners))
+	for _, s := range slots {
+		share := allocation[s.listener]
+		if s.decoders {
+			share.decoders = s.share
+		} else {
+			share.workers = s.share
+		}
+		allocation[s.listener] = share
+	}
+	return allocation
+}
+
+// DisplayListenerWorkers displays the number of workers of each listener, and the total, using the given log function (e.g. log.Infof)
+func DisplayListenerWorkers(config *api.MinionConfig, logf func(format string, params ...interface{})) {
+	if config.MaxListenerWorkers <= 0 {
+		return
+	}
+	allocation := allocateListenerWorkers(config)
+	total := 0
+	for i := range config.Listeners {
+		listener := &config.Listeners[i]
+		requested := getRequestedWorkers(listener)
+		actual := requested
+		if share, ok := allocation[listener.Name]; ok {
+			actual = share
+		}
+		total += actual.total()
+		detail := ""
+		if actual.decoders > 0 {
+			detail = fmt.Sprintf(" (%d receivers and %d decoders)", actual.workers, actual.decoders)
+		}
+		if actual.total() < requested.total() {
+			logf("Listener %s limited to %d of %d requested workers%s", listener.Name, actual.total(), requested.total(), detail)
+		} else {
+			logf("Listener %s uses %d workers%s", listener.Name, actual.total(), detail)
+		}
+	}
+	if total > config.MaxListenerWorkers {
+		logf("Listeners use %d workers in total, exceeding the max of %d, as each listener requires at least one worker (and one decoder for flows)", total, config.MaxListenerWorkers)
+	} else {
+		logf("Listeners use %d workers in total (max %d across all listeners)", total, config.MaxListenerWorkers)
+	}
+}
+
+// Gets the size in bytes of the receive buffer from the so-rcvbuf property of a listener (0 to use the OS default)
+func getListenerReadBuffer(listener *api.MinionListener) int {
+	if value, ok := listener.Properties["so-rcvbuf"]; ok {
+		if size, err := strconv.Atoi(value); err == nil && size > 0 {
+			return size
+		}
+		log.Warnf("Ignoring invalid so-rcvbuf %s for listener %s", value, listener.Name)
+	}
+	return 0
+}
+
+// Gets the TLS configuration of a TCP receiver from the tls-cert-path and tls-key-path properties of its listener, requiring client certificates when it also has tls-client-ca-path.
+// Returns nil when TLS is not configured.
+func getServerTLSConfig(name string, listener *api.MinionListener) (*tls.Config, error) {
+	certPath := listener.Properties["tls-cert-path"]
+	keyPath := listener.Properties["tls-key-path"]
+	if certPath == "" && keyPath == "" {
+		return nil, nil
+	}
+	if certPath == "" || keyPath == "" {
+		return nil, fmt.Errorf("%s listener requires both tls-cert-path and tls-key-path to enable TLS", name)
+	}
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load %s server certificate: %v", name, err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if caPath := listener.Properties["tls-client-ca-path"]; caPath != "" {
+		data, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s client CA: %v", name, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("invalid %s client CA %s: no certificates found", name, caPath)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		log.Infof("Enabling mutual TLS for %s", name)
+	} else {
+		log.Infof("Enabling TLS for %s", name)
+	}
+	return cfg, nil
+}
+
+// tcpServer tracks the TCP listener and the active connections of a TCP receiver
+type tcpServer struct {
+	listener    net.Listener
+	connections map[net.Conn]bool
+	mutex       *sync.Mutex
+	stopping    bool
+}
+
+func newTCPServer(listener net.Listener) *tcpServer {
+	return &tcpServer{
+		listener:    listener,
+		connections: make(map[net.Conn]bool),
+		mutex:       new(sync.Mutex),
+	}
+}
+
+// Tracks a new connection; returns false when the server is stopping or the maximum number of connections was reached
+func (server *tcpServer) add(conn net.Conn, maxConnections int) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.stopping || len(server.connections) >= maxConnections {
+		return false
+	}
+	server.connections[conn] = true
+	return true
+}
+
+func (server *tcpServer) remove(conn net.Conn) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	delete(server.connections, conn)
+}
+
+// Closes the listener and all the active connections
+func (server *tcpServer) stop() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.stopping = true
+	server.listener.Close()
+	for conn := range server.connections {
+		conn.Close()
+	}
+}
@@ -2,26 +2,21 @@ package sink
 
 import (
 	"encoding/xml"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/agalue/gominion/api"
 	"github.com/agalue/gominion/protobuf/ipc"
-	"github.com/agalue/gominion/protobuf/telemetry"
-
-	"google.golang.org/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"gotest.tools/v3/assert"
 )
 
-type MockSink struct {
-	messages []*ipc.SinkMessage
-}
-
-func (sink *MockSink) Send(msg *ipc.SinkMessage) error {
-	sink.messages = append(sink.messages, msg)
-	return nil
-}
-
 type Person struct {
 	XMLName   xml.Name `xml:"person"`
 	FirstName string   `xml:"first-name"`
@@ -29,14 +24,15 @@ type Person struct {
 }
 
 func TestSendXMLResponse(t *testing.T) {
-	sink := new(MockSink)
+	sink := new(api.MockBroker)
 	config := &api.MinionConfig{ID: "minion1", Location: "Test"}
 	object := Person{FirstName: "Alejandro", LastName: "Galue"}
 
 	sendXMLResponse("Test", config, sink, object)
 
-	assert.Equal(t, 1, len(sink.messages))
-	msg := sink.messages[0]
+	messages := sink.GetMessages()
+	assert.Equal(t, 1, len(messages))
+	msg := messages[0]
 	assert.Equal(t, config.ID, msg.SystemId)
 
 	received := &Person{}
@@ -45,28 +41,260 @@ func TestSendXMLResponse(t *testing.T) {
 	assert.Equal(t, object.FirstName, received.FirstName)
 }
 
-func TestWrapMessageToTelemetry(t *testing.T) {
-	port := uint32(50000)
-	ipaddr := "10.0.0.1"
+type failingSink struct{}
+
+func (sink *failingSink) Send(msg *ipc.SinkMessage) error {
+	return fmt.Errorf("broker unavailable")
+}
+
+func TestTelemetryMetrics(t *testing.T) {
+	metrics := api.NewMetrics()
+	SetMetrics(metrics)
+	defer SetMetrics(nil)
 	config := &api.MinionConfig{ID: "minion1", Location: "Test"}
-	object := Person{FirstName: "Alejandro", LastName: "Galue"}
-	data, err := xml.Marshal(object)
+
+	countReceived(config, "Graphite", UDPForwardParser, 100)
+	countReceived(config, "Graphite", UDPForwardParser, 50)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.TelemetryPacketsReceived.WithLabelValues("minion1", "Graphite", UDPForwardParser)))
+	assert.Equal(t, 150.0, testutil.ToFloat64(metrics.TelemetryBytesReceived.WithLabelValues("minion1", "Graphite", UDPForwardParser)))
+
+	assert.NilError(t, sendEncoded("Graphite", "Graphite", UDPForwardParser, config, new(api.MockBroker), "10.0.0.1", 2003, [][]byte{[]byte("a")}))
+	assert.Assert(t, sendEncoded("Graphite", "Graphite", UDPForwardParser, config, &failingSink{}, "10.0.0.1", 2003, [][]byte{[]byte("a")}) != nil)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TelemetryPacketsDropped.WithLabelValues("minion1", "Graphite", UDPForwardParser)))
+}
+
+func TestGetListenerWorkers(t *testing.T) {
+	config := &api.MinionConfig{}
+	assert.Equal(t, 1, getListenerWorkers(config, &api.MinionListener{}))
+	assert.Equal(t, 4, getListenerWorkers(config, &api.MinionListener{Properties: map[string]string{"workers": "4"}}))
+	assert.Equal(t, 1, getListenerWorkers(config, &api.MinionListener{Properties: map[string]string{"workers": "x"}}))
+	assert.Equal(t, defaultNxosWorkers, getListenerWorkers(config, &api.MinionListener{Parser: NxosGrpcParser}))
+
+	netflow := &api.MinionListener{Parser: UDPNetflow9Parser}
+	assert.Equal(t, 1, getListenerWorkers(config, netflow))
+	assert.Equal(t, runtime.NumCPU(), getListenerDecoders(config, netflow))
+	netflow.Properties = map[string]string{"workers": "4"}
+	assert.Equal(t, 4, getListenerWorkers(config, netflow))
+	assert.Equal(t, 4, getListenerDecoders(config, netflow))
+	ipfix := &api.MinionListener{Parser: TCPIpfixParser}
+	assert.Equal(t, 0, getListenerWorkers(config, ipfix))
+	assert.Equal(t, runtime.NumCPU(), getListenerDecoders(config, ipfix))
+}
+
+func TestAllocateListenerWorkers(t *testing.T) {
+	config := &api.MinionConfig{
+		Listeners: []api.MinionListener{
+			{Name: "Graphite", Port: 2003, Parser: UDPForwardParser, Properties: map[string]string{"workers": "2"}},
+			{Name: "NXOS", Port: 50001, Parser: NxosGrpcParser, Properties: map[string]string{"workers": "12"}},
+			{Name: "SFlow", Port: 6343, Parser: UDPSFlowParser},
+		},
+	}
+	assert.Assert(t, allocateListenerWorkers(config) == nil)
+
+	config.MaxListenerWorkers = 15
+	assert.Assert(t, allocateListenerWorkers(config) == nil)
+
+	config.MaxListenerWorkers = 6
+	assert.Equal(t, 3, len(allocateListenerWorkers(config)))
+	assert.Equal(t, 1, getListenerWorkers(config, &config.Listeners[0]))
+	assert.Equal(t, 4, getListenerWorkers(config, &config.Listeners[1]))
+	assert.Equal(t, 1, getListenerWorkers(config, &config.Listeners[2]))
+
+	var lines []string
+	logf := func(format string, params ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, params...))
+	}
+	DisplayListenerWorkers(config, logf)
+	assert.DeepEqual(t, []string{
+		"Listener Graphite limited to 1 of 2 requested workers",
+		"Listener NXOS limited to 4 of 12 requested workers",
+		"Listener SFlow uses 1 workers",
+		"Listeners use 6 workers in total (max 6 across all listeners)",
+	}, lines)
+
+	// The receivers and the decoders of a Netflow listener share the budget of the Minion
+	config.Listeners = append(config.Listeners, api.MinionListener{Name: "Netflow-9", Port: 4729, Parser: UDPNetflow9Parser, Properties: map[string]string{"workers": "4"}})
+	config.MaxListenerWorkers = 12
+	assert.Equal(t, 2, getListenerWorkers(config, &config.Listeners[0]))
+	assert.Equal(t, 5, getListenerWorkers(config, &config.Listeners[1]))
+	assert.Equal(t, 1, getListenerWorkers(config, &config.Listeners[2]))
+	assert.Equal(t, 2, getListenerWorkers(config, &config.Listeners[3]))
+	assert.Equal(t, 2, getListenerDecoders(config, &config.Listeners[3]))
+
+	// Each listener keeps one worker (and one decoder), even when that exceeds the limit
+	config.MaxListenerWorkers = 3
+	lines = nil
+	DisplayListenerWorkers(config, logf)
+	assert.DeepEqual(t, []string{
+		"Listener Graphite limited to 1 of 2 requested workers",
+		"Listener NXOS limited to 1 of 12 requested workers",
+		"Listener SFlow uses 1 workers",
+		"Listener Netflow-9 limited to 2 of 8 requested workers (1 receivers and 1 decoders)",
+		"Listeners use 5 workers in total, exceeding the max of 3, as each listener requires at least one worker (and one decoder for flows)",
+	}, lines)
+
+	// Listeners requesting one worker each, above the limit
+	config = &api.MinionConfig{
+		MaxListenerWorkers: 1,
+		Listeners: []api.MinionListener{
+			{Name: "Graphite", Port: 2003, Parser: UDPForwardParser},
+			{Name: "Collectd", Port: 25826, Parser: UDPForwardParser},
+		},
+	}
+	assert.Equal(t, 2, len(allocateListenerWorkers(config)))
+	assert.Equal(t, 1, getListenerWorkers(config, &config.Listeners[0]))
+	assert.Equal(t, 1, getListenerWorkers(config, &config.Listeners[1]))
+	lines = nil
+	DisplayListenerWorkers(config, logf)
+	assert.Equal(t, "Listeners use 2 workers in total, exceeding the max of 1, as each listener requires at least one worker (and one decoder for flows)", lines[2])
+}
+
+func TestGetListenerReadBuffer(t *testing.T) {
+	assert.Equal(t, 0, getListenerReadBuffer(&api.MinionListener{}))
+	assert.Equal(t, 8388608, getListenerReadBuffer(&api.MinionListener{Properties: map[string]string{"so-rcvbuf": "8388608"}}))
+	assert.Equal(t, 0, getListenerReadBuffer(&api.MinionListener{Properties: map[string]string{"so-rcvbuf": "8MB"}}))
+}
+
+func TestUDPServerReadBuffer(t *testing.T) {
+	server, err := newUDPServer(&api.MinionConfig{ID: "minion1"}, "Test", "127.0.0.1", "", 35997, 1, 65536)
 	assert.NilError(t, err)
+	defer server.stop()
+	if granted, ok := getReadBuffer(server.conns[0]); ok {
+		assert.Assert(t, granted > 0) // The kernel might clamp or double the requested size
+	}
+}
 
-	messages := make([][]byte, 1)
-	messages[0] = data
-	bytes := wrapMessageToTelemetry(config, ipaddr, port, messages)
+func TestUDPServerWorkers(t *testing.T) {
+	server, err := newUDPServer(&api.MinionConfig{ID: "minion1"}, "Test", "", "", 35999, 4, 0)
+	assert.NilError(t, err)
+	var received int32
+	server.serve(1024, func(addr *net.UDPAddr, data []byte) {
+		atomic.AddInt32(&received, 1)
+	})
 
-	telemetry := &telemetry.TelemetryMessageLog{}
-	err = proto.Unmarshal(bytes, telemetry)
+	conn, err := net.Dial("udp", "127.0.0.1:35999")
 	assert.NilError(t, err)
-	assert.Equal(t, port, telemetry.GetSourcePort())
-	assert.Equal(t, ipaddr, telemetry.GetSourceAddress())
-	assert.Equal(t, 1, len(telemetry.Message))
+	defer conn.Close()
+	for i := 0; i < 20; i++ {
+		_, err = conn.Write([]byte("test"))
+		assert.NilError(t, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(20), atomic.LoadInt32(&received))
 
-	msg := telemetry.Message[0]
-	received := &Person{}
-	err = xml.Unmarshal(msg.Bytes, received)
+	server.stop() // returns only when all the workers are done
+	server, err = newUDPServer(&api.MinionConfig{ID: "minion1"}, "Test", "", "", 35999, 1, 0)
 	assert.NilError(t, err)
-	assert.Equal(t, object.FirstName, received.FirstName)
+	server.stop()
+}
+
+func TestUDPServerPanic(t *testing.T) {
+	server, err := newUDPServer(&api.MinionConfig{ID: "minion1"}, "Panic", "127.0.0.1", "", 35996, 1, 0)
+	assert.NilError(t, err)
+	defer server.stop()
+	var received int32
+	server.serve(1024, func(addr *net.UDPAddr, data []byte) {
+		if string(data) == "bad" {
+			panic("cannot handle message")
+		}
+		atomic.AddInt32(&received, 1)
+	})
+
+	conn, err := net.Dial("udp", "127.0.0.1:35996")
+	assert.NilError(t, err)
+	defer conn.Close()
+	for _, msg := range []string{"good", "bad", "good"} {
+		_, err = conn.Write([]byte(msg))
+		assert.NilError(t, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&received)) // The worker keeps receiving after the panic
+	assert.Equal(t, 1.0, testutil.ToFloat64(sinkModulePanicked.WithLabelValues("minion1", "Panic")))
+}
+
+func TestUDPServerClosedSocket(t *testing.T) {
+	defer api.ClearModuleFailure("Closed")
+	server, err := newUDPServer(&api.MinionConfig{ID: "minion1"}, "Closed", "127.0.0.1", "", 35994, 1, 0)
+	assert.NilError(t, err)
+	server.serve(512, func(addr *net.UDPAddr, data []byte) {})
+	server.conns[0].Close() // Not stopping, so the worker must report the failure and exit
+	server.wg.Wait()
+	reason, ok := api.GetHealth().FailedModules["Closed"]
+	assert.Assert(t, ok)
+	assert.Assert(t, strings.Contains(reason, "use of closed network connection"), reason)
+	server.stop()
+}
+
+func TestCreateUDPListener(t *testing.T) {
+	conn, err := createUDPListener("127.0.0.1", "", 35998)
+	assert.NilError(t, err)
+	assert.Equal(t, "127.0.0.1:35998", conn.LocalAddr().String())
+	conn.Close()
+
+	_, err = createUDPListener("not-an-ip", "", 35998)
+	assert.ErrorContains(t, err, "invalid bind address")
+
+	_, err = newUDPServer(&api.MinionConfig{ID: "minion1"}, "Test", "not-an-ip", "", 35998, 2, 0)
+	assert.ErrorContains(t, err, "invalid bind address")
+}
+
+func TestGetUDPAddress(t *testing.T) {
+	tests := []struct {
+		bindAddress string
+		ipVersion   string
+		network     string
+		address     string
+		err         string
+	}{
+		{"", "", "udp4", ":2055", ""},
+		{"", "ipv4", "udp4", ":2055", ""},
+		{"", "ipv6", "udp6", ":2055", ""},
+		{"", "dual", "udp", ":2055", ""},
+		{"::", "dual", "udp", "[::]:2055", ""},
+		{"10.0.0.1", "", "udp4", "10.0.0.1:2055", ""},
+		{"fd00::1", "", "udp6", "[fd00::1]:2055", ""},
+		{"fd00::1", "ipv6", "udp6", "[fd00::1]:2055", ""},
+		{"fd00::1", "ipv4", "", "", "doesn't match ip-version ipv4"},
+		{"10.0.0.1", "dual", "", "", "requires an empty or :: bind address"},
+		{"", "ipv5", "", "", "invalid ip-version ipv5"},
+	}
+	for _, test := range tests {
+		network, address, err := getUDPAddress(test.bindAddress, test.ipVersion, 2055)
+		if test.err != "" {
+			assert.ErrorContains(t, err, test.err)
+			continue
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, test.network, network)
+		assert.Equal(t, test.address, address)
+	}
+	assert.Equal(t, "dual", getListenerIPVersion(&api.MinionListener{Properties: map[string]string{"ip-version": "Dual"}}))
+	assert.Equal(t, "", getListenerIPVersion(nil))
+}
+
+func TestUDPServerDualStack(t *testing.T) {
+	server, err := newUDPServer(&api.MinionConfig{ID: "minion1"}, "Test", "", "dual", 35995, 1, 0)
+	if err != nil {
+		t.Skipf("IPv6 is not available: %v", err)
+	}
+	defer server.stop()
+	var received int32
+	server.serve(1024, func(addr *net.UDPAddr, data []byte) {
+		atomic.AddInt32(&received, 1)
+	})
+
+	for _, addr := range []string{"127.0.0.1:35995", "[::1]:35995"} {
+		conn, err := net.Dial("udp", addr)
+		assert.NilError(t, err)
+		_, err = conn.Write([]byte("test"))
+		assert.NilError(t, err)
+		conn.Close()
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&received))
+}
+
+func TestSupportedParsers(t *testing.T) {
+	parsers := CreateSinkRegistry().GetSupportedParsers()
+	assert.DeepEqual(t, []string{UDPForwardParser, TCPIpfixParser, UDPIpfixParser, UDPNetflow5Parser, UDPNetflow9Parser, NxosGrpcParser, UDPSFlowParser}, parsers)
 }
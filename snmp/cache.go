@@ -0,0 +1,218 @@
+package snmp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SNMP session cache statistics
+var (
+	sessionCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "onms_snmp_session_cache_hits",
+		Help: "The total number of SNMP requests that reused a cached session",
+	})
+	sessionCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "onms_snmp_session_cache_misses",
+		Help: "The total number of SNMP requests that required a new session",
+	})
+	sessionCacheEvicted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "onms_snmp_session_cache_evicted",
+		Help: "The total number of SNMP sessions closed after being idle",
+	})
+	sessionsCached = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "onms_snmp_sessions_cached",
+		Help: "The number of idle SNMP sessions available for reuse",
+	})
+)
+
+// The cache used by Acquire and Release; disabled until configured
+var (
+	defaultCache      = NewSessionCache(0)
+	defaultCacheMutex = sync.RWMutex{}
+)
+
+// session represents an SNMP session owned by the cache
+type session struct {
+	api.SNMPHandler
+	key      string
+	lastUsed time.Time
+}
+
+// SessionCache keeps the idle SNMP sessions, so they can be reused by requests against the same agent with the same settings.
+// A session is used by one request at a time; sessions idle for longer than the idle timeout are closed.
+type SessionCache struct {
+	idle      time.Duration
+	sessions  map[string][]*session
+	mutex     sync.Mutex
+	stop      chan struct{}
+	newClient func(agent *api.SNMPAgentDTO) api.SNMPHandler
+}
+
+// NewSessionCache creates a new session cache; caching is disabled when the idle timeout is zero
+func NewSessionCache(idle time.Duration) *SessionCache {
+	cache := &SessionCache{
+		idle:     idle,
+		sessions: make(map[string][]*session),
+		newClient: func(agent *api.SNMPAgentDTO) api.SNMPHandler {
+			return agent.GetSNMPClient()
+		},
+	}
+	if idle > 0 {
+		cache.stop = make(chan struct{})
+		go cache.evictLoop(cache.stop)
+	}
+	return cache
+}
+
+// Acquire returns a connected session for the agent, reusing an idle one when available
+func (cache *SessionCache) Acquire(agent *api.SNMPAgentDTO) (api.SNMPHandler, error) {
+	key := sessionKey(agent)
+	if cache.idle > 0 {
+		cache.mutex.Lock()
+		if idle := cache.sessions[key]; len(idle) > 0 {
+			s := idle[len(idle)-1]
+			cache.sessions[key] = idle[:len(idle)-1]
+			cache.mutex.Unlock()
+			sessionsCached.Dec()
+			sessionCacheHits.Inc()
+			return s, nil
+		}
+		cache.mutex.Unlock()
+		sessionCacheMisses.Inc()
+	}
+	client := cache.newClient(agent)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	return &session{SNMPHandler: client, key: key}, nil
+}
+
+// Release returns a session obtained from Acquire to the cache, or closes it when caching is disabled
+func (cache *SessionCache) Release(client api.SNMPHandler) {
+	s, ok := client.(*session)
+	if !ok || cache.idle <= 0 {
+		client.Disconnect()
+		return
+	}
+	s.lastUsed = time.Now()
+	cache.mutex.Lock()
+	cache.sessions[s.key] = append(cache.sessions[s.key], s)
+	cache.mutex.Unlock()
+	sessionsCached.Inc()
+}
+
+// Size returns the number of idle sessions
+func (cache *SessionCache) Size() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	size := 0
+	for _, idle := range cache.sessions {
+		size += len(idle)
+	}
+	return size
+}
+
+// Close stops the eviction and closes all the idle sessions
+func (cache *SessionCache) Close() {
+	cache.mutex.Lock()
+	if cache.stop != nil {
+		close(cache.stop)
+		cache.stop = nil
+	}
+	cache.mutex.Unlock()
+	cache.evict(func(s *session) bool { return true })
+}
+
+// Closes the sessions that have been idle for too long, until the cache is closed
+func (cache *SessionCache) evictLoop(stop chan struct{}) {
+	ticker := time.NewTicker(cache.idle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			evicted := cache.evict(func(s *session) bool { return now.Sub(s.lastUsed) > cache.idle })
+			sessionCacheEvicted.Add(float64(evicted))
+		}
+	}
+}
+
+// Removes and closes the idle sessions that match the given criteria; returns the number of sessions closed
+func (cache *SessionCache) evict(expired func(s *session) bool) int {
+	var closed []*session
+	cache.mutex.Lock()
+	for key, idle := range cache.sessions {
+		kept := idle[:0]
+		for _, s := range idle {
+			if expired(s) {
+				closed = append(closed, s)
+			} else {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) == 0 {
+			delete(cache.sessions, key)
+		} else {
+			cache.sessions[key] = kept
+		}
+	}
+	cache.mutex.Unlock()
+	for _, s := range closed {
+		log.Debugf("Closing idle SNMP session against %s", s.Target())
+		s.Disconnect()
+		sessionsCached.Dec()
+	}
+	return len(closed)
+}
+
+// Builds the cache key from the target, port, and all the settings of the session, hashed, so the credentials are not part of the key
+func sessionKey(agent *api.SNMPAgentDTO) string {
+	data := fmt.Sprintf("%s|%d|%d|%d|%d|%d|%s|%s|%d|%s|%s|%s|%s|%s|%s|%s",
+		agent.Address, agent.Port, agent.Version, agent.Timeout, agent.Retries, agent.MaxRepetitions,
+		agent.ReadCommunity, agent.SecurityName, agent.SecurityLevel, agent.AuthProtocol, agent.AuthPassPhrase,
+		agent.PrivProtocol, agent.PrivPassPhrase, agent.ContextName, agent.ContextEngineID, agent.EngineID)
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+// Configure replaces the shared session cache, closing the idle sessions of the previous one; caching is disabled when the idle timeout is zero
+func Configure(idle time.Duration) {
+	defaultCacheMutex.Lock()
+	previous := defaultCache
+	defaultCache = NewSessionCache(idle)
+	defaultCacheMutex.Unlock()
+	previous.Close()
+}
+
+func getDefaultCache() *SessionCache {
+	defaultCacheMutex.RLock()
+	defer defaultCacheMutex.RUnlock()
+	return defaultCache
+}
+
+// Acquire returns a connected session for the agent from the shared cache; it must be returned with Release
+func Acquire(agent *api.SNMPAgentDTO) (api.SNMPHandler, error) {
+	return getDefaultCache().Acquire(agent)
+}
+
+// Release returns a session to the shared cache
+func Release(client api.SNMPHandler) {
+	getDefaultCache().Release(client)
+}
+
+// Close closes all the idle sessions of the shared cache
+func Close() {
+	getDefaultCache().Close()
+}
+
+func init() {
+	prometheus.MustRegister(sessionCacheHits, sessionCacheMisses, sessionCacheEvicted, sessionsCached)
+}
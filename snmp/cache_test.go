@@ -0,0 +1,80 @@
+package snmp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agalue/gominion/api"
+	"github.com/agalue/gominion/tools"
+	"gotest.tools/v3/assert"
+)
+
+func newMockCache(idle time.Duration, created *int) *SessionCache {
+	cache := NewSessionCache(idle)
+	cache.newClient = func(agent *api.SNMPAgentDTO) api.SNMPHandler {
+		*created++
+		return &tools.MockSNMPClient{}
+	}
+	return cache
+}
+
+func TestSessionCache(t *testing.T) {
+	created := 0
+	cache := newMockCache(time.Minute, &created)
+	defer cache.Close()
+	agent := &api.SNMPAgentDTO{Address: "10.0.0.1", Port: 161, Version: 2, ReadCommunity: "public"}
+
+	// Concurrent requests against the same agent use different sessions
+	s1, err := cache.Acquire(agent)
+	assert.NilError(t, err)
+	s2, err := cache.Acquire(agent)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, created)
+	cache.Release(s1)
+	cache.Release(s2)
+	assert.Equal(t, 2, cache.Size())
+
+	// Idle sessions are reused
+	s3, err := cache.Acquire(agent)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, created)
+	cache.Release(s3)
+
+	// Different credentials use a different session
+	other := *agent
+	other.ReadCommunity = "private"
+	s4, err := cache.Acquire(&other)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, created)
+	cache.Release(s4)
+	assert.Equal(t, 3, cache.Size())
+
+	cache.Close()
+	assert.Equal(t, 0, cache.Size())
+}
+
+func TestSessionCacheEviction(t *testing.T) {
+	created := 0
+	cache := newMockCache(100*time.Millisecond, &created)
+	defer cache.Close()
+	agent := &api.SNMPAgentDTO{Address: "10.0.0.1", Port: 161, Version: 2, ReadCommunity: "public"}
+	s, err := cache.Acquire(agent)
+	assert.NilError(t, err)
+	cache.Release(s)
+	assert.Equal(t, 1, cache.Size())
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 0, cache.Size())
+}
+
+func TestSessionCacheDisabled(t *testing.T) {
+	created := 0
+	cache := newMockCache(0, &created)
+	agent := &api.SNMPAgentDTO{Address: "10.0.0.1", Port: 161, Version: 2, ReadCommunity: "public"}
+	for i := 0; i < 2; i++ {
+		s, err := cache.Acquire(agent)
+		assert.NilError(t, err)
+		cache.Release(s)
+	}
+	assert.Equal(t, 2, created)
+	assert.Equal(t, 0, cache.Size())
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SourcePortRangeAttribute is the attribute of a request that overrides the default range of local ports
+const SourcePortRangeAttribute = "source-port-range"
+
+// PortRange represents a range of local ports for outbound connections
+type PortRange struct {
+	Min int
+	Max int
+}
+
+func (r *PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+var (
+	sourcePorts      *PortRange
+	sourcePortsMutex sync.RWMutex
+)
+
+// ParsePortRange parses a port range in min-max format (or a single port); returns nil when the value is empty
+func ParsePortRange(value string) (*PortRange, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+	min, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	max, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || min < 1 || max > 65535 || min > max {
+		return nil, fmt.Errorf("invalid port range %s, expected min-max between 1 and 65535", value)
+	}
+	return &PortRange{Min: min, Max: max}, nil
+}
+
+// SetSourcePortRange sets the range of local ports used by default for outbound connections (nil to let the OS choose)
+func SetSourcePortRange(r *PortRange) {
+	sourcePortsMutex.Lock()
+	defer sourcePortsMutex.Unlock()
+	sourcePorts = r
+}
+
+func getSourcePortRange() *PortRange {
+	sourcePortsMutex.RLock()
+	defer sourcePortsMutex.RUnlock()
+	return sourcePorts
+}
+
+// DialContext connects to the address, binding the connection to a free local port within the given range (or the default one when nil).
+// When there is no range, the OS chooses the local port. Returns an error when all the ports of the range are in use.
+func DialContext(ctx context.Context, network string, address string, timeout time.Duration, ports *PortRange) (net.Conn, error) {
+	return dialContext(ctx, network, address, timeout, ports, nil)
+}
+
+// Connects like DialContext, calling the given control function (when not nil) on the socket before connecting
+func dialContext(ctx context.Context, network string, address string, timeout time.Duration, ports *PortRange, control func(network, address string, c syscall.RawConn) error) (net.Conn, error) {
+	if ports == nil {
+		ports = getSourcePortRange()
+	}
+	if ports == nil {
+		dialer := net.Dialer{Timeout: timeout, Control: control}
+		return dialer.DialContext(ctx, network, address)
+	}
+	size := ports.Max - ports.Min + 1
+	offset := rand.Intn(size) // Avoids reusing the same ports on every connection
+	for i := 0; i < size; i++ {
+		port := ports.Min + (offset+i)%size
+		dialer := net.Dialer{Timeout: timeout, LocalAddr: &net.TCPAddr{Port: port}, Control: control}
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err == nil {
+			return conn, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) && !errors.Is(err, syscall.EADDRNOTAVAIL) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("cannot connect to %s: all the source ports in range %s are in use", address, ports)
+}
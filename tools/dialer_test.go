@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParsePortRange(t *testing.T) {
+	r, err := ParsePortRange("")
+	assert.NilError(t, err)
+	assert.Assert(t, r == nil)
+
+	r, err = ParsePortRange("40000-40999")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &PortRange{Min: 40000, Max: 40999}, r)
+	assert.Equal(t, "40000-40999", r.String())
+
+	r, err = ParsePortRange("5000")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &PortRange{Min: 5000, Max: 5000}, r)
+
+	for _, value := range []string{"abc", "10-a", "0-10", "20-10", "1-70000"} {
+		_, err = ParsePortRange(value)
+		assert.ErrorContains(t, err, "invalid port range")
+	}
+}
+
+func TestDialContextWithSourcePorts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	port := getFreePort(t)
+	ports := &PortRange{Min: port, Max: port}
+	conn, err := DialContext(context.Background(), "tcp", listener.Addr().String(), time.Second, ports)
+	assert.NilError(t, err)
+	assert.Equal(t, port, conn.LocalAddr().(*net.TCPAddr).Port)
+	conn.Close()
+
+	// The default range applies when the request doesn't have one
+	port = getFreePort(t)
+	SetSourcePortRange(&PortRange{Min: port, Max: port})
+	defer SetSourcePortRange(nil)
+	conn, err = DialContext(context.Background(), "tcp", listener.Addr().String(), time.Second, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, port, conn.LocalAddr().(*net.TCPAddr).Port)
+	conn.Close()
+
+	// The only port of the range is taken by the listener
+	busy := &PortRange{Min: listener.Addr().(*net.TCPAddr).Port, Max: listener.Addr().(*net.TCPAddr).Port}
+	_, err = DialContext(context.Background(), "tcp", listener.Addr().String(), time.Second, busy)
+	assert.ErrorContains(t, err, "all the source ports in range")
+}
+
+func getFreePort(t *testing.T) int {
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer free.Close()
+	return free.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ErrUnsupportedRecordType is returned by QueryDNS for record types it cannot query
+var ErrUnsupportedRecordType = errors.New("unsupported record type")
+
+// ErrDNSNameNotFound is returned by QueryDNS when the server answers that the name doesn't exist
+var ErrDNSNameNotFound = errors.New("NXDOMAIN")
+
+var dnsRecordTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// QueryDNS sends a query for the records of the given type (A, AAAA, CNAME, MX, NS or TXT) directly to the DNS server at address (ip:port),
+// over UDP, and over TCP when the answer is truncated. The name is always treated as fully qualified, so neither /etc/hosts nor the search list apply.
+// Returns the values of the records (empty when the name exists without records of that type).
+// Errors that won't change on the next attempt (NXDOMAIN, unsupported record types or invalid names) are wrapped by StopRetries.
+func QueryDNS(ctx context.Context, address string, name string, recordType string, timeout time.Duration) ([]string, error) {
+	qtype, ok := dnsRecordTypes[recordType]
+	if !ok {
+		return nil, StopRetries(fmt.Errorf("%w %s", ErrUnsupportedRecordType, recordType))
+	}
+	fqdn := name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	qname, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, StopRetries(fmt.Errorf("invalid name %s: %v", name, err))
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Intn(1 << 16)), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	data, err := query.Pack()
+	if err != nil {
+		return nil, StopRetries(fmt.Errorf("cannot build query for %s: %v", name, err))
+	}
+	reply, err := exchangeDNS(ctx, "udp", address, data, query.ID, timeout)
+	if err == nil && reply.Truncated {
+		reply, err = exchangeDNS(ctx, "tcp", address, data, query.ID, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch reply.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, StopRetries(fmt.Errorf("%w: %s not found on %s", ErrDNSNameNotFound, fqdn, address))
+	default:
+		return nil, fmt.Errorf("server %s answered %s for %s", address, strings.TrimPrefix(reply.RCode.String(), "RCode"), fqdn)
+	}
+	var values []string
+	for _, answer := range reply.Answers {
+		if answer.Header.Type != qtype {
+			continue // e.g. the CNAME records of the chain leading to an A record
+		}
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			values = append(values, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			values = append(values, net.IP(body.AAAA[:]).String())
+		case *dnsmessage.CNAMEResource:
+			values = append(values, body.CNAME.String())
+		case *dnsmessage.MXResource:
+			values = append(values, body.MX.String())
+		case *dnsmessage.NSResource:
+			values = append(values, body.NS.String())
+		case *dnsmessage.TXTResource:
+			values = append(values, strings.Join(body.TXT, ""))
+		}
+	}
+	return values, nil
+}
+
+// Sends a query over the given network, and waits for the answer with the same ID
+func exchangeDNS(ctx context.Context, network string, address string, query []byte, id uint16, timeout time.Duration) (*dnsmessage.Message, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now()) // Unblocks the read when the context is cancelled
+		case <-stop:
+		}
+	}()
+	if network == "tcp" {
+		query = append([]byte{byte(len(query) >> 8), byte(len(query))}, query...)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	for {
+		var data []byte
+		if network == "tcp" {
+			var size [2]byte
+			if _, err := io.ReadFull(conn, size[:]); err != nil {
+				return nil, err
+			}
+			data = make([]byte, binary.BigEndian.Uint16(size[:]))
+			if _, err := io.ReadFull(conn, data); err != nil {
+				return nil, err
+			}
+		} else {
+			data = make([]byte, 65535)
+			n, err := conn.Read(data)
+			if err != nil {
+				return nil, err
+			}
+			data = data[:n]
+		}
+		reply := &dnsmessage.Message{}
+		if err := reply.Unpack(data); err != nil {
+			if network == "tcp" {
+				return nil, fmt.Errorf("invalid answer from %s: %v", address, err)
+			}
+			continue // Ignores garbage on UDP, waiting for the actual answer
+		}
+		if reply.Response && reply.ID == id {
+			return reply, nil
+		}
+	}
+}
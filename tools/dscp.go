@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// DSCPAttribute is the attribute of a request that sets the DSCP value of the probe traffic
+const DSCPAttribute = "dscp"
+
+// The well known DSCP names
+var dscpNames = map[string]int{
+	"BE": 0, "DF": 0, "EF": 46, "VA": 44,
+	"CS0": 0, "CS1": 8, "CS2": 16, "CS3": 24, "CS4": 32, "CS5": 40, "CS6": 48, "CS7": 56,
+	"AF11": 10, "AF12": 12, "AF13": 14,
+	"AF21": 18, "AF22": 20, "AF23": 22,
+	"AF31": 26, "AF32": 28, "AF33": 30,
+	"AF41": 34, "AF42": 36, "AF43": 38,
+}
+
+// ParseDSCP parses a DSCP value, either as a number between 0 and 63 (decimal or 0x hexadecimal), or as a name like EF, AF41 or CS5; returns 0 when the value is empty
+func ParseDSCP(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if dscp, ok := dscpNames[strings.ToUpper(value)]; ok {
+		return dscp, nil
+	}
+	dscp, err := strconv.ParseInt(value, 0, 64)
+	if err != nil || dscp < 0 || dscp > 63 {
+		return 0, fmt.Errorf("invalid DSCP value %s, expected a number between 0 and 63, or a name like EF, AF41 or CS5", value)
+	}
+	return int(dscp), nil
+}
+
+// SetDSCP marks the traffic sent through the connection with the given DSCP value, via the IPv4 ToS or the IPv6 traffic class.
+// Nothing changes when the value is 0, so the connection keeps the default of the OS.
+func SetDSCP(conn net.Conn, dscp int) error {
+	if dscp == 0 {
+		return nil
+	}
+	var err error
+	if isIPv4(conn.LocalAddr()) {
+		err = ipv4.NewConn(conn).SetTOS(dscp << 2)
+	} else {
+		err = ipv6.NewConn(conn).SetTrafficClass(dscp << 2)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot set DSCP %d on the connection to %s: %v", dscp, conn.RemoteAddr(), err)
+	}
+	return nil
+}
+
+// DialContextWithDSCP connects to the address like DialContext, and marks the connection with the given DSCP value.
+// The value is set on the socket before connecting, so the handshake is marked too.
+func DialContextWithDSCP(ctx context.Context, network string, address string, timeout time.Duration, ports *PortRange, dscp int) (net.Conn, error) {
+	control := dscpControl(dscp)
+	conn, err := dialContext(ctx, network, address, timeout, ports, control)
+	if err != nil {
+		return nil, err
+	}
+	if control == nil { // The socket options can't be set before connecting on this platform
+		if err := SetDSCP(conn, dscp); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func isIPv4(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.IPAddr:
+		ip = a.IP
+	}
+	return ip.To4() != nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package tools
+
+import "syscall"
+
+// The socket options can't be set before connecting on this platform, so the connections are marked once established
+func dscpControl(dscp int) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseDSCP(t *testing.T) {
+	for value, expected := range map[string]int{"": 0, "46": 46, "0x2e": 46, "ef": 46, "AF41": 34, "CS5": 40, "63": 63} {
+		dscp, err := ParseDSCP(value)
+		assert.NilError(t, err)
+		assert.Equal(t, expected, dscp, value)
+	}
+	for _, value := range []string{"64", "-1", "abc", "AF51"} {
+		_, err := ParseDSCP(value)
+		assert.ErrorContains(t, err, "invalid DSCP value")
+	}
+}
+
+func TestDialContextWithDSCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	conn, err := DialContextWithDSCP(context.Background(), "tcp", listener.Addr().String(), time.Second, nil, 46)
+	assert.NilError(t, err)
+	defer conn.Close()
+	tos, err := ipv4.NewConn(conn).TOS()
+	assert.NilError(t, err)
+	assert.Equal(t, 46<<2, tos)
+}
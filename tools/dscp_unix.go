@@ -0,0 +1,36 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Gets the dialer control function that sets the IPv4 ToS or the IPv6 traffic class of the socket; nil when the value is 0
+func dscpControl(dscp int) func(network, address string, c syscall.RawConn) error {
+	if dscp == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var opErr error
+		err := c.Control(func(fd uintptr) {
+			if strings.HasSuffix(network, "6") {
+				opErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, dscp<<2)
+			} else {
+				opErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, dscp<<2)
+			}
+		})
+		if err == nil {
+			err = opErr
+		}
+		if err != nil {
+			return fmt.Errorf("cannot set DSCP %d on the connection to %s: %v", dscp, address, err)
+		}
+		return nil
+	}
+}
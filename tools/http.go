@@ -1,7 +1,9 @@
 package tools
 
 import (
+	"context"
 	"crypto/tls"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -9,9 +11,31 @@ import (
 )
 
 // GetHTTPClient returns an HTTP Client with a given timeout, and transport
-func GetHTTPClient(skipSSL bool, timeout time.Duration) *http.Client {
+// The connections use local ports within the given range (or the default one when nil).
+// Keep-alives are disabled, as the clients are created per request, and their idle connections would hold the local ports until they expire.
+func GetHTTPClient(skipSSL bool, timeout time.Duration, ports *PortRange) *http.Client {
+	return GetHTTPClientWithDSCP(skipSSL, timeout, ports, 0)
+}
+
+// GetHTTPClientWithDSCP returns an HTTP Client like GetHTTPClient, whose connections are marked with the given DSCP value
+func GetHTTPClientWithDSCP(skipSSL bool, timeout time.Duration, ports *PortRange, dscp int) *http.Client {
+	return newHTTPClient(skipSSL, timeout, ports, dscp, false)
+}
+
+// GetPersistentHTTPClient returns an HTTP Client like GetHTTPClient that keeps the connections alive, for clients reused across requests, or connection-based authentication like NTLM.
+// The caller must close the idle connections when the client is no longer needed.
+func GetPersistentHTTPClient(skipSSL bool, timeout time.Duration, ports *PortRange) *http.Client {
+	return newHTTPClient(skipSSL, timeout, ports, 0, true)
+}
+
+func newHTTPClient(skipSSL bool, timeout time.Duration, ports *PortRange, dscp int, keepAlive bool) *http.Client {
 	transport := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipSSL},
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return DialContextWithDSCP(ctx, network, address, timeout, ports, dscp)
+		},
+		DisableKeepAlives: !keepAlive,
+		IdleConnTimeout:   90 * time.Second, // Same as http.DefaultTransport
 	}
 	return &http.Client{
 		Transport: transport,
@@ -26,11 +50,24 @@ func ParseHTTPResponseRange(responseRange string) (min int, max int) {
 	if len(rangeArray) < 2 {
 		return 100, 399
 	}
-	if min, err = strconv.Atoi(rangeArray[0]); err == nil {
+	if min, err = strconv.Atoi(strings.TrimSpace(rangeArray[0])); err != nil {
 		min = 100
 	}
-	if max, err = strconv.Atoi(rangeArray[1]); err == nil {
+	if max, err = strconv.Atoi(strings.TrimSpace(rangeArray[1])); err != nil {
 		max = 399
 	}
 	return min, max
 }
+
+// GetURLHost returns the given address as the host of a URL, bracketing IPv6 addresses
+func GetURLHost(ipaddr string) string {
+	if strings.Contains(ipaddr, ":") {
+		return "[" + ipaddr + "]"
+	}
+	return ipaddr
+}
+
+// ExpandURL replaces the ${ipaddr} placeholder of a URL template with the given address, bracketed when it is an IPv6 address
+func ExpandURL(template string, ipaddr string) string {
+	return strings.ReplaceAll(template, "${ipaddr}", GetURLHost(ipaddr))
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGetHTTPClient(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	get := func(client *http.Client) {
+		for i := 0; i < 3; i++ {
+			res, err := client.Get(server.URL)
+			assert.NilError(t, err)
+			res.Body.Close()
+		}
+	}
+
+	// The connections of the per-request clients are not kept idle
+	get(GetHTTPClient(false, time.Second, nil))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&connections))
+
+	client := GetPersistentHTTPClient(false, time.Second, nil)
+	get(client)
+	client.CloseIdleConnections()
+	assert.Equal(t, int32(4), atomic.LoadInt32(&connections))
+}
+
+func TestExpandURL(t *testing.T) {
+	assert.Equal(t, "http://10.0.0.1:8080/metrics", ExpandURL("http://${ipaddr}:8080/metrics", "10.0.0.1"))
+	assert.Equal(t, "http://[fe80::1]:8080/metrics", ExpandURL("http://${ipaddr}:8080/metrics", "fe80::1"))
+	assert.Equal(t, "http://server/metrics", ExpandURL("http://server/metrics", "fe80::1"))
+	assert.Equal(t, "[fe80::1]", GetURLHost("fe80::1"))
+	assert.Equal(t, "server", GetURLHost("server"))
+}
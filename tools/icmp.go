@@ -1,23 +1,167 @@
 package tools
 
 import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/agalue/gominion/log"
 	"github.com/go-ping/ping"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
-// TODO add logic for handling retries
+// DefaultPingInterval the default wait time between ICMP echo requests
+const DefaultPingInterval = 500 * time.Millisecond
+
+// Tracks whether raw ICMP sockets were denied, to avoid trying them on every ping
+var unprivilegedICMP int32
+
+// PingOptions represents the settings of an ICMP ping
+type PingOptions struct {
+	Count      int           // Number of echo requests to send (defaults to 1)
+	PacketSize int           // Payload size in bytes (go-ping's minimum when smaller)
+	Timeout    time.Duration // Time to wait for each echo reply
+	DSCP       int           // DSCP value of the echo requests (0 keeps the default of the OS)
+}
+
+// Ping sends a single ICMP echo request to the given address and returns the round trip time
 func Ping(addr string, timeout time.Duration) (time.Duration, error) {
-	pinger, err := ping.NewPinger(addr)
+	stats, err := PingWithOptions(addr, PingOptions{Count: 1, Timeout: timeout})
 	if err != nil {
 		return 0, err
 	}
-	pinger.Count = 1
-	pinger.Timeout = timeout
-	pinger.SetPrivileged(true)
+	if stats.PacketsRecv == 0 {
+		return 0, fmt.Errorf("no response received from %s after %s", addr, timeout)
+	}
+	return stats.AvgRtt, nil
+}
+
+// PingWithOptions sends ICMP echo requests to the given address and returns the statistics.
+// Raw (privileged) sockets are preferred; when they are not permitted, the request is sent through unprivileged (UDP) ICMP sockets.
+func PingWithOptions(addr string, options PingOptions) (*ping.Statistics, error) {
+	privileged := atomic.LoadInt32(&unprivilegedICMP) == 0
+	stats, err := runPinger(addr, options, privileged)
+	if err != nil && privileged && errors.Is(err, os.ErrPermission) {
+		log.Infof("Raw ICMP sockets are not permitted (%v), falling back to unprivileged ICMP", err)
+		atomic.StoreInt32(&unprivilegedICMP, 1)
+		stats, err = runPinger(addr, options, false)
+	}
+	return stats, err
+}
+
+func runPinger(addr string, options PingOptions, privileged bool) (*ping.Statistics, error) {
+	pinger, err := ping.NewPinger(addr)
+	if err != nil {
+		return nil, err
+	}
+	count := options.Count
+	if count < 1 {
+		count = 1
+	}
+	if options.PacketSize > pinger.Size {
+		pinger.Size = options.PacketSize
+	}
+	if options.DSCP != 0 {
+		return runMarkedPing(pinger.IPAddr(), options, count, pinger.Size, privileged)
+	}
+	pinger.Count = count
+	pinger.Interval = DefaultPingInterval
+	pinger.Timeout = options.Timeout + time.Duration(count-1)*pinger.Interval
+	pinger.SetPrivileged(privileged)
+	mode := "privileged"
+	if !privileged {
+		mode = "unprivileged"
+	}
+	log.Debugf("Sending %d ICMP echo requests of %d bytes to %s using %s mode", count, pinger.Size, addr, mode)
 	if err := pinger.Run(); err != nil {
-		return 0, err
+		return nil, err
+	}
+	return pinger.Statistics(), nil
+}
+
+// Sends the echo requests through an ICMP socket marked with the DSCP value of the options, as go-ping doesn't expose its socket.
+// Returns the statistics like go-ping does.
+func runMarkedPing(ipaddr *net.IPAddr, options PingOptions, count int, size int, privileged bool) (*ping.Statistics, error) {
+	network, address, protocol := "ip4:icmp", "0.0.0.0", 1
+	var request, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if ipaddr.IP.To4() == nil {
+		network, address, protocol = "ip6:ipv6-icmp", "::", 58
+		request, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	var dst net.Addr = ipaddr
+	if !privileged {
+		network = "udp4"
+		if protocol == 58 {
+			network = "udp6"
+		}
+		dst = &net.UDPAddr{IP: ipaddr.IP, Zone: ipaddr.Zone}
+	}
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if protocol == 1 {
+		err = conn.IPv4PacketConn().SetTOS(options.DSCP << 2)
+	} else {
+		err = conn.IPv6PacketConn().SetTrafficClass(options.DSCP << 2)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot set DSCP %d on the ICMP socket: %v", options.DSCP, err)
+	}
+	log.Debugf("Sending %d ICMP echo requests of %d bytes to %s marked with DSCP %d", count, size, ipaddr, options.DSCP)
+	stats := &ping.Statistics{IPAddr: ipaddr, Addr: ipaddr.String()}
+	id := rand.Intn(0xffff) // Replaced by the local port on unprivileged sockets
+	buffer := make([]byte, 1500)
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			time.Sleep(DefaultPingInterval)
+		}
+		data, err := (&icmp.Message{Type: request, Body: &icmp.Echo{ID: id, Seq: seq, Data: make([]byte, size)}}).Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		if _, err := conn.WriteTo(data, dst); err != nil {
+			return nil, err
+		}
+		stats.PacketsSent++
+		conn.SetReadDeadline(start.Add(options.Timeout))
+		for {
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				break // Timed out waiting for the reply
+			}
+			msg, err := icmp.ParseMessage(protocol, buffer[:n])
+			if err != nil || msg.Type != reply {
+				continue
+			}
+			if echo, ok := msg.Body.(*icmp.Echo); ok && echo.Seq == seq && (!privileged || echo.ID == id) {
+				stats.PacketsRecv++
+				stats.Rtts = append(stats.Rtts, time.Since(start))
+				break
+			}
+		}
+	}
+	stats.PacketLoss = float64(stats.PacketsSent-stats.PacketsRecv) / float64(stats.PacketsSent) * 100
+	var total time.Duration
+	for i, rtt := range stats.Rtts {
+		if i == 0 || rtt < stats.MinRtt {
+			stats.MinRtt = rtt
+		}
+		if rtt > stats.MaxRtt {
+			stats.MaxRtt = rtt
+		}
+		total += rtt
+	}
+	if len(stats.Rtts) > 0 {
+		stats.AvgRtt = total / time.Duration(len(stats.Rtts))
 	}
-	stats := pinger.Statistics()
-	return stats.AvgRtt, err
+	return stats, nil
 }
@@ -15,3 +15,12 @@ func TestPing(t *testing.T) {
 	assert.Assert(t, duration.Microseconds() > 0)
 
 }
+
+func TestPingWithDSCP(t *testing.T) {
+	stats, err := PingWithOptions("127.0.0.1", PingOptions{Count: 2, Timeout: time.Second, DSCP: 46})
+	assert.NilError(t, err)
+	assert.Equal(t, 2, stats.PacketsSent)
+	assert.Equal(t, 2, stats.PacketsRecv)
+	assert.Equal(t, 0.0, stats.PacketLoss)
+	assert.Assert(t, stats.AvgRtt > 0)
+}
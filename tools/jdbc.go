@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql" // Register the MySQL driver
+	_ "github.com/lib/pq"              // Register the PostgreSQL driver
+)
+
+// GetDataSource gets the Go driver name and DSN for a JDBC data source.
+// The driver can be the Java class name (e.g. org.postgresql.Driver), and the url can be a JDBC URL (e.g. jdbc:postgresql://${ipaddr}:5432/opennms).
+// The ${ipaddr} placeholder is replaced with the given address.
+func GetDataSource(driver string, rawURL string, ipaddr string, user string, password string) (string, string, error) {
+	rawURL = strings.TrimPrefix(rawURL, "jdbc:")
+	if rawURL == "" {
+		return "", "", fmt.Errorf("missing url attribute")
+	}
+	if ipaddr != "" {
+		rawURL = ExpandURL(rawURL, ipaddr)
+	}
+	lower := strings.ToLower(driver + " " + rawURL)
+	switch {
+	case strings.Contains(lower, "postgres"):
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid url: %v", err)
+		}
+		u.Scheme = "postgres"
+		if user != "" {
+			u.User = url.UserPassword(user, password)
+		}
+		return "postgres", u.String(), nil
+	case strings.Contains(lower, "mysql"), strings.Contains(lower, "mariadb"):
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid url: %v", err)
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "3306")
+		}
+		dsn := fmt.Sprintf("tcp(%s)%s", host, u.Path)
+		if u.RawQuery != "" {
+			dsn += "?" + u.RawQuery
+		}
+		if user != "" {
+			dsn = user + ":" + password + "@" + dsn
+		}
+		return "mysql", dsn, nil
+	case driver != "":
+		return driver, rawURL, nil
+	}
+	return "", "", fmt.Errorf("cannot find a driver for %s", rawURL)
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// permanentError represents an error that won't change on the next attempt
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// StopRetries wraps an error to tell WithRetries not to try again (e.g. when the record doesn't exist, or the request is invalid)
+func StopRetries(err error) error {
+	return &permanentError{err}
+}
+
+// WithRetries executes an attempt up to retries+1 times, until it succeeds, fails with an error wrapped by StopRetries, or the context is done.
+// Each attempt runs with its own timeout, and waits for the given interval after a failure.
+// Returns the response time of the successful attempt, or the error of the last one (unwrapped when it was permanent).
+func WithRetries(ctx context.Context, retries int, timeout time.Duration, interval time.Duration, attempt func(ctx context.Context) (time.Duration, error)) (time.Duration, error) {
+	if retries < 0 {
+		retries = 0
+	}
+	var err error
+	for i := 0; i <= retries; i++ {
+		var duration time.Duration
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		duration, err = attempt(attemptCtx)
+		cancel()
+		if err == nil {
+			return duration, nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return 0, permanent.err
+		}
+		if ctx.Err() != nil {
+			return 0, err
+		}
+		if interval > 0 && i < retries {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return 0, err
+			}
+		}
+	}
+	return 0, err
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithRetries(t *testing.T) {
+	attempts := 0
+	duration, err := WithRetries(context.Background(), 2, time.Second, 0, func(ctx context.Context) (time.Duration, error) {
+		attempts++
+		if attempts < 3 {
+			return 0, fmt.Errorf("attempt %d failed", attempts)
+		}
+		return time.Millisecond, nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, time.Millisecond, duration)
+
+	attempts = 0
+	_, err = WithRetries(context.Background(), 2, time.Second, 0, func(ctx context.Context) (time.Duration, error) {
+		attempts++
+		return 0, fmt.Errorf("attempt %d failed", attempts)
+	})
+	assert.Error(t, err, "attempt 3 failed")
+}
+
+func TestWithRetriesStop(t *testing.T) {
+	attempts := 0
+	_, err := WithRetries(context.Background(), 5, time.Second, 0, func(ctx context.Context) (time.Duration, error) {
+		attempts++
+		return 0, StopRetries(fmt.Errorf("not found"))
+	})
+	assert.Error(t, err, "not found")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestWithRetriesInterval(t *testing.T) {
+	start := time.Now()
+	_, err := WithRetries(context.Background(), 2, time.Second, 20*time.Millisecond, func(ctx context.Context) (time.Duration, error) {
+		return 0, fmt.Errorf("failed")
+	})
+	assert.ErrorContains(t, err, "failed")
+	assert.Assert(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+func TestWithRetriesTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	attempts := 0
+	_, err := WithRetries(ctx, 10, 20*time.Millisecond, time.Second, func(ctx context.Context) (time.Duration, error) {
+		attempts++
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	assert.Error(t, err, context.DeadlineExceeded.Error())
+	assert.Equal(t, 1, attempts)
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func TestGetResultForPDU(t *testing.T) {
-	log.InitLogger("debug")
+	log.InitLogger("debug", "console", nil)
 	gosnmp.Default.Target = "127.0.0.1"
 	err := gosnmp.Default.Connect()
 	assert.NilError(t, err)
@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// The maximum number of lines a server can send before its SSH identification string
+const maxSSHBannerLines = 20
+
+// ReadSSHBanner reads the identification string of an SSH server (e.g. SSH-2.0-OpenSSH_8.4), skipping the lines sent before it as RFC 4253 allows.
+// Returns an error when the server doesn't speak SSH 2.0.
+func ReadSSHBanner(conn net.Conn, timeout time.Duration) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	defer conn.SetReadDeadline(time.Time{})
+	reader := bufio.NewReaderSize(conn, 256)
+	for i := 0; i < maxSSHBannerLines; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(line, "SSH-") {
+			continue
+		}
+		if !strings.HasPrefix(line, "SSH-2.0-") && !strings.HasPrefix(line, "SSH-1.99-") {
+			return "", fmt.Errorf("unsupported SSH version on banner %s", line)
+		}
+		return line, nil
+	}
+	return "", fmt.Errorf("SSH identification string not found")
+}
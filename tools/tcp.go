@@ -8,13 +8,24 @@ import (
 	"time"
 )
 
+// DefaultBannerSize the default maximum number of bytes to read when verifying a banner
+const DefaultBannerSize = 1024
+
 // NetMessageContains reads message from network connection and verify if contains banner
 func NetMessageContains(conn net.Conn, timeout time.Duration, banner string) (bool, error) {
+	return NetMessageContainsN(conn, timeout, banner, DefaultBannerSize)
+}
+
+// NetMessageContainsN reads up to maxBytes from network connection and verify if contains banner
+func NetMessageContainsN(conn net.Conn, timeout time.Duration, banner string, maxBytes int) (bool, error) {
 	if banner == "" || banner == "*" {
 		return true, nil
 	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultBannerSize
+	}
 	conn.SetReadDeadline(time.Now().Add(timeout))
-	payload := make([]byte, 1024)
+	payload := make([]byte, maxBytes)
 	size, err := conn.Read(payload)
 	if err != nil {
 		return false, err
@@ -24,7 +35,14 @@ func NetMessageContains(conn net.Conn, timeout time.Duration, banner string) (bo
 	}
 	payloadCut := make([]byte, size)
 	copy(payloadCut, payload[0:size])
-	received := string(payloadCut)
+	return MatchBanner(string(payloadCut), banner)
+}
+
+// MatchBanner verifies if the received message contains the banner (or matches it, when the banner starts with ~)
+func MatchBanner(received string, banner string) (bool, error) {
+	if banner == "" || banner == "*" {
+		return true, nil
+	}
 	if strings.HasPrefix(banner, "~") {
 		result := string([]rune(banner)[1:])
 		exp, err := regexp.Compile(result)